@@ -0,0 +1,429 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: booklibrary/v1/booklibrary.proto
+
+package booklibraryv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Book is a book in the library.
+type Book struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Author        string                 `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	ReleaseDate   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=release_date,json=releaseDate,proto3" json:"release_date,omitempty"`
+	Keywords      []string               `protobuf:"bytes,5,rep,name=keywords,proto3" json:"keywords,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Book) Reset() {
+	*x = Book{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Book) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Book) ProtoMessage() {}
+
+func (x *Book) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Book.ProtoReflect.Descriptor instead.
+func (*Book) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Book) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Book) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Book) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Book) GetReleaseDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReleaseDate
+	}
+	return nil
+}
+
+func (x *Book) GetKeywords() []string {
+	if x != nil {
+		return x.Keywords
+	}
+	return nil
+}
+
+type ListRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Maximum number of books to return. Values less than 1 select 100.
+	Limit         int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type AddRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The book to add. Its ID is ignored.
+	Book          *Book `protobuf:"bytes,1,opt,name=book,proto3" json:"book,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddRequest) Reset() {
+	*x = AddRequest{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRequest) ProtoMessage() {}
+
+func (x *AddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRequest.ProtoReflect.Descriptor instead.
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddRequest) GetBook() *Book {
+	if x != nil {
+		return x.Book
+	}
+	return nil
+}
+
+type UpdateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The new state of the book. Its ID is ignored.
+	Book          *Book `protobuf:"bytes,2,opt,name=book,proto3" json:"book,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRequest) Reset() {
+	*x = UpdateRequest{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRequest) ProtoMessage() {}
+
+func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRequest) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateRequest) GetBook() *Book {
+	if x != nil {
+		return x.Book
+	}
+	return nil
+}
+
+type RemoveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveRequest) Reset() {
+	*x = RemoveRequest{}
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRequest) ProtoMessage() {}
+
+func (x *RemoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_booklibrary_v1_booklibrary_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRequest.ProtoReflect.Descriptor instead.
+func (*RemoveRequest) Descriptor() ([]byte, []int) {
+	return file_booklibrary_v1_booklibrary_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RemoveRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_booklibrary_v1_booklibrary_proto protoreflect.FileDescriptor
+
+const file_booklibrary_v1_booklibrary_proto_rawDesc = "" +
+	"\n" +
+	" booklibrary/v1/booklibrary.proto\x12\x0ebooklibrary.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9f\x01\n" +
+	"\x04Book\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06author\x18\x02 \x01(\tR\x06author\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12=\n" +
+	"\frelease_date\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\vreleaseDate\x12\x1a\n" +
+	"\bkeywords\x18\x05 \x03(\tR\bkeywords\"#\n" +
+	"\vListRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\"\x1c\n" +
+	"\n" +
+	"GetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"6\n" +
+	"\n" +
+	"AddRequest\x12(\n" +
+	"\x04book\x18\x01 \x01(\v2\x14.booklibrary.v1.BookR\x04book\"I\n" +
+	"\rUpdateRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12(\n" +
+	"\x04book\x18\x02 \x01(\v2\x14.booklibrary.v1.BookR\x04book\"\x1f\n" +
+	"\rRemoveRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id2\xba\x02\n" +
+	"\vBookLibrary\x12;\n" +
+	"\x04List\x12\x1b.booklibrary.v1.ListRequest\x1a\x14.booklibrary.v1.Book0\x01\x127\n" +
+	"\x03Get\x12\x1a.booklibrary.v1.GetRequest\x1a\x14.booklibrary.v1.Book\x127\n" +
+	"\x03Add\x12\x1a.booklibrary.v1.AddRequest\x1a\x14.booklibrary.v1.Book\x12=\n" +
+	"\x06Update\x12\x1d.booklibrary.v1.UpdateRequest\x1a\x14.booklibrary.v1.Book\x12=\n" +
+	"\x06Remove\x12\x1d.booklibrary.v1.RemoveRequest\x1a\x14.booklibrary.v1.BookBLZJgithub.com/joergjo/go-samples/booklibrary/api/booklibrary/v1;booklibraryv1b\x06proto3"
+
+var (
+	file_booklibrary_v1_booklibrary_proto_rawDescOnce sync.Once
+	file_booklibrary_v1_booklibrary_proto_rawDescData []byte
+)
+
+func file_booklibrary_v1_booklibrary_proto_rawDescGZIP() []byte {
+	file_booklibrary_v1_booklibrary_proto_rawDescOnce.Do(func() {
+		file_booklibrary_v1_booklibrary_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_booklibrary_v1_booklibrary_proto_rawDesc), len(file_booklibrary_v1_booklibrary_proto_rawDesc)))
+	})
+	return file_booklibrary_v1_booklibrary_proto_rawDescData
+}
+
+var file_booklibrary_v1_booklibrary_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_booklibrary_v1_booklibrary_proto_goTypes = []any{
+	(*Book)(nil),                  // 0: booklibrary.v1.Book
+	(*ListRequest)(nil),           // 1: booklibrary.v1.ListRequest
+	(*GetRequest)(nil),            // 2: booklibrary.v1.GetRequest
+	(*AddRequest)(nil),            // 3: booklibrary.v1.AddRequest
+	(*UpdateRequest)(nil),         // 4: booklibrary.v1.UpdateRequest
+	(*RemoveRequest)(nil),         // 5: booklibrary.v1.RemoveRequest
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_booklibrary_v1_booklibrary_proto_depIdxs = []int32{
+	6, // 0: booklibrary.v1.Book.release_date:type_name -> google.protobuf.Timestamp
+	0, // 1: booklibrary.v1.AddRequest.book:type_name -> booklibrary.v1.Book
+	0, // 2: booklibrary.v1.UpdateRequest.book:type_name -> booklibrary.v1.Book
+	1, // 3: booklibrary.v1.BookLibrary.List:input_type -> booklibrary.v1.ListRequest
+	2, // 4: booklibrary.v1.BookLibrary.Get:input_type -> booklibrary.v1.GetRequest
+	3, // 5: booklibrary.v1.BookLibrary.Add:input_type -> booklibrary.v1.AddRequest
+	4, // 6: booklibrary.v1.BookLibrary.Update:input_type -> booklibrary.v1.UpdateRequest
+	5, // 7: booklibrary.v1.BookLibrary.Remove:input_type -> booklibrary.v1.RemoveRequest
+	0, // 8: booklibrary.v1.BookLibrary.List:output_type -> booklibrary.v1.Book
+	0, // 9: booklibrary.v1.BookLibrary.Get:output_type -> booklibrary.v1.Book
+	0, // 10: booklibrary.v1.BookLibrary.Add:output_type -> booklibrary.v1.Book
+	0, // 11: booklibrary.v1.BookLibrary.Update:output_type -> booklibrary.v1.Book
+	0, // 12: booklibrary.v1.BookLibrary.Remove:output_type -> booklibrary.v1.Book
+	8, // [8:13] is the sub-list for method output_type
+	3, // [3:8] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_booklibrary_v1_booklibrary_proto_init() }
+func file_booklibrary_v1_booklibrary_proto_init() {
+	if File_booklibrary_v1_booklibrary_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_booklibrary_v1_booklibrary_proto_rawDesc), len(file_booklibrary_v1_booklibrary_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_booklibrary_v1_booklibrary_proto_goTypes,
+		DependencyIndexes: file_booklibrary_v1_booklibrary_proto_depIdxs,
+		MessageInfos:      file_booklibrary_v1_booklibrary_proto_msgTypes,
+	}.Build()
+	File_booklibrary_v1_booklibrary_proto = out.File
+	file_booklibrary_v1_booklibrary_proto_goTypes = nil
+	file_booklibrary_v1_booklibrary_proto_depIdxs = nil
+}
@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: booklibrary/v1/booklibrary.proto
+
+package booklibraryv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BookLibrary_List_FullMethodName   = "/booklibrary.v1.BookLibrary/List"
+	BookLibrary_Get_FullMethodName    = "/booklibrary.v1.BookLibrary/Get"
+	BookLibrary_Add_FullMethodName    = "/booklibrary.v1.BookLibrary/Add"
+	BookLibrary_Update_FullMethodName = "/booklibrary.v1.BookLibrary/Update"
+	BookLibrary_Remove_FullMethodName = "/booklibrary.v1.BookLibrary/Remove"
+)
+
+// BookLibraryClient is the client API for BookLibrary service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// BookLibrary manages the books of a library.
+type BookLibraryClient interface {
+	// List streams up to limit books.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Book], error)
+	// Get returns a single book by its ID.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Book, error)
+	// Add adds a new book to the library and returns it with its assigned ID.
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*Book, error)
+	// Update replaces the book with the given ID.
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Book, error)
+	// Remove deletes the book with the given ID and returns it.
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Book, error)
+}
+
+type bookLibraryClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBookLibraryClient(cc grpc.ClientConnInterface) BookLibraryClient {
+	return &bookLibraryClient{cc}
+}
+
+func (c *bookLibraryClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Book], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BookLibrary_ServiceDesc.Streams[0], BookLibrary_List_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListRequest, Book]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BookLibrary_ListClient = grpc.ServerStreamingClient[Book]
+
+func (c *bookLibraryClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, BookLibrary_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookLibraryClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, BookLibrary_Add_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookLibraryClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, BookLibrary_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookLibraryClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Book, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Book)
+	err := c.cc.Invoke(ctx, BookLibrary_Remove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BookLibraryServer is the server API for BookLibrary service.
+// All implementations must embed UnimplementedBookLibraryServer
+// for forward compatibility.
+//
+// BookLibrary manages the books of a library.
+type BookLibraryServer interface {
+	// List streams up to limit books.
+	List(*ListRequest, grpc.ServerStreamingServer[Book]) error
+	// Get returns a single book by its ID.
+	Get(context.Context, *GetRequest) (*Book, error)
+	// Add adds a new book to the library and returns it with its assigned ID.
+	Add(context.Context, *AddRequest) (*Book, error)
+	// Update replaces the book with the given ID.
+	Update(context.Context, *UpdateRequest) (*Book, error)
+	// Remove deletes the book with the given ID and returns it.
+	Remove(context.Context, *RemoveRequest) (*Book, error)
+	mustEmbedUnimplementedBookLibraryServer()
+}
+
+// UnimplementedBookLibraryServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBookLibraryServer struct{}
+
+func (UnimplementedBookLibraryServer) List(*ListRequest, grpc.ServerStreamingServer[Book]) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedBookLibraryServer) Get(context.Context, *GetRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedBookLibraryServer) Add(context.Context, *AddRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedBookLibraryServer) Update(context.Context, *UpdateRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedBookLibraryServer) Remove(context.Context, *RemoveRequest) (*Book, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedBookLibraryServer) mustEmbedUnimplementedBookLibraryServer() {}
+func (UnimplementedBookLibraryServer) testEmbeddedByValue()                     {}
+
+// UnsafeBookLibraryServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BookLibraryServer will
+// result in compilation errors.
+type UnsafeBookLibraryServer interface {
+	mustEmbedUnimplementedBookLibraryServer()
+}
+
+func RegisterBookLibraryServer(s grpc.ServiceRegistrar, srv BookLibraryServer) {
+	// If the following call pancis, it indicates UnimplementedBookLibraryServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BookLibrary_ServiceDesc, srv)
+}
+
+func _BookLibrary_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BookLibraryServer).List(m, &grpc.GenericServerStream[ListRequest, Book]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BookLibrary_ListServer = grpc.ServerStreamingServer[Book]
+
+func _BookLibrary_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookLibraryServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookLibrary_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookLibraryServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookLibrary_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookLibraryServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookLibrary_Add_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookLibraryServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookLibrary_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookLibraryServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookLibrary_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookLibraryServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookLibrary_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookLibraryServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookLibrary_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookLibraryServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BookLibrary_ServiceDesc is the grpc.ServiceDesc for BookLibrary service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BookLibrary_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "booklibrary.v1.BookLibrary",
+	HandlerType: (*BookLibraryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _BookLibrary_Get_Handler,
+		},
+		{
+			MethodName: "Add",
+			Handler:    _BookLibrary_Add_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _BookLibrary_Update_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _BookLibrary_Remove_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _BookLibrary_List_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "booklibrary/v1/booklibrary.proto",
+}
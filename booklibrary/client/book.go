@@ -0,0 +1,26 @@
+package client
+
+import "time"
+
+// Book is a book in the library.
+type Book struct {
+	ID          string    `json:"_id,omitempty"`
+	Author      string    `json:"author"`
+	Title       string    `json:"title"`
+	ReleaseDate time.Time `json:"releaseDate"`
+	Keywords    []Keyword `json:"keywords"`
+	// Availability is set by servers that track holdings and loans.
+	Availability *Availability `json:"availability,omitempty"`
+}
+
+// Keyword is a book's topic.
+type Keyword struct {
+	Value string `json:"keyword"`
+}
+
+// Availability is the number of copies of a book the library holds and how many are on loan.
+type Availability struct {
+	Copies    int `json:"copies"`
+	OnLoan    int `json:"onLoan"`
+	Available int `json:"available"`
+}
@@ -0,0 +1,279 @@
+// Package client provides a typed Go client for the booklibrary REST API.
+//
+// A Client is safe for concurrent use:
+//
+//	c, err := client.New("http://localhost:8000", client.WithAPIKey(key))
+//	if err != nil {
+//		return err
+//	}
+//	book, err := c.Get(ctx, id)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const booksPath = "/api/books"
+
+// mediaType is the media type of requests and responses. Release dates are exchanged as RFC 3339
+// timestamps, so that they keep their precision and match time.Time's JSON encoding.
+const mediaType = "application/json; dateFormat=rfc3339"
+
+// maxErrorBody is the maximum number of bytes of an error response included in an APIError.
+const maxErrorBody = 1 << 10
+
+// Client calls the booklibrary REST API.
+type Client struct {
+	base *url.URL
+	s    settings
+}
+
+// New creates a Client for the API served at baseURL, e.g. http://localhost:8000.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q must use http or https", baseURL)
+	}
+	s := settings{
+		httpClient: http.DefaultClient,
+		timeout:    defaultTimeout,
+		retries:    defaultRetries,
+		baseDelay:  defaultBaseDelay,
+		maxDelay:   defaultMaxDelay,
+	}
+	for _, o := range opts {
+		o(&s)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{base: u, s: s}, nil
+}
+
+// List returns up to limit books ordered by ID. The server caps limits it considers too large.
+func (c *Client) List(ctx context.Context, limit int) ([]Book, error) {
+	books, _, err := c.page(ctx, limit, "")
+	return books, err
+}
+
+// ListAfter returns up to limit books following the book with ID after, ordered by ID. Passing
+// the ID of the last book of the previous call pages through the library.
+func (c *Client) ListAfter(ctx context.Context, after string, limit int) ([]Book, error) {
+	books, _, err := c.page(ctx, limit, after)
+	return books, err
+}
+
+// Get returns the book with the given ID.
+func (c *Client) Get(ctx context.Context, id string) (Book, error) {
+	var book Book
+	_, err := c.do(ctx, http.MethodGet, c.bookURL(id), nil, &book, true)
+	return book, err
+}
+
+// Add adds book to the library and returns it with its assigned ID. Add is never retried, since
+// the server might have stored the book before a failure.
+func (c *Client) Add(ctx context.Context, book Book) (Book, error) {
+	var added Book
+	_, err := c.do(ctx, http.MethodPost, c.url(booksPath, nil), book, &added, false)
+	return added, err
+}
+
+// Update replaces the book with the given ID and returns its new state.
+func (c *Client) Update(ctx context.Context, id string, book Book) (Book, error) {
+	var updated Book
+	_, err := c.do(ctx, http.MethodPut, c.bookURL(id), book, &updated, true)
+	return updated, err
+}
+
+// Remove deletes the book with the given ID.
+func (c *Client) Remove(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.bookURL(id), nil, nil, true)
+	return err
+}
+
+// All iterates over all books in the library ordered by ID, fetching pageSize books per request.
+// A pageSize less than 1 selects 100. Iteration stops at the first error, which is yielded with a
+// zero Book.
+func (c *Client) All(ctx context.Context, pageSize int) iter.Seq2[Book, error] {
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	return func(yield func(Book, error) bool) {
+		after := ""
+		for {
+			books, more, err := c.page(ctx, pageSize, after)
+			if err != nil {
+				yield(Book{}, err)
+				return
+			}
+			for _, b := range books {
+				if !yield(b, nil) {
+					return
+				}
+			}
+			if !more || len(books) == 0 {
+				return
+			}
+			after = books[len(books)-1].ID
+		}
+	}
+}
+
+// page fetches up to limit books after the given ID and reports whether the server links to a
+// next page.
+func (c *Client) page(ctx context.Context, limit int, after string) ([]Book, bool, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if after != "" {
+		q.Set("after", after)
+	}
+	var books []Book
+	res, err := c.do(ctx, http.MethodGet, c.url(booksPath, q), nil, &books, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return books, strings.Contains(res.Header.Get("Link"), `rel="next"`), nil
+}
+
+func (c *Client) url(path string, q url.Values) string {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (c *Client) bookURL(id string) string {
+	return c.url(booksPath+"/"+url.PathEscape(id), nil)
+}
+
+// do sends a request with body encoded as JSON and decodes the response into out, if not nil.
+// Retryable failures are retried if idempotent is set.
+func (c *Client) do(ctx context.Context, method, u string, body, out any, idempotent bool) (*http.Response, error) {
+	if c.s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.s.timeout)
+		defer cancel()
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.s.retries
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt, err); err != nil {
+				return nil, err
+			}
+		}
+		var res *http.Response
+		var transient bool
+		res, transient, err = c.send(ctx, method, u, payload, out)
+		if err == nil {
+			return res, nil
+		}
+		if !transient || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// send makes a single attempt and reports whether a failure is transient.
+func (c *Client) send(ctx context.Context, method, u string, payload []byte, out any) (*http.Response, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", mediaType)
+	if payload != nil {
+		req.Header.Set("Content-Type", mediaType)
+	}
+	if c.s.userAgent != "" {
+		req.Header.Set("User-Agent", c.s.userAgent)
+	}
+	for _, auth := range c.s.auth {
+		if err := auth(req); err != nil {
+			return nil, false, fmt.Errorf("authenticating request: %w", err)
+		}
+	}
+
+	res, err := c.s.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return nil, retryable(res.StatusCode), &APIError{
+			StatusCode: res.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+			RetryAfter: retryAfter(res.Header.Get("Retry-After")),
+		}
+	}
+	if out != nil && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return nil, false, fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	return res, false, nil
+}
+
+// wait sleeps before the given retry attempt, for the server's Retry-After if the last error
+// carries one, or an exponential backoff with full jitter otherwise.
+func (c *Client) wait(ctx context.Context, attempt int, last error) error {
+	d := c.s.baseDelay << (attempt - 1)
+	if d > c.s.maxDelay || d <= 0 {
+		d = c.s.maxDelay
+	}
+	d = rand.N(d + 1)
+	var apiErr *APIError
+	if errors.As(last, &apiErr) && apiErr.RetryAfter > 0 {
+		d = apiErr.RetryAfter
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return last
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
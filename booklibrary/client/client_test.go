@@ -0,0 +1,262 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/client"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	var h http.Handler = webapi.NewMux(memory.NewCrudService())
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(url, opts...)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return c
+}
+
+func TestCRUD(t *testing.T) {
+	srv := newServer(t, nil)
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	book := client.Book{
+		Author:      "Jörg Jooss",
+		Title:       "Go Clients in Action",
+		ReleaseDate: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Keywords:    []client.Keyword{{Value: "Go"}, {Value: "HTTP"}},
+	}
+	added, err := c.Add(ctx, book)
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	if added.ID == "" {
+		t.Fatal("Added book has no ID")
+	}
+	book.ID = added.ID
+
+	got, err := c.Get(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	if diff := cmp.Diff(book, got); diff != "" {
+		t.Fatalf("Book mismatch (-want +got):\n%s", diff)
+	}
+
+	book.Title = "Go Clients in Action, 2nd Edition"
+	got, err = c.Update(ctx, added.ID, book)
+	if err != nil {
+		t.Fatalf("Error updating book: %v", err)
+	}
+	if diff := cmp.Diff(book, got); diff != "" {
+		t.Fatalf("Book mismatch (-want +got):\n%s", diff)
+	}
+
+	books, err := c.List(ctx, 10)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	if diff := cmp.Diff([]client.Book{book}, books); diff != "" {
+		t.Fatalf("Books mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := c.Remove(ctx, added.ID); err != nil {
+		t.Fatalf("Error removing book: %v", err)
+	}
+	if _, err := c.Get(ctx, added.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Unexpected error getting removed book, got %v, want %v", err, client.ErrNotFound)
+	}
+	if err := c.Remove(ctx, added.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Unexpected error removing removed book, got %v, want %v", err, client.ErrNotFound)
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	srv := newServer(t, func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid book", http.StatusBadRequest)
+		})
+	})
+	c := newClient(t, srv.URL)
+
+	_, err := c.Add(context.Background(), client.Book{})
+	if !errors.Is(err, client.ErrBadRequest) {
+		t.Fatalf("Unexpected error adding book, got %v, want %v", err, client.ErrBadRequest)
+	}
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "invalid book" {
+		t.Errorf("Unexpected error message, got %v, want %q", err, "invalid book")
+	}
+}
+
+func TestAll(t *testing.T) {
+	var requests atomic.Int32
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				requests.Add(1)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	var want []string
+	for i := range 5 {
+		b, err := c.Add(ctx, client.Book{Title: fmt.Sprintf("Volume %d", i)})
+		if err != nil {
+			t.Fatalf("Error adding book: %v", err)
+		}
+		want = append(want, b.Title)
+	}
+
+	var got []string
+	for b, err := range c.All(ctx, 2) {
+		if err != nil {
+			t.Fatalf("Error iterating books: %v", err)
+		}
+		got = append(got, b.Title)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Books mismatch (-want +got):\n%s", diff)
+	}
+	// Pages of 2, 2 and 1 books; the last page is not full and has no next link.
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("Unexpected number of requests, got %d, want %d", got, 3)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		call     func(context.Context, *client.Client) error
+		wantErr  error
+		wantHits int32
+	}{
+		{
+			name:     "get_recovers",
+			failures: 2,
+			call: func(ctx context.Context, c *client.Client) error {
+				_, err := c.List(ctx, 1)
+				return err
+			},
+			wantHits: 3,
+		},
+		{
+			name:     "get_gives_up",
+			failures: 5,
+			call: func(ctx context.Context, c *client.Client) error {
+				_, err := c.List(ctx, 1)
+				return err
+			},
+			wantErr:  client.ErrUnavailable,
+			wantHits: 3,
+		},
+		{
+			name:     "add_not_retried",
+			failures: 1,
+			call: func(ctx context.Context, c *client.Client) error {
+				_, err := c.Add(ctx, client.Book{Title: "Retries"})
+				return err
+			},
+			wantErr:  client.ErrUnavailable,
+			wantHits: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := newServer(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if hits.Add(1) <= tc.failures {
+						http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+			c := newClient(t, srv.URL, client.WithRetries(2, time.Millisecond, time.Millisecond))
+
+			err := tc.call(context.Background(), c)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Unexpected error, got %v, want %v", err, tc.wantErr)
+			}
+			if got := hits.Load(); got != tc.wantHits {
+				t.Fatalf("Unexpected number of requests, got %d, want %d", got, tc.wantHits)
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	ctx := context.Background()
+
+	var apiErr *client.APIError
+	_, err := newClient(t, srv.URL).List(ctx, 1)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Unexpected error without credentials, got %v, want status %d", err, http.StatusUnauthorized)
+	}
+	if _, err := newClient(t, srv.URL, client.WithBearerToken("secret")).List(ctx, 1); err != nil {
+		t.Fatalf("Unexpected error with credentials: %v", err)
+	}
+
+	hookErr := errors.New("no token")
+	c := newClient(t, srv.URL, client.WithAuth(func(*http.Request) error { return hookErr }))
+	if _, err := c.List(ctx, 1); !errors.Is(err, hookErr) {
+		t.Fatalf("Unexpected error from failing auth hook, got %v, want %v", err, hookErr)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(t, srv.URL, client.WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := c.List(context.Background(), 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error, got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Call took %v despite timeout", elapsed)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a book does not exist.
+	ErrNotFound = errors.New("book not found")
+	// ErrUnavailable is returned when the server or its data store is temporarily unavailable.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrBadRequest is returned when the server rejects a request as invalid, e.g. a book without
+	// a title.
+	ErrBadRequest = errors.New("bad request")
+	// ErrRateLimited is returned when the client has exhausted its rate limit.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// APIError is returned for responses with an unexpected status code. It matches ErrBadRequest,
+// ErrNotFound, ErrUnavailable or ErrRateLimited with errors.Is, depending on the status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the response body, if any.
+	Message string
+	// RetryAfter is the delay requested by the server's Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("booklibrary: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("booklibrary: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// retryable reports whether a response with this status code may succeed when retried.
+func retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultRetries   = 2
+	defaultBaseDelay = 100 * time.Millisecond
+	defaultMaxDelay  = 2 * time.Second
+	defaultPageSize  = 100
+)
+
+type settings struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	retries    int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	auth       []func(*http.Request) error
+	userAgent  string
+}
+
+// Option configures a Client.
+type Option func(*settings)
+
+// WithHTTPClient sets the HTTP client used to send requests. It defaults to a client without timeout,
+// since each call is bounded by the timeout set with WithTimeout.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *settings) {
+		s.httpClient = c
+	}
+}
+
+// WithTimeout sets the maximum duration of a call, including all retries. Zero disables the timeout,
+// leaving calls bounded only by their context.
+func WithTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		s.timeout = d
+	}
+}
+
+// WithRetries sets how often a failed idempotent call (List, Get, Update and Remove) is retried
+// after network errors, 429, 502, 503 and 504 responses, and the base and maximum backoff between
+// attempts. A Retry-After header sent by the server takes precedence over the backoff. Add is never
+// retried. Zero retries disable retrying.
+func WithRetries(retries int, baseDelay, maxDelay time.Duration) Option {
+	return func(s *settings) {
+		if retries >= 0 {
+			s.retries = retries
+		}
+		if baseDelay > 0 {
+			s.baseDelay = baseDelay
+		}
+		if maxDelay > 0 {
+			s.maxDelay = maxDelay
+		}
+	}
+}
+
+// WithAuth adds a hook that is called for every request, including retries, before it is sent. It
+// can add credentials such as headers or cookies. An error aborts the call.
+func WithAuth(fn func(*http.Request) error) Option {
+	return func(s *settings) {
+		s.auth = append(s.auth, fn)
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header.
+func WithAPIKey(key string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set("X-API-Key", key)
+		return nil
+	})
+}
+
+// WithBearerToken sends token in the Authorization header.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(s *settings) {
+		s.userAgent = ua
+	}
+}
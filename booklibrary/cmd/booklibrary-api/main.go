@@ -3,19 +3,37 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	"log/slog"
 
+	"github.com/joergjo/go-samples/booklibrary/internal/cache"
 	"github.com/joergjo/go-samples/booklibrary/internal/config"
+	"github.com/joergjo/go-samples/booklibrary/internal/grpcapi"
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"github.com/joergjo/go-samples/booklibrary/internal/integrity"
 	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
 	"github.com/joergjo/go-samples/booklibrary/internal/mongo"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+	"github.com/joergjo/go-samples/booklibrary/internal/resilience"
+	"github.com/joergjo/go-samples/booklibrary/internal/shutdown"
+	"github.com/joergjo/go-samples/booklibrary/internal/similar"
+	"github.com/joergjo/go-samples/booklibrary/internal/telemetry"
+	"github.com/joergjo/go-samples/booklibrary/internal/tlsconfig"
 	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var (
@@ -27,43 +45,172 @@ var (
 
 func main() {
 	s := configure()
-	slog.SetDefault(log.New(os.Stdout, s.Debug))
+	var logOpts []log.Option
+	if s.LogFormat == "json" {
+		logOpts = append(logOpts, log.WithJSON())
+	}
+	level := new(slog.LevelVar)
+	if s.Debug {
+		level.Set(slog.LevelDebug)
+	}
+	slog.SetDefault(log.New(os.Stdout, level, logOpts...))
 
 	slog.Info("booklibrary-api", "version", version, "commit", commit, "date", date, "builtBy", builtBy, "goVersion", runtime.Version(), "goMaxProcs", runtime.GOMAXPROCS(0))
 	if s.Debug {
 		slog.Warn("debug logging enabled")
 	}
 
-	os.Exit(run(s))
+	os.Exit(run(s, level))
 }
 
-func run(s config.Settings) int {
-	crud, err := newCrudService(s.MongoURI, s.Db, s.Collection)
+func run(s config.Settings, level *slog.LevelVar) int {
+	if s.Tracing {
+		shutdown, err := telemetry.Setup(context.Background(), s.OTLPEndpoint, version)
+		if err != nil {
+			slog.Error("setting up tracing", log.ErrorKey, err)
+			return 1
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("shutting down tracing", log.ErrorKey, err)
+			}
+		}()
+	}
+
+	var tlsOpts []webapi.Option
+	var reloader *tlsconfig.Reloader
+	if s.TLSCertFile != "" {
+		var err error
+		tlsOpts, reloader, err = newTLS(s)
+		if err != nil {
+			slog.Error("configuring TLS", log.ErrorKey, err)
+			return 1
+		}
+	}
+
+	var grpcLis net.Listener
+	if s.GRPCPort > 0 {
+		addr := net.JoinHostPort("", strconv.Itoa(s.GRPCPort))
+		var err error
+		grpcLis, err = net.Listen("tcp", addr)
+		if err != nil {
+			slog.Error("listening for gRPC", log.AddrKey, addr, log.ErrorKey, err)
+			return 1
+		}
+	}
+
+	reg := webapi.NewRegistry()
+	crud, err := newCrudService(s, reg)
 	if err != nil {
 		slog.Error("creating book service", log.ErrorKey, err)
+		if grpcLis != nil {
+			grpcLis.Close()
+		}
 		return 1
 	}
-	defer func() {
-		slog.Info("closing database connection")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := crud.Close(ctx); err != nil {
-			slog.Error("closing database connection", log.ErrorKey, err)
-		} else {
-			slog.Info("closed database connection")
+
+	resilient := resilience.NewCrudService(crud,
+		resilience.WithBreaker(s.BreakerThreshold, s.BreakerCooldown),
+		resilience.WithReadRetries(s.ReadAttempts, s.RetryBaseDelay, s.RetryMaxDelay),
+		resilience.WithRetryable(mongo.IsTransient),
+		resilience.WithRegisterer(reg))
+	var store model.CrudService = resilient
+	if s.CacheSize > 0 {
+		store = cache.NewCrudService(store, s.CacheSize, s.CacheTTL, reg)
+	}
+	// Books are looked up without the cache when they are put on lists or lent, so that removed
+	// books are not.
+	listStore := mongo.NewListService(crud, s.ListsCollection)
+	lists := integrity.NewListService(listStore, resilient)
+	loanStore := mongo.NewLoanService(crud, s.LoansCollection, s.CopiesCollection)
+	loans := integrity.NewLoanService(loanStore, resilient)
+	store = integrity.NewCrudService(store, lists, loans)
+	index := similar.NewIndex()
+	store = similar.NewCrudService(store, index)
+
+	checks := newHealth(s, crud, resilient)
+	// Books changed over gRPC are counted and published to GraphQL subscriptions like those changed
+	// over HTTP.
+	changes := webapi.NewChanges(reg)
+	opts := []webapi.Option{
+		webapi.WithTimeouts(s.ReadTimeout, s.WriteTimeout, s.IdleTimeout),
+		webapi.WithRegistry(reg),
+		webapi.WithChanges(changes),
+		webapi.WithHealth(checks),
+		webapi.WithRateLimit(newLimiter(s.RateLimitRead, s.RateLimitPeriod), newLimiter(s.RateLimitWrite, s.RateLimitPeriod), s.RateLimitByAPIKey),
+		webapi.WithLists(lists),
+		webapi.WithLoans(loans),
+		webapi.WithLoanPolicy(s.LoanPeriod, s.MaxRenewals),
+		webapi.WithRecommender(index),
+	}
+	reindex := reindexers{crud, listStore, loanStore}
+	if s.IdempotencyTTL > 0 {
+		idempotency := mongo.NewIdempotencyService(crud, s.IdempotencyCollection)
+		// Without its TTL index, expired keys are never removed, and the admin API may be disabled.
+		ctx, cancel := context.WithTimeout(context.Background(), s.MongoStartupTimeout)
+		if err := idempotency.Reindex(ctx); err != nil {
+			slog.Warn("creating idempotency indexes", log.ErrorKey, err)
 		}
-	}()
+		cancel()
+		opts = append(opts, webapi.WithIdempotency(idempotency, s.IdempotencyTTL))
+		reindex = append(reindex, idempotency)
+	}
+	if s.TrustProxy {
+		opts = append(opts, webapi.WithTrustedProxy())
+	}
+	if s.TrustUserHeader {
+		opts = append(opts, webapi.WithTrustedUserHeader())
+	}
+	if s.AdminToken != "" {
+		opts = append(opts,
+			webapi.WithAdmin(s.AdminToken),
+			webapi.WithLogLevel(level),
+			webapi.WithBuildInfo(webapi.BuildInfo{Version: version, Commit: commit, Date: date, BuiltBy: builtBy, GoVersion: runtime.Version()}),
+			webapi.WithReindexer(reindex))
+	}
+	opts = append(opts, tlsOpts...)
+	srv := webapi.NewServer(store, s.Port, opts...)
 
-	srv := webapi.NewServer(crud, s.Port)
+	// Background workers run until the shutdown coordinator stops them.
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var wg sync.WaitGroup
+	if reloader != nil {
+		wg.Go(func() { reloader.Run(workers) })
+	}
+	wg.Go(func() { rebuildSimilar(workers, index, resilient, s.SimilarRebuildInterval) })
 
-	errC := make(chan error, 1)
+	errC := make(chan error, 2)
 	go func() {
-		slog.Info("starting server", log.AddrKey, srv.Addr)
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		slog.Info("starting server", log.AddrKey, srv.Addr, "tls", srv.TLSConfig != nil)
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			errC <- err
 		}
 	}()
 
+	var grpcSrv *grpc.Server
+	if grpcLis != nil {
+		grpcOpts := []grpcapi.Option{grpcapi.WithHealth(checks)}
+		if srv.TLSConfig != nil {
+			grpcOpts = append(grpcOpts, grpcapi.WithServerOptions(grpc.Creds(credentials.NewTLS(srv.TLSConfig))))
+		}
+		grpcSrv = grpcapi.NewServer(changes.Store(store), grpcOpts...)
+		go func() {
+			slog.Info("starting gRPC server", log.AddrKey, grpcLis.Addr().String(), "tls", srv.TLSConfig != nil)
+			if err := grpcSrv.Serve(grpcLis); err != nil {
+				errC <- err
+			}
+		}()
+	}
+
 	sigC := make(chan os.Signal, 1)
 	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
 
@@ -74,46 +221,289 @@ func run(s config.Settings) int {
 		exit = 1
 	case sig := <-sigC:
 		slog.Warn("received signal, shutting down", "signal", sig.String())
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		slog.Info("waiting for server to shut down")
+	sd := shutdown.New()
+	if exit == 0 {
+		sd.Add("readiness", 0, func(context.Context) error {
+			checks.Shutdown()
+			return nil
+		})
+		sd.Add("pre-stop delay", 0, shutdown.Delay(s.PreStopDelay))
+	}
+	inFlight := func() int {
+		n, err := webapi.InFlightRequests(reg)
+		if err != nil {
+			slog.Warn("gathering in-flight requests", log.ErrorKey, err)
+		}
+		return n
+	}
+	sd.Add("drain", s.ShutdownTimeout, shutdown.Drain(inFlight, time.Second, func(ctx context.Context) error {
+		var wg sync.WaitGroup
+		if grpcSrv != nil {
+			wg.Go(func() { stopGRPC(ctx, grpcSrv) })
+		}
+		defer wg.Wait()
 		if err := srv.Shutdown(ctx); err != nil {
-			slog.Error("shutting down server", "error", err)
-			if err := srv.Close(); err != nil {
-				slog.Error("forcefully closing server", "error", err)
+			pending := inFlight()
+			if cerr := srv.Close(); cerr != nil {
+				slog.Error("forcefully closing server", log.ErrorKey, cerr)
 			}
+			return fmt.Errorf("%w with %d requests in flight", err, pending)
+		}
+		return nil
+	}))
+	sd.Add("background workers", 5*time.Second, func(ctx context.Context) error {
+		stopWorkers()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-		slog.Info("server has shut down")
+	})
+	sd.Add("store", 5*time.Second, crud.Close)
+	if err := sd.Run(context.Background()); err != nil {
+		exit = 1
 	}
-
 	return exit
 }
 
+// reindexers reindexes the collections of several stores in turn.
+type reindexers []webapi.Reindexer
+
+func (rs reindexers) Reindex(ctx context.Context) error {
+	for _, r := range rs {
+		if err := r.Reindex(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSimilar rebuilds index from crud now and then every interval until ctx is done, so that
+// changes made by other instances show up in recommendations. A zero interval rebuilds only once.
+func rebuildSimilar(ctx context.Context, index *similar.Index, crud model.CrudService, interval time.Duration) {
+	for {
+		if err := index.Rebuild(ctx, crud); err != nil && ctx.Err() == nil {
+			slog.Error("rebuilding similarity index", log.ErrorKey, err)
+		}
+		if interval <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// stopGRPC stops srv gracefully, or forcefully once ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("gRPC server has shut down")
+	case <-ctx.Done():
+		slog.Error("shutting down gRPC server", log.ErrorKey, ctx.Err())
+		srv.Stop()
+	}
+}
+
 func configure() config.Settings {
 	var s config.Settings
 	mongoURI := config.GetEnvString("BOOKLIBRARY_MONGOURI", "mongodb://localhost/?timeoutMS=0")
 	port := config.GetEnvInt("BOOKLIBRARY_PORT", 8000)
+	grpcPort := config.GetEnvInt("BOOKLIBRARY_GRPC_PORT", 0)
 	db := config.GetEnvString("BOOKLIBRARY_DB", "library_database")
 	coll := config.GetEnvString("BOOKLIBRARY_COLLECTION", "books")
 	debug := config.GetEnvBool("BOOKLIBRARY_DEBUG", false)
+	logFormat := config.GetEnvString("BOOKLIBRARY_LOG_FORMAT", "text")
+	readTimeout := config.GetEnvDuration("BOOKLIBRARY_READ_TIMEOUT", 5*time.Second)
+	writeTimeout := config.GetEnvDuration("BOOKLIBRARY_WRITE_TIMEOUT", 10*time.Second)
+	idleTimeout := config.GetEnvDuration("BOOKLIBRARY_IDLE_TIMEOUT", 120*time.Second)
+	mongoTimeout := config.GetEnvDuration("BOOKLIBRARY_MONGO_TIMEOUT", 2*time.Second)
+	mongoStartupTimeout := config.GetEnvDuration("BOOKLIBRARY_MONGO_STARTUP_TIMEOUT", 10*time.Second)
+	mongoMaxPoolSize := config.GetEnvUint64("BOOKLIBRARY_MONGO_MAX_POOL_SIZE", 100)
+	mongoMaxConnIdleTime := config.GetEnvDuration("BOOKLIBRARY_MONGO_MAX_CONN_IDLE_TIME", 0)
+	mongoRetryWrites := config.GetEnvBool("BOOKLIBRARY_MONGO_RETRY_WRITES", true)
+	mongoRetryReads := config.GetEnvBool("BOOKLIBRARY_MONGO_RETRY_READS", true)
+	rateLimitRead := config.GetEnvInt("BOOKLIBRARY_RATELIMIT_READ", 0)
+	rateLimitWrite := config.GetEnvInt("BOOKLIBRARY_RATELIMIT_WRITE", 0)
+	rateLimitPeriod := config.GetEnvDuration("BOOKLIBRARY_RATELIMIT_PERIOD", time.Minute)
+	rateLimitByAPIKey := config.GetEnvBool("BOOKLIBRARY_RATELIMIT_BY_APIKEY", false)
+	trustProxy := config.GetEnvBool("BOOKLIBRARY_TRUST_PROXY", false)
+	breakerThreshold := config.GetEnvInt("BOOKLIBRARY_BREAKER_THRESHOLD", 5)
+	breakerCooldown := config.GetEnvDuration("BOOKLIBRARY_BREAKER_COOLDOWN", 10*time.Second)
+	readAttempts := config.GetEnvInt("BOOKLIBRARY_READ_ATTEMPTS", 3)
+	retryBaseDelay := config.GetEnvDuration("BOOKLIBRARY_RETRY_BASE_DELAY", 50*time.Millisecond)
+	retryMaxDelay := config.GetEnvDuration("BOOKLIBRARY_RETRY_MAX_DELAY", time.Second)
+	cacheSize := config.GetEnvInt("BOOKLIBRARY_CACHE_SIZE", 0)
+	cacheTTL := config.GetEnvDuration("BOOKLIBRARY_CACHE_TTL", 30*time.Second)
+	healthCacheTTL := config.GetEnvDuration("BOOKLIBRARY_HEALTH_CACHE_TTL", 2*time.Second)
+	healthDiskPath := config.GetEnvString("BOOKLIBRARY_HEALTH_DISK_PATH", "")
+	healthDiskMinFree := config.GetEnvUint64("BOOKLIBRARY_HEALTH_DISK_MIN_FREE", 100<<20)
+	healthDiskWarnFree := config.GetEnvUint64("BOOKLIBRARY_HEALTH_DISK_WARN_FREE", 1<<30)
+	tracing := config.GetEnvBool("BOOKLIBRARY_TRACING", false)
+	otlpEndpoint := config.GetEnvString("BOOKLIBRARY_OTLP_ENDPOINT", "")
+	tlsCertFile := config.GetEnvString("BOOKLIBRARY_TLS_CERT_FILE", "")
+	tlsKeyFile := config.GetEnvString("BOOKLIBRARY_TLS_KEY_FILE", "")
+	tlsClientCAFile := config.GetEnvString("BOOKLIBRARY_TLS_CLIENT_CA_FILE", "")
+	tlsClientAuth := config.GetEnvString("BOOKLIBRARY_TLS_CLIENT_AUTH", "require")
+	tlsIdentity := config.GetEnvString("BOOKLIBRARY_TLS_IDENTITY", "cn")
+	tlsMinVersion := config.GetEnvString("BOOKLIBRARY_TLS_MIN_VERSION", "1.2")
+	tlsCipherSuites := config.GetEnvString("BOOKLIBRARY_TLS_CIPHER_SUITES", "")
+	tlsReloadInterval := config.GetEnvDuration("BOOKLIBRARY_TLS_RELOAD_INTERVAL", 30*time.Second)
+	preStopDelay := config.GetEnvDuration("BOOKLIBRARY_PRESTOP_DELAY", 5*time.Second)
+	shutdownTimeout := config.GetEnvDuration("BOOKLIBRARY_SHUTDOWN_TIMEOUT", 30*time.Second)
+	adminToken := config.GetEnvString("BOOKLIBRARY_ADMIN_TOKEN", "")
+	listsColl := config.GetEnvString("BOOKLIBRARY_LISTS_COLLECTION", "lists")
+	trustUserHeader := config.GetEnvBool("BOOKLIBRARY_TRUST_USER_HEADER", false)
+	loansColl := config.GetEnvString("BOOKLIBRARY_LOANS_COLLECTION", "loans")
+	copiesColl := config.GetEnvString("BOOKLIBRARY_COPIES_COLLECTION", "copies")
+	loanPeriod := config.GetEnvDuration("BOOKLIBRARY_LOAN_PERIOD", 21*24*time.Hour)
+	maxRenewals := config.GetEnvInt("BOOKLIBRARY_MAX_RENEWALS", 2)
+	idempotencyColl := config.GetEnvString("BOOKLIBRARY_IDEMPOTENCY_COLLECTION", "idempotency")
+	idempotencyTTL := config.GetEnvDuration("BOOKLIBRARY_IDEMPOTENCY_TTL", 24*time.Hour)
+	similarRebuild := config.GetEnvDuration("BOOKLIBRARY_SIMILAR_REBUILD_INTERVAL", 15*time.Minute)
 
 	flag.IntVar(&s.Port, "port", port, "HTTP port to listen on")
+	flag.IntVar(&s.GRPCPort, "grpcPort", grpcPort, "gRPC port to listen on (0 disables)")
 	flag.StringVar(&s.MongoURI, "mongoURI", mongoURI, "MongoDB URI to connect to")
 	flag.StringVar(&s.Db, "db", db, "MongoDB database")
 	flag.StringVar(&s.Collection, "collection", coll, "MongoDB collection")
 	flag.BoolVar(&s.Debug, "debug", debug, "Enable debug logging")
+	flag.StringVar(&s.LogFormat, "logFormat", logFormat, "Log format (text or json)")
+	flag.DurationVar(&s.ReadTimeout, "readTimeout", readTimeout, "HTTP server read timeout")
+	flag.DurationVar(&s.WriteTimeout, "writeTimeout", writeTimeout, "HTTP server write timeout")
+	flag.DurationVar(&s.IdleTimeout, "idleTimeout", idleTimeout, "HTTP server idle timeout")
+	flag.DurationVar(&s.MongoTimeout, "mongoTimeout", mongoTimeout, "MongoDB operation timeout")
+	flag.DurationVar(&s.MongoStartupTimeout, "mongoStartupTimeout", mongoStartupTimeout, "MongoDB connect timeout at startup")
+	flag.Uint64Var(&s.MongoMaxPoolSize, "mongoMaxPoolSize", mongoMaxPoolSize, "MongoDB connection pool size")
+	flag.DurationVar(&s.MongoMaxConnIdleTime, "mongoMaxConnIdleTime", mongoMaxConnIdleTime, "MongoDB connection max idle time (0 means no limit)")
+	flag.BoolVar(&s.MongoRetryWrites, "mongoRetryWrites", mongoRetryWrites, "Enable MongoDB retryable writes")
+	flag.BoolVar(&s.MongoRetryReads, "mongoRetryReads", mongoRetryReads, "Enable MongoDB retryable reads")
+	flag.IntVar(&s.RateLimitRead, "rateLimitRead", rateLimitRead, "Read requests per client and rate limit period (0 disables)")
+	flag.IntVar(&s.RateLimitWrite, "rateLimitWrite", rateLimitWrite, "Write requests per client and rate limit period (0 disables)")
+	flag.DurationVar(&s.RateLimitPeriod, "rateLimitPeriod", rateLimitPeriod, "Rate limit period")
+	flag.BoolVar(&s.RateLimitByAPIKey, "rateLimitByAPIKey", rateLimitByAPIKey, "Identify clients by X-API-Key header instead of IP address")
+	flag.BoolVar(&s.TrustProxy, "trustProxy", trustProxy, "Take client IP addresses from proxy headers")
+	flag.IntVar(&s.BreakerThreshold, "breakerThreshold", breakerThreshold, "Consecutive store failures that open the circuit breaker")
+	flag.DurationVar(&s.BreakerCooldown, "breakerCooldown", breakerCooldown, "Time the circuit breaker stays open before probing")
+	flag.IntVar(&s.ReadAttempts, "readAttempts", readAttempts, "Maximum attempts for reads failing with transient errors")
+	flag.DurationVar(&s.RetryBaseDelay, "retryBaseDelay", retryBaseDelay, "Base backoff between read attempts")
+	flag.DurationVar(&s.RetryMaxDelay, "retryMaxDelay", retryMaxDelay, "Maximum backoff between read attempts")
+	flag.IntVar(&s.CacheSize, "cacheSize", cacheSize, "Number of books and lists held in the response cache (0 disables)")
+	flag.DurationVar(&s.CacheTTL, "cacheTTL", cacheTTL, "Response cache entry lifetime")
+	flag.DurationVar(&s.HealthCacheTTL, "healthCacheTTL", healthCacheTTL, "Health check result cache duration")
+	flag.StringVar(&s.HealthDiskPath, "healthDiskPath", healthDiskPath, "Path whose free disk space is checked (empty disables)")
+	flag.Uint64Var(&s.HealthDiskMinFree, "healthDiskMinFree", healthDiskMinFree, "Free bytes below which the disk check fails")
+	flag.Uint64Var(&s.HealthDiskWarnFree, "healthDiskWarnFree", healthDiskWarnFree, "Free bytes below which the disk check is degraded")
+	flag.BoolVar(&s.Tracing, "tracing", tracing, "Enable OpenTelemetry tracing")
+	flag.StringVar(&s.OTLPEndpoint, "otlpEndpoint", otlpEndpoint, "OTLP/HTTP endpoint URL for traces (stdout if empty)")
+	flag.StringVar(&s.TLSCertFile, "tlsCertFile", tlsCertFile, "PEM file with the server certificate chain (empty serves plain HTTP)")
+	flag.StringVar(&s.TLSKeyFile, "tlsKeyFile", tlsKeyFile, "PEM file with the server certificate's private key")
+	flag.StringVar(&s.TLSClientCAFile, "tlsClientCAFile", tlsClientCAFile, "PEM file with CAs for verifying client certificates (empty disables mutual TLS)")
+	flag.StringVar(&s.TLSClientAuth, "tlsClientAuth", tlsClientAuth, "Client certificate policy with a client CA (require or optional)")
+	flag.StringVar(&s.TLSIdentity, "tlsIdentity", tlsIdentity, "Client certificate field used as identity (cn, subject, email, uri or dns)")
+	flag.StringVar(&s.TLSMinVersion, "tlsMinVersion", tlsMinVersion, "Minimum TLS version (1.2 or 1.3)")
+	flag.StringVar(&s.TLSCipherSuites, "tlsCipherSuites", tlsCipherSuites, "Comma-separated TLS 1.2 cipher suites (empty uses Go's defaults)")
+	flag.DurationVar(&s.TLSReloadInterval, "tlsReloadInterval", tlsReloadInterval, "Interval for checking certificate files for changes")
+	flag.DurationVar(&s.PreStopDelay, "preStopDelay", preStopDelay, "Time between failing readiness and draining connections on shutdown")
+	flag.DurationVar(&s.ShutdownTimeout, "shutdownTimeout", shutdownTimeout, "Maximum time for draining in-flight requests on shutdown")
+	flag.StringVar(&s.AdminToken, "adminToken", adminToken, "Bearer token for the /admin endpoints (empty disables them)")
+	flag.StringVar(&s.ListsCollection, "listsCollection", listsColl, "MongoDB collection of reading lists")
+	flag.BoolVar(&s.TrustUserHeader, "trustUserHeader", trustUserHeader, "Identify users by the X-User-ID header without a client certificate")
+	flag.StringVar(&s.LoansCollection, "loansCollection", loansColl, "MongoDB collection of loans")
+	flag.StringVar(&s.CopiesCollection, "copiesCollection", copiesColl, "MongoDB collection counting the copies of each book")
+	flag.DurationVar(&s.LoanPeriod, "loanPeriod", loanPeriod, "Default loan period and extension per renewal")
+	flag.IntVar(&s.MaxRenewals, "maxRenewals", maxRenewals, "Maximum number of renewals per loan")
+	flag.StringVar(&s.IdempotencyCollection, "idempotencyCollection", idempotencyColl, "MongoDB collection of idempotency keys and recorded responses")
+	flag.DurationVar(&s.IdempotencyTTL, "idempotencyTTL", idempotencyTTL, "Time responses to requests with an Idempotency-Key are kept for retries (0 disables)")
+	flag.DurationVar(&s.SimilarRebuildInterval, "similarRebuildInterval", similarRebuild, "Interval for rebuilding the similar books index from the database (0 only builds it at startup)")
 	flag.Parse()
 	return s
 }
 
-func newCrudService(uri, db, coll string) (*mongo.CrudService, error) {
-	slog.Debug("connecting to MongoDB", log.MongoURIKey, uri)
-	crud, err := mongo.NewCrudService(uri, db, coll)
+func newCrudService(s config.Settings, reg prometheus.Registerer) (*mongo.CrudService, error) {
+	slog.Debug("connecting to MongoDB", log.MongoURIKey, s.MongoURI)
+	crud, err := mongo.NewCrudService(s.MongoURI, s.Db, s.Collection,
+		mongo.WithTimeout(s.MongoTimeout),
+		mongo.WithStartupTimeout(s.MongoStartupTimeout),
+		mongo.WithMaxPoolSize(s.MongoMaxPoolSize),
+		mongo.WithMaxConnIdleTime(s.MongoMaxConnIdleTime),
+		mongo.WithRetryWrites(s.MongoRetryWrites),
+		mongo.WithRetryReads(s.MongoRetryReads),
+		mongo.WithRegisterer(reg),
+	)
 	if err != nil {
 		return nil, err
 	}
-	slog.Debug("connected to MongoDB", log.MongoURIKey, uri)
+	slog.Debug("connected to MongoDB", log.MongoURIKey, s.MongoURI)
 	return crud, nil
 }
+
+// newTLS returns the options for serving HTTPS with the configured certificate and for mapping
+// client certificates to identities, and the reloader that must run to pick up renewed certificates.
+func newTLS(s config.Settings) ([]webapi.Option, *tlsconfig.Reloader, error) {
+	minVersion, err := tlsconfig.ParseVersion(s.TLSMinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	suites, err := tlsconfig.ParseCipherSuites(s.TLSCipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsOpts := []tlsconfig.Option{
+		tlsconfig.WithMinVersion(minVersion),
+		tlsconfig.WithCipherSuites(suites),
+		tlsconfig.WithReloadInterval(s.TLSReloadInterval),
+	}
+	var opts []webapi.Option
+	if s.TLSClientCAFile != "" {
+		auth, err := tlsconfig.ParseClientAuth(s.TLSClientAuth)
+		if err != nil {
+			return nil, nil, err
+		}
+		mapper, err := tlsconfig.IdentityMapper(s.TLSIdentity)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsOpts = append(tlsOpts, tlsconfig.WithClientCA(s.TLSClientCAFile, auth))
+		opts = append(opts, webapi.WithClientIdentity(mapper))
+	}
+
+	reloader, err := tlsconfig.New(s.TLSCertFile, s.TLSKeyFile, tlsOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(opts, webapi.WithTLS(reloader.TLSConfig())), reloader, nil
+}
+
+func newLimiter(limit int, period time.Duration) ratelimit.Limiter {
+	if limit <= 0 || period <= 0 {
+		return nil
+	}
+	return ratelimit.NewMemory(limit, period)
+}
+
+func newHealth(s config.Settings, crud *mongo.CrudService, store *resilience.CrudService) *health.Registry {
+	h := health.NewRegistry(s.HealthCacheTTL)
+	h.Register("database", crud.Ping)
+	h.Register("mongodb_heartbeat", crud.CheckHeartbeat, health.NonCritical())
+	h.Register("circuit_breaker", store.CheckBreaker, health.NonCritical())
+	if s.HealthDiskPath != "" {
+		h.Register("disk", health.DiskSpace(s.HealthDiskPath, s.HealthDiskMinFree, s.HealthDiskWarnFree))
+	}
+	return h
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/client"
+)
+
+// generator sends requests for operations and tracks the books it created.
+type generator struct {
+	c         *client.Client
+	listLimit int
+	pool      *idPool
+}
+
+// do sends a request for op and returns the operation it performed. Operations that need an
+// existing book create one instead if none is left, which is reported as opCreate.
+func (g *generator) do(ctx context.Context, r *rand.Rand, op string) (string, error) {
+	switch op {
+	case opList:
+		_, err := g.c.List(ctx, g.listLimit)
+		return op, err
+	case opGet:
+		id, ok := g.pool.random(r)
+		if !ok {
+			return opCreate, g.create(ctx, r)
+		}
+		_, err := g.c.Get(ctx, id)
+		return op, err
+	case opCreate:
+		return op, g.create(ctx, r)
+	case opUpdate:
+		id, ok := g.pool.random(r)
+		if !ok {
+			return opCreate, g.create(ctx, r)
+		}
+		_, err := g.c.Update(ctx, id, book(r))
+		return op, err
+	case opDelete:
+		id, ok := g.pool.take(r)
+		if !ok {
+			return opCreate, g.create(ctx, r)
+		}
+		return op, g.c.Remove(ctx, id)
+	default:
+		return op, fmt.Errorf("unknown operation %q", op)
+	}
+}
+
+func (g *generator) create(ctx context.Context, r *rand.Rand) error {
+	b, err := g.c.Add(ctx, book(r))
+	if err != nil {
+		return err
+	}
+	g.pool.add(b.ID)
+	return nil
+}
+
+// cleanup deletes all books created by g that have not been deleted yet.
+func (g *generator) cleanup(stderr io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ids := g.pool.drain()
+	fmt.Fprintf(stderr, "deleting %d books\n", len(ids))
+	for _, id := range ids {
+		if err := g.c.Remove(ctx, id); err != nil && !errors.Is(err, client.ErrNotFound) {
+			fmt.Fprintf(stderr, "deleting book %s: %v\n", id, err)
+			return
+		}
+	}
+}
+
+func book(r *rand.Rand) client.Book {
+	n := r.IntN(1_000_000)
+	return client.Book{
+		Author:      fmt.Sprintf("Load Test Author %d", n%100),
+		Title:       fmt.Sprintf("Load Test Book %d", n),
+		ReleaseDate: time.Unix(r.Int64N(1_700_000_000), 0).UTC(),
+		Keywords:    []client.Keyword{{Value: "Load Test"}, {Value: fmt.Sprintf("Topic %d", n%10)}},
+	}
+}
+
+// idPool holds the IDs of books available for get, update and delete. It is safe for concurrent
+// use.
+type idPool struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (p *idPool) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = append(p.ids, id)
+}
+
+// random returns a random ID from the pool.
+func (p *idPool) random(r *rand.Rand) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return "", false
+	}
+	return p.ids[r.IntN(len(p.ids))], true
+}
+
+// take removes a random ID from the pool and returns it, so that no other request deletes it.
+func (p *idPool) take(r *rand.Rand) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return "", false
+	}
+	i := r.IntN(len(p.ids))
+	id := p.ids[i]
+	p.ids[i] = p.ids[len(p.ids)-1]
+	p.ids = p.ids[:len(p.ids)-1]
+	return id, true
+}
+
+func (p *idPool) drain() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := p.ids
+	p.ids = nil
+	return ids
+}
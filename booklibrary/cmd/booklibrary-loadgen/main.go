@@ -0,0 +1,212 @@
+// Command booklibrary-loadgen sends a mix of requests to the booklibrary API at a target rate and
+// reports throughput, error rates and latency percentiles per operation. Reports can be saved and
+// compared to catch performance regressions.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/client"
+	"github.com/joergjo/go-samples/booklibrary/internal/config"
+)
+
+type settings struct {
+	apiURL        string
+	apiKey        string
+	rps           float64
+	duration      time.Duration
+	workers       int
+	mix           mix
+	books         int
+	listLimit     int
+	timeout       time.Duration
+	out           string
+	baseline      string
+	maxRegression float64
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "compare" {
+		return compareReports(args[1:], stdout, stderr)
+	}
+
+	var s settings
+	var mixSpec string
+	fs := flag.NewFlagSet("booklibrary-loadgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&s.apiURL, "api", config.GetEnvString("BOOKLIBRARY_API_URL", "http://localhost:8000"), "Base URL of the booklibrary API")
+	fs.StringVar(&s.apiKey, "apiKey", config.GetEnvString("BOOKLIBRARY_API_KEY", ""), "API key sent in the X-API-Key header")
+	fs.Float64Var(&s.rps, "rps", 100, "Target requests per second")
+	fs.DurationVar(&s.duration, "duration", 30*time.Second, "Duration of the run")
+	fs.IntVar(&s.workers, "workers", 32, "Maximum number of concurrent requests")
+	fs.StringVar(&mixSpec, "mix", "list=40,get=40,create=10,update=5,delete=5", "Relative weights of operations")
+	fs.IntVar(&s.books, "books", 100, "Books created before the run for get, update and delete")
+	fs.IntVar(&s.listLimit, "listLimit", 20, "Limit of list requests")
+	fs.DurationVar(&s.timeout, "timeout", 5*time.Second, "Request timeout")
+	fs.StringVar(&s.out, "out", "", "Write the report as JSON to this file")
+	fs.StringVar(&s.baseline, "baseline", "", "Compare the run to the JSON report in this file")
+	fs.Float64Var(&s.maxRegression, "maxRegression", 0.1, "Relative p99 latency increase over the baseline that fails the run")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: booklibrary-loadgen [flags]\n       booklibrary-loadgen compare [-maxRegression f] <baseline.json> <report.json>\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	m, err := parseMix(mixSpec)
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+		return 2
+	}
+	s.mix = m
+	if s.rps <= 0 || s.workers < 1 || s.duration <= 0 {
+		fmt.Fprintln(stderr, "booklibrary-loadgen: -rps, -workers and -duration must be positive")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	rep, err := loadTest(ctx, s, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+		return 1
+	}
+	rep.print(stdout)
+	if s.out != "" {
+		if err := writeReport(s.out, rep); err != nil {
+			fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+			return 1
+		}
+	}
+	if s.baseline == "" {
+		return 0
+	}
+	base, err := readReport(s.baseline)
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout)
+	return verdict(compare(stdout, base, rep, s.maxRegression), stderr)
+}
+
+func compareReports(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	maxRegression := fs.Float64("maxRegression", 0.1, "Relative p99 latency increase that fails the comparison")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "Usage: booklibrary-loadgen compare [-maxRegression f] <baseline.json> <report.json>")
+		return 2
+	}
+	base, err := readReport(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+		return 1
+	}
+	cur, err := readReport(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary-loadgen: %v\n", err)
+		return 1
+	}
+	return verdict(compare(stdout, base, cur, *maxRegression), stderr)
+}
+
+// verdict reports regressed operations and returns the exit code.
+func verdict(regressed []string, stderr io.Writer) int {
+	if len(regressed) == 0 {
+		return 0
+	}
+	fmt.Fprintf(stderr, "booklibrary-loadgen: regression in %s\n", strings.Join(regressed, ", "))
+	return 1
+}
+
+// loadTest creates s.books books, sends requests at s.rps for s.duration, deletes the books it
+// created and reports on the requests sent in between.
+func loadTest(ctx context.Context, s settings, stderr io.Writer) (report, error) {
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: s.workers}}),
+		client.WithTimeout(s.timeout),
+		// Retries would hide errors and distort latencies.
+		client.WithRetries(0, 0, 0),
+		client.WithUserAgent("booklibrary-loadgen"),
+	}
+	if s.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(s.apiKey))
+	}
+	c, err := client.New(s.apiURL, opts...)
+	if err != nil {
+		return report{}, err
+	}
+
+	g := &generator{c: c, listLimit: s.listLimit, pool: &idPool{}}
+	fmt.Fprintf(stderr, "creating %d books\n", s.books)
+	r := rand.New(rand.NewPCG(0, 0))
+	for range s.books {
+		if err := g.create(ctx, r); err != nil {
+			return report{}, fmt.Errorf("creating books: %w", err)
+		}
+	}
+	defer g.cleanup(stderr)
+
+	fmt.Fprintf(stderr, "sending %.0f req/s for %v\n", s.rps, s.duration)
+	rec := newRecorder()
+	runCtx, cancel := context.WithTimeout(ctx, s.duration)
+	defer cancel()
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := range s.workers {
+		r := rand.New(rand.NewPCG(uint64(w), 1))
+		wg.Go(func() {
+			for op := range jobs {
+				start := time.Now()
+				done, err := g.do(ctx, r, op)
+				rec.record(done, time.Since(start), err)
+			}
+		})
+	}
+
+	// Requests are scheduled at a fixed rate regardless of how long earlier requests take. If all
+	// workers are busy, the request is dropped rather than delayed, so that a slow API shows up in
+	// the report instead of lowering the rate. Rates beyond one request per nanosecond are capped,
+	// since tickers need a positive interval.
+	interval := max(time.Duration(float64(time.Second)/s.rps), time.Nanosecond)
+	ticker := time.NewTicker(interval)
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-runCtx.Done():
+			break loop
+		case <-ticker.C:
+			select {
+			case jobs <- s.mix.pick(r):
+			default:
+				rec.drop()
+			}
+		}
+	}
+	ticker.Stop()
+	close(jobs)
+	wg.Wait()
+	return rec.report(start, time.Since(start), s.rps), nil
+}
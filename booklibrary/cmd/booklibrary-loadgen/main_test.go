@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/client"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{spec: "list=1", want: []string{opList}},
+		{spec: "list=50, get=30,delete=0", want: []string{opList, opGet, opDelete}},
+		{spec: "list", wantErr: true},
+		{spec: "borrow=1", wantErr: true},
+		{spec: "list=1,list=2", wantErr: true},
+		{spec: "list=-1", wantErr: true},
+		{spec: "list=0", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			m, err := parseMix(tc.spec)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseMix() error = %v, want error %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, m.ops); diff != "" {
+				t.Fatalf("Unexpected operations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMixPick(t *testing.T) {
+	m, err := parseMix("list=3,get=1,delete=0")
+	if err != nil {
+		t.Fatalf("parseMix() error = %v", err)
+	}
+	r := rand.New(rand.NewPCG(1, 1))
+	counts := make(map[string]int)
+	for range 4000 {
+		counts[m.pick(r)]++
+	}
+	if counts[opDelete] != 0 {
+		t.Fatalf("Picked operation with weight 0 %d times", counts[opDelete])
+	}
+	if got := float64(counts[opList]) / 4000; got < 0.7 || got > 0.8 {
+		t.Fatalf("Unexpected share of list operations, got %.2f, want about 0.75", got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var lat []time.Duration
+	for i := range 100 {
+		lat = append(lat, time.Duration(i+1)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Millisecond},
+		{p: 50, want: 50 * time.Millisecond},
+		{p: 99, want: 99 * time.Millisecond},
+		{p: 100, want: 100 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		if got := percentile(lat, tc.p); got != tc.want {
+			t.Errorf("percentile(%v) = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := report{Ops: map[string]opStats{
+		opGet:  {P99: 10, Throughput: 100},
+		opList: {P99: 20, Throughput: 100},
+	}}
+	cur := report{Ops: map[string]opStats{
+		opGet:  {P99: 10.5, Throughput: 100},
+		opList: {P99: 25, Throughput: 100, ErrorRate: 0.01},
+	}}
+	got := compare(io.Discard, base, cur, 0.1)
+	if diff := cmp.Diff([]string{opList}, got); diff != "" {
+		t.Fatalf("Unexpected regressions (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadTest(t *testing.T) {
+	crud := memory.NewCrudService()
+	srv := httptest.NewServer(webapi.NewMux(crud))
+	defer srv.Close()
+
+	m, err := parseMix("list=1,get=1,create=1,update=1,delete=1")
+	if err != nil {
+		t.Fatalf("parseMix() error = %v", err)
+	}
+	s := settings{
+		apiURL:    srv.URL,
+		rps:       200,
+		duration:  300 * time.Millisecond,
+		workers:   4,
+		mix:       m,
+		books:     10,
+		listLimit: 5,
+		timeout:   time.Second,
+	}
+	rep, err := loadTest(context.Background(), s, io.Discard)
+	if err != nil {
+		t.Fatalf("loadTest() error = %v", err)
+	}
+	total := rep.Ops[totalOp]
+	if total.Requests == 0 {
+		t.Fatal("No requests were sent")
+	}
+	if total.Errors != 0 {
+		t.Fatalf("Unexpected errors, got %d of %d requests", total.Errors, total.Requests)
+	}
+
+	// All books created during the run are deleted afterwards.
+	books, err := crud.List(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if diff := cmp.Diff([]model.Book{}, books); diff != "" {
+		t.Fatalf("Books left after run (-want +got):\n%s", diff)
+	}
+
+	// A saved report compares without regressions to itself.
+	file := filepath.Join(t.TempDir(), "report.json")
+	if err := writeReport(file, rep); err != nil {
+		t.Fatalf("writeReport() error = %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"compare", file, file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Unexpected exit code, got %d, want 0, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), totalOp) {
+		t.Fatalf("Comparison %q does not include total", stdout.String())
+	}
+}
+
+func TestGeneratorFallback(t *testing.T) {
+	srv := httptest.NewServer(webapi.NewMux(memory.NewCrudService()))
+	defer srv.Close()
+	c, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	g := &generator{c: c, listLimit: 5, pool: &idPool{}}
+	r := rand.New(rand.NewPCG(0, 0))
+
+	// Without books, operations on existing books create one and are recorded as creates.
+	for _, op := range []string{opGet, opUpdate, opDelete} {
+		done, err := g.do(context.Background(), r, op)
+		if err != nil {
+			t.Fatalf("Error sending %s request: %v", op, err)
+		}
+		if done != opCreate {
+			t.Errorf("Unexpected operation for %s without books, got %q, want %q", op, done, opCreate)
+		}
+		g.pool.drain()
+	}
+	if done, _ := g.do(context.Background(), r, opList); done != opList {
+		t.Errorf("Unexpected operation for %s, got %q, want %q", opList, done, opList)
+	}
+}
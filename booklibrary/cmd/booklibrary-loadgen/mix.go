@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	opList   = "list"
+	opGet    = "get"
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// operations are all operations in report order.
+var operations = []string{opList, opGet, opCreate, opUpdate, opDelete}
+
+// mix selects operations at random with relative weights.
+type mix struct {
+	ops     []string
+	weights []int
+	total   int
+}
+
+// parseMix parses weights like "list=50,get=30,create=10,update=5,delete=5". Operations that are
+// not listed are not run.
+func parseMix(s string) (mix, error) {
+	var m mix
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return mix{}, fmt.Errorf("operation %q has no weight", part)
+		}
+		if !slices.Contains(operations, name) {
+			return mix{}, fmt.Errorf("unknown operation %q", name)
+		}
+		if slices.Contains(m.ops, name) {
+			return mix{}, fmt.Errorf("operation %q given twice", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return mix{}, fmt.Errorf("invalid weight %q for operation %q", weight, name)
+		}
+		m.ops = append(m.ops, name)
+		m.weights = append(m.weights, w)
+		m.total += w
+	}
+	if m.total == 0 {
+		return mix{}, fmt.Errorf("mix %q has no operation with a positive weight", s)
+	}
+	return m, nil
+}
+
+// pick returns an operation with probability proportional to its weight.
+func (m mix) pick(r *rand.Rand) string {
+	n := r.IntN(m.total)
+	for i, w := range m.weights {
+		if n < w {
+			return m.ops[i]
+		}
+		n -= w
+	}
+	panic("unreachable")
+}
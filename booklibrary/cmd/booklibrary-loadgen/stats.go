@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// totalOp is the name under which statistics across all operations are reported.
+const totalOp = "total"
+
+// recorder collects the outcome of requests. It is safe for concurrent use.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	dropped   int
+}
+
+func newRecorder() *recorder {
+	return &recorder{latencies: make(map[string][]time.Duration), errors: make(map[string]int)}
+}
+
+func (r *recorder) record(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[op] = append(r.latencies[op], d)
+	if err != nil {
+		r.errors[op]++
+	}
+}
+
+// drop counts a request that was not sent because all workers were busy.
+func (r *recorder) drop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped++
+}
+
+// report is the result of a run. Latencies are given in milliseconds.
+type report struct {
+	Started   time.Time          `json:"started"`
+	Seconds   float64            `json:"seconds"`
+	TargetRPS float64            `json:"targetRps"`
+	Dropped   int                `json:"dropped"`
+	Ops       map[string]opStats `json:"ops"`
+}
+
+type opStats struct {
+	Requests   int     `json:"requests"`
+	Errors     int     `json:"errors"`
+	ErrorRate  float64 `json:"errorRate"`
+	Throughput float64 `json:"throughput"`
+	P50        float64 `json:"p50Ms"`
+	P90        float64 `json:"p90Ms"`
+	P99        float64 `json:"p99Ms"`
+	Max        float64 `json:"maxMs"`
+}
+
+// report summarizes the recorded requests of a run that started at start and took elapsed.
+func (r *recorder) report(start time.Time, elapsed time.Duration, targetRPS float64) report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := report{Started: start, Seconds: elapsed.Seconds(), TargetRPS: targetRPS, Dropped: r.dropped, Ops: make(map[string]opStats)}
+	var all []time.Duration
+	errs := 0
+	for op, lat := range r.latencies {
+		rep.Ops[op] = summarize(lat, r.errors[op], elapsed)
+		all = append(all, lat...)
+		errs += r.errors[op]
+	}
+	rep.Ops[totalOp] = summarize(all, errs, elapsed)
+	return rep
+}
+
+func summarize(lat []time.Duration, errs int, elapsed time.Duration) opStats {
+	s := opStats{Requests: len(lat), Errors: errs}
+	if len(lat) == 0 {
+		return s
+	}
+	lat = slices.Clone(lat)
+	slices.Sort(lat)
+	s.ErrorRate = float64(errs) / float64(len(lat))
+	s.Throughput = float64(len(lat)) / elapsed.Seconds()
+	s.P50 = millis(percentile(lat, 50))
+	s.P90 = millis(percentile(lat, 90))
+	s.P99 = millis(percentile(lat, 99))
+	s.Max = millis(lat[len(lat)-1])
+	return s
+}
+
+// percentile returns the nearest-rank pth percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// reportOps returns the operations in rep in report order, followed by the total.
+func reportOps(rep report) []string {
+	var ops []string
+	for _, op := range append(slices.Clone(operations), totalOp) {
+		if _, ok := rep.Ops[op]; ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func (rep report) print(w io.Writer) {
+	fmt.Fprintf(w, "%.1fs at target %.0f req/s, %d requests dropped\n\n", rep.Seconds, rep.TargetRPS, rep.Dropped)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OP\tREQUESTS\tERRORS\tREQ/S\tP50 MS\tP90 MS\tP99 MS\tMAX MS\t")
+	for _, op := range reportOps(rep) {
+		s := rep.Ops[op]
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			op, s.Requests, s.ErrorRate*100, s.Throughput, s.P50, s.P90, s.P99, s.Max)
+	}
+	tw.Flush()
+}
+
+func writeReport(name string, rep report) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+func readReport(name string) (report, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return report{}, err
+	}
+	var rep report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return report{}, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return rep, nil
+}
+
+// compare prints the change from base to cur per operation and returns the operations whose p99
+// latency grew by more than maxRegression (e.g. 0.1 for 10%) or whose error rate increased.
+func compare(w io.Writer, base, cur report, maxRegression float64) []string {
+	var regressed []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OP\tREQ/S\tP50 MS\tP99 MS\tERRORS\t")
+	for _, op := range reportOps(cur) {
+		b, ok := base.Ops[op]
+		if !ok {
+			continue
+		}
+		c := cur.Ops[op]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.2f%% → %.2f%%\t\n", op,
+			change(b.Throughput, c.Throughput), change(b.P50, c.P50), change(b.P99, c.P99), b.ErrorRate*100, c.ErrorRate*100)
+		if c.P99 > b.P99*(1+maxRegression) || c.ErrorRate > b.ErrorRate {
+			regressed = append(regressed, op)
+		}
+	}
+	tw.Flush()
+	return regressed
+}
+
+func change(base, cur float64) string {
+	if base == 0 {
+		return fmt.Sprintf("%.2f", cur)
+	}
+	return fmt.Sprintf("%.2f (%+.1f%%)", cur, (cur-base)/base*100)
+}
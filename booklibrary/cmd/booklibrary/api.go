@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joergjo/go-samples/booklibrary/client"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// apiPageSize is the number of books requested per page when filtering through the API.
+const apiPageSize = 100
+
+// Compile-time check to verify we implement Storage
+var _ model.CrudService = apiService{}
+
+// apiService adapts a client to model.CrudService, so that commands work the same against the API
+// and MongoDB.
+type apiService struct {
+	c *client.Client
+}
+
+func (s apiService) List(ctx context.Context, limit int) ([]model.Book, error) {
+	return modelBooks(s.c.List(ctx, limit))
+}
+
+// Find pages through the API and applies filter locally, since the REST API only pages by ID.
+func (s apiService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	if filter == (model.Filter{After: filter.After, Limit: filter.Limit}) && filter.Limit > 0 {
+		return modelBooks(s.c.ListAfter(ctx, filter.After, filter.Limit))
+	}
+	books := []model.Book{}
+	after := filter.After
+	for {
+		page, err := modelBooks(s.c.ListAfter(ctx, after, apiPageSize))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range page {
+			if !filter.Match(b) {
+				continue
+			}
+			books = append(books, b)
+			if len(books) == filter.Limit {
+				return books, nil
+			}
+		}
+		if len(page) < apiPageSize {
+			return books, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+func (s apiService) Get(ctx context.Context, id string) (model.Book, error) {
+	return modelBook(s.c.Get(ctx, id))
+}
+
+func (s apiService) Add(ctx context.Context, b model.Book) (model.Book, error) {
+	return modelBook(s.c.Add(ctx, clientBook(b)))
+}
+
+func (s apiService) Update(ctx context.Context, id string, b model.Book) (model.Book, error) {
+	return modelBook(s.c.Update(ctx, id, clientBook(b)))
+}
+
+// Remove deletes the book with the given ID. The API does not return deleted books, so only the
+// ID of the returned book is set.
+func (s apiService) Remove(ctx context.Context, id string) (model.Book, error) {
+	if err := s.c.Remove(ctx, id); err != nil {
+		return model.Book{}, storeError(err)
+	}
+	return model.Book{ID: id}, nil
+}
+
+// Ping checks that the API can list books.
+func (s apiService) Ping(ctx context.Context) error {
+	_, err := s.c.List(ctx, 1)
+	return storeError(err)
+}
+
+// modelBook converts a book returned by the client to a model.Book.
+func modelBook(b client.Book, err error) (model.Book, error) {
+	if err != nil {
+		return model.Book{}, storeError(err)
+	}
+	kws := make([]model.Keyword, len(b.Keywords))
+	for i, kw := range b.Keywords {
+		kws[i] = model.Keyword{Value: kw.Value}
+	}
+	mb := model.Book{ID: b.ID, Author: b.Author, Title: b.Title, ReleaseDate: b.ReleaseDate, Keywords: kws}
+	if a := b.Availability; a != nil {
+		mb.Availability = &model.Availability{Copies: a.Copies, OnLoan: a.OnLoan, Available: a.Available}
+	}
+	return mb, nil
+}
+
+// modelBooks converts books returned by the client to model.Books.
+func modelBooks(bs []client.Book, err error) ([]model.Book, error) {
+	if err != nil {
+		return nil, storeError(err)
+	}
+	mbs := make([]model.Book, len(bs))
+	for i, b := range bs {
+		mbs[i], _ = modelBook(b, nil)
+	}
+	return mbs, nil
+}
+
+// clientBook converts b to the client's representation. The server assigns availability, so it is
+// not sent.
+func clientBook(b model.Book) client.Book {
+	kws := make([]client.Keyword, len(b.Keywords))
+	for i, kw := range b.Keywords {
+		kws[i] = client.Keyword{Value: kw.Value}
+	}
+	return client.Book{ID: b.ID, Author: b.Author, Title: b.Title, ReleaseDate: b.ReleaseDate, Keywords: kws}
+}
+
+// storeError maps client errors to the errors of model.CrudService, keeping the client error.
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return fmt.Errorf("%w: %w", model.ErrNotFound, err)
+	case errors.Is(err, client.ErrUnavailable):
+		return fmt.Errorf("%w: %w", model.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
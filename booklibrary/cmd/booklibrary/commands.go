@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/catalog"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// exportPageSize is the number of books read per request when exporting the library.
+const exportPageSize = 100
+
+// app is the environment commands run in.
+type app struct {
+	crud   model.CrudService
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+	output string
+}
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, a app, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"list":   {summary: "List books, optionally filtered", run: list},
+		"get":    {summary: "Show books by ID", run: get},
+		"add":    {summary: "Add a book", run: add},
+		"update": {summary: "Change fields of a book", run: update},
+		"delete": {summary: "Delete books by ID", run: remove},
+		"import": {summary: "Add books from a JSON, CSV, MARC or BibTeX file", run: importBooks},
+		"export": {summary: "Write all books to a JSON, CSV or MARCXML file", run: exportBooks},
+		"seed":   {summary: "Add books from a JSON or YAML fixture and synthetic books, unless present", run: seed},
+	}
+}
+
+// newFlagSet returns a flag set for the named command that prints its usage to a.errOut.
+func (a app) newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(a.errOut, "Usage: booklibrary %s [flags] %s\n\n%s.\n", name, args, commands[name].summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args with fs and checks that at least minArgs arguments remain. It returns
+// flag.ErrHelp if help was requested.
+func parse(fs *flag.FlagSet, args []string, minArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() < minArgs {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func list(ctx context.Context, a app, args []string) error {
+	var filter model.Filter
+	var after, before string
+	fs := a.newFlagSet("list", "")
+	fs.IntVar(&filter.Limit, "limit", 100, "Maximum number of books (0 lists all)")
+	fs.StringVar(&filter.Author, "author", "", "Only books by this author (case-insensitive)")
+	fs.StringVar(&filter.Title, "title", "", "Only books whose title contains this text (case-insensitive)")
+	fs.StringVar(&filter.Keyword, "keyword", "", "Only books with this keyword")
+	fs.StringVar(&after, "releasedAfter", "", "Only books released at or after this date")
+	fs.StringVar(&before, "releasedBefore", "", "Only books released before this date")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	var err error
+	if filter.ReleasedAfter, err = parseOptionalDate(after); err != nil {
+		return err
+	}
+	if filter.ReleasedBefore, err = parseOptionalDate(before); err != nil {
+		return err
+	}
+
+	w := writers[a.output](a.out, false)
+	if filter.Limit > 0 {
+		books, err := model.Find(ctx, a.crud, filter)
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			if err := w.write(b); err != nil {
+				return err
+			}
+		}
+		return w.close()
+	}
+	if err := all(ctx, a.crud, filter, w.write); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+func get(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("get", "<id>...")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	w := writers[a.output](a.out, fs.NArg() == 1)
+	for _, id := range fs.Args() {
+		b, err := a.crud.Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("getting book %s: %w", id, err)
+		}
+		if err := w.write(b); err != nil {
+			return err
+		}
+	}
+	return w.close()
+}
+
+func add(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("add", "")
+	var b model.Book
+	bookFlags(fs, &b)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if b.Title == "" {
+		return fmt.Errorf("a title is required")
+	}
+	added, err := a.crud.Add(ctx, b)
+	if err != nil {
+		return fmt.Errorf("adding book: %w", err)
+	}
+	return writeOne(a, added)
+}
+
+func update(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("update", "<id>")
+	var changes model.Book
+	bookFlags(fs, &changes)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	id := fs.Arg(0)
+	b, err := a.crud.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting book %s: %w", id, err)
+	}
+	// Only fields given on the command line are changed.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "author":
+			b.Author = changes.Author
+		case "title":
+			b.Title = changes.Title
+		case "released":
+			b.ReleaseDate = changes.ReleaseDate
+		case "keyword":
+			b.Keywords = changes.Keywords
+		}
+	})
+	updated, err := a.crud.Update(ctx, id, b)
+	if err != nil {
+		return fmt.Errorf("updating book %s: %w", id, err)
+	}
+	return writeOne(a, updated)
+}
+
+func remove(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("delete", "<id>...")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	for _, id := range fs.Args() {
+		if _, err := a.crud.Remove(ctx, id); err != nil {
+			return fmt.Errorf("deleting book %s: %w", id, err)
+		}
+		fmt.Fprintln(a.out, id)
+	}
+	return nil
+}
+
+func importBooks(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("import", "[file]")
+	format := fs.String("format", "", "Input format (json, csv, marc, marcxml or bibtex), derived from the file extension if empty")
+	dryRun := fs.Bool("dry-run", false, "Report mapping warnings without adding books")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	r, closeFn, err := openInput(a.in, name)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	records, err := readRecords(fileFormat(*format, name), r)
+	if err != nil {
+		return err
+	}
+	n, skipped := 0, 0
+	for i, rec := range records {
+		ref := fmt.Sprintf("record %d", i+1)
+		if rec.Ref != "" {
+			ref += " (" + rec.Ref + ")"
+		}
+		for _, w := range rec.Warnings {
+			fmt.Fprintf(a.errOut, "%s: %s\n", ref, w)
+		}
+		if rec.Err != nil {
+			fmt.Fprintf(a.errOut, "%s: skipped: %v\n", ref, rec.Err)
+			skipped++
+			continue
+		}
+		if *dryRun {
+			n++
+			continue
+		}
+		// Imported books are assigned new IDs.
+		b := rec.Book
+		b.ID = ""
+		if _, err := a.crud.Add(ctx, b); err != nil {
+			return fmt.Errorf("adding %s (%q) after importing %d books: %w", ref, b.Title, n, err)
+		}
+		n++
+	}
+	if *dryRun {
+		fmt.Fprintf(a.errOut, "would import %d books, skipping %d\n", n, skipped)
+		return nil
+	}
+	fmt.Fprintf(a.errOut, "imported %d books, skipped %d\n", n, skipped)
+	return nil
+}
+
+func exportBooks(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("export", "[file]")
+	format := fs.String("format", "", "Output format (json, csv or marcxml), derived from the file extension if empty")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	f := fileFormat(*format, name)
+	newWriter, ok := writers[f]
+	if !ok || f == "table" {
+		return fmt.Errorf("unknown export format %q", f)
+	}
+
+	out := a.out
+	if name != "" && name != "-" {
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	w := newWriter(out, false)
+	n := 0
+	err := all(ctx, a.crud, model.Filter{}, func(b model.Book) error {
+		n++
+		return w.write(b)
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.errOut, "exported %d books\n", n)
+	return nil
+}
+
+// all calls fn for every book matching filter, reading the store in pages ordered by ID. The
+// filter's After and Limit are ignored.
+func all(ctx context.Context, crud model.CrudService, filter model.Filter, fn func(model.Book) error) error {
+	filter.After, filter.Limit = "", exportPageSize
+	for {
+		books, err := model.Find(ctx, crud, filter)
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+		if len(books) < filter.Limit {
+			return nil
+		}
+		filter.After = books[len(books)-1].ID
+	}
+}
+
+// bookFlags defines the flags that set the fields of b.
+func bookFlags(fs *flag.FlagSet, b *model.Book) {
+	fs.StringVar(&b.Author, "author", "", "Author")
+	fs.StringVar(&b.Title, "title", "", "Title")
+	fs.Func("released", "Release date as YYYY-MM-DD or RFC 3339 timestamp", func(s string) error {
+		t, err := model.ParseDate(s)
+		b.ReleaseDate = t
+		return err
+	})
+	fs.Func("keyword", "Keyword, may be repeated", func(s string) error {
+		b.Keywords = append(b.Keywords, model.Keyword{Value: s})
+		return nil
+	})
+}
+
+func writeOne(a app, b model.Book) error {
+	w := writers[a.output](a.out, true)
+	if err := w.write(b); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+func parseOptionalDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return model.ParseDate(s)
+}
+
+// openInput returns the named file, or in if name is empty or "-".
+func openInput(in io.Reader, name string) (io.Reader, func(), error) {
+	if name == "" || name == "-" {
+		return in, func() {}, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// extFormats maps file extensions to formats.
+var extFormats = map[string]string{
+	".csv":  "csv",
+	".mrc":  catalog.MARC,
+	".marc": catalog.MARC,
+	".xml":  catalog.MARCXML,
+	".bib":  catalog.BibTeX,
+}
+
+// fileFormat returns format, or else the format implied by name's extension, or JSON.
+func fileFormat(format, name string) string {
+	if format != "" {
+		return format
+	}
+	if f, ok := extFormats[strings.ToLower(filepath.Ext(name))]; ok {
+		return f
+	}
+	return "json"
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/catalog"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// fileDateFormat is the release date format of JSON and CSV output. Input accepts any format.
+const fileDateFormat = model.DateRFC3339
+
+// csvHeader matches the columns of the API's text/csv representation.
+var csvHeader = []string{"id", "author", "title", "releaseDate", "keywords"}
+
+// bookWriter writes books one at a time in an output format. close must be called after the last
+// book.
+type bookWriter interface {
+	write(b model.Book) error
+	close() error
+}
+
+// writers create bookWriters by output format. With single set, a writer may render a single book
+// differently from a list, e.g. as a JSON object instead of an array.
+var writers = map[string]func(w io.Writer, single bool) bookWriter{
+	"table":         func(w io.Writer, _ bool) bookWriter { return newTableWriter(w) },
+	"json":          func(w io.Writer, single bool) bookWriter { return &jsonWriter{w: w, single: single} },
+	"csv":           func(w io.Writer, _ bool) bookWriter { return &csvWriter{w: csv.NewWriter(w)} },
+	catalog.MARCXML: func(w io.Writer, _ bool) bookWriter { return marcxmlWriter{catalog.NewMARCXMLWriter(w)} },
+}
+
+// readers parse files of books by format. Catalogue formats are read by readRecords.
+var readers = map[string]func(r io.Reader) ([]model.Book, error){
+	"json": readJSON,
+	"csv":  readCSV,
+}
+
+// readRecords reads a file of books, or a catalogue in one of the catalog formats, in format f.
+// Only catalogue records carry mapping warnings and errors.
+func readRecords(f string, r io.Reader) ([]catalog.Record, error) {
+	if read, ok := readers[f]; ok {
+		books, err := read(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading books: %w", err)
+		}
+		records := make([]catalog.Record, len(books))
+		for i, b := range books {
+			records[i] = catalog.Record{Book: b}
+		}
+		return records, nil
+	}
+	records, err := catalog.Read(f, r)
+	if errors.Is(err, catalog.ErrUnknownFormat) {
+		return nil, fmt.Errorf("unknown input format %q", f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading catalogue: %w", err)
+	}
+	return records, nil
+}
+
+type tableWriter struct {
+	tw *tabwriter.Writer
+}
+
+func newTableWriter(w io.Writer) *tableWriter {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tAUTHOR\tTITLE\tRELEASED\tKEYWORDS")
+	return &tableWriter{tw: tw}
+}
+
+func (t *tableWriter) write(b model.Book) error {
+	released := ""
+	if !b.ReleaseDate.IsZero() {
+		released = b.ReleaseDate.UTC().Format(time.DateOnly)
+	}
+	_, err := fmt.Fprintf(t.tw, "%s\t%s\t%s\t%s\t%s\n", b.ID, b.Author, b.Title, released, keywords(b, ", "))
+	return err
+}
+
+func (t *tableWriter) close() error {
+	return t.tw.Flush()
+}
+
+// jsonWriter streams books as an indented JSON array, or a single book as an object.
+type jsonWriter struct {
+	w      io.Writer
+	single bool
+	n      int
+}
+
+func (j *jsonWriter) write(b model.Book) error {
+	prefix := "  "
+	if j.single {
+		prefix = ""
+	}
+	data, err := json.MarshalIndent(b.View(fileDateFormat), prefix, "  ")
+	if err != nil {
+		return err
+	}
+	switch {
+	case j.single:
+	case j.n == 0:
+		data = append([]byte("[\n  "), data...)
+	default:
+		data = append([]byte(",\n  "), data...)
+	}
+	j.n++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) close() error {
+	var err error
+	switch {
+	case j.single:
+		_, err = io.WriteString(j.w, "\n")
+	case j.n == 0:
+		_, err = io.WriteString(j.w, "[]\n")
+	default:
+		_, err = io.WriteString(j.w, "\n]\n")
+	}
+	return err
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvWriter) write(b model.Book) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{b.ID, b.Author, b.Title, fileDateFormat.Format(b.ReleaseDate), keywords(b, ";")})
+}
+
+func (c *csvWriter) close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write(csvHeader)
+}
+
+type marcxmlWriter struct {
+	mw *catalog.MARCXMLWriter
+}
+
+func (m marcxmlWriter) write(b model.Book) error { return m.mw.Write(b) }
+
+func (m marcxmlWriter) close() error { return m.mw.Close() }
+
+func keywords(b model.Book, sep string) string {
+	kw := make([]string, len(b.Keywords))
+	for i, k := range b.Keywords {
+		kw[i] = k.Value
+	}
+	return strings.Join(kw, sep)
+}
+
+// readJSON reads a JSON array of books.
+func readJSON(r io.Reader) ([]model.Book, error) {
+	var views []model.BookView
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&views); err != nil {
+		return nil, err
+	}
+	books := make([]model.Book, len(views))
+	for i, v := range views {
+		books[i] = v.Book
+	}
+	return books, nil
+}
+
+// readCSV reads books from CSV with a header row naming the columns as in csvHeader. Columns may
+// appear in any order and all but title may be omitted. Release dates may be given as Unix time
+// in seconds, RFC 3339 timestamps or dates.
+func readCSV(r io.Reader) ([]model.Book, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		return nil, errors.New("CSV header has no title column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var books []model.Book
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return books, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := model.Book{
+			Author: field(rec, "author"),
+			Title:  field(rec, "title"),
+		}
+		if b.ReleaseDate, err = parseFileDate(field(rec, "releaseDate")); err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		for _, k := range strings.Split(field(rec, "keywords"), ";") {
+			if k = strings.TrimSpace(k); k != "" {
+				b.Keywords = append(b.Keywords, model.Keyword{Value: k})
+			}
+		}
+		books = append(books, b)
+	}
+}
+
+// parseFileDate parses a release date given as Unix time in seconds, an RFC 3339 timestamp or a
+// date. An empty s is the zero time.
+func parseFileDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return model.ParseDate(s)
+}
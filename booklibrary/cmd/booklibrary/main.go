@@ -0,0 +1,145 @@
+// Command booklibrary administers the book library from the command line, either through a running
+// booklibrary-api or directly against its MongoDB database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/client"
+	"github.com/joergjo/go-samples/booklibrary/internal/config"
+	"github.com/joergjo/go-samples/booklibrary/internal/integrity"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/mongo"
+)
+
+// errUsage reports invalid command line arguments. The usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+type settings struct {
+	apiURL     string
+	apiKey     string
+	mongoURI   string
+	db         string
+	collection string
+	lists      string
+	loans      string
+	copies     string
+	output     string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var s settings
+	fs := flag.NewFlagSet("booklibrary", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&s.apiURL, "api", config.GetEnvString("BOOKLIBRARY_API_URL", ""), "Base URL of a booklibrary API to use instead of MongoDB")
+	fs.StringVar(&s.apiKey, "apiKey", config.GetEnvString("BOOKLIBRARY_API_KEY", ""), "API key sent to the API in the X-API-Key header")
+	fs.StringVar(&s.mongoURI, "mongoURI", config.GetEnvString("BOOKLIBRARY_MONGOURI", "mongodb://localhost/?timeoutMS=0"), "MongoDB URI to connect to")
+	fs.StringVar(&s.db, "db", config.GetEnvString("BOOKLIBRARY_DB", "library_database"), "MongoDB database")
+	fs.StringVar(&s.collection, "collection", config.GetEnvString("BOOKLIBRARY_COLLECTION", "books"), "MongoDB collection")
+	fs.StringVar(&s.lists, "listsCollection", config.GetEnvString("BOOKLIBRARY_LISTS_COLLECTION", "lists"), "MongoDB collection of reading lists, from which deleted books are removed")
+	fs.StringVar(&s.loans, "loansCollection", config.GetEnvString("BOOKLIBRARY_LOANS_COLLECTION", "loans"), "MongoDB collection of loans")
+	fs.StringVar(&s.copies, "copiesCollection", config.GetEnvString("BOOKLIBRARY_COPIES_COLLECTION", "copies"), "MongoDB collection of copies, which keep books on loan from being deleted and are removed with deleted books")
+	fs.StringVar(&s.output, "o", "table", "Output format (table, json, csv or marcxml)")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		usage(fs)
+		return 2
+	}
+	name, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "booklibrary: unknown command %q\n", name)
+		usage(fs)
+		return 2
+	}
+	if _, ok := writers[s.output]; !ok {
+		fmt.Fprintf(stderr, "booklibrary: unknown output format %q\n", s.output)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	crud, closeFn, err := open(s)
+	if err != nil {
+		fmt.Fprintf(stderr, "booklibrary: %v\n", err)
+		return 1
+	}
+	defer closeFn()
+
+	a := app{crud: crud, in: stdin, out: stdout, errOut: stderr, output: s.output}
+	if err := cmd.run(ctx, a, cmdArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "booklibrary: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// open returns the store selected by s and a function that releases it.
+func open(s settings) (model.CrudService, func(), error) {
+	if s.apiURL != "" {
+		var opts []client.Option
+		if s.apiKey != "" {
+			opts = append(opts, client.WithAPIKey(s.apiKey))
+		}
+		c, err := client.New(s.apiURL, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return apiService{c: c}, func() {}, nil
+	}
+
+	crud, err := mongo.NewCrudService(s.mongoURI, s.db, s.collection)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to MongoDB: %w", err)
+	}
+	closeFn := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = crud.Close(ctx)
+	}
+	lists := mongo.NewListService(crud, s.lists)
+	loans := mongo.NewLoanService(crud, s.loans, s.copies)
+	return integrity.NewCrudService(crud, lists, loans), closeFn, nil
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "Usage: booklibrary [flags] <command> [command flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\nWithout -api, the tool connects to MongoDB directly. Run 'booklibrary <command> -h' for command flags.")
+}
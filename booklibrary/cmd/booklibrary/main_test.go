@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+// cli runs the command line against an API served from an in-memory store.
+type cli struct {
+	t   *testing.T
+	url string
+}
+
+func newCLI(t *testing.T) cli {
+	t.Helper()
+	srv := httptest.NewServer(webapi.NewMux(memory.NewCrudService()))
+	t.Cleanup(srv.Close)
+	return cli{t: t, url: srv.URL}
+}
+
+// run runs the command line with args and stdin, fails the test if it exits with another code
+// than want, and returns stdout.
+func (c cli) run(want int, stdin string, args ...string) string {
+	c.t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-api", c.url}, args...)
+	if got := run(args, strings.NewReader(stdin), &stdout, &stderr); got != want {
+		c.t.Fatalf("Unexpected exit code for %v, got %d, want %d, stderr: %s", args, got, want, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	c := newCLI(t)
+
+	var added model.BookView
+	out := c.run(0, "", "-o", "json", "add", "-author", "Jane Doe", "-title", "Scripting Go", "-released", "2021-06-01", "-keyword", "Go", "-keyword", "CLI")
+	if err := json.Unmarshal([]byte(out), &added); err != nil {
+		t.Fatalf("Error unmarshaling added book %q: %v", out, err)
+	}
+	want := model.Book{
+		ID:          added.ID,
+		Author:      "Jane Doe",
+		Title:       "Scripting Go",
+		ReleaseDate: time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Keywords:    []model.Keyword{{Value: "Go"}, {Value: "CLI"}},
+	}
+	if diff := cmp.Diff(want, added.Book); diff != "" {
+		t.Fatalf("Added book mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only the given fields are updated.
+	c.run(0, "", "update", "-title", "Scripting Go, 2nd Edition", added.ID)
+	want.Title = "Scripting Go, 2nd Edition"
+	var got model.BookView
+	if err := json.Unmarshal([]byte(c.run(0, "", "-o", "json", "get", added.ID)), &got); err != nil {
+		t.Fatalf("Error unmarshaling book: %v", err)
+	}
+	if diff := cmp.Diff(want, got.Book); diff != "" {
+		t.Fatalf("Updated book mismatch (-want +got):\n%s", diff)
+	}
+
+	table := c.run(0, "", "list", "-keyword", "CLI")
+	for _, s := range []string{"ID", added.ID, "Jane Doe", "2021-06-01", "Go, CLI"} {
+		if !strings.Contains(table, s) {
+			t.Fatalf("Table %q does not contain %q", table, s)
+		}
+	}
+	if table := c.run(0, "", "list", "-author", "John Doe"); strings.Contains(table, added.ID) {
+		t.Fatalf("Table %q unexpectedly contains filtered book", table)
+	}
+
+	if out := c.run(0, "", "delete", added.ID); strings.TrimSpace(out) != added.ID {
+		t.Fatalf("Unexpected delete output, got %q, want %q", out, added.ID)
+	}
+	c.run(1, "", "get", added.ID)
+}
+
+func TestImportExport(t *testing.T) {
+	c := newCLI(t)
+	in := "title,author,keywords,releaseDate\n" +
+		"\"Go, the Language\",Jane Doe,Go;Languages,2015-10-26\n" +
+		"Unix Time,John Doe,,1700000000\n"
+	c.run(0, in, "import", "-format", "csv")
+
+	out := c.run(0, "", "export", "-format", "csv")
+	want := "id,author,title,releaseDate,keywords\n"
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || lines[0]+"\n" != want {
+		t.Fatalf("Unexpected CSV export %q", out)
+	}
+	if !strings.HasSuffix(lines[1], `,Jane Doe,"Go, the Language",2015-10-26T00:00:00Z,Go;Languages`) {
+		t.Fatalf("Unexpected CSV row %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], ",John Doe,Unix Time,2023-11-14T22:13:20Z,") {
+		t.Fatalf("Unexpected CSV row %q", lines[2])
+	}
+
+	// A JSON export can be imported into another library.
+	file := filepath.Join(t.TempDir(), "books.json")
+	c.run(0, "", "export", file)
+	other := newCLI(t)
+	other.run(0, "", "import", file)
+
+	var before, after []model.BookView
+	if err := json.Unmarshal([]byte(c.run(0, "", "-o", "json", "list")), &before); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	if err := json.Unmarshal([]byte(other.run(0, "", "-o", "json", "list")), &after); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	ignoreID := cmpopts.IgnoreFields(model.Book{}, "ID")
+	if diff := cmp.Diff(before, after, ignoreID); diff != "" {
+		t.Fatalf("Imported books mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportCatalogue(t *testing.T) {
+	c := newCLI(t)
+	bib := `@book{gopl, author = {Donovan, Alan A. A.}, title = {The {Go} Programming Language},
+  year = 2015, month = oct, keywords = {Go}}
+@book{untitled, author = {Jane Doe}}
+@misc{gophers, title = {Gophers}, keywords = {Go}}
+`
+	var stdout, stderr bytes.Buffer
+	args := []string{"-api", c.url, "import", "-format", "bibtex", "-dry-run"}
+	if got := run(args, strings.NewReader(bib), &stdout, &stderr); got != 0 {
+		t.Fatalf("Unexpected exit code, got %d, want 0, stderr: %s", got, stderr.String())
+	}
+	for _, s := range []string{
+		"record 2 (untitled): skipped: record has no title",
+		`record 3 (gophers): entry type "misc" is not a book`,
+		"would import 2 books, skipping 1",
+	} {
+		if !strings.Contains(stderr.String(), s) {
+			t.Errorf("Dry run output %q does not contain %q", stderr.String(), s)
+		}
+	}
+	if out := c.run(0, "", "-o", "csv", "list"); strings.Count(out, "\n") != 1 {
+		t.Fatalf("Dry run added books: %q", out)
+	}
+
+	// A MARCXML export can be imported into another library.
+	c.run(0, bib, "import", "-format", "bibtex")
+	file := filepath.Join(t.TempDir(), "books.xml")
+	c.run(0, "", "export", file)
+	other := newCLI(t)
+	other.run(0, "", "import", file)
+
+	var before, after []model.BookView
+	if err := json.Unmarshal([]byte(c.run(0, "", "-o", "json", "list")), &before); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	if err := json.Unmarshal([]byte(other.run(0, "", "-o", "json", "list")), &after); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	if len(before) != 2 {
+		t.Fatalf("Unexpected number of imported books, got %d, want 2", len(before))
+	}
+	ignoreID := cmpopts.IgnoreFields(model.Book{}, "ID")
+	if diff := cmp.Diff(before, after, ignoreID); diff != "" {
+		t.Fatalf("Imported books mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	crud := memory.NewCrudService()
+	for range exportPageSize + 5 {
+		if _, err := crud.Add(context.Background(), model.Book{Title: "Paging"}); err != nil {
+			t.Fatalf("Error adding book: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	a := app{crud: crud, out: &out, errOut: os.Stderr, output: "csv"}
+	if err := list(context.Background(), a, []string{"-limit", "0"}); err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	// One header row and one row per book.
+	if got, want := strings.Count(out.String(), "\n"), exportPageSize+6; got != want {
+		t.Fatalf("Unexpected number of lines, got %d, want %d", got, want)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no_command", args: nil, want: 2},
+		{name: "unknown_command", args: []string{"borrow"}, want: 2},
+		{name: "unknown_output", args: []string{"-o", "yaml", "list"}, want: 2},
+		{name: "missing_id", args: []string{"get"}, want: 2},
+		{name: "help", args: []string{"-h"}, want: 0},
+		{name: "command_help", args: []string{"add", "-h"}, want: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-api", "http://localhost:1"}, tc.args...)
+			if got := run(args, strings.NewReader(""), &stdout, &stderr); got != tc.want {
+				t.Fatalf("Unexpected exit code, got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeed(t *testing.T) {
+	c := newCLI(t)
+	fixture := filepath.Join("..", "..", "fixtures", "books.yaml")
+	c.run(0, "", "seed", "-generate", "25", fixture)
+
+	var first []model.BookView
+	if err := json.Unmarshal([]byte(c.run(0, "", "-o", "json", "list", "-limit", "0")), &first); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	if len(first) != 35 {
+		t.Fatalf("Unexpected number of books, got %d, want %d", len(first), 35)
+	}
+
+	// Seeding again with the same fixture and random seed adds nothing.
+	c.run(0, "", "seed", "-generate", "25", fixture)
+	var second []model.BookView
+	if err := json.Unmarshal([]byte(c.run(0, "", "-o", "json", "list", "-limit", "0")), &second); err != nil {
+		t.Fatalf("Error unmarshaling books: %v", err)
+	}
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Fatalf("Books changed by seeding again (-want +got):\n%s", diff)
+	}
+
+	// A natural key match ignores case.
+	c.run(0, `[{"title":"learning go","author":"JON BODNER"}]`, "seed", "-")
+	if out := c.run(0, "", "list", "-title", "learning go"); strings.Count(out, "\n") != 2 {
+		t.Fatalf("Unexpected books after seeding duplicate:\n%s", out)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+func seed(ctx context.Context, a app, args []string) error {
+	fs := a.newFlagSet("seed", "[fixture file]")
+	generate := fs.Int("generate", 0, "Number of synthetic books to generate in addition to the fixture")
+	rnd := fs.Uint64("seed", 1, "Random seed for synthetic books; the same seed generates the same books")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 && *generate <= 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var books []model.Book
+	if name := fs.Arg(0); name != "" {
+		r, closeFn, err := openInput(a.in, name)
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+		if books, err = readFixture(r, name); err != nil {
+			return fmt.Errorf("reading fixture: %w", err)
+		}
+	}
+	books = append(books, synthesize(*generate, *rnd)...)
+
+	// Books are identified by title and author, so that seeding again adds only missing books.
+	existing := make(map[string]bool)
+	err := all(ctx, a.crud, model.Filter{}, func(b model.Book) error {
+		existing[naturalKey(b)] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading existing books: %w", err)
+	}
+	added := 0
+	for _, b := range books {
+		key := naturalKey(b)
+		if existing[key] {
+			continue
+		}
+		b.ID = ""
+		if _, err := a.crud.Add(ctx, b); err != nil {
+			return fmt.Errorf("adding %q after seeding %d books: %w", b.Title, added, err)
+		}
+		existing[key] = true
+		added++
+	}
+	fmt.Fprintf(a.errOut, "seeded %d books, %d already present\n", added, len(books)-added)
+	return nil
+}
+
+// naturalKey identifies b by its title and author, ignoring case and surrounding whitespace.
+func naturalKey(b model.Book) string {
+	return strings.ToLower(strings.TrimSpace(b.Title)) + "\x00" + strings.ToLower(strings.TrimSpace(b.Author))
+}
+
+// fixtureBook is a book in a YAML fixture. Its fields match the JSON representation.
+type fixtureBook struct {
+	Author      string   `yaml:"author"`
+	Title       string   `yaml:"title"`
+	ReleaseDate string   `yaml:"releaseDate"`
+	Keywords    []string `yaml:"keywords"`
+}
+
+// readFixture reads a JSON or YAML array of books, depending on name's extension. JSON fixtures
+// use the same format as import and export.
+func readFixture(r io.Reader, name string) ([]model.Book, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+	default:
+		return readJSON(r)
+	}
+
+	var fixtures []fixtureBook
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&fixtures); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	books := make([]model.Book, len(fixtures))
+	for i, f := range fixtures {
+		released, err := parseFileDate(f.ReleaseDate)
+		if err != nil {
+			return nil, fmt.Errorf("book %d (%q): %w", i+1, f.Title, err)
+		}
+		books[i] = model.Book{Author: f.Author, Title: f.Title, ReleaseDate: released}
+		for _, k := range f.Keywords {
+			books[i].Keywords = append(books[i].Keywords, model.Keyword{Value: k})
+		}
+	}
+	return books, nil
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Barbara", "Dennis", "Edsger", "Frances", "Grace", "Ken", "Margaret", "Niklaus", "Radia", "Rob"}
+	lastNames  = []string{"Allen", "Dijkstra", "Hamilton", "Hopper", "Kernighan", "Liskov", "Lovelace", "Perlman", "Pike", "Ritchie", "Turing", "Wirth"}
+	adjectives = []string{"Concurrent", "Practical", "Idiomatic", "Distributed", "Modern", "Effective", "Pragmatic", "Reliable", "Scalable", "Secure"}
+	subjects   = []string{"Go", "Systems", "Databases", "APIs", "Testing", "Networking", "Compilers", "Algorithms", "Cloud Services", "Observability"}
+	topics     = []string{"Go", "MongoDB", "Concurrency", "Testing", "Cloud", "Kubernetes", "Security", "Performance", "Design", "Databases"}
+)
+
+// synthesize generates n distinct books. The same seed always generates the same books.
+func synthesize(n int, seed uint64) []model.Book {
+	r := rand.New(rand.NewPCG(seed, seed))
+	start := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := int(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC).Sub(start).Hours() / 24)
+
+	books := make([]model.Book, n)
+	for i := range books {
+		b := model.Book{
+			Author:      pick(r, firstNames) + " " + pick(r, lastNames),
+			Title:       fmt.Sprintf("%s %s, Volume %d", pick(r, adjectives), pick(r, subjects), i+1),
+			ReleaseDate: start.AddDate(0, 0, r.IntN(days)),
+		}
+		for _, j := range r.Perm(len(topics))[:1+r.IntN(3)] {
+			b.Keywords = append(b.Keywords, model.Keyword{Value: topics[j]})
+		}
+		books[i] = b
+	}
+	return books
+}
+
+func pick(r *rand.Rand, s []string) string {
+	return s[r.IntN(len(s))]
+}
@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) model.CrudService {
+		return NewCrudService(memory.NewCrudService(), 100, time.Minute, nil)
+	})
+}
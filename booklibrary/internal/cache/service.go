@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+// Compile-time check to verify we implement Storage
+var (
+	_ model.CrudService = (*CrudService)(nil)
+	_ model.Finder      = (*CrudService)(nil)
+)
+
+const (
+	opGet  = "get"
+	opList = "list"
+)
+
+// CrudService decorates a model.CrudService with an in-process read-through cache for Get and
+// List. Entries expire after a TTL and are invalidated by every Add, Update and Remove made through
+// this instance; writes made elsewhere (e.g. by other replicas) become visible once entries expire.
+// Concurrent misses for the same key are collapsed into a single call to the underlying store.
+type CrudService struct {
+	next   model.CrudService
+	books  *lru[string, model.Book]
+	lists  *lru[int, []model.Book]
+	group  singleflight.Group
+	gen    atomic.Uint64
+	lookup *prometheus.CounterVec
+}
+
+// NewCrudService wraps next with a cache holding up to size books and size lists for ttl. Metrics
+// are registered with reg if it is not nil.
+func NewCrudService(next model.CrudService, size int, ttl time.Duration, reg prometheus.Registerer) *CrudService {
+	f := promauto.With(reg)
+	cs := &CrudService{
+		next:  next,
+		books: newLRU[string, model.Book](size, ttl),
+		lists: newLRU[int, []model.Book](size, ttl),
+		lookup: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "booklibrary_cache_requests_total",
+			Help: "The total number of cache lookups by operation and result (hit or miss).",
+		}, []string{"operation", "result"}),
+	}
+	evictions := f.NewCounter(prometheus.CounterOpts{
+		Name: "booklibrary_cache_evictions_total",
+		Help: "The total number of cache entries evicted to make room for new ones.",
+	})
+	cs.books.onEvict = evictions.Inc
+	cs.lists.onEvict = evictions.Inc
+	f.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "booklibrary_cache_entries",
+		Help: "The number of entries currently held in the cache.",
+	}, func() float64 {
+		return float64(cs.books.len() + cs.lists.len())
+	})
+	return cs
+}
+
+// List returns up to limit books, from the cache if possible.
+func (cs *CrudService) List(ctx context.Context, limit int) ([]model.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if books, ok := cs.lists.get(limit); ok {
+		cs.lookup.WithLabelValues(opList, "hit").Inc()
+		return cloneBooks(books), nil
+	}
+	cs.lookup.WithLabelValues(opList, "miss").Inc()
+
+	v, err := cs.do(ctx, opList+":"+strconv.Itoa(limit), func(ctx context.Context) (any, error) {
+		gen := cs.gen.Load()
+		books, err := cs.next.List(ctx, limit)
+		if err != nil {
+			return nil, err
+		}
+		if cs.gen.Load() == gen {
+			cs.lists.add(limit, books)
+		}
+		return books, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneBooks(v.([]model.Book)), nil
+}
+
+// Find returns the books matching filter, if next is a model.Finder. Filtered results are not cached.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	return model.Find(ctx, cs.next, filter)
+}
+
+// Get returns the book with the given ID, from the cache if possible.
+func (cs *CrudService) Get(ctx context.Context, id string) (model.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Book{}, err
+	}
+	if book, ok := cs.books.get(id); ok {
+		cs.lookup.WithLabelValues(opGet, "hit").Inc()
+		return cloneBook(book), nil
+	}
+	cs.lookup.WithLabelValues(opGet, "miss").Inc()
+
+	v, err := cs.do(ctx, opGet+":"+id, func(ctx context.Context) (any, error) {
+		gen := cs.gen.Load()
+		book, err := cs.next.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if cs.gen.Load() == gen {
+			cs.books.add(id, book)
+		}
+		return book, nil
+	})
+	if err != nil {
+		return model.Book{}, err
+	}
+	return cloneBook(v.(model.Book)), nil
+}
+
+// Add adds a book and invalidates all cached lists.
+func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, error) {
+	added, err := cs.next.Add(ctx, book)
+	cs.invalidate("")
+	return added, err
+}
+
+// Update replaces a book and invalidates it and all cached lists.
+func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
+	updated, err := cs.next.Update(ctx, id, book)
+	cs.invalidate(id)
+	return updated, err
+}
+
+// Remove deletes a book and invalidates it and all cached lists.
+func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
+	removed, err := cs.next.Remove(ctx, id)
+	cs.invalidate(id)
+	return removed, err
+}
+
+// Ping checks the underlying store.
+func (cs *CrudService) Ping(ctx context.Context) error {
+	return cs.next.Ping(ctx)
+}
+
+// do runs fn once for all concurrent misses of key. The shared call is not canceled with the
+// context of the caller that started it, since other callers may still wait for it, but it keeps
+// that caller's deadline, so that store timeouts still apply. Each caller stops waiting when its own
+// context is done.
+func (cs *CrudService) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	ch := cs.group.DoChan(key, func() (any, error) {
+		shared := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			shared, cancel = context.WithDeadline(shared, deadline)
+			defer cancel()
+		}
+		return fn(shared)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// invalidate drops the book with the given ID, if any, and all lists. Bumping the generation keeps
+// reads that were in flight during the write from caching their possibly stale results. Writes
+// invalidate even if they failed, since a timed out write may still have been applied.
+func (cs *CrudService) invalidate(id string) {
+	cs.gen.Add(1)
+	if id != "" {
+		cs.books.delete(id)
+	}
+	cs.lists.purge()
+}
+
+// cloneBook copies b, so that callers cannot modify the keywords of cached books.
+func cloneBook(b model.Book) model.Book {
+	b.Keywords = slices.Clone(b.Keywords)
+	return b
+}
+
+func cloneBooks(books []model.Book) []model.Book {
+	c := make([]model.Book, len(books))
+	for i, b := range books {
+		c[i] = cloneBook(b)
+	}
+	return c
+}
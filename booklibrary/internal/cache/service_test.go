@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+type storeStub struct {
+	model.CrudService
+	gets     atomic.Int32
+	lists    atomic.Int32
+	release  chan struct{}
+	deadline chan time.Time
+}
+
+func (s *storeStub) Get(ctx context.Context, id string) (model.Book, error) {
+	s.gets.Add(1)
+	if s.deadline != nil {
+		d, _ := ctx.Deadline()
+		s.deadline <- d
+	}
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return model.Book{}, ctx.Err()
+		}
+	}
+	return model.Book{ID: id, Title: "Caching in Go", Keywords: []model.Keyword{{Value: "Go"}}}, nil
+}
+
+func (s *storeStub) List(_ context.Context, limit int) ([]model.Book, error) {
+	s.lists.Add(1)
+	return make([]model.Book, limit), nil
+}
+
+func (s *storeStub) Update(_ context.Context, id string, book model.Book) (model.Book, error) {
+	book.ID = id
+	return book, nil
+}
+
+func (s *storeStub) Add(_ context.Context, book model.Book) (model.Book, error) {
+	return book, nil
+}
+
+func TestReadThrough(t *testing.T) {
+	store := &storeStub{}
+	cs := NewCrudService(store, 10, time.Minute, nil)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := cs.Get(ctx, "1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := cs.List(ctx, 5); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if got := store.gets.Load(); got != 1 {
+		t.Errorf("Expected a single Get on the store, got %d", got)
+	}
+	if got := store.lists.Load(); got != 1 {
+		t.Errorf("Expected a single List on the store, got %d", got)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	store := &storeStub{}
+	cs := NewCrudService(store, 10, time.Minute, nil)
+	ctx := context.Background()
+
+	cs.Get(ctx, "1")
+	cs.Get(ctx, "2")
+	cs.List(ctx, 5)
+	cs.Update(ctx, "1", model.Book{})
+	cs.Get(ctx, "1")
+	cs.Get(ctx, "2")
+	cs.List(ctx, 5)
+
+	if got := store.gets.Load(); got != 3 {
+		t.Errorf("Expected updated book to be fetched again, got %d store calls, want 3", got)
+	}
+	if got := store.lists.Load(); got != 2 {
+		t.Errorf("Expected list to be fetched again, got %d store calls, want 2", got)
+	}
+
+	cs.Add(ctx, model.Book{})
+	cs.List(ctx, 5)
+	if got := store.lists.Load(); got != 3 {
+		t.Errorf("Expected list to be invalidated by Add, got %d store calls, want 3", got)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	store := &storeStub{}
+	cs := NewCrudService(store, 10, time.Minute, nil)
+	cs.books.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cs.Get(ctx, "1")
+	now = now.Add(time.Minute)
+	cs.Get(ctx, "1")
+	if got := store.gets.Load(); got != 2 {
+		t.Errorf("Expected expired entry to be fetched again, got %d store calls, want 2", got)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	store := &storeStub{}
+	cs := NewCrudService(store, 2, time.Minute, nil)
+	ctx := context.Background()
+
+	cs.Get(ctx, "1")
+	cs.Get(ctx, "2")
+	cs.Get(ctx, "1")
+	cs.Get(ctx, "3")
+	if _, ok := cs.books.get("2"); ok {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if _, ok := cs.books.get("1"); !ok {
+		t.Errorf("Expected recently used entry to be kept")
+	}
+}
+
+func TestConcurrentMisses(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		store := &storeStub{release: make(chan struct{})}
+		cs := NewCrudService(store, 10, time.Minute, nil)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				cs.Get(context.Background(), "1")
+			})
+		}
+		// Wait until all goroutines have joined the in-flight call before releasing it.
+		synctest.Wait()
+		close(store.release)
+		wg.Wait()
+
+		if got := store.gets.Load(); got != 1 {
+			t.Errorf("Expected concurrent misses to be collapsed, got %d store calls", got)
+		}
+	})
+}
+
+func TestMissCanceled(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		store := &storeStub{release: make(chan struct{})}
+		cs := NewCrudService(store, 10, time.Minute, nil)
+
+		// A caller that gives up does not wait for the shared call, nor cancel it for others.
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := cs.Get(ctx, "1")
+			errs <- err
+		}()
+		synctest.Wait()
+		var book model.Book
+		done := make(chan struct{})
+		go func() {
+			book, _ = cs.Get(context.Background(), "1")
+			close(done)
+		}()
+		synctest.Wait()
+		cancel()
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error, got %v, want %v", err, context.Canceled)
+		}
+		close(store.release)
+		<-done
+		if book.ID != "1" {
+			t.Errorf("Unexpected book for remaining caller, got %+v", book)
+		}
+	})
+}
+
+func TestMissDeadline(t *testing.T) {
+	store := &storeStub{deadline: make(chan time.Time, 1)}
+	cs := NewCrudService(store, 10, time.Minute, nil)
+	want := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+
+	if _, err := cs.Get(ctx, "1"); err != nil {
+		t.Fatalf("Unexpected error, got %v", err)
+	}
+	if got := <-store.deadline; !got.Equal(want) {
+		t.Errorf("Unexpected store deadline, got %v, want %v", got, want)
+	}
+}
+
+func TestCachedBooksNotShared(t *testing.T) {
+	store := &storeStub{}
+	cs := NewCrudService(store, 10, time.Minute, nil)
+	ctx := context.Background()
+
+	book, _ := cs.Get(ctx, "1")
+	book.Keywords[0].Value = "Rust"
+	book, _ = cs.Get(ctx, "1")
+	if got := book.Keywords[0].Value; got != "Go" {
+		t.Errorf("Unexpected keyword of cached book, got %q, want %q", got, "Go")
+	}
+}
@@ -0,0 +1,501 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// bookTypes are the BibTeX and BibLaTeX entry types that describe books.
+var bookTypes = map[string]bool{
+	"book": true, "mvbook": true, "booklet": true, "collection": true, "mvcollection": true,
+	"manual": true, "proceedings": true, "mvproceedings": true, "reference": true,
+	"phdthesis": true, "mastersthesis": true, "thesis": true, "techreport": true, "report": true,
+}
+
+// months are the predefined month macros.
+var months = map[string]string{
+	"jan": "1", "feb": "2", "mar": "3", "apr": "4", "may": "5", "jun": "6",
+	"jul": "7", "aug": "8", "sep": "9", "oct": "10", "nov": "11", "dec": "12",
+}
+
+// bibEntry is a BibTeX entry with lowercase field names and values with macros expanded.
+type bibEntry struct {
+	typ, key string
+	fields   map[string]string
+	warnings []string
+}
+
+// ReadBibTeX reads the entries of a BibTeX database. @string macros are expanded, and @comment
+// and @preamble entries as well as text outside of entries are ignored. LaTeX accents and
+// escapes are replaced by the characters they stand for.
+func ReadBibTeX(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := bibParser{s: string(data), macros: make(map[string]string)}
+	entries, err := p.entries()
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]Record, len(entries))
+	for i, e := range entries {
+		recs[i] = e.record()
+	}
+	return recs, nil
+}
+
+// record maps e to a book.
+func (e bibEntry) record() Record {
+	rec := Record{Ref: e.key, Warnings: e.warnings}
+	if !bookTypes[e.typ] {
+		rec.warn("entry type %q is not a book", e.typ)
+	}
+
+	rec.Book.Title = latex(e.fields["title"])
+	if rec.Book.Title == "" {
+		rec.Err = ErrNoTitle
+	}
+
+	authors := e.fields["author"]
+	if authors == "" && e.fields["editor"] != "" {
+		authors = e.fields["editor"]
+		rec.warn("no author, editor taken instead")
+	}
+	if authors == "" {
+		rec.warn("no author")
+	}
+	var names []string
+	for _, name := range splitNames(authors) {
+		names = append(names, latex(bibName(name)))
+	}
+	rec.Book.Author = strings.Join(names, " and ")
+
+	e.mapDate(&rec)
+	for _, kw := range strings.FieldsFunc(latex(e.fields["keywords"]), func(r rune) bool { return r == ',' || r == ';' }) {
+		rec.addKeyword(kw)
+	}
+	if len(rec.Book.Keywords) == 0 {
+		rec.warn("no keywords")
+	}
+	return rec
+}
+
+// mapDate takes the release date from the BibLaTeX date field, or else from year and month.
+func (e bibEntry) mapDate(rec *Record) {
+	if d := e.fields["date"]; d != "" {
+		// Date ranges start at their first date.
+		d, _, _ = strings.Cut(d, "/")
+		if t, err := time.Parse("2006-01", d); err == nil {
+			rec.Book.ReleaseDate = t
+			return
+		}
+		if t, ok := parseDate(d); ok {
+			rec.Book.ReleaseDate = t
+			return
+		}
+		rec.warn("unrecognized date %q", d)
+	}
+
+	y := e.fields["year"]
+	if y == "" {
+		rec.warn("no publication date")
+		return
+	}
+	t, ok := parseDate(y)
+	if !ok {
+		rec.warn("unrecognized year %q", y)
+		return
+	}
+	if m := e.fields["month"]; m != "" {
+		if month, ok := parseMonth(m); ok {
+			t = t.AddDate(0, int(month)-1, 0)
+		} else {
+			rec.warn("unrecognized month %q", m)
+		}
+	}
+	rec.Book.ReleaseDate = t
+}
+
+// parseMonth reads a month given as a number or an English name or abbreviation.
+func parseMonth(s string) (time.Month, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 3 {
+		if n, ok := months[s[:3]]; ok {
+			s = n
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 12 {
+		return 0, false
+	}
+	return time.Month(n), true
+}
+
+// splitNames splits a BibTeX name list at "and" outside of braces.
+func splitNames(s string) []string {
+	var names []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 && i+5 <= len(s) && strings.EqualFold(s[i+1:i+4], "and") && unicode.IsSpace(rune(s[i+4])) {
+				names = append(names, strings.TrimSpace(s[start:i]))
+				start = i + 5
+				i += 4
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		names = append(names, last)
+	}
+	return names
+}
+
+// bibName turns a name in one of the BibTeX forms "First von Last", "von Last, First" and
+// "von Last, Jr, First" into the first form, followed by Jr if given.
+func bibName(name string) string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(name[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, strings.TrimSpace(name[start:]))
+	switch len(parts) {
+	case 2:
+		return parts[1] + " " + parts[0]
+	case 3:
+		return parts[2] + " " + parts[0] + ", " + parts[1]
+	default:
+		return name
+	}
+}
+
+// accents maps LaTeX accent commands to combining characters.
+var accents = map[byte]rune{
+	'`': '\u0300', '\'': '\u0301', '^': '\u0302', '~': '\u0303', '=': '\u0304', 'u': '\u0306',
+	'.': '\u0307', '"': '\u0308', 'r': '\u030a', 'H': '\u030b', 'v': '\u030c', 'c': '\u0327',
+	'k': '\u0328',
+}
+
+// symbols maps LaTeX commands to the characters they stand for.
+var symbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "aa": "å", "AA": "Å",
+	"l": "ł", "L": "Ł", "i": "ı", "j": "ȷ", "&": "&", "%": "%", "$": "$", "#": "#", "_": "_",
+	"{": "{", "}": "}",
+}
+
+// latex turns BibTeX field text into plain text. It resolves accents, escapes and dashes, removes
+// braces and collapses white space.
+func latex(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' || c == '}':
+		case c == '~':
+			sb.WriteByte(' ')
+		case c == '-' && strings.HasPrefix(s[i:], "---"):
+			sb.WriteString("—")
+			i += 2
+		case c == '-' && strings.HasPrefix(s[i:], "--"):
+			sb.WriteString("–")
+			i++
+		case c == '\\' && i+1 < len(s):
+			i = command(s, i+1, &sb)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(norm.NFC.String(sb.String())), " ")
+}
+
+// command writes the character for the LaTeX command starting after the backslash at s[i] and
+// returns the index of its last byte. Unknown commands are dropped, keeping their arguments.
+func command(s string, i int, sb *strings.Builder) int {
+	name := s[i : i+1]
+	if isLetter(s[i]) {
+		j := i
+		for j < len(s) && isLetter(s[j]) {
+			j++
+		}
+		name = s[i:j]
+	}
+	end := i + len(name) - 1
+	mark, ok := accents[name[0]]
+	if !ok || len(name) > 1 {
+		if sym, ok := symbols[name]; ok {
+			sb.WriteString(sym)
+		}
+		// A space ends the name of a command made of letters.
+		if isLetter(name[0]) && end+1 < len(s) && s[end+1] == ' ' {
+			end++
+		}
+		return end
+	}
+	// The accented letter follows, possibly in braces or after a space.
+	j := end + 1
+	for j < len(s) && (s[j] == '{' || s[j] == ' ') {
+		j++
+	}
+	if j == len(s) {
+		return end
+	}
+	if s[j] == '\\' && j+1 < len(s) && (s[j+1] == 'i' || s[j+1] == 'j') {
+		// Dotless i and j are accented as plain i and j.
+		sb.WriteByte(s[j+1])
+		j++
+	} else {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		sb.WriteRune(r)
+		j += size - 1
+	}
+	sb.WriteRune(mark)
+	for j+1 < len(s) && s[j+1] == '}' {
+		j++
+	}
+	return j
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// bibParser parses BibTeX databases.
+type bibParser struct {
+	s      string
+	pos    int
+	macros map[string]string
+}
+
+func (p *bibParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.s[:p.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *bibParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// ident reads an entry type, citation key, field or macro name.
+func (p *bibParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t\r\n{}(),=#\"", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *bibParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *bibParser) entries() ([]bibEntry, error) {
+	var entries []bibEntry
+	for {
+		at := strings.IndexByte(p.s[p.pos:], '@')
+		if at < 0 {
+			return entries, nil
+		}
+		p.pos += at + 1
+		p.skipSpace()
+		typ := strings.ToLower(p.ident())
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != '{' && p.s[p.pos] != '(' {
+			return nil, p.errorf("expected { or ( after @%s", typ)
+		}
+		closing := byte('}')
+		if p.s[p.pos] == '(' {
+			closing = ')'
+		}
+		p.pos++
+
+		switch typ {
+		case "comment":
+			// Comments in parentheses are skipped like any text outside of entries.
+			if closing == '}' {
+				p.pos--
+				if _, err := p.braced(); err != nil {
+					return nil, err
+				}
+			}
+		case "preamble":
+			if _, err := p.value(nil); err != nil {
+				return nil, err
+			}
+			if err := p.expect(closing); err != nil {
+				return nil, err
+			}
+		case "string":
+			p.skipSpace()
+			name := strings.ToLower(p.ident())
+			if err := p.expect('='); err != nil {
+				return nil, err
+			}
+			v, err := p.value(nil)
+			if err != nil {
+				return nil, err
+			}
+			p.macros[name] = v
+			if err := p.expect(closing); err != nil {
+				return nil, err
+			}
+		default:
+			e, err := p.entry(typ, closing)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+}
+
+// entry parses the citation key and fields of an entry up to its closing delimiter.
+func (p *bibParser) entry(typ string, closing byte) (bibEntry, error) {
+	e := bibEntry{typ: typ, fields: make(map[string]string)}
+	p.skipSpace()
+	e.key = p.ident()
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return e, p.errorf("entry %q is not closed", e.key)
+		}
+		switch p.s[p.pos] {
+		case closing:
+			p.pos++
+			return e, nil
+		case ',':
+			p.pos++
+			continue
+		}
+		name := strings.ToLower(p.ident())
+		if name == "" {
+			return e, p.errorf("expected field name in entry %q", e.key)
+		}
+		if err := p.expect('='); err != nil {
+			return e, err
+		}
+		v, err := p.value(&e)
+		if err != nil {
+			return e, err
+		}
+		e.fields[name] = v
+	}
+}
+
+// value parses a field value, concatenating its parts. Undefined macros are reported as warnings
+// of e and expand to nothing.
+func (p *bibParser) value(e *bibEntry) (string, error) {
+	var sb strings.Builder
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return "", p.errorf("expected value")
+		}
+		switch c := p.s[p.pos]; {
+		case c == '{':
+			v, err := p.braced()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+		case c == '"':
+			v, err := p.quoted()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+		default:
+			name := p.ident()
+			if name == "" {
+				return "", p.errorf("expected value")
+			}
+			if _, err := strconv.Atoi(name); err == nil {
+				sb.WriteString(name)
+				break
+			}
+			name = strings.ToLower(name)
+			v, ok := p.macros[name]
+			if !ok {
+				v, ok = months[name]
+			}
+			if !ok && e != nil {
+				e.warnings = append(e.warnings, fmt.Sprintf("undefined string %q", name))
+			}
+			sb.WriteString(v)
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != '#' {
+			return sb.String(), nil
+		}
+		p.pos++
+	}
+}
+
+// braced returns the text between the opening brace at p.pos and its matching closing brace,
+// keeping nested braces.
+func (p *bibParser) braced() (string, error) {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.s[start+1 : p.pos-1], nil
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unbalanced braces")
+}
+
+// quoted returns the text between the quote at p.pos and the next quote outside of braces.
+func (p *bibParser) quoted() (string, error) {
+	start, depth := p.pos, 0
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			if depth == 0 {
+				p.pos++
+				return p.s[start+1 : p.pos-1], nil
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quote")
+}
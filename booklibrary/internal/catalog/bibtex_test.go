@@ -0,0 +1,135 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+func TestReadBibTeX(t *testing.T) {
+	in := `
+This text is ignored, as is the comment @comment{with {nested} braces}.
+
+@string{aw = "Addison-Wesley"}
+@preamble{"\newcommand{\noop}[1]{}"}
+
+@Book{donovan2015,
+  author    = {Donovan, Alan A. A. and Brian W. Kernighan},
+  title     = {The {Go} Programming Language},
+  publisher = aw # " Professional",
+  year      = 2015,
+  month     = oct,
+  keywords  = {Go, programming; go},
+}
+
+@book(goedel1931,
+  author = "G{\"o}del, Kurt",
+  title  = "{\"U}ber formal unentscheidbare S{\"a}tze",
+  date   = {1931-03/1931-04},
+  keywords = {Logik},
+)
+
+@article{knuth1974,
+  author = {Knuth, Jr, Donald E.},
+  title = "Structured Programming with {\tt go to} Statements",
+  year = {1974},
+  month = {Dezember},
+  journal = acm,
+}
+
+@manual{nobody,
+  editor = {{Ministry of Silly Walks}},
+  year = {n.d.},
+}
+`
+	recs, err := ReadBibTeX(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadBibTeX() error = %v", err)
+	}
+	want := []Record{
+		{
+			Ref: "donovan2015",
+			Book: model.Book{
+				Author:      "Alan A. A. Donovan and Brian W. Kernighan",
+				Title:       "The Go Programming Language",
+				ReleaseDate: time.Date(2015, time.October, 1, 0, 0, 0, 0, time.UTC),
+				Keywords:    []model.Keyword{{Value: "Go"}, {Value: "programming"}},
+			},
+		},
+		{
+			Ref: "goedel1931",
+			Book: model.Book{
+				Author:      "Kurt Gödel",
+				Title:       "Über formal unentscheidbare Sätze",
+				ReleaseDate: time.Date(1931, time.March, 1, 0, 0, 0, 0, time.UTC),
+				Keywords:    []model.Keyword{{Value: "Logik"}},
+			},
+		},
+		{
+			Ref: "knuth1974",
+			Book: model.Book{
+				Author:      "Donald E. Knuth, Jr",
+				Title:       "Structured Programming with go to Statements",
+				ReleaseDate: time.Date(1974, time.January, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Warnings: []string{`undefined string "acm"`, `entry type "article" is not a book`, `unrecognized month "Dezember"`, "no keywords"},
+		},
+		{
+			Ref:      "nobody",
+			Book:     model.Book{Author: "Ministry of Silly Walks"},
+			Warnings: []string{"no author, editor taken instead", `unrecognized year "n.d."`, "no keywords"},
+			Err:      ErrNoTitle,
+		},
+	}
+	if diff := cmp.Diff(want, recs, cmp.Comparer(func(a, b error) bool { return errors.Is(a, b) })); diff != "" {
+		t.Errorf("ReadBibTeX() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadBibTeXMalformed(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{name: "unclosed_entry", in: "@book{key,\n title = {Title}", want: "line 2: "},
+		{name: "unbalanced_braces", in: "@book{key,\n\n title = {Ti{tle}\n", want: "line 3: "},
+		{name: "missing_equals", in: "@book{key, title {Title}}", want: "line 1: "},
+		{name: "unterminated_quote", in: `@book{key, title = "Title}`, want: "line 1: "},
+		{name: "no_delimiter", in: "@book key", want: "line 1: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadBibTeX(strings.NewReader(tt.in)); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ReadBibTeX() error = %v, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatex(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: `Schr{\"o}dinger`, want: "Schrödinger"},
+		{in: `Erd\H{o}s`, want: "Erdős"},
+		{in: `Fran\c{c}ois`, want: "François"},
+		{in: `Ca\~{n}on`, want: "Cañon"},
+		{in: `na\"{\i}ve`, want: "naïve"},
+		{in: `Stra\ss e`, want: "Straße"},
+		{in: `\AA ngstr\"om`, want: "Ångström"},
+		{in: `AT\&T, 50\% off`, want: "AT&T, 50% off"},
+		{in: "pages 1--10 --- or so", want: "pages 1–10 — or so"},
+		{in: "  {Go}\n  in~Action ", want: "Go in Action"},
+		{in: `\emph{Gophers}`, want: "Gophers"},
+		{in: `\'{ø}re`, want: "ǿre"},
+		{in: `\=æ`, want: "ǣ"},
+	}
+	for _, tt := range tests {
+		if got := latex(tt.in); got != tt.want {
+			t.Errorf("latex(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package catalog maps bibliographic records in the formats libraries keep their catalogues in,
+// MARC 21 (as ISO 2709 or MARCXML) and BibTeX, to books, and writes books as MARCXML.
+//
+// Records rarely carry exactly what a book needs, so mapping is lenient: whatever can be mapped
+// is, and each record reports what was missing, guessed or dropped as warnings. Only records
+// without a title cannot be mapped.
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// Names of the supported formats.
+const (
+	MARC    = "marc"
+	MARCXML = "marcxml"
+	BibTeX  = "bibtex"
+)
+
+var (
+	// ErrUnknownFormat is returned by Read for formats other than MARC, MARCXML and BibTeX.
+	ErrUnknownFormat = errors.New("unknown catalogue format")
+	// ErrNoTitle is the error of records without a title.
+	ErrNoTitle = errors.New("record has no title")
+)
+
+// Record is a book mapped from a catalogue record.
+type Record struct {
+	// Ref identifies the record in its source, i.e. the control number (001) of MARC records and the
+	// citation key of BibTeX entries. It may be empty.
+	Ref  string
+	Book model.Book
+	// Warnings describe information that was missing from the record or could not be mapped.
+	Warnings []string
+	// Err is set if the record cannot be imported as a book.
+	Err error
+}
+
+func (r *Record) warn(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// addKeyword adds kw to the book's keywords unless it is empty or already there, ignoring case.
+func (r *Record) addKeyword(kw string) {
+	kw = strings.TrimSpace(kw)
+	if kw == "" {
+		return
+	}
+	if slices.ContainsFunc(r.Book.Keywords, func(k model.Keyword) bool { return strings.EqualFold(k.Value, kw) }) {
+		return
+	}
+	r.Book.Keywords = append(r.Book.Keywords, model.Keyword{Value: kw})
+}
+
+var readers = map[string]func(io.Reader) ([]Record, error){
+	MARC:    ReadMARC,
+	MARCXML: ReadMARCXML,
+	BibTeX:  ReadBibTeX,
+}
+
+// Formats returns the names of the supported formats.
+func Formats() []string {
+	return []string{MARC, MARCXML, BibTeX}
+}
+
+// Read reads all records from r in the named format. It fails if r is malformed, but not if
+// individual records cannot be mapped; see Record.Err.
+func Read(format string, r io.Reader) ([]Record, error) {
+	read, ok := readers[format]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	return read(r)
+}
+
+var yearPattern = regexp.MustCompile(`\d{4}`)
+
+// parseDate reads a publication date as written in catalogues, e.g. "c2015.", "[2015?]" or
+// "2015-10-26". Dates that only give a year are January 1 of that year.
+func parseDate(s string) (time.Time, bool) {
+	s = strings.Trim(s, " []().,;:?©℗cp")
+	if t, err := model.ParseDate(s); err == nil {
+		return t.UTC(), true
+	}
+	y := yearPattern.FindString(s)
+	if y == "" {
+		return time.Time{}, false
+	}
+	n, _ := strconv.Atoi(y)
+	return time.Date(n, time.January, 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// trimPunct removes the ISBD punctuation that separates the parts of a catalogue entry from the end
+// of s. A final period is kept after initials and abbreviations like "J." or "Jr.".
+func trimPunct(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), " /:;,=")
+	if strings.HasSuffix(s, ".") {
+		words := strings.Fields(s)
+		if utf8.RuneCountInString(words[len(words)-1]) > 4 {
+			s = strings.TrimSuffix(s, ".")
+		}
+	}
+	return s
+}
+
+// directOrder turns a name in inverted order, e.g. "Doe, Jane" or "King, Martin Luther, Jr.",
+// into direct order, e.g. "Jane Doe" or "Martin Luther King, Jr.".
+func directOrder(name string) string {
+	surname, rest, ok := strings.Cut(name, ", ")
+	if !ok {
+		return name
+	}
+	forenames, suffix, ok := strings.Cut(rest, ", ")
+	if !ok {
+		return rest + " " + surname
+	}
+	return forenames + " " + surname + ", " + suffix
+}
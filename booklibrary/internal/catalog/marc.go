@@ -0,0 +1,299 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ISO 2709 delimiters.
+const (
+	subfieldDelimiter = 0x1f
+	fieldTerminator   = 0x1e
+	recordTerminator  = 0x1d
+)
+
+const (
+	leaderLength   = 24
+	directoryEntry = 12
+)
+
+// subjectTags are the MARC 21 subject access fields mapped to keywords.
+var subjectTags = []string{"600", "610", "611", "630", "650", "651", "653", "655"}
+
+// marcRecord is a MARC 21 bibliographic record, regardless of its serialization.
+type marcRecord struct {
+	leader string
+	fields []marcField
+}
+
+// marcField is a control field with a value, or a data field with indicators and subfields.
+type marcField struct {
+	tag        string
+	ind1, ind2 byte
+	value      string
+	subfields  []subfield
+}
+
+type subfield struct {
+	code  byte
+	value string
+}
+
+// control reports whether tag holds control fields, which have a value instead of subfields.
+func control(tag string) bool {
+	return tag < "010"
+}
+
+// tagged returns the fields with any of the given tags, in record order.
+func (r marcRecord) tagged(tags ...string) []marcField {
+	var fs []marcField
+	for _, f := range r.fields {
+		for _, tag := range tags {
+			if f.tag == tag {
+				fs = append(fs, f)
+				break
+			}
+		}
+	}
+	return fs
+}
+
+// first returns the first field with any of the given tags.
+func (r marcRecord) first(tags ...string) (marcField, bool) {
+	for _, tag := range tags {
+		if fs := r.tagged(tag); len(fs) > 0 {
+			return fs[0], true
+		}
+	}
+	return marcField{}, false
+}
+
+// subfield returns the value of the first subfield with the given code.
+func (f marcField) subfield(code byte) string {
+	for _, sf := range f.subfields {
+		if sf.code == code {
+			return sf.value
+		}
+	}
+	return ""
+}
+
+// record maps r to a book.
+func (r marcRecord) record() Record {
+	var rec Record
+	if f, ok := r.first("001"); ok {
+		rec.Ref = strings.TrimSpace(f.value)
+	}
+	if len(r.leader) == leaderLength && r.leader[6] != 'a' && r.leader[6] != 't' {
+		rec.warn("record type %q is not language material", r.leader[6])
+	}
+
+	if f, ok := r.first("245"); ok {
+		title := trimPunct(f.subfield('a'))
+		if sub := trimPunct(f.subfield('b')); sub != "" {
+			title += " : " + sub
+		}
+		for _, sf := range f.subfields {
+			if v := trimPunct(sf.value); (sf.code == 'n' || sf.code == 'p') && v != "" {
+				title += ". " + v
+			}
+		}
+		rec.Book.Title = title
+	}
+	if rec.Book.Title == "" {
+		rec.Err = ErrNoTitle
+	}
+
+	r.mapAuthor(&rec)
+	r.mapDate(&rec)
+	for _, f := range r.tagged(subjectTags...) {
+		for _, sf := range f.subfields {
+			if sf.code == 'a' {
+				rec.addKeyword(trimPunct(sf.value))
+			}
+		}
+	}
+	if len(rec.Book.Keywords) == 0 {
+		rec.warn("no subject headings")
+	}
+	return rec
+}
+
+// mapAuthor takes the author from the main entry, or else the first added entry.
+func (r marcRecord) mapAuthor(rec *Record) {
+	f, ok := r.first("100", "110", "111")
+	if !ok {
+		if f, ok = r.first("700", "710", "711"); ok {
+			rec.warn("no main entry, author taken from field %s", f.tag)
+		}
+	}
+	if !ok {
+		rec.warn("no author")
+		return
+	}
+	name := trimPunct(f.subfield('a'))
+	// Personal names with a surname are inverted.
+	if (f.tag == "100" || f.tag == "700") && f.ind1 == '1' {
+		name = directOrder(name)
+	}
+	rec.Book.Author = name
+}
+
+// mapDate takes the release date from the publication statement, or else from the fixed-length
+// data elements.
+func (r marcRecord) mapDate(rec *Record) {
+	pubs := r.tagged("264")
+	for i, f := range pubs {
+		// Prefer the publication over production, distribution or copyright dates.
+		if f.ind2 == '1' {
+			pubs[0], pubs[i] = pubs[i], pubs[0]
+			break
+		}
+	}
+	pubs = append(pubs, r.tagged("260")...)
+	for _, f := range pubs {
+		c := f.subfield('c')
+		if c == "" {
+			continue
+		}
+		if t, ok := parseDate(c); ok {
+			rec.Book.ReleaseDate = t
+			return
+		}
+		rec.warn("unrecognized publication date %q in field %s", c, f.tag)
+		break
+	}
+	if f, ok := r.first("008"); ok && len(f.value) >= 11 {
+		if t, ok := parseDate(f.value[7:11]); ok {
+			rec.Book.ReleaseDate = t
+			return
+		}
+	}
+	rec.warn("no publication date")
+}
+
+// ReadMARC reads MARC 21 records in ISO 2709 format. Records are expected to be encoded in UTF-8;
+// non-ASCII characters of MARC-8 encoded records are replaced with U+FFFD and reported as a
+// warning.
+func ReadMARC(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	var recs []Record
+	for n := 1; ; n++ {
+		data, err := nextMARC(br)
+		if errors.Is(err, io.EOF) {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		mr, valid, err := parseMARC(data)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", n, err)
+		}
+		rec := mr.record()
+		if !valid {
+			rec.warn("record is not encoded in UTF-8, non-ASCII characters were replaced")
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// nextMARC reads the next record, skipping line breaks some tools put between records.
+func nextMARC(br *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' && b[0] != '\r' {
+			break
+		}
+		br.Discard(1)
+	}
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("reading record length: %w", io.ErrUnexpectedEOF)
+	}
+	n, err := strconv.Atoi(string(head))
+	if err != nil || n < leaderLength+1 {
+		return nil, fmt.Errorf("invalid record length %q", head)
+	}
+	data := make([]byte, n)
+	copy(data, head)
+	if _, err := io.ReadFull(br, data[5:]); err != nil {
+		return nil, fmt.Errorf("reading record of length %d: %w", n, io.ErrUnexpectedEOF)
+	}
+	return data, nil
+}
+
+// parseMARC parses an ISO 2709 record and reports whether it was valid UTF-8.
+func parseMARC(data []byte) (marcRecord, bool, error) {
+	if data[len(data)-1] != recordTerminator {
+		return marcRecord{}, false, errors.New("missing record terminator")
+	}
+	leader := string(data[:leaderLength])
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base <= leaderLength || base > len(data) {
+		return marcRecord{}, false, fmt.Errorf("invalid base address of data %q", leader[12:17])
+	}
+	dir := data[leaderLength : base-1]
+	if data[base-1] != fieldTerminator || len(dir)%directoryEntry != 0 {
+		return marcRecord{}, false, errors.New("invalid directory")
+	}
+
+	// MARC-8 is declared by a blank character coding scheme. Records that declare UTF-8 but are
+	// not are repaired the same way.
+	ascii := !bytes.ContainsFunc(data, func(r rune) bool { return r >= utf8.RuneSelf })
+	valid := ascii || leader[9] == 'a' && utf8.Valid(data)
+	text := func(b []byte) string {
+		if valid {
+			return string(b)
+		}
+		var sb strings.Builder
+		for _, c := range b {
+			if c >= utf8.RuneSelf {
+				sb.WriteRune(utf8.RuneError)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+		return sb.String()
+	}
+
+	rec := marcRecord{leader: leader}
+	for e := dir; len(e) > 0; e = e[directoryEntry:] {
+		tag := string(e[:3])
+		length, err1 := strconv.Atoi(string(e[3:7]))
+		start, err2 := strconv.Atoi(string(e[7:12]))
+		end := base + start + length
+		if err1 != nil || err2 != nil || length < 1 || start < 0 || end > len(data) {
+			return marcRecord{}, false, fmt.Errorf("invalid directory entry for field %s", tag)
+		}
+		// The field terminator is included in the field's length.
+		raw := bytes.TrimSuffix(data[base+start:end], []byte{fieldTerminator})
+		f := marcField{tag: tag}
+		if control(tag) {
+			f.value = text(raw)
+			rec.fields = append(rec.fields, f)
+			continue
+		}
+		if len(raw) < 2 {
+			return marcRecord{}, false, fmt.Errorf("field %s has no indicators", tag)
+		}
+		f.ind1, f.ind2 = raw[0], raw[1]
+		for _, sf := range bytes.Split(raw[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			f.subfields = append(f.subfields, subfield{code: sf[0], value: text(sf[1:])})
+		}
+		rec.fields = append(rec.fields, f)
+	}
+	return rec, valid, nil
+}
@@ -0,0 +1,137 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// iso2709 encodes fields given as tag followed by the field data, with "$" starting subfields, as
+// an ISO 2709 record with the given character coding scheme.
+func iso2709(coding byte, fields ...string) []byte {
+	var dir, data bytes.Buffer
+	for _, f := range fields {
+		tag, body := f[:3], strings.ReplaceAll(f[3:], "$", string(rune(subfieldDelimiter)))
+		fmt.Fprintf(&dir, "%s%04d%05d", tag, len(body)+1, data.Len())
+		data.WriteString(body)
+		data.WriteByte(fieldTerminator)
+	}
+	dir.WriteByte(fieldTerminator)
+	base := leaderLength + dir.Len()
+	n := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam %c22%05d   4500", n, coding, base)
+	return append(append(append([]byte(leader), dir.Bytes()...), data.Bytes()...), recordTerminator)
+}
+
+func TestReadMARC(t *testing.T) {
+	var in bytes.Buffer
+	in.Write(iso2709('a',
+		"001ocm123",
+		"008151026s2015    nyu           000 0 eng d",
+		"1001 $aDonovan, Alan A. A.,$eauthor.",
+		"24514$aThe Go programming language /$cAlan A. A. Donovan, Brian W. Kernighan.",
+		"264 4$c©2015",
+		"264 1$aNew York :$bAddison-Wesley,$c[2015]",
+		"650 0$aGo (Computer program language)",
+		"650 0$aOpen source software.",
+		"653  $aGo$aConcurrency",
+	))
+	in.WriteString("\n")
+	in.Write(iso2709(' ',
+		"001ocm456",
+		"008990101s1999    gw            000 0 ger d",
+		"245 0$aGr\xfcne B\xfccher :$bein Katalog.",
+		"7001 $aM\xfcller, Hans",
+	))
+	in.Write(iso2709('a',
+		"1102 $aAcme Corporation.",
+		"24500$aAnnual report.$nPart 2,$pFinances",
+		"260  $cundated",
+	))
+	in.Write(iso2709('a', "100  $aNobody"))
+
+	recs, err := ReadMARC(&in)
+	if err != nil {
+		t.Fatalf("ReadMARC() error = %v", err)
+	}
+	want := []Record{
+		{
+			Ref: "ocm123",
+			Book: model.Book{
+				Author:      "Alan A. A. Donovan",
+				Title:       "The Go programming language",
+				ReleaseDate: time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Keywords:    []model.Keyword{{Value: "Go (Computer program language)"}, {Value: "Open source software"}, {Value: "Go"}, {Value: "Concurrency"}},
+			},
+		},
+		{
+			Ref: "ocm456",
+			Book: model.Book{
+				Author:      "Hans M\uFFFDller",
+				Title:       "Gr\uFFFDne B\uFFFDcher : ein Katalog",
+				ReleaseDate: time.Date(1999, time.January, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Warnings: []string{"no main entry, author taken from field 700", "no subject headings", "record is not encoded in UTF-8, non-ASCII characters were replaced"},
+		},
+		{
+			Book:     model.Book{Author: "Acme Corporation", Title: "Annual report. Part 2. Finances"},
+			Warnings: []string{`unrecognized publication date "undated" in field 260`, "no publication date", "no subject headings"},
+		},
+		{
+			Book:     model.Book{Author: "Nobody"},
+			Warnings: []string{"no publication date", "no subject headings"},
+			Err:      ErrNoTitle,
+		},
+	}
+	if diff := cmp.Diff(want, recs, cmp.Comparer(func(a, b error) bool { return errors.Is(a, b) })); diff != "" {
+		t.Errorf("ReadMARC() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadMARCMalformed(t *testing.T) {
+	valid := iso2709('a', "24500$aTitle")
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{name: "length", in: []byte("abcde")},
+		{name: "truncated", in: valid[:len(valid)-5]},
+		{name: "terminator", in: append(bytes.Clone(valid[:len(valid)-1]), 'x')},
+		{name: "directory", in: bytes.Replace(valid, []byte("24500"), []byte("245xx"), 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := append(iso2709('a', "24500$aFirst"), tt.in...)
+			if _, err := ReadMARC(bytes.NewReader(in)); err == nil || !strings.HasPrefix(err.Error(), "record 2: ") {
+				t.Errorf("ReadMARC() error = %v, want error for record 2", err)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{in: "2015", want: time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{in: "c2015.", want: time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{in: "[2015?]", want: time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{in: "2015-10-26", want: time.Date(2015, time.October, 26, 0, 0, 0, 0, time.UTC), ok: true},
+		{in: "19--", ok: false},
+		{in: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDate(tt.in)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, %t, want %v, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// marcxmlNamespace is the namespace of MARCXML documents.
+const marcxmlNamespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (x xmlRecord) marc() marcRecord {
+	rec := marcRecord{leader: x.Leader}
+	for _, cf := range x.ControlFields {
+		rec.fields = append(rec.fields, marcField{tag: cf.Tag, value: cf.Value})
+	}
+	for _, df := range x.DataFields {
+		f := marcField{tag: df.Tag, ind1: indicator(df.Ind1), ind2: indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			if sf.Code != "" {
+				f.subfields = append(f.subfields, subfield{code: sf.Code[0], value: sf.Value})
+			}
+		}
+		rec.fields = append(rec.fields, f)
+	}
+	return rec
+}
+
+func indicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+// ReadMARCXML reads the records of a MARCXML collection or a single MARCXML record. Records are
+// found wherever they are nested, and with or without the MARCXML namespace.
+func ReadMARCXML(r io.Reader) ([]Record, error) {
+	dec := xml.NewDecoder(r)
+	var recs []Record
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var x xmlRecord
+		if err := dec.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(recs)+1, err)
+		}
+		recs = append(recs, x.marc().record())
+	}
+}
+
+// MARCXMLWriter writes books as a MARCXML collection. Each book is a record with its ID as control
+// number, its author as main entry, its title, its release date as date of publication and its
+// keywords as uncontrolled index terms, so that reading the collection back yields the same books.
+// Release dates are written as years unless they fall on another day than January 1.
+type MARCXMLWriter struct {
+	enc     *xml.Encoder
+	started bool
+}
+
+// NewMARCXMLWriter creates a writer that writes to w. Close must be called after the last book.
+func NewMARCXMLWriter(w io.Writer) *MARCXMLWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &MARCXMLWriter{enc: enc}
+}
+
+var collection = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: marcxmlNamespace}},
+}
+
+// Write writes b as a record.
+func (mw *MARCXMLWriter) Write(b model.Book) error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	return mw.enc.Encode(toMARCXML(b))
+}
+
+// Close ends the collection and flushes it.
+func (mw *MARCXMLWriter) Close() error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	if err := mw.enc.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	return mw.enc.Close()
+}
+
+func (mw *MARCXMLWriter) start() error {
+	if mw.started {
+		return nil
+	}
+	mw.started = true
+	if err := mw.enc.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err != nil {
+		return err
+	}
+	return mw.enc.EncodeToken(collection)
+}
+
+// toMARCXML maps b to a record of a printed book, encoded in UTF-8 without ISBD punctuation.
+func toMARCXML(b model.Book) xmlRecord {
+	x := xmlRecord{Leader: "00000nam a2200000   4500"}
+	if b.ID != "" {
+		x.ControlFields = append(x.ControlFields, xmlControlField{Tag: "001", Value: b.ID})
+	}
+	// Only the type of date and Date 1 of the fixed-length data elements are coded.
+	date1, pub := "||||", ""
+	if !b.ReleaseDate.IsZero() {
+		t := b.ReleaseDate.UTC()
+		date1, pub = t.Format("2006"), t.Format("2006")
+		if t != time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC) {
+			pub = t.Format(time.DateOnly)
+		}
+	}
+	x.ControlFields = append(x.ControlFields, xmlControlField{Tag: "008", Value: "||||||s" + date1 + strings.Repeat("|", 29)})
+
+	if b.Author != "" {
+		// Names are inverted unless that would be ambiguous.
+		ind1, name := "0", b.Author
+		if i := strings.LastIndexByte(name, ' '); i > 0 && !strings.Contains(name, ",") {
+			ind1, name = "1", name[i+1:]+", "+name[:i]
+		}
+		x.DataFields = append(x.DataFields, xmlDataField{Tag: "100", Ind1: ind1, Ind2: " ", Subfields: []xmlSubfield{{Code: "a", Value: name}}})
+	}
+	x.DataFields = append(x.DataFields, xmlDataField{Tag: "245", Ind1: "0", Ind2: "0", Subfields: []xmlSubfield{{Code: "a", Value: b.Title}}})
+	if pub != "" {
+		x.DataFields = append(x.DataFields, xmlDataField{Tag: "264", Ind1: " ", Ind2: "1", Subfields: []xmlSubfield{{Code: "c", Value: pub}}})
+	}
+	for _, kw := range b.Keywords {
+		x.DataFields = append(x.DataFields, xmlDataField{Tag: "653", Ind1: " ", Ind2: " ", Subfields: []xmlSubfield{{Code: "a", Value: kw.Value}}})
+	}
+	return x
+}
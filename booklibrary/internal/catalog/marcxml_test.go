@@ -0,0 +1,134 @@
+package catalog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+func TestReadMARCXML(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:record>
+    <marc:leader>01142cam  2200301 a 4500</marc:leader>
+    <marc:controlfield tag="001">92005291</marc:controlfield>
+    <marc:controlfield tag="008">920219s1993    caua   j      000 0 eng  </marc:controlfield>
+    <marc:datafield tag="100" ind1="1" ind2=" ">
+      <marc:subfield code="a">Sharpe, Tom,</marc:subfield>
+      <marc:subfield code="d">1928-2013.</marc:subfield>
+    </marc:datafield>
+    <marc:datafield tag="245" ind1="1" ind2="0">
+      <marc:subfield code="a">Wilt :</marc:subfield>
+      <marc:subfield code="b">a novel /</marc:subfield>
+    </marc:datafield>
+    <marc:datafield tag="260" ind1=" " ind2=" ">
+      <marc:subfield code="c">c1976.</marc:subfield>
+    </marc:datafield>
+    <marc:datafield tag="650" ind1=" " ind2="0">
+      <marc:subfield code="a">Humorous stories.</marc:subfield>
+      <marc:subfield code="v">Fiction.</marc:subfield>
+    </marc:datafield>
+  </marc:record>
+</marc:collection>`
+	recs, err := ReadMARCXML(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadMARCXML() error = %v", err)
+	}
+	want := []Record{{
+		Ref: "92005291",
+		Book: model.Book{
+			Author:      "Tom Sharpe",
+			Title:       "Wilt : a novel",
+			ReleaseDate: time.Date(1976, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Keywords:    []model.Keyword{{Value: "Humorous stories"}},
+		},
+	}}
+	if diff := cmp.Diff(want, recs); diff != "" {
+		t.Errorf("ReadMARCXML() mismatch (-want +got):\n%s", diff)
+	}
+
+	// A single record without namespace
+	recs, err = ReadMARCXML(strings.NewReader(`<record><datafield tag="245"><subfield code="a">Untitled</subfield></datafield></record>`))
+	if err != nil {
+		t.Fatalf("ReadMARCXML() error = %v", err)
+	}
+	if len(recs) != 1 || recs[0].Book.Title != "Untitled" {
+		t.Errorf("Unexpected records %+v", recs)
+	}
+
+	if _, err := ReadMARCXML(strings.NewReader(`<collection><record><leader>`)); err == nil {
+		t.Error("ReadMARCXML() of truncated document error = nil, want error")
+	}
+}
+
+func TestMARCXMLRoundTrip(t *testing.T) {
+	books := []model.Book{
+		{
+			ID:          "65a0f0c2e4b0a1b2c3d4e5f6",
+			Author:      "Alan A. A. Donovan",
+			Title:       "The Go Programming Language",
+			ReleaseDate: time.Date(2015, time.October, 26, 0, 0, 0, 0, time.UTC),
+			Keywords:    []model.Keyword{{Value: "Go"}, {Value: "Programming"}},
+		},
+		{
+			ID:          "65a0f0c2e4b0a1b2c3d4e5f7",
+			Author:      "Martin Luther King, Jr.",
+			Title:       "Strength to Love",
+			ReleaseDate: time.Date(1963, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Keywords:    []model.Keyword{{Value: "Sermons"}},
+		},
+		{ID: "65a0f0c2e4b0a1b2c3d4e5f8", Author: "Anonymous", Title: "Beowulf & Grendel <abridged>"},
+	}
+
+	var buf bytes.Buffer
+	w := NewMARCXMLWriter(&buf)
+	for _, b := range books {
+		if err := w.Write(b); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for _, s := range []string{
+		`<collection xmlns="http://www.loc.gov/MARC21/slim">`,
+		`<controlfield tag="008">||||||s2015|||||||||||||||||||||||||||||</controlfield>`,
+		`<datafield tag="100" ind1="1" ind2=" ">`,
+		`<subfield code="a">Donovan, Alan A. A.</subfield>`,
+		`<subfield code="c">2015-10-26</subfield>`,
+		`<subfield code="c">1963</subfield>`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("MARCXML does not contain %s:\n%s", s, buf.String())
+		}
+	}
+
+	recs, err := ReadMARCXML(&buf)
+	if err != nil {
+		t.Fatalf("ReadMARCXML() error = %v", err)
+	}
+	var got []model.Book
+	for _, rec := range recs {
+		if rec.Err != nil {
+			t.Errorf("Unexpected error for record %s: %v", rec.Ref, rec.Err)
+		}
+		rec.Book.ID = rec.Ref
+		got = append(got, rec.Book)
+	}
+	if diff := cmp.Diff(books, got); diff != "" {
+		t.Errorf("Round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	// An empty collection is still a document.
+	buf.Reset()
+	if err := NewMARCXMLWriter(&buf).Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if recs, err := ReadMARCXML(&buf); err != nil || len(recs) != 0 {
+		t.Errorf("ReadMARCXML() of empty collection = %v, %v, want no records", recs, err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // GetEnvString returns the value of the environment variable named by the key, or the default value if the environment variable is not set.
@@ -39,3 +40,29 @@ func GetEnvBool(name string, value bool) bool {
 	}
 	return env
 }
+
+// GetEnvUint64 returns the value of the environment variable named by the key, or the default value if the environment variable is not set or is not a valid unsigned integer.
+func GetEnvUint64(name string, value uint64) uint64 {
+	envStr, ok := os.LookupEnv(name)
+	if !ok {
+		return value
+	}
+	env, err := strconv.ParseUint(envStr, 10, 64)
+	if err != nil {
+		return value
+	}
+	return env
+}
+
+// GetEnvDuration returns the value of the environment variable named by the key, or the default value if the environment variable is not set or is not a valid duration.
+func GetEnvDuration(name string, value time.Duration) time.Duration {
+	envStr, ok := os.LookupEnv(name)
+	if !ok {
+		return value
+	}
+	env, err := time.ParseDuration(envStr)
+	if err != nil {
+		return value
+	}
+	return env
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		set  bool
+		want time.Duration
+	}{
+		{"unset", "", false, time.Second},
+		{"valid", "250ms", true, 250 * time.Millisecond},
+		{"invalid", "soon", true, time.Second},
+		{"integer without unit", "5", true, time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.set {
+				t.Setenv("BOOKLIBRARY_TEST_DURATION", tc.env)
+			}
+			if got := GetEnvDuration("BOOKLIBRARY_TEST_DURATION", time.Second); got != tc.want {
+				t.Errorf("Unexpected duration, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvUint64(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		set  bool
+		want uint64
+	}{
+		{"unset", "", false, 100},
+		{"valid", "20", true, 20},
+		{"zero", "0", true, 0},
+		{"negative", "-1", true, 100},
+		{"invalid", "many", true, 100},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.set {
+				t.Setenv("BOOKLIBRARY_TEST_UINT64", tc.env)
+			}
+			if got := GetEnvUint64("BOOKLIBRARY_TEST_UINT64", 100); got != tc.want {
+				t.Errorf("Unexpected value, got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
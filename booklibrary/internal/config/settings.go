@@ -1,15 +1,115 @@
 package config
 
+import "time"
+
 // Settings represents the application settings.
 type Settings struct {
 	// Port is the port the HTTP server listens on.
 	Port int
+	// GRPCPort is the port the gRPC server listens on. Zero disables the gRPC server.
+	GRPCPort int
 	// MongoURI is the MongoDB connection string.
 	MongoURI string
 	// MongoDB is the MongoDB database name.
 	Db string
 	// Collection is the MongoDB collection name.
 	Collection string
-	// Debug is the debug mode (verbose logging).
+	// Debug is the debug mode (verbose logging). The log level can be changed at runtime through /admin.
 	Debug bool
+	// LogFormat is the log record format, either "text" or "json".
+	LogFormat string
+	// ReadTimeout is the HTTP server's maximum duration for reading an entire request.
+	ReadTimeout time.Duration
+	// WriteTimeout is the HTTP server's maximum duration before timing out writes of a response.
+	WriteTimeout time.Duration
+	// IdleTimeout is the HTTP server's maximum time to wait for the next request on a keep-alive connection.
+	IdleTimeout time.Duration
+	// MongoTimeout is the maximum duration of a single MongoDB operation.
+	MongoTimeout time.Duration
+	// MongoStartupTimeout is the maximum duration for connecting to MongoDB at startup.
+	MongoStartupTimeout time.Duration
+	// MongoMaxPoolSize is the maximum number of connections in the MongoDB connection pool.
+	MongoMaxPoolSize uint64
+	// MongoMaxConnIdleTime is the maximum time a pooled MongoDB connection may remain idle.
+	MongoMaxConnIdleTime time.Duration
+	// MongoRetryWrites enables retryable writes.
+	MongoRetryWrites bool
+	// MongoRetryReads enables retryable reads.
+	MongoRetryReads bool
+	// Tracing enables OpenTelemetry tracing.
+	Tracing bool
+	// RateLimitRead is the number of read requests a client may make per RateLimitPeriod. Zero disables the limit.
+	RateLimitRead int
+	// RateLimitWrite is the number of write requests a client may make per RateLimitPeriod. Zero disables the limit.
+	RateLimitWrite int
+	// RateLimitPeriod is the period over which rate limits are enforced.
+	RateLimitPeriod time.Duration
+	// RateLimitByAPIKey identifies clients by their X-API-Key header instead of their IP address.
+	RateLimitByAPIKey bool
+	// TrustProxy takes the client IP address from proxy headers such as X-Forwarded-For.
+	TrustProxy bool
+	// BreakerThreshold is the number of consecutive store failures that open the circuit breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit breaker stays open before probing the store.
+	BreakerCooldown time.Duration
+	// ReadAttempts is the maximum number of attempts for reads that fail with transient errors.
+	ReadAttempts int
+	// RetryBaseDelay is the base delay of the exponential backoff between read attempts.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay is the maximum delay between read attempts.
+	RetryMaxDelay time.Duration
+	// CacheSize is the number of books and lists held in the response cache. Zero disables the cache.
+	CacheSize int
+	// CacheTTL is how long cached books and lists are served.
+	CacheTTL time.Duration
+	// HealthCacheTTL is how long health check results are cached.
+	HealthCacheTTL time.Duration
+	// HealthDiskPath is the path whose file system's free space is checked. Empty disables the check.
+	HealthDiskPath string
+	// HealthDiskMinFree is the free space in bytes below which the disk check reports down.
+	HealthDiskMinFree uint64
+	// HealthDiskWarnFree is the free space in bytes below which the disk check reports degraded.
+	HealthDiskWarnFree uint64
+	// TLSCertFile is the PEM file with the server certificate chain. Empty serves plain HTTP.
+	TLSCertFile string
+	// TLSKeyFile is the PEM file with the server certificate's private key.
+	TLSKeyFile string
+	// TLSClientCAFile is the PEM file with the CAs that client certificates are verified against. Empty disables mutual TLS.
+	TLSClientCAFile string
+	// TLSClientAuth is "require" to reject clients without a valid certificate, or "optional".
+	TLSClientAuth string
+	// TLSIdentity is the client certificate field mapped to the client's identity: cn, subject, email, uri or dns.
+	TLSIdentity string
+	// TLSMinVersion is the minimum TLS version, 1.2 or 1.3.
+	TLSMinVersion string
+	// TLSCipherSuites is a comma-separated list of TLS 1.2 cipher suites. Empty uses Go's defaults.
+	TLSCipherSuites string
+	// TLSReloadInterval is how often the certificate and CA files are checked for changes.
+	TLSReloadInterval time.Duration
+	// PreStopDelay is how long the service keeps serving after failing readiness on shutdown, so that load balancers stop sending traffic first.
+	PreStopDelay time.Duration
+	// ShutdownTimeout is the maximum time for draining in-flight requests on shutdown.
+	ShutdownTimeout time.Duration
+	// ListsCollection is the MongoDB collection of reading lists.
+	ListsCollection string
+	// TrustUserHeader identifies users by the X-User-ID header if they present no TLS client certificate.
+	TrustUserHeader bool
+	// LoansCollection is the MongoDB collection of loans.
+	LoansCollection string
+	// CopiesCollection is the MongoDB collection that counts the copies of each book.
+	CopiesCollection string
+	// LoanPeriod is how long books are lent by default and on each renewal.
+	LoanPeriod time.Duration
+	// MaxRenewals is how often a loan can be renewed.
+	MaxRenewals int
+	// IdempotencyCollection is the MongoDB collection of idempotency keys and recorded responses.
+	IdempotencyCollection string
+	// IdempotencyTTL is how long responses to requests with an Idempotency-Key are kept for retries. Zero disables idempotency keys.
+	IdempotencyTTL time.Duration
+	// SimilarRebuildInterval is how often the similar books index is rebuilt from the database. Zero only builds it at startup.
+	SimilarRebuildInterval time.Duration
+	// AdminToken is the bearer token required by the /admin endpoints. Empty disables them.
+	AdminToken string
+	// OTLPEndpoint is the OTLP/HTTP endpoint URL spans are exported to. If empty, spans are written to stdout.
+	OTLPEndpoint string
 }
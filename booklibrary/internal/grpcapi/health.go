@@ -0,0 +1,82 @@
+package grpcapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthServer implements the gRPC health checking protocol on top of a health.Registry. The
+// server as a whole ("") and the BookLibrary service share one status: serving unless a critical
+// check is down.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	registry *health.Registry
+	services []string
+	interval time.Duration
+}
+
+func (hs healthServer) known(service string) bool {
+	for _, s := range hs.services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+func (hs healthServer) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if hs.registry.Report(ctx).Status == health.StatusDown {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+// Check returns the current status of the requested service.
+func (hs healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !hs.known(req.GetService()) {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: hs.status(ctx)}, nil
+}
+
+// List returns the current status of all services.
+func (hs healthServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	st := hs.status(ctx)
+	res := &healthpb.HealthListResponse{Statuses: make(map[string]*healthpb.HealthCheckResponse, len(hs.services))}
+	for _, s := range hs.services {
+		res.Statuses[s] = &healthpb.HealthCheckResponse{Status: st}
+	}
+	return res, nil
+}
+
+// Watch sends the status of the requested service right away and whenever it changes. Unknown
+// services are reported as SERVICE_UNKNOWN, as required by the protocol.
+func (hs healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(hs.interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		if hs.known(req.GetService()) {
+			st = hs.status(ctx)
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key used to propagate request IDs, matching the REST API's X-Request-ID.
+const requestIDKey = "x-request-id"
+
+// unaryLogger attaches a logger carrying the call's request ID to its context and writes one access
+// log record per call.
+func unaryLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, logger := withLogger(ctx)
+	res, err := handler(ctx, req)
+	logCall(ctx, logger, info.FullMethod, start, err)
+	return res, err
+}
+
+// streamLogger is the streaming counterpart of unaryLogger.
+func streamLogger(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, logger := withLogger(ss.Context())
+	err := handler(srv, &loggingStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, logger, info.FullMethod, start, err)
+	return err
+}
+
+type loggingStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggingStream) Context() context.Context {
+	return s.ctx
+}
+
+func withLogger(ctx context.Context) (context.Context, *slog.Logger) {
+	logger := log.FromContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDKey); len(ids) > 0 && ids[0] != "" {
+			logger = logger.With(log.RequestIDKey, ids[0])
+		}
+	}
+	return log.NewContext(ctx, logger), logger
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	level := slog.LevelInfo
+	if method == healthpb.Health_Check_FullMethodName || method == healthpb.Health_Watch_FullMethodName {
+		level = slog.LevelDebug
+	}
+	logger.LogAttrs(ctx, level, "rpc",
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("latency", time.Since(start)))
+}
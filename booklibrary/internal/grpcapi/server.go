@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"time"
+
+	booklibraryv1 "github.com/joergjo/go-samples/booklibrary/api/booklibrary/v1"
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+const defaultWatchInterval = 5 * time.Second
+
+type settings struct {
+	health        *health.Registry
+	watchInterval time.Duration
+	serverOpts    []grpc.ServerOption
+}
+
+// Option configures the server created by NewServer.
+type Option func(*settings)
+
+// WithHealth reports the checks registered with h through the gRPC health service. Without it, the
+// health service only checks the data store with CrudService.Ping.
+func WithHealth(h *health.Registry) Option {
+	return func(s *settings) {
+		s.health = h
+	}
+}
+
+// WithWatchInterval sets how often health Watch streams re-evaluate the service's status.
+func WithWatchInterval(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.watchInterval = d
+		}
+	}
+}
+
+// WithServerOptions passes additional options to grpc.NewServer.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *settings) {
+		s.serverOpts = append(s.serverOpts, opts...)
+	}
+}
+
+// NewServer creates a gRPC server offering the BookLibrary service backed by crud, the gRPC health
+// checking service and server reflection.
+func NewServer(crud model.CrudService, opts ...Option) *grpc.Server {
+	s := settings{watchInterval: defaultWatchInterval}
+	for _, o := range opts {
+		o(&s)
+	}
+	h := s.health
+	if h == nil {
+		h = health.NewRegistry(0)
+		h.Register("database", crud.Ping)
+	}
+
+	serverOpts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryLogger),
+		grpc.ChainStreamInterceptor(streamLogger),
+	}, s.serverOpts...)
+	srv := grpc.NewServer(serverOpts...)
+	booklibraryv1.RegisterBookLibraryServer(srv, bookLibrary{crud: crud})
+	healthpb.RegisterHealthServer(srv, healthServer{
+		registry: h,
+		services: []string{"", booklibraryv1.BookLibrary_ServiceDesc.ServiceName},
+		interval: s.watchInterval,
+	})
+	reflection.Register(srv)
+	return srv
+}
@@ -0,0 +1,205 @@
+package grpcapi_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	booklibraryv1 "github.com/joergjo/go-samples/booklibrary/api/booklibrary/v1"
+	"github.com/joergjo/go-samples/booklibrary/internal/grpcapi"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type storeStub struct {
+	model.CrudService
+	books map[string]model.Book
+	ping  error
+	err   error
+}
+
+func (s *storeStub) List(_ context.Context, limit int) ([]model.Book, error) {
+	var books []model.Book
+	for _, b := range s.books {
+		if len(books) == limit {
+			break
+		}
+		books = append(books, b)
+	}
+	return books, nil
+}
+
+func (s *storeStub) Get(_ context.Context, id string) (model.Book, error) {
+	if s.err != nil {
+		return model.Book{}, s.err
+	}
+	if len(id) != 24 {
+		return model.Book{}, model.ErrInvalidID
+	}
+	b, ok := s.books[id]
+	if !ok {
+		return model.Book{}, model.ErrNotFound
+	}
+	return b, nil
+}
+
+func (s *storeStub) Add(_ context.Context, b model.Book) (model.Book, error) {
+	b.ID = "000000000000000000000002"
+	s.books[b.ID] = b
+	return b, nil
+}
+
+func (s *storeStub) Ping(_ context.Context) error {
+	return s.ping
+}
+
+func dial(t *testing.T, crud model.CrudService) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpcapi.NewServer(crud)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Error dialing server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBookLibrary(t *testing.T) {
+	released := time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC)
+	crud := &storeStub{books: map[string]model.Book{
+		"000000000000000000000001": {
+			ID:          "000000000000000000000001",
+			Author:      "John Doe",
+			Title:       "gRPC in Go",
+			ReleaseDate: released,
+			Keywords:    []model.Keyword{{Value: "Go"}, {Value: "gRPC"}},
+		},
+	}}
+	client := booklibraryv1.NewBookLibraryClient(dial(t, crud))
+	ctx := context.Background()
+
+	want := &booklibraryv1.Book{
+		Id:          "000000000000000000000001",
+		Author:      "John Doe",
+		Title:       "gRPC in Go",
+		ReleaseDate: timestamppb.New(released),
+		Keywords:    []string{"Go", "gRPC"},
+	}
+
+	t.Run("list", func(t *testing.T) {
+		stream, err := client.List(ctx, &booklibraryv1.ListRequest{})
+		if err != nil {
+			t.Fatalf("Error listing books: %v", err)
+		}
+		var got []*booklibraryv1.Book
+		for {
+			b, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Error receiving book: %v", err)
+			}
+			got = append(got, b)
+		}
+		if diff := cmp.Diff([]*booklibraryv1.Book{want}, got, protocmp.Transform()); diff != "" {
+			t.Fatalf("Unexpected books (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		got, err := client.Get(ctx, &booklibraryv1.GetRequest{Id: want.Id})
+		if err != nil {
+			t.Fatalf("Error getting book: %v", err)
+		}
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Fatalf("Unexpected book (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("add", func(t *testing.T) {
+		got, err := client.Add(ctx, &booklibraryv1.AddRequest{Book: &booklibraryv1.Book{Title: "Protocol Buffers", ReleaseDate: timestamppb.New(released)}})
+		if err != nil {
+			t.Fatalf("Error adding book: %v", err)
+		}
+		if got.GetId() == "" || !got.GetReleaseDate().AsTime().Equal(released) {
+			t.Fatalf("Unexpected book %v", got)
+		}
+	})
+
+	errTests := []struct {
+		name string
+		id   string
+		want codes.Code
+	}{
+		{"not_found", "000000000000000000000009", codes.NotFound},
+		{"invalid_id", "42", codes.InvalidArgument},
+	}
+	for _, tc := range errTests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.Get(ctx, &booklibraryv1.GetRequest{Id: tc.id})
+			if got := status.Code(err); got != tc.want {
+				t.Fatalf("Received unexpected status code, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInternalError(t *testing.T) {
+	crud := &storeStub{err: errors.New("connection(localhost:27017[-1]) incomplete read of message header")}
+	client := booklibraryv1.NewBookLibraryClient(dial(t, crud))
+
+	_, err := client.Get(context.Background(), &booklibraryv1.GetRequest{Id: "000000000000000000000001"})
+	st := status.Convert(err)
+	if st.Code() != codes.Internal {
+		t.Fatalf("Received unexpected status code, got %v, want %v", st.Code(), codes.Internal)
+	}
+	// Details of the data store are not passed on to clients.
+	if got, want := st.Message(), "internal error"; got != want {
+		t.Errorf("Unexpected status message, got %q, want %q", got, want)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		ping    error
+		want    healthpb.HealthCheckResponse_ServingStatus
+		code    codes.Code
+	}{
+		{"server", "", nil, healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"service", "booklibrary.v1.BookLibrary", nil, healthpb.HealthCheckResponse_SERVING, codes.OK},
+		{"database_down", "", errors.New("connection refused"), healthpb.HealthCheckResponse_NOT_SERVING, codes.OK},
+		{"unknown_service", "unknown", nil, healthpb.HealthCheckResponse_UNKNOWN, codes.NotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := healthpb.NewHealthClient(dial(t, &storeStub{ping: tc.ping}))
+			res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: tc.service})
+			if got := status.Code(err); got != tc.code {
+				t.Fatalf("Received unexpected status code, got %v, want %v", got, tc.code)
+			}
+			if got := res.GetStatus(); got != tc.want {
+				t.Fatalf("Received unexpected serving status, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
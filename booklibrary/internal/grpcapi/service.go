@@ -0,0 +1,133 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	booklibraryv1 "github.com/joergjo/go-samples/booklibrary/api/booklibrary/v1"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultLimit = 100
+
+// bookLibrary implements the BookLibrary gRPC service on top of a model.CrudService.
+type bookLibrary struct {
+	booklibraryv1.UnimplementedBookLibraryServer
+	crud model.CrudService
+}
+
+// List streams up to req.Limit books, or at most 100 if the limit is not positive.
+func (bl bookLibrary) List(req *booklibraryv1.ListRequest, stream booklibraryv1.BookLibrary_ListServer) error {
+	limit := int(req.GetLimit())
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	books, err := bl.crud.List(stream.Context(), limit)
+	if err != nil {
+		return toStatus(stream.Context(), err)
+	}
+	for _, b := range books {
+		if err := stream.Send(toProto(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns a single book by its ID.
+func (bl bookLibrary) Get(ctx context.Context, req *booklibraryv1.GetRequest) (*booklibraryv1.Book, error) {
+	book, err := bl.crud.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(book), nil
+}
+
+// Add adds a new book to the library.
+func (bl bookLibrary) Add(ctx context.Context, req *booklibraryv1.AddRequest) (*booklibraryv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+	added, err := bl.crud.Add(ctx, fromProto(req.GetBook()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(added), nil
+}
+
+// Update replaces a book in the library with the given ID.
+func (bl bookLibrary) Update(ctx context.Context, req *booklibraryv1.UpdateRequest) (*booklibraryv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+	updated, err := bl.crud.Update(ctx, req.GetId(), fromProto(req.GetBook()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(updated), nil
+}
+
+// Remove deletes a book from the library by its ID.
+func (bl bookLibrary) Remove(ctx context.Context, req *booklibraryv1.RemoveRequest) (*booklibraryv1.Book, error) {
+	removed, err := bl.crud.Remove(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(removed), nil
+}
+
+// toStatus maps errors of the data store to gRPC status errors. Unexpected errors are logged and
+// reported without their details, which may reveal internals of the data store.
+func toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, model.ErrCopiesOnLoan):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		log.FromContext(ctx).ErrorContext(ctx, "database access", log.ErrorKey, err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toProto(b model.Book) *booklibraryv1.Book {
+	kws := make([]string, len(b.Keywords))
+	for i, kw := range b.Keywords {
+		kws[i] = kw.Value
+	}
+	return &booklibraryv1.Book{
+		Id:          b.ID,
+		Author:      b.Author,
+		Title:       b.Title,
+		ReleaseDate: timestamppb.New(b.ReleaseDate),
+		Keywords:    kws,
+	}
+}
+
+// fromProto converts a protobuf book to a model.Book. The ID is ignored, since it is either
+// assigned by the store or taken from the request.
+func fromProto(b *booklibraryv1.Book) model.Book {
+	kws := make([]model.Keyword, len(b.GetKeywords()))
+	for i, kw := range b.GetKeywords() {
+		kws[i] = model.Keyword{Value: kw}
+	}
+	book := model.Book{
+		Author:   b.GetAuthor(),
+		Title:    b.GetTitle(),
+		Keywords: kws,
+	}
+	if b.GetReleaseDate() != nil {
+		book.ReleaseDate = b.GetReleaseDate().AsTime()
+	}
+	return book
+}
@@ -0,0 +1,24 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// DiskSpace returns a check that reports down if the file system containing path has less than
+// minFree bytes available, and degraded if it has less than warnFree bytes available.
+func DiskSpace(path string, minFree, warnFree uint64) CheckFunc {
+	return func(_ context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%d bytes free on %s, need at least %d", free, path, minFree)
+		}
+		if free < warnFree {
+			return Degraded(fmt.Errorf("%d bytes free on %s, below warning threshold of %d", free, path, warnFree))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,201 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the state of a single check or of the service as a whole.
+type Status string
+
+const (
+	// StatusOK means the dependency is fully functional.
+	StatusOK Status = "ok"
+	// StatusDegraded means the dependency has problems, but the service can still handle requests.
+	StatusDegraded Status = "degraded"
+	// StatusDown means the dependency is unavailable and the service cannot handle requests.
+	StatusDown Status = "down"
+)
+
+// ShutdownCheck is the name of the result reported once the service is shutting down.
+const ShutdownCheck = "shutdown"
+
+var errShuttingDown = errors.New("service is shutting down")
+
+const (
+	defaultTTL     = 2 * time.Second
+	defaultTimeout = 5 * time.Second
+)
+
+// CheckFunc checks a dependency. It returns nil if the dependency is healthy. Errors wrapped with
+// Degraded mark the dependency as degraded instead of down.
+type CheckFunc func(ctx context.Context) error
+
+type degradedError struct {
+	err error
+}
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+// Degraded wraps err so that a failing check reports StatusDegraded rather than StatusDown.
+func Degraded(err error) error {
+	return degradedError{err: err}
+}
+
+// CheckOption configures a registered check.
+type CheckOption func(*check)
+
+// NonCritical makes any failure of the check degrade the service instead of taking it down.
+func NonCritical() CheckOption {
+	return func(c *check) {
+		c.critical = false
+	}
+}
+
+// WithTimeout sets the maximum duration of a single run of the check.
+func WithTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status    Status    `json:"status"`
+	LatencyMS float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Report is the aggregated outcome of all registered checks.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	critical bool
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	last Result
+}
+
+// Registry runs named health checks and caches their results.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   []*check
+	ttl      time.Duration
+	started  atomic.Bool
+	shutdown atomic.Bool
+	now      func() time.Time
+}
+
+// NewRegistry creates an empty Registry that caches check results for ttl. A ttl of zero selects
+// a default of two seconds.
+func NewRegistry(ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Registry{ttl: ttl, now: time.Now}
+}
+
+// Register adds a named check. Checks are critical unless NonCritical is passed.
+func (r *Registry) Register(name string, fn CheckFunc, opts ...CheckOption) {
+	c := &check{name: name, fn: fn, critical: true, timeout: defaultTimeout}
+	for _, o := range opts {
+		o(c)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+}
+
+// Shutdown marks the service as shutting down. From then on, Report reports the service as down
+// without running any checks, so that load balancers stop sending traffic right away.
+func (r *Registry) Shutdown() {
+	r.shutdown.Store(true)
+}
+
+// Report runs all checks whose cached result has expired, concurrently, and aggregates the results.
+// The service is down if any critical check is down, degraded if any check is not ok, and ok otherwise.
+func (r *Registry) Report(ctx context.Context) Report {
+	if r.shutdown.Load() {
+		res := Result{Status: StatusDown, CheckedAt: r.now(), Error: errShuttingDown.Error()}
+		return Report{Status: StatusDown, Checks: map[string]Result{ShutdownCheck: res}}
+	}
+
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		res := results[i]
+		rep.Checks[c.name] = res
+		switch {
+		case res.Status == StatusDown:
+			rep.Status = StatusDown
+		case res.Status != StatusOK && rep.Status == StatusOK:
+			rep.Status = StatusDegraded
+		}
+	}
+	return rep
+}
+
+// Started reports whether all critical checks have passed at least once. Once true, it stays true.
+func (r *Registry) Started(ctx context.Context) bool {
+	if r.started.Load() {
+		return true
+	}
+	if r.Report(ctx).Status == StatusDown {
+		return false
+	}
+	r.started.Store(true)
+	return true
+}
+
+func (r *Registry) run(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := r.now()
+	if !c.last.CheckedAt.IsZero() && now.Sub(c.last.CheckedAt) < r.ttl {
+		return c.last
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := c.fn(ctx)
+	res := Result{
+		Status:    StatusOK,
+		LatencyMS: float64(r.now().Sub(now).Microseconds()) / 1000,
+		CheckedAt: now,
+	}
+	if err != nil {
+		res.Error = err.Error()
+		res.Status = StatusDown
+		if !c.critical || errors.As(err, new(degradedError)) {
+			res.Status = StatusDegraded
+		}
+	}
+	c.last = res
+	return res
+}
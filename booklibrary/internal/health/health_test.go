@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name   string
+		checks map[string]CheckFunc
+		opts   map[string][]CheckOption
+		want   Status
+	}{
+		{
+			name:   "all_ok",
+			checks: map[string]CheckFunc{"a": ok, "b": ok},
+			want:   StatusOK,
+		},
+		{
+			name:   "critical_down",
+			checks: map[string]CheckFunc{"a": ok, "b": fail(errDown)},
+			want:   StatusDown,
+		},
+		{
+			name:   "non_critical_down",
+			checks: map[string]CheckFunc{"a": ok, "b": fail(errDown)},
+			opts:   map[string][]CheckOption{"b": {NonCritical()}},
+			want:   StatusDegraded,
+		},
+		{
+			name:   "critical_degraded",
+			checks: map[string]CheckFunc{"a": ok, "b": fail(Degraded(errDown))},
+			want:   StatusDegraded,
+		},
+		{
+			name:   "degraded_and_down",
+			checks: map[string]CheckFunc{"a": fail(Degraded(errDown)), "b": fail(errDown)},
+			want:   StatusDown,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry(0)
+			for name, fn := range tc.checks {
+				r.Register(name, fn, tc.opts[name]...)
+			}
+			rep := r.Report(context.Background())
+			if rep.Status != tc.want {
+				t.Errorf("Unexpected status, got %q, want %q", rep.Status, tc.want)
+			}
+			if got := len(rep.Checks); got != len(tc.checks) {
+				t.Errorf("Unexpected number of results, got %d, want %d", got, len(tc.checks))
+			}
+		})
+	}
+}
+
+func TestReportCachesResults(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRegistry(time.Second)
+	r.now = func() time.Time { return now }
+	calls := 0
+	r.Register("counting", func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	r.Report(context.Background())
+	r.Report(context.Background())
+	if calls != 1 {
+		t.Errorf("Expected cached result, check ran %d times", calls)
+	}
+	now = now.Add(time.Second)
+	r.Report(context.Background())
+	if calls != 2 {
+		t.Errorf("Expected expired result to be refreshed, check ran %d times", calls)
+	}
+}
+
+func TestStarted(t *testing.T) {
+	r := NewRegistry(time.Nanosecond)
+	var err error
+	r.Register("flaky", func(context.Context) error { return err })
+
+	err = errors.New("not yet")
+	if r.Started(context.Background()) {
+		t.Fatalf("Expected startup to be pending while critical check is down")
+	}
+	err = nil
+	if !r.Started(context.Background()) {
+		t.Fatalf("Expected startup to complete once critical check passes")
+	}
+	err = errors.New("down again")
+	if !r.Started(context.Background()) {
+		t.Fatalf("Expected startup to remain complete")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	calls := 0
+	r.Register("counting", func(context.Context) error {
+		calls++
+		return nil
+	})
+	if rep := r.Report(context.Background()); rep.Status != StatusOK {
+		t.Fatalf("Unexpected status before shutdown, got %q, want %q", rep.Status, StatusOK)
+	}
+
+	r.Shutdown()
+	rep := r.Report(context.Background())
+	if rep.Status != StatusDown {
+		t.Errorf("Unexpected status after shutdown, got %q, want %q", rep.Status, StatusDown)
+	}
+	if _, ok := rep.Checks[ShutdownCheck]; !ok {
+		t.Errorf("Expected %q result in report, got %v", ShutdownCheck, rep.Checks)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no checks to run after shutdown, check ran %d times", calls)
+	}
+}
+
+func ok(context.Context) error { return nil }
+
+func fail(err error) CheckFunc {
+	return func(context.Context) error { return err }
+}
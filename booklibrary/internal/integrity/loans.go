@@ -0,0 +1,60 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// LoanService decorates a model.LoanService so that only books in the catalogue can have copies and
+// be checked out.
+type LoanService struct {
+	model.LoanService
+	books model.CrudService
+}
+
+// NewLoanService wraps next so that SetCopies and Checkout only accept books that exist in books.
+// books should not be cached, since a cached book may already have been removed.
+func NewLoanService(next model.LoanService, books model.CrudService) *LoanService {
+	return &LoanService{LoanService: next, books: books}
+}
+
+// SetCopies sets the number of copies of the book with the given ID if it exists. CrudService.Remove
+// may have withdrawn the copies of a book removed while they are set, so the book is looked up
+// again and its copies withdrawn if it is gone. SetCopies returns ErrNotFound in both cases.
+func (ls *LoanService) SetCopies(ctx context.Context, bookID string, copies int) (model.Availability, error) {
+	if _, err := ls.books.Get(ctx, bookID); err != nil {
+		return model.Availability{}, err
+	}
+	a, err := ls.LoanService.SetCopies(ctx, bookID, copies)
+	if err != nil {
+		return a, err
+	}
+	_, err = ls.books.Get(ctx, bookID)
+	switch {
+	case err == nil:
+		return a, nil
+	case !errors.Is(err, model.ErrNotFound):
+		return model.Availability{}, err
+	}
+	// Withdrawing the copies must not be abandoned because the caller is gone.
+	cleanupCtx := context.WithoutCancel(ctx)
+	if _, err := ls.LoanService.SetCopies(cleanupCtx, bookID, 0); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "withdrawing copies of missing book", log.ErrorKey, err, log.IdKey, bookID)
+		return model.Availability{}, fmt.Errorf("withdrawing copies of missing book %s: %w", bookID, err)
+	}
+	return model.Availability{}, fmt.Errorf("book %s: %w", bookID, model.ErrNotFound)
+}
+
+// Checkout lends a copy of the loan's book if the book exists. CrudService.Remove withdraws the
+// copies of a book before it removes the book, so checking out a book removed meanwhile fails with
+// ErrNoCopyAvailable.
+func (ls *LoanService) Checkout(ctx context.Context, loan model.Loan) (model.Loan, error) {
+	if _, err := ls.books.Get(ctx, loan.BookID); err != nil {
+		return model.Loan{}, err
+	}
+	return ls.LoanService.Checkout(ctx, loan)
+}
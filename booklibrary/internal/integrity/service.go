@@ -0,0 +1,110 @@
+// Package integrity keeps reading lists, copies and loans consistent with the book catalogue.
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// Compile-time checks to verify we implement Storage
+var (
+	_ model.CrudService = (*CrudService)(nil)
+	_ model.ListService = (*ListService)(nil)
+	_ model.LoanService = (*LoanService)(nil)
+)
+
+// CrudService decorates a model.CrudService so that books on loan cannot be removed, and removing a
+// book also removes its copies and removes it from all reading lists. Books are removed before
+// they are removed from lists, so that ListService can detect books removed while they are put on
+// a list. A failure to update the lists leaves references to a missing book, which Remove reports;
+// removing the book again retries the cleanup.
+type CrudService struct {
+	model.CrudService
+	lists model.ListService
+	loans model.LoanService
+}
+
+// NewCrudService wraps next so that Remove also removes books from lists and their copies from
+// loans.
+func NewCrudService(next model.CrudService, lists model.ListService, loans model.LoanService) *CrudService {
+	return &CrudService{CrudService: next, lists: lists, loans: loans}
+}
+
+// Find returns the books matching filter, if next is a model.Finder.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	return model.Find(ctx, cs.CrudService, filter)
+}
+
+// Remove deletes the book with the given ID from next and from all reading lists. It returns
+// ErrCopiesOnLoan if copies of the book are on loan. The book's copies are withdrawn before it is
+// removed, which fails while copies are on loan and keeps copies from being checked out while it
+// is removed. They are restored if the book cannot be removed. If the book is already gone, it is
+// still removed from the lists, since an earlier Remove may have failed to clean them up.
+func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
+	held, err := cs.loans.Availability(ctx, id)
+	if err != nil {
+		return model.Book{}, err
+	}
+	// A LoanService that only accepts books in the catalogue reports a removed book as not found.
+	if _, err := cs.loans.SetCopies(ctx, id, 0); err != nil && !errors.Is(err, model.ErrNotFound) {
+		return model.Book{}, fmt.Errorf("withdrawing copies of book %s: %w", id, err)
+	}
+	// Cleaning up must not be abandoned because the caller is gone.
+	cleanupCtx := context.WithoutCancel(ctx)
+	removed, err := cs.CrudService.Remove(ctx, id)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		if _, rerr := cs.loans.SetCopies(cleanupCtx, id, held.Copies); rerr != nil {
+			log.FromContext(ctx).ErrorContext(ctx, "restoring copies", log.ErrorKey, rerr, log.IdKey, id)
+		}
+		return removed, err
+	}
+	if err := cs.lists.RemoveBookFromLists(cleanupCtx, id); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "removing book from reading lists", log.ErrorKey, err, log.IdKey, id)
+		return removed, fmt.Errorf("removing book %s from reading lists: %w", id, err)
+	}
+	return removed, err
+}
+
+// ListService decorates a model.ListService so that only books in the catalogue can be put on
+// reading lists.
+type ListService struct {
+	model.ListService
+	books model.CrudService
+}
+
+// NewListService wraps next so that PutBook only puts books that exist in books. books should not
+// be cached, since a cached book may already have been removed.
+func NewListService(next model.ListService, books model.CrudService) *ListService {
+	return &ListService{ListService: next, books: books}
+}
+
+// PutBook puts the book with the given ID on a list if it exists. A book removed while it is put
+// may have been cleaned up from the lists before it was put, so the book is looked up again and
+// taken off the list if it is gone. PutBook returns ErrNotFound in both cases.
+func (ls *ListService) PutBook(ctx context.Context, owner, id, bookID string, position int) (model.ReadingList, error) {
+	if _, err := ls.books.Get(ctx, bookID); err != nil {
+		return model.ReadingList{}, err
+	}
+	l, err := ls.ListService.PutBook(ctx, owner, id, bookID, position)
+	if err != nil {
+		return l, err
+	}
+	_, err = ls.books.Get(ctx, bookID)
+	switch {
+	case err == nil:
+		return l, nil
+	case !errors.Is(err, model.ErrNotFound):
+		return model.ReadingList{}, err
+	}
+	// Removing the reference must not be abandoned because the caller is gone.
+	cleanupCtx := context.WithoutCancel(ctx)
+	if _, err := ls.ListService.RemoveBook(cleanupCtx, owner, id, bookID); err != nil && !errors.Is(err, model.ErrNotFound) {
+		log.FromContext(ctx).ErrorContext(ctx, "removing missing book from reading list", log.ErrorKey, err, log.IdKey, bookID)
+		return model.ReadingList{}, fmt.Errorf("removing missing book %s from reading list: %w", bookID, err)
+	}
+	return model.ReadingList{}, fmt.Errorf("book %s: %w", bookID, model.ErrNotFound)
+}
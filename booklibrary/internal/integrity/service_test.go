@@ -0,0 +1,222 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) model.CrudService {
+		return NewCrudService(memory.NewCrudService(), memory.NewListService(), memory.NewLoanService())
+	})
+}
+
+func TestRemoveFromLists(t *testing.T) {
+	ctx := context.Background()
+	books := memory.NewCrudService()
+	lists := memory.NewListService()
+	crud := NewCrudService(books, lists, memory.NewLoanService())
+
+	kept, err := crud.Add(ctx, model.Book{Title: "Kept"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	removed, err := crud.Add(ctx, model.Book{Title: "Removed"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	l, err := lists.AddList(ctx, model.ReadingList{Owner: "alice", Name: "Favorites", Visibility: model.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("AddList() error = %v", err)
+	}
+	for _, id := range []string{removed.ID, kept.ID} {
+		if _, err := lists.PutBook(ctx, "alice", l.ID, id, -1); err != nil {
+			t.Fatalf("PutBook() error = %v", err)
+		}
+	}
+
+	if _, err := crud.Remove(ctx, removed.ID); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	got, err := lists.GetList(ctx, "alice", l.ID)
+	if err != nil {
+		t.Fatalf("GetList() error = %v", err)
+	}
+	if len(got.BookIDs) != 1 || got.BookIDs[0] != kept.ID {
+		t.Errorf("Unexpected books on list after Remove(), got %v, want [%s]", got.BookIDs, kept.ID)
+	}
+
+	if _, err := crud.Remove(ctx, removed.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Second Remove() error = %v, want %v", err, model.ErrNotFound)
+	}
+}
+
+// failingLists fails RemoveBookFromLists while fail is set.
+type failingLists struct {
+	model.ListService
+	fail bool
+}
+
+func (fl *failingLists) RemoveBookFromLists(ctx context.Context, bookID string) error {
+	if fl.fail {
+		return errors.New("lists unavailable")
+	}
+	return fl.ListService.RemoveBookFromLists(ctx, bookID)
+}
+
+func TestRemoveRetriesCleanup(t *testing.T) {
+	ctx := context.Background()
+	books := memory.NewCrudService()
+	lists := &failingLists{ListService: memory.NewListService(), fail: true}
+	// Wire the stores as main does, so that withdrawing copies of the removed book fails.
+	crud := NewCrudService(books, lists, NewLoanService(memory.NewLoanService(), books))
+	b, err := crud.Add(ctx, model.Book{Title: "Removed"})
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	l, err := lists.AddList(ctx, model.ReadingList{Owner: "alice", Name: "Favorites", Visibility: model.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("Error adding list: %v", err)
+	}
+	if _, err := lists.PutBook(ctx, "alice", l.ID, b.ID, -1); err != nil {
+		t.Fatalf("Error putting book on list: %v", err)
+	}
+
+	if _, err := crud.Remove(ctx, b.ID); err == nil {
+		t.Fatal("Unexpected success of Remove() with failing lists")
+	}
+	// Removing the book again cleans up the lists, although the book is gone.
+	lists.fail = false
+	if _, err := crud.Remove(ctx, b.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from second Remove(), got %v, want %v", err, model.ErrNotFound)
+	}
+	got, err := lists.GetList(ctx, "alice", l.ID)
+	if err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	if len(got.BookIDs) != 0 {
+		t.Errorf("Unexpected books on list after second Remove(), got %v, want none", got.BookIDs)
+	}
+}
+
+// vanishingBooks finds a book only on the first Get, as if it were removed right after.
+type vanishingBooks struct {
+	model.CrudService
+	gets int
+}
+
+func (vb *vanishingBooks) Get(ctx context.Context, id string) (model.Book, error) {
+	vb.gets++
+	if vb.gets > 1 {
+		return model.Book{}, model.ErrNotFound
+	}
+	return vb.CrudService.Get(ctx, id)
+}
+
+func TestPutBook(t *testing.T) {
+	ctx := context.Background()
+	books := memory.NewCrudService()
+	b, err := books.Add(ctx, model.Book{Title: "Listed"})
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	next := memory.NewListService()
+	l, err := next.AddList(ctx, model.ReadingList{Owner: "alice", Name: "Favorites", Visibility: model.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("Error adding list: %v", err)
+	}
+
+	lists := NewListService(next, books)
+	if _, err := lists.PutBook(ctx, "alice", l.ID, "000000000000000000000000", -1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error putting unknown book, got %v, want %v", err, model.ErrNotFound)
+	}
+	got, err := lists.PutBook(ctx, "alice", l.ID, b.ID, -1)
+	if err != nil {
+		t.Fatalf("Error putting book on list: %v", err)
+	}
+	if len(got.BookIDs) != 1 || got.BookIDs[0] != b.ID {
+		t.Errorf("Unexpected books on list, got %v, want [%s]", got.BookIDs, b.ID)
+	}
+
+	// A book removed while it is put is taken off the list again.
+	if _, err := next.RemoveBook(ctx, "alice", l.ID, b.ID); err != nil {
+		t.Fatalf("Error removing book from list: %v", err)
+	}
+	lists = NewListService(next, &vanishingBooks{CrudService: books})
+	if _, err := lists.PutBook(ctx, "alice", l.ID, b.ID, -1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error putting removed book, got %v, want %v", err, model.ErrNotFound)
+	}
+	if got, err = next.GetList(ctx, "alice", l.ID); err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	if len(got.BookIDs) != 0 {
+		t.Errorf("Unexpected books on list after putting removed book, got %v, want none", got.BookIDs)
+	}
+}
+
+func TestRemoveOnLoan(t *testing.T) {
+	ctx := context.Background()
+	loans := memory.NewLoanService()
+	crud := NewCrudService(memory.NewCrudService(), memory.NewListService(), loans)
+	b, err := crud.Add(ctx, model.Book{Title: "Lent"})
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	if _, err := loans.SetCopies(ctx, b.ID, 2); err != nil {
+		t.Fatalf("Error setting copies: %v", err)
+	}
+	l, err := loans.Checkout(ctx, model.Loan{BookID: b.ID, Borrower: "alice", Due: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Error checking out book: %v", err)
+	}
+
+	if _, err := crud.Remove(ctx, b.ID); !errors.Is(err, model.ErrCopiesOnLoan) {
+		t.Fatalf("Unexpected error removing book on loan, got %v, want %v", err, model.ErrCopiesOnLoan)
+	}
+	if a, err := loans.Availability(ctx, b.ID); err != nil || a.Copies != 2 {
+		t.Errorf("Unexpected availability after rejected Remove(), got %+v, %v, want 2 copies", a, err)
+	}
+	if _, err := loans.Return(ctx, l.ID, time.Now()); err != nil {
+		t.Fatalf("Error returning loan: %v", err)
+	}
+	if _, err := crud.Remove(ctx, b.ID); err != nil {
+		t.Fatalf("Error removing book: %v", err)
+	}
+	if a, err := loans.Availability(ctx, b.ID); err != nil || a.Copies != 0 {
+		t.Errorf("Unexpected availability after Remove(), got %+v, %v, want no copies", a, err)
+	}
+}
+
+func TestSetCopies(t *testing.T) {
+	ctx := context.Background()
+	books := memory.NewCrudService()
+	b, err := books.Add(ctx, model.Book{Title: "Held"})
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	next := memory.NewLoanService()
+
+	loans := NewLoanService(next, books)
+	if _, err := loans.SetCopies(ctx, "000000000000000000000000", 1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error setting copies of unknown book, got %v, want %v", err, model.ErrNotFound)
+	}
+	due := time.Now().Add(time.Hour)
+	if _, err := loans.Checkout(ctx, model.Loan{BookID: "000000000000000000000000", Borrower: "alice", Due: due}); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error checking out unknown book, got %v, want %v", err, model.ErrNotFound)
+	}
+
+	// The copies of a book removed while they are set are withdrawn again.
+	loans = NewLoanService(next, &vanishingBooks{CrudService: books})
+	if _, err := loans.SetCopies(ctx, b.ID, 1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error setting copies of removed book, got %v, want %v", err, model.ErrNotFound)
+	}
+	if a, err := next.Availability(ctx, b.ID); err != nil || a.Copies != 0 {
+		t.Errorf("Unexpected availability of removed book, got %+v, %v, want no copies", a, err)
+	}
+}
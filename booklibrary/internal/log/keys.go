@@ -1,8 +1,16 @@
 package log
 
 const (
-	ErrorKey    = "error"
-	IdKey       = "id"
-	MongoURIKey = "mongoURI"
-	AddrKey     = "addr"
+	ErrorKey     = "error"
+	IdKey        = "id"
+	MongoURIKey  = "mongoURI"
+	AddrKey      = "addr"
+	OperationKey = "operation"
+	BudgetKey    = "budget"
+	SourceKey    = "source"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+	RequestIDKey = "request_id"
+	IdentityKey  = "identity"
+	PhaseKey     = "phase"
 )
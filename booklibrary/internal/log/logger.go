@@ -1,13 +1,38 @@
 package log
 
 import (
+	"context"
 	"io"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-// New creates a new logger with the given writer and debug mode. This logger logs in UTC.
-func New(w io.Writer, debug bool) *slog.Logger {
-	opts := slog.HandlerOptions{
+type options struct {
+	json bool
+}
+
+// Option configures a logger created by New.
+type Option func(*options)
+
+// WithJSON makes the logger write JSON records instead of logfmt-style text.
+func WithJSON() Option {
+	return func(o *options) {
+		o.json = true
+	}
+}
+
+// New creates a new logger with the given writer that logs records at or above level. Passing a
+// *slog.LevelVar allows changing the level at runtime. This logger logs in UTC. Records logged
+// with a context that carries a valid span are annotated with its trace and span IDs.
+func New(w io.Writer, level slog.Leveler, opts ...Option) *slog.Logger {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	hopts := slog.HandlerOptions{
+		Level: level,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
 				return slog.Time(a.Key, a.Value.Time().UTC())
@@ -15,9 +40,33 @@ func New(w io.Writer, debug bool) *slog.Logger {
 			return a
 		},
 	}
-	if debug {
-		opts.Level = slog.LevelDebug
+	var h slog.Handler
+	if o.json {
+		h = slog.NewJSONHandler(w, &hopts)
+	} else {
+		h = slog.NewTextHandler(w, &hopts)
 	}
+	return slog.New(traceHandler{h})
+}
+
+// traceHandler adds the trace and span IDs of the span in a record's context.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String(TraceIDKey, sc.TraceID().String()),
+			slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
 
-	return slog.New(slog.NewTextHandler(w, &opts))
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
 }
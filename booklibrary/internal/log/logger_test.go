@@ -0,0 +1,53 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLevelVar(t *testing.T) {
+	var buf bytes.Buffer
+	var level slog.LevelVar
+	logger := New(&buf, &level)
+
+	logger.Debug("hidden")
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+	got := buf.String()
+	if strings.Contains(got, "hidden") || !strings.Contains(got, "shown") {
+		t.Errorf("Unexpected log output %q, want only records logged after lowering the level", got)
+	}
+}
+
+func TestTraceAttributes(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"with_span", trace.ContextWithSpanContext(context.Background(), sc), true},
+		{"without_span", context.Background(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, slog.LevelInfo)
+			logger.InfoContext(tt.ctx, "test")
+			got := buf.String()
+			for _, attr := range []string{TraceIDKey + "=" + traceID.String(), SpanIDKey + "=" + spanID.String()} {
+				if strings.Contains(got, attr) != tt.want {
+					t.Errorf("Unexpected log record %q, want %q present: %t", got, attr, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+var _ model.IdempotencyService = (*IdempotencyService)(nil)
+
+// sweepInterval is how often Claim removes expired requests.
+const sweepInterval = time.Minute
+
+// IdempotencyService stores idempotent requests in memory. Claim removes expired requests at most
+// once per sweepInterval, so that keys that are never reused do not accumulate.
+type IdempotencyService struct {
+	mu        sync.Mutex
+	requests  map[string]model.IdempotentRequest
+	lastSweep time.Time
+}
+
+// NewIdempotencyService creates an empty in-memory idempotency store.
+func NewIdempotencyService() *IdempotencyService {
+	return &IdempotencyService{requests: make(map[string]model.IdempotentRequest), lastSweep: time.Now()}
+}
+
+// Claim records a request in progress for key with token unless the key is held.
+func (is *IdempotencyService) Claim(ctx context.Context, key, token, fingerprint string, expires time.Time) (model.IdempotentRequest, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return model.IdempotentRequest{}, false, err
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	now := time.Now()
+	if now.Sub(is.lastSweep) >= sweepInterval {
+		maps.DeleteFunc(is.requests, func(_ string, req model.IdempotentRequest) bool {
+			return !req.Expires.After(now)
+		})
+		is.lastSweep = now
+	}
+	if req, ok := is.requests[key]; ok && req.Expires.After(now) {
+		return cloneRequest(req), false, nil
+	}
+	is.requests[key] = model.IdempotentRequest{Key: key, Fingerprint: fingerprint, Token: token, Expires: expires}
+	return model.IdempotentRequest{}, true, nil
+}
+
+// Complete records the response of the request in progress for key with token.
+func (is *IdempotencyService) Complete(ctx context.Context, key, token string, resp model.RecordedResponse, expires time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	req, ok := is.requests[key]
+	if !ok || req.Token != token || req.Response != nil {
+		return nil
+	}
+	req.Response = cloneResponse(&resp)
+	req.Expires = expires
+	is.requests[key] = req
+	return nil
+}
+
+// Release removes the request in progress for key with token.
+func (is *IdempotencyService) Release(ctx context.Context, key, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if req, ok := is.requests[key]; ok && req.Token == token && req.Response == nil {
+		delete(is.requests, key)
+	}
+	return nil
+}
+
+// cloneRequest copies req so that callers cannot modify the stored response.
+func cloneRequest(req model.IdempotentRequest) model.IdempotentRequest {
+	req.Response = cloneResponse(req.Response)
+	return req
+}
+
+func cloneResponse(resp *model.RecordedResponse) *model.RecordedResponse {
+	if resp == nil {
+		return nil
+	}
+	c := *resp
+	c.Header = maps.Clone(resp.Header)
+	for k, v := range c.Header {
+		c.Header[k] = append([]string(nil), v...)
+	}
+	c.Body = append([]byte(nil), resp.Body...)
+	return &c
+}
@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestIdempotencySweep(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx := context.Background()
+		is := NewIdempotencyService()
+		for _, key := range []string{"a", "b"} {
+			if _, _, err := is.Claim(ctx, key, "token", "fp", time.Now().Add(time.Second)); err != nil {
+				t.Fatalf("Error claiming key: %v", err)
+			}
+		}
+
+		// Keys that are never claimed again are removed once they have expired.
+		time.Sleep(sweepInterval)
+		if _, _, err := is.Claim(ctx, "c", "token", "fp", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Error claiming key: %v", err)
+		}
+		if got := len(is.requests); got != 1 {
+			t.Errorf("Unexpected number of stored requests, got %d, want 1", got)
+		}
+	})
+}
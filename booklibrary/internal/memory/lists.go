@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ model.ListService = (*ListService)(nil)
+
+// ListService stores reading lists in memory. IDs are assigned and validated like MongoDB
+// ObjectIDs.
+type ListService struct {
+	mu    sync.RWMutex
+	lists map[string]model.ReadingList
+}
+
+// NewListService creates an empty in-memory reading list store.
+func NewListService() *ListService {
+	return &ListService{lists: make(map[string]model.ReadingList)}
+}
+
+// Lists returns all lists of owner ordered by ID.
+func (ls *ListService) Lists(ctx context.Context, owner string) ([]model.ReadingList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	lists := []model.ReadingList{}
+	for _, l := range ls.lists {
+		if l.Owner == owner {
+			lists = append(lists, cloneList(l))
+		}
+	}
+	slices.SortFunc(lists, func(a, b model.ReadingList) int { return cmp.Compare(a.ID, b.ID) })
+	return lists, nil
+}
+
+// GetList returns the list of owner with the given ID.
+func (ls *ListService) GetList(ctx context.Context, owner, id string) (model.ReadingList, error) {
+	if err := check(ctx, id); err != nil {
+		return model.ReadingList{}, err
+	}
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	l, err := ls.get(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	return cloneList(l), nil
+}
+
+// AddList stores an empty list under a new ID and returns it.
+func (ls *ListService) AddList(ctx context.Context, list model.ReadingList) (model.ReadingList, error) {
+	if err := ctx.Err(); err != nil {
+		return model.ReadingList{}, err
+	}
+	list.ID = bson.NewObjectID().Hex()
+	list.BookIDs = []string{}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.lists[list.ID] = list
+	return cloneList(list), nil
+}
+
+// UpdateList changes the name and visibility of the list and returns the new state.
+func (ls *ListService) UpdateList(ctx context.Context, owner, id string, list model.ReadingList) (model.ReadingList, error) {
+	return ls.modify(ctx, owner, id, func(l *model.ReadingList) error {
+		l.Name = list.Name
+		l.Visibility = list.Visibility
+		return nil
+	})
+}
+
+// RemoveList deletes the list and returns it.
+func (ls *ListService) RemoveList(ctx context.Context, owner, id string) (model.ReadingList, error) {
+	if err := check(ctx, id); err != nil {
+		return model.ReadingList{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, err := ls.get(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	delete(ls.lists, id)
+	return l, nil
+}
+
+// PutBook inserts the book at position, or moves it there, and returns the new state.
+func (ls *ListService) PutBook(ctx context.Context, owner, id, bookID string, position int) (model.ReadingList, error) {
+	if !validID(bookID) {
+		return model.ReadingList{}, model.ErrInvalidID
+	}
+	return ls.modify(ctx, owner, id, func(l *model.ReadingList) error {
+		ids := slices.DeleteFunc(l.BookIDs, func(b string) bool { return b == bookID })
+		if position < 0 || position > len(ids) {
+			position = len(ids)
+		}
+		l.BookIDs = slices.Insert(ids, position, bookID)
+		return nil
+	})
+}
+
+// RemoveBook removes the book from the list and returns the new state.
+func (ls *ListService) RemoveBook(ctx context.Context, owner, id, bookID string) (model.ReadingList, error) {
+	if !validID(bookID) {
+		return model.ReadingList{}, model.ErrInvalidID
+	}
+	return ls.modify(ctx, owner, id, func(l *model.ReadingList) error {
+		i := slices.Index(l.BookIDs, bookID)
+		if i < 0 {
+			return model.ErrNotFound
+		}
+		l.BookIDs = slices.Delete(l.BookIDs, i, i+1)
+		return nil
+	})
+}
+
+// RemoveBookFromLists removes the book from all lists.
+func (ls *ListService) RemoveBookFromLists(ctx context.Context, bookID string) error {
+	if err := check(ctx, bookID); err != nil {
+		return err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for id, l := range ls.lists {
+		if slices.Contains(l.BookIDs, bookID) {
+			l.BookIDs = slices.DeleteFunc(slices.Clone(l.BookIDs), func(b string) bool { return b == bookID })
+			ls.lists[id] = l
+		}
+	}
+	return nil
+}
+
+// modify applies fn to a copy of the list and stores the copy if fn succeeds.
+func (ls *ListService) modify(ctx context.Context, owner, id string, fn func(*model.ReadingList) error) (model.ReadingList, error) {
+	if err := check(ctx, id); err != nil {
+		return model.ReadingList{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, err := ls.get(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	l = cloneList(l)
+	if err := fn(&l); err != nil {
+		return model.ReadingList{}, err
+	}
+	ls.lists[id] = l
+	return cloneList(l), nil
+}
+
+// get returns the stored list. Callers must hold ls.mu.
+func (ls *ListService) get(owner, id string) (model.ReadingList, error) {
+	l, ok := ls.lists[id]
+	if !ok || l.Owner != owner {
+		return model.ReadingList{}, model.ErrListNotFound
+	}
+	return l, nil
+}
+
+// cloneList copies l so that callers cannot modify stored book IDs.
+func cloneList(l model.ReadingList) model.ReadingList {
+	l.BookIDs = slices.Clone(l.BookIDs)
+	if l.BookIDs == nil {
+		l.BookIDs = []string{}
+	}
+	return l
+}
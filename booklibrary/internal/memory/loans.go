@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ model.LoanService = (*LoanService)(nil)
+
+// LoanService stores copies and loans in memory. IDs are assigned and validated like MongoDB
+// ObjectIDs.
+type LoanService struct {
+	mu     sync.RWMutex
+	copies map[string]int
+	loans  map[string]model.Loan
+}
+
+// NewLoanService creates an empty in-memory loan store.
+func NewLoanService() *LoanService {
+	return &LoanService{copies: make(map[string]int), loans: make(map[string]model.Loan)}
+}
+
+// Availability returns the number of copies of the book and how many are on loan.
+func (ls *LoanService) Availability(ctx context.Context, bookID string) (model.Availability, error) {
+	if err := check(ctx, bookID); err != nil {
+		return model.Availability{}, err
+	}
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return model.NewAvailability(ls.copies[bookID], ls.onLoan(bookID)), nil
+}
+
+// SetCopies sets the number of copies of the book.
+func (ls *LoanService) SetCopies(ctx context.Context, bookID string, copies int) (model.Availability, error) {
+	if err := check(ctx, bookID); err != nil {
+		return model.Availability{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	onLoan := ls.onLoan(bookID)
+	if copies < onLoan {
+		return model.Availability{}, model.ErrCopiesOnLoan
+	}
+	ls.copies[bookID] = copies
+	return model.NewAvailability(copies, onLoan), nil
+}
+
+// Checkout stores the loan under a new ID if a copy of its book is available.
+func (ls *LoanService) Checkout(ctx context.Context, loan model.Loan) (model.Loan, error) {
+	if err := check(ctx, loan.BookID); err != nil {
+		return model.Loan{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.onLoan(loan.BookID) >= ls.copies[loan.BookID] {
+		return model.Loan{}, model.ErrNoCopyAvailable
+	}
+	loan.ID = bson.NewObjectID().Hex()
+	loan.Returned = nil
+	loan.Renewals = 0
+	ls.loans[loan.ID] = loan
+	return loan, nil
+}
+
+// GetLoan returns the loan with the given ID.
+func (ls *LoanService) GetLoan(ctx context.Context, id string) (model.Loan, error) {
+	if err := check(ctx, id); err != nil {
+		return model.Loan{}, err
+	}
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	l, ok := ls.loans[id]
+	if !ok {
+		return model.Loan{}, model.ErrLoanNotFound
+	}
+	return cloneLoan(l), nil
+}
+
+// Return closes the loan at the given time.
+func (ls *LoanService) Return(ctx context.Context, id string, at time.Time) (model.Loan, error) {
+	return ls.modify(ctx, id, func(l *model.Loan) error {
+		l.Returned = &at
+		return nil
+	})
+}
+
+// Renew moves the due date of the loan.
+func (ls *LoanService) Renew(ctx context.Context, id string, due time.Time, maxRenewals int) (model.Loan, error) {
+	return ls.modify(ctx, id, func(l *model.Loan) error {
+		if l.Renewals >= maxRenewals {
+			return model.ErrRenewalLimit
+		}
+		l.Due = due
+		l.Renewals++
+		return nil
+	})
+}
+
+// Overdue returns the open loans due before now, ordered by due date.
+func (ls *LoanService) Overdue(ctx context.Context, now time.Time) ([]model.Loan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	loans := []model.Loan{}
+	for _, l := range ls.loans {
+		if l.Overdue(now) {
+			loans = append(loans, cloneLoan(l))
+		}
+	}
+	slices.SortFunc(loans, func(a, b model.Loan) int { return a.Due.Compare(b.Due) })
+	return loans, nil
+}
+
+// modify applies fn to a copy of the open loan and stores the copy if fn succeeds.
+func (ls *LoanService) modify(ctx context.Context, id string, fn func(*model.Loan) error) (model.Loan, error) {
+	if err := check(ctx, id); err != nil {
+		return model.Loan{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, ok := ls.loans[id]
+	if !ok {
+		return model.Loan{}, model.ErrLoanNotFound
+	}
+	if !l.Open() {
+		return model.Loan{}, model.ErrLoanReturned
+	}
+	if err := fn(&l); err != nil {
+		return model.Loan{}, err
+	}
+	ls.loans[id] = l
+	return cloneLoan(l), nil
+}
+
+// onLoan counts the open loans of the book. Callers must hold ls.mu.
+func (ls *LoanService) onLoan(bookID string) int {
+	n := 0
+	for _, l := range ls.loans {
+		if l.BookID == bookID && l.Open() {
+			n++
+		}
+	}
+	return n
+}
+
+// cloneLoan copies l so that callers cannot modify a stored return time.
+func cloneLoan(l model.Loan) model.Loan {
+	if l.Returned != nil {
+		returned := *l.Returned
+		l.Returned = &returned
+	}
+	return l
+}
@@ -0,0 +1,144 @@
+// Package memory provides an in-memory book store for tests and local development.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Compile-time check to verify we implement Storage
+var (
+	_ model.CrudService = (*CrudService)(nil)
+	_ model.Finder      = (*CrudService)(nil)
+)
+
+// CrudService stores Book instances in memory. IDs are assigned and validated like MongoDB
+// ObjectIDs, so that the store behaves like the MongoDB store towards clients.
+type CrudService struct {
+	mu    sync.RWMutex
+	books map[string]model.Book
+}
+
+// NewCrudService creates an empty in-memory store.
+func NewCrudService() *CrudService {
+	return &CrudService{books: make(map[string]model.Book)}
+}
+
+// List returns up to limit books ordered by ID.
+func (cs *CrudService) List(ctx context.Context, limit int) ([]model.Book, error) {
+	return cs.Find(ctx, model.Filter{Limit: limit})
+}
+
+// Find returns the books matching filter ordered by ID.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filter.After != "" && !validID(filter.After) {
+		return nil, model.ErrInvalidID
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	books := []model.Book{}
+	for _, b := range cs.books {
+		if filter.Match(b) {
+			books = append(books, clone(b))
+		}
+	}
+	slices.SortFunc(books, func(a, b model.Book) int { return cmp.Compare(a.ID, b.ID) })
+	if filter.Limit > 0 && len(books) > filter.Limit {
+		books = books[:filter.Limit]
+	}
+	return books, nil
+}
+
+// Get returns the book with the given ID.
+func (cs *CrudService) Get(ctx context.Context, id string) (model.Book, error) {
+	if err := check(ctx, id); err != nil {
+		return model.Book{}, err
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	b, ok := cs.books[id]
+	if !ok {
+		return model.Book{}, model.ErrNotFound
+	}
+	return clone(b), nil
+}
+
+// Add stores book under a new ID and returns it.
+func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Book{}, err
+	}
+	book = clone(book)
+	book.ID = bson.NewObjectID().Hex()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.books[book.ID] = book
+	return clone(book), nil
+}
+
+// Update replaces the book with the given ID and returns the new state.
+func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
+	if err := check(ctx, id); err != nil {
+		return model.Book{}, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.books[id]; !ok {
+		return model.Book{}, model.ErrNotFound
+	}
+	book = clone(book)
+	book.ID = id
+	cs.books[id] = book
+	return clone(book), nil
+}
+
+// Remove deletes the book with the given ID and returns it.
+func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
+	if err := check(ctx, id); err != nil {
+		return model.Book{}, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	b, ok := cs.books[id]
+	if !ok {
+		return model.Book{}, model.ErrNotFound
+	}
+	delete(cs.books, id)
+	return b, nil
+}
+
+// Ping always succeeds.
+func (cs *CrudService) Ping(_ context.Context) error {
+	return nil
+}
+
+// check returns ctx's error if it is done, or ErrInvalidID if id is malformed.
+func check(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !validID(id) {
+		return model.ErrInvalidID
+	}
+	return nil
+}
+
+func validID(id string) bool {
+	_, err := bson.ObjectIDFromHex(id)
+	return err == nil
+}
+
+// clone copies b so that callers cannot modify stored keywords.
+func clone(b model.Book) model.Book {
+	b.Keywords = slices.Clone(b.Keywords)
+	return b
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) model.CrudService {
+		return NewCrudService()
+	})
+}
+
+func TestListConformance(t *testing.T) {
+	storetest.RunLists(t, func(*testing.T) model.ListService {
+		return NewListService()
+	})
+}
+
+func TestLoanConformance(t *testing.T) {
+	storetest.RunLoans(t, func(*testing.T) model.LoanService {
+		return NewLoanService()
+	})
+}
+
+func TestIdempotencyConformance(t *testing.T) {
+	storetest.RunIdempotency(t, func(*testing.T) model.IdempotencyService {
+		return NewIdempotencyService()
+	})
+}
@@ -1,8 +1,10 @@
 package model
 
 import (
-	"encoding/json"
+	"encoding/xml"
 	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 // Book represent a book in the library.
@@ -12,39 +14,94 @@ type Book struct {
 	Title       string    `json:"title" bson:"title"`
 	ReleaseDate time.Time `json:"releaseDate" bson:"releaseDate"`
 	Keywords    []Keyword `json:"keywords" bson:"keywords"`
+	// Availability is set by the API on single books if it tracks loans. It is not stored.
+	Availability *Availability `json:"availability,omitempty" bson:"-"`
+}
+
+// wireBook is the representation of a Book shared by all wire formats.
+type wireBook struct {
+	XMLName     xml.Name    `json:"-" xml:"book" msgpack:"-"`
+	ReleaseDate releaseDate `json:"releaseDate" xml:"releaseDate" msgpack:"releaseDate"`
+	ID          string      `json:"_id" xml:"id,attr,omitempty" msgpack:"_id"`
+	Author      string      `json:"author" xml:"author" msgpack:"author"`
+	Title       string      `json:"title" xml:"title" msgpack:"title"`
+	Keywords    []Keyword   `json:"keywords" xml:"keywords>keyword" msgpack:"keywords"`
+	// Availability is omitted unless set, so that books without it keep their representation.
+	Availability *Availability `json:"availability,omitempty" xml:"availability,omitempty" msgpack:"availability,omitempty"`
+}
+
+func (b Book) wire(f DateFormat) wireBook {
+	return wireBook{
+		ReleaseDate:  releaseDate{t: b.ReleaseDate, format: f},
+		ID:           b.ID,
+		Author:       b.Author,
+		Title:        b.Title,
+		Keywords:     b.Keywords,
+		Availability: b.Availability,
+	}
+}
+
+func (w wireBook) book() Book {
+	return Book{
+		ID:           w.ID,
+		Author:       w.Author,
+		Title:        w.Title,
+		ReleaseDate:  w.ReleaseDate.t,
+		Keywords:     w.Keywords,
+		Availability: w.Availability,
+	}
 }
 
 // MarshalJSON serializes a Book with its ReleaseDate rendered as Unix time.
 func (b Book) MarshalJSON() ([]byte, error) {
-	type Dto Book
-	return json.Marshal(struct {
-		ReleaseDate int64 `json:"releaseDate"`
-		Dto
-	}{
-		ReleaseDate: b.ReleaseDate.Unix(),
-		Dto:         (Dto)(b),
-	})
+	return b.View(DateUnix).MarshalJSON()
 }
 
-// UnmarshalJSON deserializes a Book with its ReleaseDate rendered as Unix time.
+// UnmarshalJSON deserializes a Book with its ReleaseDate rendered as Unix time, RFC 3339 or date-only value.
 func (b *Book) UnmarshalJSON(data []byte) error {
-	type Dto Book
-	dto := struct {
-		ReleaseDate int64 `json:"releaseDate"`
-		*Dto
-	}{
-		Dto: (*Dto)(b),
+	v := BookView{Format: DateUnix}
+	if err := v.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*b = v.Book
+	return nil
+}
+
+// MarshalXML serializes a Book as a <book> element with its ReleaseDate rendered as Unix time.
+func (b Book) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return b.View(DateUnix).MarshalXML(e, start)
+}
+
+// UnmarshalXML deserializes a Book from a <book> element with its ReleaseDate rendered as Unix time,
+// RFC 3339 or date-only value.
+func (b *Book) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v := BookView{Format: DateUnix}
+	if err := v.UnmarshalXML(d, start); err != nil {
+		return err
 	}
-	if err := json.Unmarshal(data, &dto); err != nil {
+	*b = v.Book
+	return nil
+}
+
+// EncodeMsgpack serializes a Book as a MessagePack map with its ReleaseDate rendered as Unix time.
+func (b Book) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return b.View(DateUnix).EncodeMsgpack(enc)
+}
+
+// DecodeMsgpack deserializes a Book from a MessagePack map with its ReleaseDate rendered as Unix
+// time, RFC 3339 or date-only value.
+func (b *Book) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v := BookView{Format: DateUnix}
+	if err := v.DecodeMsgpack(dec); err != nil {
 		return err
 	}
-	b.ReleaseDate = time.Unix(dto.ReleaseDate, 0)
+	*b = v.Book
 	return nil
 }
 
 // Keyword represents a book's topic.
 type Keyword struct {
-	Value string `json:"keyword" bson:"keyword"`
+	Value string `json:"keyword" bson:"keyword" xml:",chardata" msgpack:"keyword"`
 }
 
 // String returns the keyword value.
@@ -2,10 +2,13 @@ package model
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestMarshalJSON(t *testing.T) {
@@ -102,3 +105,197 @@ func TestUnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDateFormatRoundTrip(t *testing.T) {
+	in := Book{
+		ID:          "000000000000000000000001",
+		Author:      "John Doe",
+		Title:       "Unit Testing in Go",
+		ReleaseDate: time.Date(2020, time.February, 1, 11, 0, 0, 123456789, time.UTC),
+		Keywords:    []Keyword{{Value: "Golang"}},
+	}
+	tests := []struct {
+		format DateFormat
+		date   string
+		want   time.Time
+	}{
+		{DateUnix, `1580554800`, time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC)},
+		{DateUnixMilli, `1580554800123`, time.Date(2020, time.February, 1, 11, 0, 0, 123000000, time.UTC)},
+		{DateRFC3339, `"2020-02-01T11:00:00.123456789Z"`, in.ReleaseDate},
+		{DateOnly, `"2020-02-01"`, time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			b, err := json.Marshal(in.View(tt.format))
+			if err != nil {
+				t.Fatalf("Fatal error marshalling to JSON: %v\n", err)
+			}
+			want := `{"releaseDate":` + tt.date + `,"_id":"000000000000000000000001","author":"John Doe","title":"Unit Testing in Go","keywords":[{"keyword":"Golang"}]}`
+			if got := string(b); got != want {
+				t.Fatalf("JSON does match expected result, got %q, want %q", got, want)
+			}
+
+			got := BookView{Format: tt.format}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("Fatal error unmarshalling from JSON: %v\n", err)
+			}
+			wantBook := in
+			wantBook.ReleaseDate = tt.want
+			if diff := cmp.Diff(wantBook, got.Book); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAnyDateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format DateFormat
+		in     string
+		want   time.Time
+	}{
+		{"seconds", DateUnix, `1580554800`, time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC)},
+		{"milliseconds", DateUnixMilli, `1580554800123`, time.Date(2020, time.February, 1, 11, 0, 0, 123000000, time.UTC)},
+		{"rfc3339_as_default", DateUnix, `"2020-02-01T12:00:00.5+01:00"`, time.Date(2020, time.February, 1, 11, 0, 0, 500000000, time.UTC)},
+		{"date_as_default", DateUnix, `"2020-02-01"`, time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"seconds_as_rfc3339", DateRFC3339, `1580554800`, time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BookView{Format: tt.format}
+			if err := json.Unmarshal([]byte(`{"releaseDate":`+tt.in+`}`), &got); err != nil {
+				t.Fatalf("Fatal error unmarshalling from JSON: %v\n", err)
+			}
+			if !got.ReleaseDate.Equal(tt.want) {
+				t.Errorf("Unexpected release date, got %v, want %v", got.ReleaseDate, tt.want)
+			}
+		})
+	}
+
+	var b Book
+	if err := json.Unmarshal([]byte(`{"releaseDate":"yesterday"}`), &b); err == nil {
+		t.Error("Expected error unmarshalling invalid date, got nil")
+	}
+}
+
+func TestDateFormatRoundTripXMLAndMsgpack(t *testing.T) {
+	in := Book{
+		ID:          "000000000000000000000001",
+		Author:      "John Doe",
+		Title:       "Unit Testing in Go",
+		ReleaseDate: time.Date(2020, time.February, 1, 11, 0, 0, 123456789, time.UTC),
+		Keywords:    []Keyword{{Value: "Golang"}, {Value: "XML"}},
+	}
+	codecs := []struct {
+		name      string
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}{
+		{"xml", xml.Marshal, xml.Unmarshal},
+		{"msgpack", msgpack.Marshal, msgpack.Unmarshal},
+	}
+	for _, c := range codecs {
+		for _, f := range []DateFormat{DateUnixMilli, DateRFC3339} {
+			t.Run(c.name+"_"+string(f), func(t *testing.T) {
+				b, err := c.marshal(in.View(f))
+				if err != nil {
+					t.Fatalf("Fatal error marshalling: %v\n", err)
+				}
+				got := BookView{Format: f}
+				if err := c.unmarshal(b, &got); err != nil {
+					t.Fatalf("Fatal error unmarshalling: %v\n", err)
+				}
+				want := in
+				if f == DateUnixMilli {
+					want.ReleaseDate = in.ReleaseDate.Truncate(time.Millisecond)
+				}
+				if diff := cmp.Diff(want, got.Book); diff != "" {
+					t.Error(diff)
+				}
+			})
+		}
+	}
+}
+
+func benchmarkBooks(n int) []Book {
+	books := make([]Book, n)
+	for i := range books {
+		books[i] = Book{
+			ID:          "65a0f0c2e4b0a1b2c3d4e5f6",
+			Author:      "Jörg Jooss",
+			Title:       "Benchmarking Go",
+			ReleaseDate: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC),
+			Keywords:    []Keyword{{Value: "Go"}, {Value: "Performance"}},
+		}
+	}
+	return books
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	books := benchmarkBooks(100)
+	views := make([]BookView, len(books))
+	for i, bk := range books {
+		views[i] = bk.View(DateRFC3339)
+	}
+	b.Run("book", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(books); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("view", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(views); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshalJSON(b *testing.B) {
+	data, err := json.Marshal(benchmarkBooks(100))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var books []Book
+		if err := json.Unmarshal(data, &books); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAvailabilityRoundTrip(t *testing.T) {
+	a := NewAvailability(3, 1)
+	in := Book{ID: "000000000000000000000001", Title: "Unit Testing in Go", Availability: &a}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Fatal error marshalling to JSON: %v\n", err)
+	}
+	want := `"availability":{"copies":3,"onLoan":1,"available":2}`
+	if !strings.Contains(string(b), want) {
+		t.Fatalf("JSON does not contain availability, got %q, want %q", b, want)
+	}
+	var got Book
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Fatal error unmarshalling from JSON: %v\n", err)
+	}
+	if diff := cmp.Diff(in, got); diff != "" {
+		t.Error(diff)
+	}
+
+	// Books without availability keep their representation.
+	in.Availability = nil
+	if b, err = xml.Marshal(in); err != nil {
+		t.Fatalf("Fatal error marshalling to XML: %v\n", err)
+	}
+	if strings.Contains(string(b), "availability") {
+		t.Errorf("XML contains availability, got %q", b)
+	}
+}
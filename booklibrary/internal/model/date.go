@@ -0,0 +1,223 @@
+package model
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// DateFormat selects how a Book's ReleaseDate is represented on the wire.
+type DateFormat string
+
+const (
+	// DateUnix represents dates as Unix time in seconds. This is the default, as expected by Backbone.js clients.
+	DateUnix DateFormat = "unix"
+	// DateUnixMilli represents dates as Unix time in milliseconds.
+	DateUnixMilli DateFormat = "unixms"
+	// DateRFC3339 represents dates as RFC 3339 strings in UTC with sub-second precision.
+	DateRFC3339 DateFormat = "rfc3339"
+	// DateOnly represents dates as calendar dates in UTC (YYYY-MM-DD).
+	DateOnly DateFormat = "date"
+)
+
+// ParseDateFormat returns the DateFormat named s. An empty s selects DateUnix.
+func ParseDateFormat(s string) (DateFormat, error) {
+	switch f := DateFormat(s); f {
+	case "":
+		return DateUnix, nil
+	case DateUnix, DateUnixMilli, DateRFC3339, DateOnly:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown date format %q", s)
+	}
+}
+
+// Format renders t in format f. Numeric formats are rendered as decimal integers.
+func (f DateFormat) Format(t time.Time) string {
+	switch f {
+	case DateUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case DateRFC3339:
+		return t.UTC().Format(time.RFC3339Nano)
+	case DateOnly:
+		return t.UTC().Format(time.DateOnly)
+	default:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+}
+
+// numeric reports whether f renders dates as numbers.
+func (f DateFormat) numeric() bool {
+	return f != DateRFC3339 && f != DateOnly
+}
+
+// fromNumber interprets n as Unix time in the unit of f. Non-numeric formats use seconds.
+func (f DateFormat) fromNumber(n int64) time.Time {
+	if f == DateUnixMilli {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// ParseDate parses s as an RFC 3339 timestamp or a date-only value.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: want Unix time, RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// releaseDate is a time rendered in a DateFormat. When decoding, it accepts Unix time as well as
+// RFC 3339 and date-only strings regardless of its format; numbers are read in the unit of its
+// format.
+type releaseDate struct {
+	t      time.Time
+	format DateFormat
+}
+
+func (d releaseDate) MarshalJSON() ([]byte, error) {
+	s := d.format.Format(d.t)
+	if d.format.numeric() {
+		return []byte(s), nil
+	}
+	return json.Marshal(s)
+}
+
+func (d *releaseDate) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return d.UnmarshalText([]byte(s))
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid date %s: %w", data, err)
+	}
+	d.t = d.format.fromNumber(n)
+	return nil
+}
+
+func (d releaseDate) MarshalText() ([]byte, error) {
+	return []byte(d.format.Format(d.t)), nil
+}
+
+func (d *releaseDate) UnmarshalText(text []byte) error {
+	s := string(text)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d.t = d.format.fromNumber(n)
+		return nil
+	}
+	t, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	d.t = t
+	return nil
+}
+
+func (d releaseDate) EncodeMsgpack(enc *msgpack.Encoder) error {
+	switch d.format {
+	case DateUnixMilli:
+		return enc.EncodeInt(d.t.UnixMilli())
+	case DateRFC3339, DateOnly:
+		return enc.EncodeString(d.format.Format(d.t))
+	default:
+		return enc.EncodeInt(d.t.Unix())
+	}
+}
+
+func (d *releaseDate) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		d.t = d.format.fromNumber(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return fmt.Errorf("invalid date %d: out of range", v)
+		}
+		d.t = d.format.fromNumber(int64(v))
+	case string:
+		return d.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("invalid date of type %T", v)
+	}
+	return nil
+}
+
+// BookView renders a Book with its ReleaseDate in Format instead of Unix seconds. Decoding into a
+// BookView reads numeric dates in the unit of Format.
+type BookView struct {
+	Book
+	Format DateFormat
+}
+
+// View returns b rendered with f.
+func (b Book) View(f DateFormat) BookView {
+	return BookView{Book: b, Format: f}
+}
+
+// MarshalJSON serializes the Book with its ReleaseDate rendered in v.Format.
+func (v BookView) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.wire(v.Format))
+}
+
+// UnmarshalJSON deserializes the Book, accepting any supported representation of its ReleaseDate.
+func (v *BookView) UnmarshalJSON(data []byte) error {
+	w := wireBook{ReleaseDate: releaseDate{format: v.Format}}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	v.Book = w.book()
+	return nil
+}
+
+// MarshalXML serializes the Book as a <book> element with its ReleaseDate rendered in v.Format.
+func (v BookView) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return e.Encode(v.wire(v.Format))
+}
+
+// UnmarshalXML deserializes the Book from a <book> element, accepting any supported representation
+// of its ReleaseDate.
+func (v *BookView) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	w := wireBook{ReleaseDate: releaseDate{format: v.Format}}
+	if err := d.DecodeElement(&w, &start); err != nil {
+		return err
+	}
+	v.Book = w.book()
+	return nil
+}
+
+// EncodeMsgpack serializes the Book as a MessagePack map with its ReleaseDate rendered in v.Format.
+func (v BookView) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.Encode(v.wire(v.Format))
+}
+
+// DecodeMsgpack deserializes the Book from a MessagePack map, accepting any supported
+// representation of its ReleaseDate.
+func (v *BookView) DecodeMsgpack(dec *msgpack.Decoder) error {
+	w := wireBook{ReleaseDate: releaseDate{format: v.Format}}
+	if err := dec.Decode(&w); err != nil {
+		return err
+	}
+	v.Book = w.book()
+	return nil
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter selects books in CrudService.Find. Zero-valued fields match all books.
+type Filter struct {
+	// Author matches books by this author, ignoring case.
+	Author string
+	// Title matches books whose title contains this text, ignoring case.
+	Title string
+	// Keyword matches books tagged with this keyword.
+	Keyword string
+	// ReleasedAfter matches books released at or after this time.
+	ReleasedAfter time.Time
+	// ReleasedBefore matches books released before this time.
+	ReleasedBefore time.Time
+	// After matches books whose ID sorts after this ID, for paging through books in ID order.
+	After string
+	// Limit is the maximum number of books to return. Zero means no limit.
+	Limit int
+}
+
+// Match reports whether b satisfies all criteria of f. Limit is not considered.
+func (f Filter) Match(b Book) bool {
+	if f.Author != "" && !strings.EqualFold(b.Author, f.Author) {
+		return false
+	}
+	if f.Title != "" && !strings.Contains(strings.ToLower(b.Title), strings.ToLower(f.Title)) {
+		return false
+	}
+	if f.Keyword != "" && !hasKeyword(b.Keywords, f.Keyword) {
+		return false
+	}
+	if !f.ReleasedAfter.IsZero() && b.ReleaseDate.Before(f.ReleasedAfter) {
+		return false
+	}
+	if !f.ReleasedBefore.IsZero() && !b.ReleaseDate.Before(f.ReleasedBefore) {
+		return false
+	}
+	if f.After != "" && b.ID <= f.After {
+		return false
+	}
+	return true
+}
+
+func hasKeyword(kws []Keyword, kw string) bool {
+	for _, k := range kws {
+		if k.Value == kw {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// IdempotentRequest is a request made with an idempotency key, identified by a fingerprint of its
+// content, and its response once it has completed.
+type IdempotentRequest struct {
+	Key         string `bson:"_id"`
+	Fingerprint string `bson:"fingerprint"`
+	// Token identifies the claim of the request.
+	Token string `bson:"token"`
+	// Response is nil while the request is in progress.
+	Response *RecordedResponse `bson:"response,omitempty"`
+	// Expires is when the key can be used again.
+	Expires time.Time `bson:"expires"`
+}
+
+// RecordedResponse is a response kept to be replayed to retries of its request.
+type RecordedResponse struct {
+	Status int                 `bson:"status"`
+	Header map[string][]string `bson:"header"`
+	Body   []byte              `bson:"body"`
+}
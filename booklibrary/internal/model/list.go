@@ -0,0 +1,26 @@
+package model
+
+// Visibility controls who may read a reading list.
+type Visibility string
+
+const (
+	// VisibilityPrivate lists can only be read by their owner.
+	VisibilityPrivate Visibility = "private"
+	// VisibilityPublic lists can be read by everyone.
+	VisibilityPublic Visibility = "public"
+)
+
+// Valid reports whether v is a known visibility.
+func (v Visibility) Valid() bool {
+	return v == VisibilityPrivate || v == VisibilityPublic
+}
+
+// ReadingList is a named, ordered collection of books kept by a user, such as their favorites.
+type ReadingList struct {
+	ID         string     `json:"_id" bson:"_id,omitempty"`
+	Owner      string     `json:"owner" bson:"owner"`
+	Name       string     `json:"name" bson:"name"`
+	Visibility Visibility `json:"visibility" bson:"visibility"`
+	// BookIDs references the books on the list in the owner's order.
+	BookIDs []string `json:"bookIds" bson:"bookIds"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Availability summarizes the physical copies of a book.
+type Availability struct {
+	Copies    int `json:"copies" xml:"copies" msgpack:"copies"`
+	OnLoan    int `json:"onLoan" xml:"onLoan" msgpack:"onLoan"`
+	Available int `json:"available" xml:"available" msgpack:"available"`
+}
+
+// NewAvailability returns the availability of a book with the given number of copies, of which
+// onLoan are checked out.
+func NewAvailability(copies, onLoan int) Availability {
+	return Availability{Copies: copies, OnLoan: onLoan, Available: max(copies-onLoan, 0)}
+}
+
+// Loan is the checkout of a copy of a book by a borrower. A loan is open until the copy is returned.
+type Loan struct {
+	ID         string    `json:"_id" bson:"_id,omitempty"`
+	BookID     string    `json:"bookId" bson:"bookId"`
+	Borrower   string    `json:"borrower" bson:"borrower"`
+	CheckedOut time.Time `json:"checkedOut" bson:"checkedOut"`
+	Due        time.Time `json:"due" bson:"due"`
+	// Returned is nil while the loan is open.
+	Returned *time.Time `json:"returned,omitempty" bson:"returned,omitempty"`
+	Renewals int        `json:"renewals" bson:"renewals"`
+}
+
+// Open reports whether the copy has not been returned yet.
+func (l Loan) Open() bool {
+	return l.Returned == nil
+}
+
+// Overdue reports whether the loan is open and was due before now.
+func (l Loan) Overdue(now time.Time) bool {
+	return l.Open() && l.Due.Before(now)
+}
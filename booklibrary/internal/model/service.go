@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 var (
@@ -10,10 +12,38 @@ var (
 	ErrInvalidID = errors.New("string is not valid book ID")
 	// ErrNotFound is returned when a book is not found.
 	ErrNotFound = errors.New("book not found")
+	// ErrListNotFound is returned when a reading list is not found.
+	ErrListNotFound = errors.New("reading list not found")
+	// ErrLoanNotFound is returned when a loan is not found.
+	ErrLoanNotFound = errors.New("loan not found")
+	// ErrNoCopyAvailable is returned when all copies of a book are on loan.
+	ErrNoCopyAvailable = errors.New("no copy available")
+	// ErrCopiesOnLoan is returned when the copies of a book would be fewer than those on loan.
+	ErrCopiesOnLoan = errors.New("more copies on loan than requested")
+	// ErrLoanReturned is returned when a loan has already been returned.
+	ErrLoanReturned = errors.New("loan already returned")
+	// ErrRenewalLimit is returned when a loan has been renewed too often.
+	ErrRenewalLimit = errors.New("renewal limit reached")
+	// ErrUnavailable is returned when the data store is temporarily unavailable.
+	ErrUnavailable = errors.New("book store unavailable")
 )
 
-// CrudService is the interface for all book library data stores.
-// operations
+// UnavailableError is returned when a call is rejected because the data store is known to be failing.
+// It matches ErrUnavailable with errors.Is.
+type UnavailableError struct {
+	// RetryAfter is the time after which the data store should be tried again.
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrUnavailable, e.RetryAfter)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return ErrUnavailable
+}
+
+// CrudService is the interface for all book library data stores. List returns books ordered by ID.
 type CrudService interface {
 	List(ctx context.Context, limit int) ([]Book, error)
 	Get(ctx context.Context, id string) (Book, error)
@@ -22,3 +52,76 @@ type CrudService interface {
 	Remove(ctx context.Context, id string) (Book, error)
 	Ping(ctx context.Context) error
 }
+
+// Finder is implemented by data stores that can filter books. Find returns books ordered by ID.
+type Finder interface {
+	Find(ctx context.Context, filter Filter) ([]Book, error)
+}
+
+// Find returns the books in crud matching filter. If crud is not a Finder, it returns an error
+// matching errors.ErrUnsupported.
+func Find(ctx context.Context, crud CrudService, filter Filter) ([]Book, error) {
+	f, ok := crud.(Finder)
+	if !ok {
+		return nil, fmt.Errorf("filtering books: %w", errors.ErrUnsupported)
+	}
+	return f.Find(ctx, filter)
+}
+
+// ListService is the interface for all reading list data stores. Lists are identified by their owner
+// and ID; a list ID of another owner is reported as ErrListNotFound. Lists returns lists ordered by ID.
+type ListService interface {
+	Lists(ctx context.Context, owner string) ([]ReadingList, error)
+	GetList(ctx context.Context, owner, id string) (ReadingList, error)
+	// AddList stores list under a new ID. Its books are ignored; new lists are empty.
+	AddList(ctx context.Context, list ReadingList) (ReadingList, error)
+	// UpdateList changes the name and visibility of a list.
+	UpdateList(ctx context.Context, owner, id string, list ReadingList) (ReadingList, error)
+	RemoveList(ctx context.Context, owner, id string) (ReadingList, error)
+	// PutBook inserts a book at position, or moves it there if it is already on the list. A
+	// negative position or one beyond the end of the list places the book last.
+	PutBook(ctx context.Context, owner, id, bookID string, position int) (ReadingList, error)
+	// RemoveBook removes a book from a list. It returns ErrNotFound if the book is not on the list.
+	RemoveBook(ctx context.Context, owner, id, bookID string) (ReadingList, error)
+	// RemoveBookFromLists removes a book from all lists of all owners.
+	RemoveBookFromLists(ctx context.Context, bookID string) error
+}
+
+// LoanService is the interface for all loan data stores. It tracks the number of copies of each book
+// and their loans. Books without recorded copies have none. Checkout must stay atomic under
+// concurrent calls, so that no more copies are on loan than exist.
+type LoanService interface {
+	Availability(ctx context.Context, bookID string) (Availability, error)
+	// SetCopies sets the number of copies of a book. It returns ErrCopiesOnLoan if more copies are
+	// on loan.
+	SetCopies(ctx context.Context, bookID string, copies int) (Availability, error)
+	// Checkout lends a copy of a book to the loan's borrower until its due date. It returns
+	// ErrNoCopyAvailable if all copies are on loan.
+	Checkout(ctx context.Context, loan Loan) (Loan, error)
+	GetLoan(ctx context.Context, id string) (Loan, error)
+	// Return closes an open loan at the given time. It returns ErrLoanReturned if the loan is closed.
+	Return(ctx context.Context, id string, at time.Time) (Loan, error)
+	// Renew moves the due date of an open loan that has been renewed less than maxRenewals times.
+	// It returns ErrLoanReturned if the loan is closed and ErrRenewalLimit if it cannot be renewed.
+	Renew(ctx context.Context, id string, due time.Time, maxRenewals int) (Loan, error)
+	// Overdue returns the open loans due before now, ordered by due date.
+	Overdue(ctx context.Context, now time.Time) ([]Loan, error)
+}
+
+// IdempotencyService is the interface for all stores of idempotent requests. A request claims its
+// key while it is in progress, and its recorded response keeps the key until it expires. Claim must
+// stay atomic under concurrent calls, so that only one request holds a key at a time. Each claim
+// carries a token, so that a request whose claim expired cannot complete or release the claim of
+// a retry.
+type IdempotencyService interface {
+	// Claim records a request in progress for key with token until expires, unless the key is held
+	// by a request that has not expired. It reports whether the key was claimed, and returns the
+	// request holding it otherwise.
+	Claim(ctx context.Context, key, token, fingerprint string, expires time.Time) (IdempotentRequest, bool, error)
+	// Complete records the response of the request in progress for key and keeps it until expires.
+	// It does nothing unless a request for key is in progress with token.
+	Complete(ctx context.Context, key, token string, resp RecordedResponse, expires time.Time) error
+	// Release removes the claim of the request in progress for key with token, so that it can be
+	// retried. Completed requests and other claims are kept.
+	Release(ctx context.Context, key, token string) error
+}
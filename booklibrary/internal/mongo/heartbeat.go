@@ -0,0 +1,38 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// heartbeat holds the outcome of the most recent server heartbeat.
+type heartbeat struct {
+	mu  sync.RWMutex
+	at  time.Time
+	err error
+}
+
+func (h *heartbeat) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.at = time.Now()
+	h.err = err
+}
+
+func (h *heartbeat) last() (time.Time, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.at, h.err
+}
+
+// CheckHeartbeat returns an error if the most recent MongoDB server heartbeat failed. It does not
+// contact the server and succeeds if no heartbeat has been observed yet.
+func (cs *CrudService) CheckHeartbeat(_ context.Context) error {
+	at, err := cs.heartbeat.last()
+	if err != nil {
+		return fmt.Errorf("heartbeat at %s failed: %w", at.UTC().Format(time.RFC3339), err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var _ model.IdempotencyService = (*IdempotencyService)(nil)
+
+// idempotencyIndexes are the secondary indexes of the idempotency collection. MongoDB removes
+// expired requests in the background.
+var idempotencyIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "expires", Value: 1}}, Options: options.Index().SetName("expires_1").SetExpireAfterSeconds(0)},
+}
+
+// claimAttempts is how often Claim tries to insert or read a key that is concurrently released.
+const claimAttempts = 3
+
+// IdempotencyService stores idempotent requests in a MongoDB collection of the database used by a
+// CrudService, keyed by their idempotency key.
+type IdempotencyService struct {
+	cs         *CrudService
+	collection *mongo.Collection
+}
+
+// NewIdempotencyService creates an idempotency store that keeps requests in the collection of the
+// database of cs, sharing its connection and operation timeout.
+func NewIdempotencyService(cs *CrudService, collection string) *IdempotencyService {
+	return &IdempotencyService{cs: cs, collection: cs.database.Collection(collection)}
+}
+
+// Claim records a request in progress for key with token unless the key is held. The upsert only matches an
+// expired request, so a held key collides with the existing document, and of concurrent claims of
+// a new key only one insert succeeds.
+func (is *IdempotencyService) Claim(ctx context.Context, key, token, fingerprint string, expires time.Time) (model.IdempotentRequest, bool, error) {
+	ctx, cancel := is.cs.withTimeout(ctx, "claim")
+	defer cancel()
+
+	for range claimAttempts {
+		filter := bson.M{"_id": key, "expires": bson.M{"$lte": time.Now()}}
+		update := bson.M{
+			"$set":   bson.M{"fingerprint": fingerprint, "token": token, "expires": expires},
+			"$unset": bson.M{"response": ""},
+		}
+		_, err := is.collection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+		if err == nil {
+			return model.IdempotentRequest{}, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			log.FromContext(ctx).ErrorContext(ctx, "claiming idempotency key", log.ErrorKey, err)
+			return model.IdempotentRequest{}, false, err
+		}
+
+		var req model.IdempotentRequest
+		err = is.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&req)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			// Released or removed since the upsert, try again.
+			continue
+		case err != nil:
+			log.FromContext(ctx).ErrorContext(ctx, "finding idempotent request", log.ErrorKey, err)
+			return model.IdempotentRequest{}, false, err
+		case !req.Expires.After(time.Now()):
+			// Expired since the upsert, try again.
+			continue
+		}
+		return req, false, nil
+	}
+	return model.IdempotentRequest{}, false, fmt.Errorf("claiming idempotency key: gave up after %d attempts", claimAttempts)
+}
+
+// Complete records the response of the request in progress for key with token.
+func (is *IdempotencyService) Complete(ctx context.Context, key, token string, resp model.RecordedResponse, expires time.Time) error {
+	ctx, cancel := is.cs.withTimeout(ctx, "complete")
+	defer cancel()
+
+	filter := bson.M{"_id": key, "token": token, "response": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"response": resp, "expires": expires}}
+	if _, err := is.collection.UpdateOne(ctx, filter, update); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "completing idempotent request", log.ErrorKey, err)
+		return err
+	}
+	return nil
+}
+
+// Release removes the request in progress for key with token.
+func (is *IdempotencyService) Release(ctx context.Context, key, token string) error {
+	ctx, cancel := is.cs.withTimeout(ctx, "release")
+	defer cancel()
+
+	if _, err := is.collection.DeleteOne(ctx, bson.M{"_id": key, "token": token, "response": bson.M{"$exists": false}}); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "releasing idempotency key", log.ErrorKey, err)
+		return err
+	}
+	return nil
+}
+
+// Reindex creates the TTL index of the idempotency collection.
+func (is *IdempotencyService) Reindex(ctx context.Context) error {
+	names, err := is.collection.Indexes().CreateMany(ctx, idempotencyIndexes)
+	if err != nil {
+		return fmt.Errorf("creating idempotency indexes: %w", err)
+	}
+	log.FromContext(ctx).InfoContext(ctx, "reindexed collection", "collection", is.collection.Name(), "indexes", names)
+	return nil
+}
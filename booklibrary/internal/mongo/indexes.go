@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexes are the secondary indexes supporting the queries built by query.
+var indexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "author", Value: 1}}, Options: options.Index().SetName("author_1")},
+	{Keys: bson.D{{Key: "keywords.keyword", Value: 1}}, Options: options.Index().SetName("keywords.keyword_1")},
+	{Keys: bson.D{{Key: "releaseDate", Value: 1}}, Options: options.Index().SetName("releaseDate_1")},
+}
+
+// Reindex creates the secondary indexes supporting the queries the service runs, if they are
+// missing. Other indexes, such as ones created by operators, are kept. Index builds on large
+// collections can take a while, so callers should not limit ctx to a single operation's timeout.
+func (cs *CrudService) Reindex(ctx context.Context) error {
+	names, err := cs.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("creating indexes: %w", err)
+	}
+	log.FromContext(ctx).InfoContext(ctx, "reindexed collection", "collection", cs.collection.Name(), "indexes", names)
+	return nil
+}
@@ -0,0 +1,223 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var _ model.ListService = (*ListService)(nil)
+
+// listIndexes are the secondary indexes of the reading list collection.
+var listIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "owner", Value: 1}}, Options: options.Index().SetName("owner_1")},
+	{Keys: bson.D{{Key: "bookIds", Value: 1}}, Options: options.Index().SetName("bookIds_1")},
+}
+
+// ListService stores reading lists in a MongoDB collection of the database used by a CrudService.
+type ListService struct {
+	cs         *CrudService
+	collection *mongo.Collection
+}
+
+// NewListService creates a reading list store for collection in the database of cs, sharing its
+// connection and operation timeout.
+func NewListService(cs *CrudService, collection string) *ListService {
+	return &ListService{cs: cs, collection: cs.database.Collection(collection)}
+}
+
+// Lists returns all lists of owner ordered by ID.
+func (ls *ListService) Lists(ctx context.Context, owner string) ([]model.ReadingList, error) {
+	ctx, cancel := ls.cs.withTimeout(ctx, "lists")
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := ls.collection.Find(ctx, bson.M{"owner": owner}, opts)
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "finding lists", log.ErrorKey, err)
+		return nil, err
+	}
+	lists := []model.ReadingList{}
+	if err := cur.All(ctx, &lists); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "decoding lists", log.ErrorKey, err)
+		return nil, err
+	}
+	return lists, nil
+}
+
+// GetList returns the list of owner with the given ID.
+func (ls *ListService) GetList(ctx context.Context, owner, id string) (model.ReadingList, error) {
+	filter, err := listFilter(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "get_list")
+	defer cancel()
+	return decodeList(ctx, ls.collection.FindOne(ctx, filter))
+}
+
+// AddList stores an empty list under a new ID and returns it.
+func (ls *ListService) AddList(ctx context.Context, list model.ReadingList) (model.ReadingList, error) {
+	ctx, cancel := ls.cs.withTimeout(ctx, "add_list")
+	defer cancel()
+
+	list.ID = ""
+	list.BookIDs = []string{}
+	res, err := ls.collection.InsertOne(ctx, list)
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "inserting list", log.ErrorKey, err)
+		return model.ReadingList{}, err
+	}
+	oid, ok := res.InsertedID.(bson.ObjectID)
+	if !ok {
+		panic("inserted ID is not an ObjectID")
+	}
+	list.ID = oid.Hex()
+	return list, nil
+}
+
+// UpdateList changes the name and visibility of the list and returns the new state.
+func (ls *ListService) UpdateList(ctx context.Context, owner, id string, list model.ReadingList) (model.ReadingList, error) {
+	filter, err := listFilter(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "update_list")
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"name": list.Name, "visibility": list.Visibility}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	return decodeList(ctx, ls.collection.FindOneAndUpdate(ctx, filter, update, opts))
+}
+
+// RemoveList deletes the list and returns it.
+func (ls *ListService) RemoveList(ctx context.Context, owner, id string) (model.ReadingList, error) {
+	filter, err := listFilter(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "remove_list")
+	defer cancel()
+	return decodeList(ctx, ls.collection.FindOneAndDelete(ctx, filter))
+}
+
+// PutBook inserts the book at position, or moves it there, and returns the new state. Moving a book
+// takes two updates, so concurrent readers may briefly see the list without it.
+func (ls *ListService) PutBook(ctx context.Context, owner, id, bookID string, position int) (model.ReadingList, error) {
+	filter, err := listFilter(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	if _, err := bson.ObjectIDFromHex(bookID); err != nil {
+		return model.ReadingList{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "put_list_book")
+	defer cancel()
+
+	res, err := ls.collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"bookIds": bookID}})
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "removing book from list", log.ErrorKey, err, log.IdKey, id)
+		return model.ReadingList{}, err
+	}
+	if res.MatchedCount == 0 {
+		return model.ReadingList{}, model.ErrListNotFound
+	}
+
+	each := bson.M{"$each": bson.A{bookID}}
+	if position >= 0 {
+		// Positions beyond the end of the array append.
+		each["$position"] = position
+	}
+	// Matching only lists without the book keeps concurrent puts from adding it twice.
+	filter["bookIds"] = bson.M{"$ne": bookID}
+	if _, err := ls.collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"bookIds": each}}); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "adding book to list", log.ErrorKey, err, log.IdKey, id)
+		return model.ReadingList{}, err
+	}
+	delete(filter, "bookIds")
+	return decodeList(ctx, ls.collection.FindOne(ctx, filter))
+}
+
+// RemoveBook removes the book from the list and returns the new state.
+func (ls *ListService) RemoveBook(ctx context.Context, owner, id, bookID string) (model.ReadingList, error) {
+	filter, err := listFilter(owner, id)
+	if err != nil {
+		return model.ReadingList{}, err
+	}
+	if _, err := bson.ObjectIDFromHex(bookID); err != nil {
+		return model.ReadingList{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "remove_list_book")
+	defer cancel()
+
+	filter["bookIds"] = bookID
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	list, err := decodeList(ctx, ls.collection.FindOneAndUpdate(ctx, filter, bson.M{"$pull": bson.M{"bookIds": bookID}}, opts))
+	if !errors.Is(err, model.ErrListNotFound) {
+		return list, err
+	}
+	// Tell a missing list from a list without the book.
+	delete(filter, "bookIds")
+	if _, err := decodeList(ctx, ls.collection.FindOne(ctx, filter)); err != nil {
+		return model.ReadingList{}, err
+	}
+	return model.ReadingList{}, model.ErrNotFound
+}
+
+// RemoveBookFromLists removes the book from all lists.
+func (ls *ListService) RemoveBookFromLists(ctx context.Context, bookID string) error {
+	if _, err := bson.ObjectIDFromHex(bookID); err != nil {
+		return model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "remove_book_from_lists")
+	defer cancel()
+
+	res, err := ls.collection.UpdateMany(ctx, bson.M{"bookIds": bookID}, bson.M{"$pull": bson.M{"bookIds": bookID}})
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "removing book from lists", log.ErrorKey, err, log.IdKey, bookID)
+		return err
+	}
+	log.FromContext(ctx).DebugContext(ctx, "removed book from lists", log.IdKey, bookID, "lists", res.ModifiedCount)
+	return nil
+}
+
+// Reindex creates the indexes of the reading list collection.
+func (ls *ListService) Reindex(ctx context.Context) error {
+	names, err := ls.collection.Indexes().CreateMany(ctx, listIndexes)
+	if err != nil {
+		return fmt.Errorf("creating list indexes: %w", err)
+	}
+	log.FromContext(ctx).InfoContext(ctx, "reindexed collection", "collection", ls.collection.Name(), "indexes", names)
+	return nil
+}
+
+// listFilter matches the list of owner with the given ID.
+func listFilter(owner, id string) (bson.M, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, model.ErrInvalidID
+	}
+	return bson.M{"_id": oid, "owner": owner}, nil
+}
+
+// decodeList decodes the list found by res, mapping a missing document to ErrListNotFound.
+func decodeList(ctx context.Context, res *mongo.SingleResult) (model.ReadingList, error) {
+	var l model.ReadingList
+	if err := res.Decode(&l); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.ReadingList{}, model.ErrListNotFound
+		}
+		log.FromContext(ctx).ErrorContext(ctx, "decoding list", log.ErrorKey, err)
+		return model.ReadingList{}, err
+	}
+	if l.BookIDs == nil {
+		l.BookIDs = []string{}
+	}
+	return l, nil
+}
@@ -0,0 +1,271 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var _ model.LoanService = (*LoanService)(nil)
+
+// loanIndexes are the secondary indexes of the loan collection.
+var loanIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "bookId", Value: 1}}, Options: options.Index().SetName("bookId_1")},
+	{Keys: bson.D{{Key: "due", Value: 1}}, Options: options.Index().SetName("due_1")},
+}
+
+// holding is the document that counts the copies of a book and those on loan. It is keyed by the
+// book's ID.
+type holding struct {
+	Copies int `bson:"copies"`
+	OnLoan int `bson:"onLoan"`
+}
+
+// LoanService stores copies and loans in MongoDB collections of the database used by a CrudService.
+// Checkout and Return update both collections in a transaction, which requires a replica set or
+// sharded cluster.
+type LoanService struct {
+	cs       *CrudService
+	loans    *mongo.Collection
+	holdings *mongo.Collection
+}
+
+// NewLoanService creates a loan store that keeps loans in the collection loans and the copies of
+// each book in the collection copies of the database of cs, sharing its connection and operation
+// timeout.
+func NewLoanService(cs *CrudService, loans, copies string) *LoanService {
+	return &LoanService{cs: cs, loans: cs.database.Collection(loans), holdings: cs.database.Collection(copies)}
+}
+
+// Availability returns the number of copies of the book and how many are on loan.
+func (ls *LoanService) Availability(ctx context.Context, bookID string) (model.Availability, error) {
+	if _, err := bson.ObjectIDFromHex(bookID); err != nil {
+		return model.Availability{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "availability")
+	defer cancel()
+
+	var h holding
+	if err := ls.holdings.FindOne(ctx, bson.M{"_id": bookID}).Decode(&h); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Availability{}, nil
+		}
+		log.FromContext(ctx).ErrorContext(ctx, "finding copies", log.ErrorKey, err, log.IdKey, bookID)
+		return model.Availability{}, err
+	}
+	return model.NewAvailability(h.Copies, h.OnLoan), nil
+}
+
+// SetCopies sets the number of copies of the book.
+func (ls *LoanService) SetCopies(ctx context.Context, bookID string, copies int) (model.Availability, error) {
+	if _, err := bson.ObjectIDFromHex(bookID); err != nil {
+		return model.Availability{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "set_copies")
+	defer cancel()
+
+	// If more copies are on loan, the filter fails to match and the upsert collides with the
+	// existing document.
+	filter := bson.M{"_id": bookID, "onLoan": bson.M{"$lte": copies}}
+	update := bson.M{"$set": bson.M{"copies": copies}, "$setOnInsert": bson.M{"onLoan": 0}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var h holding
+	if err := ls.holdings.FindOneAndUpdate(ctx, filter, update, opts).Decode(&h); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Availability{}, model.ErrCopiesOnLoan
+		}
+		log.FromContext(ctx).ErrorContext(ctx, "setting copies", log.ErrorKey, err, log.IdKey, bookID)
+		return model.Availability{}, err
+	}
+	return model.NewAvailability(h.Copies, h.OnLoan), nil
+}
+
+// Checkout stores the loan under a new ID if a copy of its book is available.
+func (ls *LoanService) Checkout(ctx context.Context, loan model.Loan) (model.Loan, error) {
+	if _, err := bson.ObjectIDFromHex(loan.BookID); err != nil {
+		return model.Loan{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "checkout")
+	defer cancel()
+
+	loan.ID = ""
+	loan.Returned = nil
+	loan.Renewals = 0
+	id, err := ls.transaction(ctx, func(ctx context.Context) (any, error) {
+		// Concurrent checkouts of the same book conflict on its holding, so the driver retries all
+		// but one of them against the new count.
+		filter := bson.M{"_id": loan.BookID, "$expr": bson.M{"$lt": bson.A{"$onLoan", "$copies"}}}
+		res, err := ls.holdings.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"onLoan": 1}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			return nil, model.ErrNoCopyAvailable
+		}
+		ins, err := ls.loans.InsertOne(ctx, loan)
+		if err != nil {
+			return nil, err
+		}
+		return ins.InsertedID, nil
+	})
+	if err != nil {
+		if !errors.Is(err, model.ErrNoCopyAvailable) {
+			log.FromContext(ctx).ErrorContext(ctx, "checking out book", log.ErrorKey, err, log.IdKey, loan.BookID)
+		}
+		return model.Loan{}, err
+	}
+	oid, ok := id.(bson.ObjectID)
+	if !ok {
+		panic("inserted ID is not an ObjectID")
+	}
+	loan.ID = oid.Hex()
+	return loan, nil
+}
+
+// GetLoan returns the loan with the given ID.
+func (ls *LoanService) GetLoan(ctx context.Context, id string) (model.Loan, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Loan{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "get_loan")
+	defer cancel()
+	return decodeLoan(ctx, ls.loans.FindOne(ctx, bson.M{"_id": oid}))
+}
+
+// Return closes the loan at the given time.
+func (ls *LoanService) Return(ctx context.Context, id string, at time.Time) (model.Loan, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Loan{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "return_loan")
+	defer cancel()
+
+	res, err := ls.transaction(ctx, func(ctx context.Context) (any, error) {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		l, err := decodeLoan(ctx, ls.loans.FindOneAndUpdate(ctx, openLoan(oid), bson.M{"$set": bson.M{"returned": at}}, opts))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ls.holdings.UpdateOne(ctx, bson.M{"_id": l.BookID}, bson.M{"$inc": bson.M{"onLoan": -1}}); err != nil {
+			return nil, err
+		}
+		return l, nil
+	})
+	if errors.Is(err, model.ErrLoanNotFound) {
+		// Tell a missing loan from a returned one.
+		if err := ls.closedLoan(ctx, oid); err != nil {
+			return model.Loan{}, err
+		}
+		return model.Loan{}, model.ErrLoanNotFound
+	}
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "returning loan", log.ErrorKey, err, log.IdKey, id)
+		return model.Loan{}, err
+	}
+	return res.(model.Loan), nil
+}
+
+// Renew moves the due date of the loan.
+func (ls *LoanService) Renew(ctx context.Context, id string, due time.Time, maxRenewals int) (model.Loan, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return model.Loan{}, model.ErrInvalidID
+	}
+	ctx, cancel := ls.cs.withTimeout(ctx, "renew_loan")
+	defer cancel()
+
+	filter := openLoan(oid)
+	filter["renewals"] = bson.M{"$lt": maxRenewals}
+	update := bson.M{"$set": bson.M{"due": due}, "$inc": bson.M{"renewals": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	l, err := decodeLoan(ctx, ls.loans.FindOneAndUpdate(ctx, filter, update, opts))
+	if !errors.Is(err, model.ErrLoanNotFound) {
+		return l, err
+	}
+	// Tell a missing or returned loan from one that has been renewed too often.
+	if err := ls.closedLoan(ctx, oid); err != nil {
+		return model.Loan{}, err
+	}
+	return model.Loan{}, model.ErrRenewalLimit
+}
+
+// Overdue returns the open loans due before now, ordered by due date.
+func (ls *LoanService) Overdue(ctx context.Context, now time.Time) ([]model.Loan, error) {
+	ctx, cancel := ls.cs.withTimeout(ctx, "overdue_loans")
+	defer cancel()
+
+	filter := bson.M{"returned": nil, "due": bson.M{"$lt": now}}
+	opts := options.Find().SetSort(bson.D{{Key: "due", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := ls.loans.Find(ctx, filter, opts)
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "finding overdue loans", log.ErrorKey, err)
+		return nil, err
+	}
+	loans := []model.Loan{}
+	if err := cur.All(ctx, &loans); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "decoding loans", log.ErrorKey, err)
+		return nil, err
+	}
+	return loans, nil
+}
+
+// Reindex creates the indexes of the loan collection. Copies are only looked up by book ID.
+func (ls *LoanService) Reindex(ctx context.Context) error {
+	names, err := ls.loans.Indexes().CreateMany(ctx, loanIndexes)
+	if err != nil {
+		return fmt.Errorf("creating loan indexes: %w", err)
+	}
+	log.FromContext(ctx).InfoContext(ctx, "reindexed collection", "collection", ls.loans.Name(), "indexes", names)
+	return nil
+}
+
+// transaction runs fn in a transaction, which the driver retries on transient errors such as write
+// conflicts. Errors returned by fn abort the transaction.
+func (ls *LoanService) transaction(ctx context.Context, fn func(ctx context.Context) (any, error)) (any, error) {
+	sess, err := ls.cs.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer sess.EndSession(ctx)
+	return sess.WithTransaction(ctx, fn)
+}
+
+// closedLoan explains why an update of the open loan matched nothing: it returns ErrLoanNotFound if
+// the loan is missing, ErrLoanReturned if it has been returned and nil if it is open.
+func (ls *LoanService) closedLoan(ctx context.Context, oid bson.ObjectID) error {
+	l, err := decodeLoan(ctx, ls.loans.FindOne(ctx, bson.M{"_id": oid}))
+	if err != nil {
+		return err
+	}
+	if !l.Open() {
+		return model.ErrLoanReturned
+	}
+	return nil
+}
+
+// openLoan matches the loan with the given ID if it has not been returned.
+func openLoan(oid bson.ObjectID) bson.M {
+	return bson.M{"_id": oid, "returned": nil}
+}
+
+// decodeLoan decodes the loan found by res, mapping a missing document to ErrLoanNotFound.
+func decodeLoan(ctx context.Context, res *mongo.SingleResult) (model.Loan, error) {
+	var l model.Loan
+	if err := res.Decode(&l); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Loan{}, model.ErrLoanNotFound
+		}
+		log.FromContext(ctx).ErrorContext(ctx, "decoding loan", log.ErrorKey, err)
+		return model.Loan{}, err
+	}
+	return l, nil
+}
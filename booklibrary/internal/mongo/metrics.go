@@ -0,0 +1,129 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// metrics holds the Prometheus collectors of a CrudService. Collectors are only exposed if a
+// Registerer has been passed with WithRegisterer.
+type metrics struct {
+	heartbeatSucceeded prometheus.Counter
+	heartbeatFailed    prometheus.Counter
+	commandDuration    *prometheus.HistogramVec
+	poolConnections    *prometheus.GaugeVec
+	poolInUse          *prometheus.GaugeVec
+	poolCheckoutFailed *prometheus.CounterVec
+	poolCleared        *prometheus.CounterVec
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	f := promauto.With(reg)
+	return &metrics{
+		heartbeatSucceeded: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_mongodb_heartbeat_succeeded_total",
+			Help: "The total number of successful MongoDB server heartbeats",
+		}),
+		heartbeatFailed: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_mongodb_server_heartbeat_failed_total",
+			Help: "The total number of failed MongoDB server heartbeats",
+		}),
+		commandDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "booklibrary_mongodb_command_duration_seconds",
+			Help:    "A histogram of MongoDB command latencies by operation, collection and outcome.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "collection", "outcome"}),
+		poolConnections: f.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "booklibrary_mongodb_pool_connections",
+			Help: "The number of open connections in the MongoDB connection pool.",
+		}, []string{"address"}),
+		poolInUse: f.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "booklibrary_mongodb_pool_connections_in_use",
+			Help: "The number of MongoDB connections currently checked out of the pool.",
+		}, []string{"address"}),
+		poolCheckoutFailed: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "booklibrary_mongodb_pool_checkout_failed_total",
+			Help: "The total number of failed MongoDB connection checkouts by reason.",
+		}, []string{"address", "reason"}),
+		poolCleared: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "booklibrary_mongodb_pool_cleared_total",
+			Help: "The total number of times a MongoDB connection pool has been cleared.",
+		}, []string{"address"}),
+	}
+}
+
+// commandMetrics records the latency and outcome of each command sent to MongoDB.
+type commandMetrics struct {
+	metrics     *metrics
+	collections sync.Map
+}
+
+func (cm *commandMetrics) started(_ context.Context, evt *event.CommandStartedEvent) {
+	coll, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+	cm.collections.Store(commandKey{evt.ConnectionID, evt.RequestID}, coll)
+}
+
+func (cm *commandMetrics) succeeded(_ context.Context, evt *event.CommandSucceededEvent) {
+	cm.observe(evt.CommandFinishedEvent, outcomeSuccess)
+}
+
+func (cm *commandMetrics) failed(_ context.Context, evt *event.CommandFailedEvent) {
+	cm.observe(evt.CommandFinishedEvent, outcomeFailure)
+}
+
+func (cm *commandMetrics) observe(evt event.CommandFinishedEvent, outcome string) {
+	var coll string
+	if v, ok := cm.collections.LoadAndDelete(commandKey{evt.ConnectionID, evt.RequestID}); ok {
+		coll = v.(string)
+	}
+	cm.metrics.commandDuration.WithLabelValues(evt.CommandName, coll, outcome).Observe(evt.Duration.Seconds())
+}
+
+func newCommandMonitor(m *metrics) *event.CommandMonitor {
+	ct := newCommandTracer()
+	cm := &commandMetrics{metrics: m}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			ct.started(ctx, evt)
+			cm.started(ctx, evt)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			ct.succeeded(ctx, evt)
+			cm.succeeded(ctx, evt)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			ct.failed(ctx, evt)
+			cm.failed(ctx, evt)
+		},
+	}
+}
+
+func newPoolMonitor(m *metrics) *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.ConnectionCreated:
+				m.poolConnections.WithLabelValues(evt.Address).Inc()
+			case event.ConnectionClosed:
+				m.poolConnections.WithLabelValues(evt.Address).Dec()
+			case event.ConnectionCheckedOut:
+				m.poolInUse.WithLabelValues(evt.Address).Inc()
+			case event.ConnectionCheckedIn:
+				m.poolInUse.WithLabelValues(evt.Address).Dec()
+			case event.ConnectionCheckOutFailed:
+				m.poolCheckoutFailed.WithLabelValues(evt.Address, evt.Reason).Inc()
+			case event.ConnectionPoolCleared:
+				m.poolCleared.WithLabelValues(evt.Address).Inc()
+			}
+		},
+	}
+}
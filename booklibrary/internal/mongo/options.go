@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultTimeout         = 2 * time.Second
+	defaultStartupTimeout  = 10 * time.Second
+	defaultMaxPoolSize     = 100
+	defaultMaxConnIdleTime = 0
+)
+
+type settings struct {
+	timeout         time.Duration
+	startupTimeout  time.Duration
+	maxPoolSize     uint64
+	maxConnIdleTime time.Duration
+	retryWrites     bool
+	retryReads      bool
+	registerer      prometheus.Registerer
+}
+
+func defaultSettings() settings {
+	return settings{
+		timeout:         defaultTimeout,
+		startupTimeout:  defaultStartupTimeout,
+		maxPoolSize:     defaultMaxPoolSize,
+		maxConnIdleTime: defaultMaxConnIdleTime,
+		retryWrites:     true,
+		retryReads:      true,
+	}
+}
+
+// Option configures a CrudService. Pool and retry options are overridden by the corresponding
+// parameters of the connection string, if present.
+type Option func(*settings)
+
+// WithTimeout sets the maximum duration of a single store operation. A shorter deadline on the
+// caller's context always takes precedence.
+func WithTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.timeout = d
+		}
+	}
+}
+
+// WithStartupTimeout sets the maximum duration for connecting to MongoDB.
+func WithStartupTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.startupTimeout = d
+		}
+	}
+}
+
+// WithMaxPoolSize sets the maximum number of connections in the connection pool.
+func WithMaxPoolSize(n uint64) Option {
+	return func(s *settings) {
+		s.maxPoolSize = n
+	}
+}
+
+// WithMaxConnIdleTime sets the maximum time a pooled connection may remain idle. Zero means no limit.
+func WithMaxConnIdleTime(d time.Duration) Option {
+	return func(s *settings) {
+		s.maxConnIdleTime = d
+	}
+}
+
+// WithRetryWrites enables or disables retryable writes.
+func WithRetryWrites(enabled bool) Option {
+	return func(s *settings) {
+		s.retryWrites = enabled
+	}
+}
+
+// WithRetryReads enables or disables retryable reads.
+func WithRetryReads(enabled bool) Option {
+	return func(s *settings) {
+		s.retryReads = enabled
+	}
+}
+
+// WithRegisterer registers the service's driver, connection pool and heartbeat metrics with reg.
+// Without it, metrics are collected but not exposed.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(s *settings) {
+		s.registerer = reg
+	}
+}
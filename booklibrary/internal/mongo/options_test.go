@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		want     time.Duration
+		source   string
+	}{
+		{"no caller deadline", 0, time.Second, "store"},
+		{"later caller deadline", time.Hour, time.Second, "store"},
+		{"earlier caller deadline", 100 * time.Millisecond, 100 * time.Millisecond, "caller"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			ctx := log.NewContext(context.Background(), logger)
+			if tc.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.deadline)
+				defer cancel()
+			}
+
+			cs := CrudService{timeout: time.Second}
+			start := time.Now()
+			ctx, cancel := cs.withTimeout(ctx, "get")
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("Operation context has no deadline")
+			}
+			// Allow for the time spent between taking start and deriving the deadlines.
+			if got := deadline.Sub(start); got < tc.want-50*time.Millisecond || got > tc.want+50*time.Millisecond {
+				t.Errorf("Unexpected operation budget, got %v, want %v", got, tc.want)
+			}
+			out := buf.String()
+			for _, attr := range []string{log.OperationKey + "=get", log.SourceKey + "=" + tc.source, log.BudgetKey + "="} {
+				if !strings.Contains(out, attr) {
+					t.Errorf("Log output %q does not contain %q", out, attr)
+				}
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	s := defaultSettings()
+	for _, o := range []Option{
+		WithMaxPoolSize(10),
+		WithMaxConnIdleTime(time.Minute),
+		WithRetryWrites(false),
+		WithRetryReads(false),
+		WithStartupTimeout(5 * time.Second),
+	} {
+		o(&s)
+	}
+	m := newMetrics(nil)
+
+	// Without connection string parameters, the options apply.
+	opts := clientOptions("mongodb://localhost:27017", s, m, &heartbeat{})
+	if got := *opts.MaxPoolSize; got != 10 {
+		t.Errorf("Unexpected max pool size, got %d, want %d", got, 10)
+	}
+	if got := *opts.MaxConnIdleTime; got != time.Minute {
+		t.Errorf("Unexpected max connection idle time, got %v, want %v", got, time.Minute)
+	}
+	if got := *opts.RetryWrites; got {
+		t.Errorf("Unexpected retry writes, got %t, want %t", got, false)
+	}
+	if got := *opts.RetryReads; got {
+		t.Errorf("Unexpected retry reads, got %t, want %t", got, false)
+	}
+	if got := *opts.ConnectTimeout; got != 5*time.Second {
+		t.Errorf("Unexpected connect timeout, got %v, want %v", got, 5*time.Second)
+	}
+
+	// Connection string parameters take precedence.
+	opts = clientOptions("mongodb://localhost:27017/?maxPoolSize=20&maxIdleTimeMS=1000&retryWrites=true&retryReads=true", s, m, &heartbeat{})
+	if got := *opts.MaxPoolSize; got != 20 {
+		t.Errorf("Unexpected max pool size, got %d, want %d", got, 20)
+	}
+	if got := *opts.MaxConnIdleTime; got != time.Second {
+		t.Errorf("Unexpected max connection idle time, got %v, want %v", got, time.Second)
+	}
+	if got := *opts.RetryWrites; !got {
+		t.Errorf("Unexpected retry writes, got %t, want %t", got, true)
+	}
+	if got := *opts.RetryReads; !got {
+		t.Errorf("Unexpected retry reads, got %t, want %t", got, true)
+	}
+}
+
+func TestOptionsIgnoreNonPositiveTimeouts(t *testing.T) {
+	s := defaultSettings()
+	WithTimeout(0)(&s)
+	WithStartupTimeout(-time.Second)(&s)
+	if s.timeout != defaultTimeout {
+		t.Errorf("Unexpected timeout, got %v, want %v", s.timeout, defaultTimeout)
+	}
+	if s.startupTimeout != defaultStartupTimeout {
+		t.Errorf("Unexpected startup timeout, got %v, want %v", s.startupTimeout, defaultStartupTimeout)
+	}
+}
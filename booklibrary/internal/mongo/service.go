@@ -3,14 +3,13 @@ package mongo
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
 	"log/slog"
 
 	"github.com/joergjo/go-samples/booklibrary/internal/log"
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -23,55 +22,52 @@ type CrudService struct {
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	timeout    time.Duration
+	heartbeat  *heartbeat
 }
 
 var (
 	// Compile-time check to verify we implement Storage
-	_                  model.CrudService = (*CrudService)(nil)
-	timeout                              = 2 * time.Second
-	startupTimeout                       = 10 * time.Second
-	connectionIDKey                      = "connectionID"
-	heartbeatSucceeded                   = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "booklibrary_mongodb_heartbeat_succeeded_total",
-		Help: "The total number of successful MongoDB server heartbeats",
-	})
-	heartbeatFailed = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "booklibrary_mongodb_server_heartbeat_failed_total",
-		Help: "The total number of failed MongoDB server heartbeats",
-	})
+	_               model.CrudService = (*CrudService)(nil)
+	_               model.Finder      = (*CrudService)(nil)
+	connectionIDKey                   = "connectionID"
 )
 
-func newMonitor() *event.ServerMonitor {
+func newMonitor(m *metrics, hb *heartbeat) *event.ServerMonitor {
 	return &event.ServerMonitor{
 		ServerHeartbeatFailed: func(evt *event.ServerHeartbeatFailedEvent) {
-			heartbeatFailed.Inc()
+			m.heartbeatFailed.Inc()
+			hb.record(evt.Failure)
 			slog.Warn("MongoDB server heartbeat failed", log.ErrorKey, evt.Failure, connectionIDKey, evt.ConnectionID)
 		},
 		ServerHeartbeatSucceeded: func(evt *event.ServerHeartbeatSucceededEvent) {
-			heartbeatSucceeded.Inc()
+			m.heartbeatSucceeded.Inc()
+			hb.record(nil)
 			slog.Debug("server heartbeat succeeded", connectionIDKey, evt.ConnectionID)
 		},
 	}
 }
 
 // NewCrudService creates a new CRUD service for MongoDB.
-func NewCrudService(mongoURI, database, collection string) (*CrudService, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+func NewCrudService(mongoURI, database, collection string, opts ...Option) (*CrudService, error) {
+	s := defaultSettings()
+	for _, o := range opts {
+		o(&s)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.startupTimeout)
 	defer cancel()
 
 	// Set client options
-	bsonOpts := &options.BSONOptions{
-		ObjectIDAsHexString: true,
-	}
-	opts := options.Client().ApplyURI(mongoURI).SetServerMonitor(newMonitor()).
-		SetConnectTimeout(startupTimeout).SetBSONOptions(bsonOpts)
-	if err := opts.Validate(); err != nil {
-		slog.Error("validating client options", log.ErrorKey, err, slog.Any("options", opts))
+	hb := &heartbeat{}
+	clientOpts := clientOptions(mongoURI, s, newMetrics(s.registerer), hb)
+	if err := clientOpts.Validate(); err != nil {
+		slog.Error("validating client options", log.ErrorKey, err, slog.Any("options", clientOpts))
 		return nil, err
 	}
 
 	// Connect to MongoDB
-	client, err := mongo.Connect(opts)
+	client, err := mongo.Connect(clientOpts)
 	if err != nil {
 		slog.Error("connecting to MongoDB", log.ErrorKey, err)
 		return nil, err
@@ -88,13 +84,44 @@ func NewCrudService(mongoURI, database, collection string) (*CrudService, error)
 		client:     client,
 		database:   db,
 		collection: coll,
+		timeout:    s.timeout,
+		heartbeat:  hb,
 	}
 	return &crud, nil
 }
 
+// clientOptions builds the driver's client options. Pool and retry settings are applied before the
+// connection string, so options given in mongoURI take precedence.
+func clientOptions(mongoURI string, s settings, m *metrics, hb *heartbeat) *options.ClientOptions {
+	bsonOpts := &options.BSONOptions{
+		ObjectIDAsHexString: true,
+	}
+	return options.Client().
+		SetMaxPoolSize(s.maxPoolSize).SetMaxConnIdleTime(s.maxConnIdleTime).
+		SetRetryWrites(s.retryWrites).SetRetryReads(s.retryReads).
+		ApplyURI(mongoURI).SetServerMonitor(newMonitor(m, hb)).SetMonitor(newCommandMonitor(m)).
+		SetPoolMonitor(newPoolMonitor(m)).
+		SetConnectTimeout(s.startupTimeout).SetBSONOptions(bsonOpts)
+}
+
+// withTimeout derives a context for a single store operation. The operation timeout applies unless
+// the caller's deadline is earlier, in which case the caller's deadline wins.
+func (cs *CrudService) withTimeout(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	budget := cs.timeout
+	source := "store"
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < budget {
+			budget = remaining
+			source = "caller"
+		}
+	}
+	log.FromContext(ctx).DebugContext(ctx, "operation deadline", log.OperationKey, op, log.BudgetKey, budget, log.SourceKey, source)
+	return context.WithTimeout(ctx, cs.timeout)
+}
+
 // All returns all books up to 'limit' instance in the collection.
 func (cs *CrudService) List(ctx context.Context, limit int) ([]model.Book, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := cs.withTimeout(ctx, "list")
 	defer cancel()
 	books, err := cs.find(ctx, bson.M{}, limit)
 	if err != nil {
@@ -103,13 +130,57 @@ func (cs *CrudService) List(ctx context.Context, limit int) ([]model.Book, error
 	return books, nil
 }
 
+// Find returns the books in the collection that match filter.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	q, err := query(filter)
+	if err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "parsing ObjectID", log.ErrorKey, err, log.IdKey, filter.After)
+		return nil, model.ErrInvalidID
+	}
+	ctx, cancel := cs.withTimeout(ctx, "find")
+	defer cancel()
+	return cs.find(ctx, q, filter.Limit)
+}
+
+// query translates filter into a MongoDB query document.
+func query(filter model.Filter) (bson.M, error) {
+	q := bson.M{}
+	if filter.After != "" {
+		oid, err := bson.ObjectIDFromHex(filter.After)
+		if err != nil {
+			return nil, err
+		}
+		q["_id"] = bson.M{"$gt": oid}
+	}
+	if filter.Author != "" {
+		q["author"] = bson.M{"$regex": "^" + regexp.QuoteMeta(filter.Author) + "$", "$options": "i"}
+	}
+	if filter.Title != "" {
+		q["title"] = bson.M{"$regex": regexp.QuoteMeta(filter.Title), "$options": "i"}
+	}
+	if filter.Keyword != "" {
+		q["keywords.keyword"] = filter.Keyword
+	}
+	released := bson.M{}
+	if !filter.ReleasedAfter.IsZero() {
+		released["$gte"] = filter.ReleasedAfter
+	}
+	if !filter.ReleasedBefore.IsZero() {
+		released["$lt"] = filter.ReleasedBefore
+	}
+	if len(released) > 0 {
+		q["releaseDate"] = released
+	}
+	return q, nil
+}
+
 // Book finds a book by its ID in the collection.
 func (cs *CrudService) Get(ctx context.Context, id string) (model.Book, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := cs.withTimeout(ctx, "get")
 	defer cancel()
 	oid, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		slog.Error("parsing ObjectID", log.ErrorKey, err, slog.String("id", id))
+		log.FromContext(ctx).ErrorContext(ctx, "parsing ObjectID", log.ErrorKey, err, slog.String("id", id))
 		return model.Book{}, model.ErrInvalidID
 	}
 
@@ -126,12 +197,14 @@ func (cs *CrudService) Get(ctx context.Context, id string) (model.Book, error) {
 
 // Add adds a new book to the collection.
 func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := cs.withTimeout(ctx, "add")
 	defer cancel()
 
+	// MongoDB assigns a new ObjectID only if the document has none.
+	book.ID = ""
 	res, err := cs.collection.InsertOne(ctx, book)
 	if err != nil {
-		slog.Error("inserting document", log.ErrorKey, err)
+		log.FromContext(ctx).ErrorContext(ctx, "inserting document", log.ErrorKey, err)
 		return model.Book{}, err
 	}
 	oid, ok := res.InsertedID.(bson.ObjectID)
@@ -146,11 +219,11 @@ func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, er
 func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
 	oid, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		slog.Error("parsing ObjectID", log.ErrorKey, err, log.IdKey, id)
+		log.FromContext(ctx).ErrorContext(ctx, "parsing ObjectID", log.ErrorKey, err, log.IdKey, id)
 		return model.Book{}, model.ErrInvalidID
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := cs.withTimeout(ctx, "update")
 	defer cancel()
 
 	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
@@ -162,7 +235,7 @@ func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (
 		"keywords":    book.Keywords}}
 	res := cs.collection.FindOneAndUpdate(ctx, filter, update, options)
 	if err := res.Err(); err != nil {
-		slog.Error("updating document", log.ErrorKey, err, log.IdKey, id)
+		log.FromContext(ctx).ErrorContext(ctx, "updating document", log.ErrorKey, err, log.IdKey, id)
 		if !errors.Is(err, mongo.ErrNoDocuments) {
 			return model.Book{}, err
 		}
@@ -172,7 +245,7 @@ func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (
 	var b model.Book
 	err = res.Decode(&b)
 	if err != nil {
-		slog.Error("decoding document", log.ErrorKey, err)
+		log.FromContext(ctx).ErrorContext(ctx, "decoding document", log.ErrorKey, err)
 		return model.Book{}, err
 	}
 	return b, nil
@@ -182,17 +255,17 @@ func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (
 func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
 	oid, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		slog.Error("parsing ObjectID", log.ErrorKey, err, log.IdKey, id)
+		log.FromContext(ctx).ErrorContext(ctx, "parsing ObjectID", log.ErrorKey, err, log.IdKey, id)
 		return model.Book{}, model.ErrInvalidID
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := cs.withTimeout(ctx, "remove")
 	defer cancel()
 
 	filter := bson.M{"_id": oid}
 	res := cs.collection.FindOneAndDelete(ctx, filter)
 	if err := res.Err(); err != nil {
-		slog.Error("deleting document", log.ErrorKey, err, log.IdKey, id)
+		log.FromContext(ctx).ErrorContext(ctx, "deleting document", log.ErrorKey, err, log.IdKey, id)
 		if !errors.Is(err, mongo.ErrNoDocuments) {
 			return model.Book{}, err
 		}
@@ -202,20 +275,17 @@ func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error
 	var b model.Book
 	err = res.Decode(&b)
 	if err != nil {
-		slog.Error("decoding document", log.ErrorKey, err)
+		log.FromContext(ctx).ErrorContext(ctx, "decoding document", log.ErrorKey, err)
 		return model.Book{}, err
 	}
 	return b, nil
 }
 
 func (cs *CrudService) find(ctx context.Context, filter bson.M, limit int) ([]model.Book, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	findOptions := options.Find().SetLimit(int64(limit))
+	findOptions := options.Find().SetLimit(int64(limit)).SetSort(bson.D{{Key: "_id", Value: 1}})
 	cur, err := cs.collection.Find(ctx, filter, findOptions)
 	if err != nil {
-		slog.Error("finding document(s)", log.ErrorKey, err)
+		log.FromContext(ctx).ErrorContext(ctx, "finding document(s)", log.ErrorKey, err)
 		return nil, err
 	}
 	defer cur.Close(ctx)
@@ -228,14 +298,14 @@ func (cs *CrudService) find(ctx context.Context, filter bson.M, limit int) ([]mo
 			if errors.Is(err, mongo.ErrNoDocuments) {
 				return []model.Book{}, nil
 			}
-			slog.Error("decoding document", log.ErrorKey, err)
+			log.FromContext(ctx).ErrorContext(ctx, "decoding document", log.ErrorKey, err)
 			break
 		}
 		books = append(books, b)
 	}
 
 	if err := cur.Err(); err != nil {
-		slog.Error("iterating over cursor", log.ErrorKey, err)
+		log.FromContext(ctx).ErrorContext(ctx, "iterating over cursor", log.ErrorKey, err)
 		return nil, err
 	}
 	return books, nil
@@ -248,3 +318,9 @@ func (m *CrudService) Close(ctx context.Context) error {
 func (m *CrudService) Ping(ctx context.Context) error {
 	return m.client.Ping(ctx, readpref.Primary())
 }
+
+// IsTransient reports whether err is a network error that may succeed if the operation is retried.
+// Timeouts are not considered transient, since a retry would likely time out as well.
+func IsTransient(err error) bool {
+	return mongo.IsNetworkError(err) && !mongo.IsTimeout(err)
+}
@@ -0,0 +1,301 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testURIEnv names the environment variable with the connection string of a MongoDB deployment to
+// test against. Without it, the tests start a local mongod if one is installed, and are skipped
+// otherwise.
+const testURIEnv = "BOOKLIBRARY_TEST_MONGOURI"
+
+var (
+	testURI  string
+	testColl atomic.Int32
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runMain(m))
+}
+
+func runMain(m *testing.M) int {
+	testURI = os.Getenv(testURIEnv)
+	if testURI == "" {
+		uri, stop, err := startMongod()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "not running MongoDB tests: %v\n", err)
+		}
+		if stop != nil {
+			defer stop()
+		}
+		testURI = uri
+	}
+	return m.Run()
+}
+
+// startMongod starts a mongod with a temporary data directory on a free port.
+func startMongod() (string, func(), error) {
+	bin, err := exec.LookPath("mongod")
+	if err != nil {
+		return "", nil, fmt.Errorf("set %s or install mongod: %w", testURIEnv, err)
+	}
+	dir, err := os.MkdirTemp("", "booklibrary-mongod")
+	if err != nil {
+		return "", nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	// The loan store's transactions need a replica set.
+	cmd := exec.Command(bin, "--dbpath", dir, "--port", strconv.Itoa(port), "--bind_ip", "127.0.0.1",
+		"--replSet", "rs0", "--logpath", filepath.Join(dir, "mongod.log"))
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		os.RemoveAll(dir)
+	}
+	host := fmt.Sprintf("127.0.0.1:%d", port)
+	uri := fmt.Sprintf("mongodb://%s/?timeoutMS=0&directConnection=true", host)
+	if err := initiate(uri, host); err != nil {
+		stop()
+		return "", nil, err
+	}
+	return uri, stop, nil
+}
+
+// initiate makes the mongod at uri the primary of a single-member replica set.
+func initiate(uri, host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	admin := client.Database("admin")
+	members := bson.A{bson.D{{Key: "_id", Value: 0}, {Key: "host", Value: host}}}
+	cfg := bson.D{{Key: "_id", Value: "rs0"}, {Key: "members", Value: members}}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: cfg}}).Err(); err != nil {
+		return fmt.Errorf("initiating replica set: %w", err)
+	}
+	for {
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+		if err == nil && hello.IsWritablePrimary {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for primary: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// newTestService connects to the test deployment and returns a service for a new collection,
+// which is dropped when the test ends.
+func newTestService(t testing.TB) *CrudService {
+	t.Helper()
+	coll := fmt.Sprintf("books_%d_%d", os.Getpid(), testColl.Add(1))
+	crud, err := NewCrudService(testURI, "booklibrary_test", coll, WithTimeout(10*time.Second))
+	if err != nil {
+		t.Fatalf("Error connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := crud.collection.Drop(ctx); err != nil {
+			t.Errorf("Error dropping collection %s: %v", coll, err)
+		}
+		if err := crud.Close(ctx); err != nil {
+			t.Errorf("Error disconnecting from MongoDB: %v", err)
+		}
+	})
+	return crud
+}
+
+// newTestListService returns a list service for a new collection next to the collection of a new
+// test service. Both are dropped when the test ends.
+func newTestListService(t testing.TB) *ListService {
+	t.Helper()
+	crud := newTestService(t)
+	ls := NewListService(crud, crud.collection.Name()+"_lists")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := ls.collection.Drop(ctx); err != nil {
+			t.Errorf("Error dropping collection %s: %v", ls.collection.Name(), err)
+		}
+	})
+	return ls
+}
+
+// newTestLoanService returns a loan service for new collections next to the collection of a new
+// test service. All are dropped when the test ends.
+func newTestLoanService(t testing.TB) *LoanService {
+	t.Helper()
+	crud := newTestService(t)
+	ls := NewLoanService(crud, crud.collection.Name()+"_loans", crud.collection.Name()+"_copies")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, coll := range []*mongo.Collection{ls.loans, ls.holdings} {
+			if err := coll.Drop(ctx); err != nil {
+				t.Errorf("Error dropping collection %s: %v", coll.Name(), err)
+			}
+		}
+	})
+	return ls
+}
+
+func TestConformance(t *testing.T) {
+	if testURI == "" {
+		t.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	storetest.Run(t, func(t *testing.T) model.CrudService {
+		return newTestService(t)
+	})
+}
+
+func TestListConformance(t *testing.T) {
+	if testURI == "" {
+		t.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	storetest.RunLists(t, func(t *testing.T) model.ListService {
+		return newTestListService(t)
+	})
+}
+
+// newTestIdempotencyService returns an idempotency service for a new collection next to the
+// collection of a new test service. It is dropped when the test ends.
+func newTestIdempotencyService(t testing.TB) *IdempotencyService {
+	t.Helper()
+	crud := newTestService(t)
+	is := NewIdempotencyService(crud, crud.collection.Name()+"_idempotency")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := is.collection.Drop(ctx); err != nil {
+			t.Errorf("Error dropping collection %s: %v", is.collection.Name(), err)
+		}
+	})
+	return is
+}
+
+func TestLoanConformance(t *testing.T) {
+	if testURI == "" {
+		t.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	storetest.RunLoans(t, func(t *testing.T) model.LoanService {
+		return newTestLoanService(t)
+	})
+}
+
+func TestIdempotencyConformance(t *testing.T) {
+	if testURI == "" {
+		t.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	storetest.RunIdempotency(t, func(t *testing.T) model.IdempotencyService {
+		return newTestIdempotencyService(t)
+	})
+}
+
+func TestReindex(t *testing.T) {
+	if testURI == "" {
+		t.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	crud := newTestService(t)
+	ctx := context.Background()
+	// Indexes created by others are kept.
+	custom := mongo.IndexModel{Keys: bson.D{{Key: "title", Value: 1}}}
+	if _, err := crud.collection.Indexes().CreateOne(ctx, custom); err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+
+	// Reindexing twice must be idempotent.
+	for range 2 {
+		if err := crud.Reindex(ctx); err != nil {
+			t.Fatalf("Reindex() error = %v", err)
+		}
+	}
+	specs, err := crud.collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Fatalf("Error listing indexes: %v", err)
+	}
+	var got []string
+	for _, spec := range specs {
+		got = append(got, spec.Name)
+	}
+	slices.Sort(got)
+	want := []string{"_id_", "author_1", "keywords.keyword_1", "releaseDate_1", "title_1"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected indexes (-want +got):\n%s", diff)
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	if testURI == "" {
+		b.Skipf("no MongoDB deployment, set %s or install mongod", testURIEnv)
+	}
+	crud := newTestService(b)
+	ctx := context.Background()
+	for i := range 1000 {
+		_, err := crud.Add(ctx, model.Book{
+			Author:      fmt.Sprintf("Author %d", i%50),
+			Title:       fmt.Sprintf("Title %d", i),
+			ReleaseDate: time.Date(2000+i%25, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Keywords:    []model.Keyword{{Value: "Go"}, {Value: fmt.Sprintf("Keyword %d", i%10)}},
+		})
+		if err != nil {
+			b.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	filters := []struct {
+		name   string
+		filter model.Filter
+	}{
+		{name: "list", filter: model.Filter{Limit: 100}},
+		{name: "author", filter: model.Filter{Author: "author 7", Limit: 100}},
+		{name: "keyword_and_date", filter: model.Filter{Keyword: "Keyword 3", ReleasedAfter: time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC), Limit: 100}},
+	}
+	for _, f := range filters {
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := crud.Find(ctx, f.filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/joergjo/go-samples/booklibrary/internal/mongo"
+
+type commandKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// commandTracer creates a client span for each command sent to MongoDB. Spans are children of
+// the span carried by the operation's context, so they nest below the HTTP server span.
+type commandTracer struct {
+	tracer trace.Tracer
+	spans  sync.Map
+}
+
+func newCommandTracer() *commandTracer {
+	return &commandTracer{tracer: otel.Tracer(tracerName)}
+}
+
+func (ct *commandTracer) started(ctx context.Context, evt *event.CommandStartedEvent) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemNameMongoDB,
+		semconv.DBOperationName(evt.CommandName),
+		semconv.DBNamespace(evt.DatabaseName),
+	}
+	name := evt.CommandName
+	if coll, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+		attrs = append(attrs, semconv.DBCollectionName(coll))
+		name += " " + coll
+	}
+	_, span := ct.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	ct.spans.Store(commandKey{evt.ConnectionID, evt.RequestID}, span)
+}
+
+func (ct *commandTracer) succeeded(_ context.Context, evt *event.CommandSucceededEvent) {
+	if span, ok := ct.end(evt.CommandFinishedEvent); ok {
+		span.End()
+	}
+}
+
+func (ct *commandTracer) failed(_ context.Context, evt *event.CommandFailedEvent) {
+	if span, ok := ct.end(evt.CommandFinishedEvent); ok {
+		span.RecordError(evt.Failure)
+		span.SetStatus(codes.Error, evt.Failure.Error())
+		span.End()
+	}
+}
+
+func (ct *commandTracer) end(evt event.CommandFinishedEvent) (trace.Span, bool) {
+	v, ok := ct.spans.LoadAndDelete(commandKey{evt.ConnectionID, evt.RequestID})
+	if !ok {
+		return nil, false
+	}
+	return v.(trace.Span), true
+}
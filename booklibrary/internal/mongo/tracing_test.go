@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCommandTracer(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ct := &commandTracer{tracer: tp.Tracer(tracerName)}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "GET /api/books/{id}")
+	cmd, err := bson.Marshal(bson.D{{Key: "find", Value: "books"}})
+	if err != nil {
+		t.Fatalf("Error marshaling command: %v", err)
+	}
+	start := func(requestID int64) {
+		ct.started(ctx, &event.CommandStartedEvent{
+			Command:      cmd,
+			DatabaseName: "booklibrary",
+			CommandName:  "find",
+			RequestID:    requestID,
+			ConnectionID: "localhost:27017[-1]",
+		})
+	}
+	finished := func(requestID int64) event.CommandFinishedEvent {
+		return event.CommandFinishedEvent{CommandName: "find", RequestID: requestID, ConnectionID: "localhost:27017[-1]"}
+	}
+
+	start(1)
+	start(2)
+	if got := len(sr.Started()); got != 3 {
+		t.Fatalf("Unexpected number of started spans, got %d, want 3", got)
+	}
+	if got := len(sr.Ended()); got != 0 {
+		t.Fatalf("Unexpected number of ended spans before commands finished, got %d, want 0", got)
+	}
+	ct.succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(1)})
+	failure := errors.New("connection reset")
+	ct.failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished(2), Failure: failure})
+	// Events of unknown commands are ignored.
+	ct.succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(3)})
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Unexpected number of ended spans, got %d, want 3", len(spans))
+	}
+	for i, span := range spans[:2] {
+		if got, want := span.Name(), "find books"; got != want {
+			t.Errorf("Unexpected name of span %d, got %q, want %q", i, got, want)
+		}
+		if got := span.SpanKind(); got != trace.SpanKindClient {
+			t.Errorf("Unexpected kind of span %d, got %v, want %v", i, got, trace.SpanKindClient)
+		}
+		if got, want := span.Parent().SpanID(), parent.SpanContext().SpanID(); got != want {
+			t.Errorf("Unexpected parent of span %d, got %v, want %v", i, got, want)
+		}
+		coll := semconv.DBCollectionName("books")
+		found := false
+		for _, attr := range span.Attributes() {
+			if attr == coll {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Attributes of span %d %v do not contain %v", i, span.Attributes(), coll)
+		}
+	}
+	if got := spans[0].Status().Code; got != codes.Unset {
+		t.Errorf("Unexpected status of succeeded command, got %v, want %v", got, codes.Unset)
+	}
+	status := spans[1].Status()
+	if status.Code != codes.Error || status.Description != failure.Error() {
+		t.Errorf("Unexpected status of failed command, got %+v, want %v %q", status, codes.Error, failure.Error())
+	}
+	if events := spans[1].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("Unexpected events of failed command, got %+v, want one exception", events)
+	}
+}
@@ -0,0 +1,27 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limiter decides whether a request identified by key may proceed. Implementations must be safe for
+// concurrent use. A Limiter backed by a shared store (e.g. Redis) allows limits to be enforced across
+// several instances of the API.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Result describes the outcome of a call to Limiter.Allow.
+type Result struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Limit is the maximum number of requests per period.
+	Limit int
+	// Remaining is the number of requests the key may still make right now.
+	Remaining int
+	// Reset is the time until the key's quota is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request will be allowed if Allowed is false.
+	RetryAfter time.Duration
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Compile-time check to verify we implement Limiter
+var _ Limiter = (*Memory)(nil)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Memory is an in-process token bucket Limiter. Each key gets a bucket holding up to limit tokens
+// that refills at limit tokens per period. Limits are not shared between processes.
+type Memory struct {
+	mu        sync.Mutex
+	limit     int
+	period    time.Duration
+	rate      float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemory creates a Memory limiter that allows limit requests per period and key.
+func NewMemory(limit int, period time.Duration) *Memory {
+	return &Memory{
+		limit:   limit,
+		period:  period,
+		rate:    float64(limit) / period.Seconds(),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket if one is available.
+func (m *Memory) Allow(_ context.Context, key string) (Result, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(m.limit), last: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(m.limit), b.tokens+now.Sub(b.last).Seconds()*m.rate)
+	b.last = now
+
+	res := Result{Limit: m.limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = m.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = m.duration(float64(m.limit) - b.tokens)
+	return res, nil
+}
+
+// duration returns the time it takes to refill n tokens.
+func (m *Memory) duration(n float64) time.Duration {
+	return time.Duration(math.Ceil(n / m.rate * float64(time.Second)))
+}
+
+// sweep drops buckets that have been idle long enough to be full again, so that the map does not
+// grow with every client ever seen. It runs at most once per period.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.period {
+		return
+	}
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= m.period {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
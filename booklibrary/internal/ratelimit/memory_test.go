@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryAllow(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(3, time.Minute)
+	m.now = func() time.Time { return now }
+
+	tests := []struct {
+		name          string
+		advance       time.Duration
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"first_request", 0, "a", true, 2},
+		{"second_request", 0, "a", true, 1},
+		{"third_request", 0, "a", true, 0},
+		{"exhausted", 0, "a", false, 0},
+		{"other_key", 0, "b", true, 2},
+		{"refilled_one_token", 20 * time.Second, "a", true, 0},
+		{"refilled_all_tokens", 2 * time.Minute, "a", true, 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+			res, err := m.Allow(context.Background(), tc.key)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if res.Allowed != tc.wantAllowed {
+				t.Errorf("Unexpected Allowed, got %t, want %t", res.Allowed, tc.wantAllowed)
+			}
+			if res.Remaining != tc.wantRemaining {
+				t.Errorf("Unexpected Remaining, got %d, want %d", res.Remaining, tc.wantRemaining)
+			}
+			if !res.Allowed && res.RetryAfter <= 0 {
+				t.Errorf("Expected positive RetryAfter for rejected request, got %v", res.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(1, time.Minute)
+	m.now = func() time.Time { return now }
+
+	m.Allow(context.Background(), "a")
+	now = now.Add(2 * time.Minute)
+	m.Allow(context.Background(), "b")
+
+	if _, ok := m.buckets["a"]; ok {
+		t.Errorf("Expected idle bucket to be swept")
+	}
+	if _, ok := m.buckets["b"]; !ok {
+		t.Errorf("Expected active bucket to be kept")
+	}
+}
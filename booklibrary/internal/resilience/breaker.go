@@ -0,0 +1,138 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed lets all calls through and counts consecutive failures.
+	StateClosed State = iota
+	// StateHalfOpen lets a single probe call through to test whether the store has recovered.
+	StateHalfOpen
+	// StateOpen rejects all calls until the cooldown has elapsed.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+type outcome int
+
+const (
+	success outcome = iota
+	failure
+	// ignored is recorded for calls whose outcome says nothing about the store's health, such as
+	// calls canceled by the client.
+	ignored
+)
+
+// breaker is a consecutive-failure circuit breaker. After threshold consecutive failures it opens
+// for cooldown, then admits one probe. A successful probe closes it, a failed one opens it again.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+	onChange  func(from, to State)
+}
+
+func newBreaker(threshold int, cooldown time.Duration, onChange func(from, to State)) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		onChange:  onChange,
+	}
+}
+
+// allow reports whether a call may proceed. If not, it returns the time until the next probe.
+func (b *breaker) allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		wait := b.cooldown - b.now().Sub(b.openedAt)
+		if wait > 0 {
+			return false, wait
+		}
+		b.transition(StateHalfOpen)
+		b.probing = true
+		return true, 0
+	case StateHalfOpen:
+		if b.probing {
+			return false, b.cooldown
+		}
+		b.probing = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// record reports the outcome of a call admitted by allow.
+func (b *breaker) record(o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+		switch o {
+		case success:
+			b.failures = 0
+			b.transition(StateClosed)
+		case failure:
+			b.open()
+		}
+		return
+	}
+
+	switch o {
+	case success:
+		b.failures = 0
+		return
+	case ignored:
+		return
+	}
+	b.failures++
+	if b.state == StateClosed && b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *breaker) open() {
+	b.openedAt = b.now()
+	b.transition(StateOpen)
+}
+
+func (b *breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
@@ -0,0 +1,18 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) model.CrudService {
+		return NewCrudService(memory.NewCrudService(),
+			WithBreaker(5, time.Second),
+			WithReadRetries(3, time.Millisecond, time.Millisecond))
+	})
+}
@@ -0,0 +1,43 @@
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// retryPolicy retries transient failures with capped exponential backoff and full jitter.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	retryable func(error) bool
+}
+
+// do calls fn until it succeeds, fails with a non-retryable error, the attempts are exhausted or ctx
+// is done. It returns the last error.
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < p.attempts; attempt++ {
+		if err = fn(); err == nil || !p.retryable(err) || attempt == p.attempts-1 {
+			return err
+		}
+		t := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+	return err
+}
+
+// backoff returns a random delay between zero and min(maxDelay, baseDelay * 2^attempt).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay << attempt
+	if d <= 0 || d > p.maxDelay {
+		d = p.maxDelay
+	}
+	return rand.N(d + 1)
+}
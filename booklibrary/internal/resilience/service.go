@@ -0,0 +1,252 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Compile-time check to verify we implement Storage
+var (
+	_ model.CrudService = (*CrudService)(nil)
+	_ model.Finder      = (*CrudService)(nil)
+)
+
+const (
+	defaultThreshold  = 5
+	defaultCooldown   = 10 * time.Second
+	defaultAttempts   = 3
+	defaultBaseDelay  = 50 * time.Millisecond
+	defaultMaxDelay   = time.Second
+	breakerStateLabel = "state"
+)
+
+type settings struct {
+	threshold  int
+	cooldown   time.Duration
+	retry      retryPolicy
+	registerer prometheus.Registerer
+}
+
+// Option configures a CrudService.
+type Option func(*settings)
+
+// WithBreaker sets the number of consecutive failures that open the circuit and how long it stays
+// open before a probe call is let through.
+func WithBreaker(threshold int, cooldown time.Duration) Option {
+	return func(s *settings) {
+		if threshold > 0 {
+			s.threshold = threshold
+		}
+		if cooldown > 0 {
+			s.cooldown = cooldown
+		}
+	}
+}
+
+// WithReadRetries sets the maximum number of attempts for List and Get, and the base and maximum
+// backoff between attempts. One attempt disables retries. Non-positive values keep their defaults.
+func WithReadRetries(attempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(s *settings) {
+		if attempts > 0 {
+			s.retry.attempts = attempts
+		}
+		if baseDelay > 0 {
+			s.retry.baseDelay = baseDelay
+		}
+		if maxDelay > 0 {
+			s.retry.maxDelay = maxDelay
+		}
+	}
+}
+
+// WithRetryable sets the function that decides whether a failed read is worth retrying. By default,
+// no errors are retried.
+func WithRetryable(fn func(error) bool) Option {
+	return func(s *settings) {
+		s.retry.retryable = fn
+	}
+}
+
+// WithRegisterer registers the breaker's metrics with reg.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(s *settings) {
+		s.registerer = reg
+	}
+}
+
+// CrudService decorates a model.CrudService with a circuit breaker and retries transient read
+// failures. While the circuit is open, calls fail immediately with a *model.UnavailableError
+// instead of waiting for the store to time out. Ping is passed through unprotected so that health
+// checks always observe the store's actual state.
+type CrudService struct {
+	next    model.CrudService
+	breaker *breaker
+	retry   retryPolicy
+	state   prometheus.Gauge
+	changes *prometheus.CounterVec
+	reject  prometheus.Counter
+}
+
+// NewCrudService wraps next with a circuit breaker and read retry policy.
+func NewCrudService(next model.CrudService, opts ...Option) *CrudService {
+	s := settings{
+		threshold: defaultThreshold,
+		cooldown:  defaultCooldown,
+		retry: retryPolicy{
+			attempts:  defaultAttempts,
+			baseDelay: defaultBaseDelay,
+			maxDelay:  defaultMaxDelay,
+			retryable: func(error) bool { return false },
+		},
+	}
+	for _, o := range opts {
+		o(&s)
+	}
+
+	f := promauto.With(s.registerer)
+	cs := &CrudService{
+		next:  next,
+		retry: s.retry,
+		state: f.NewGauge(prometheus.GaugeOpts{
+			Name: "booklibrary_store_circuit_breaker_state",
+			Help: "The state of the data store circuit breaker (0 closed, 1 half-open, 2 open).",
+		}),
+		changes: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "booklibrary_store_circuit_breaker_transitions_total",
+			Help: "The total number of data store circuit breaker state transitions by target state.",
+		}, []string{breakerStateLabel}),
+		reject: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_store_circuit_breaker_rejected_total",
+			Help: "The total number of data store calls rejected by an open circuit breaker.",
+		}),
+	}
+	cs.breaker = newBreaker(s.threshold, s.cooldown, cs.onStateChange)
+	return cs
+}
+
+// State returns the current state of the circuit breaker.
+func (cs *CrudService) State() State {
+	return cs.breaker.current()
+}
+
+// CheckBreaker is a health check that fails while the circuit breaker is not closed.
+func (cs *CrudService) CheckBreaker(_ context.Context) error {
+	if s := cs.State(); s != StateClosed {
+		return fmt.Errorf("circuit breaker is %s", s)
+	}
+	return nil
+}
+
+// List returns up to limit books, retrying transient failures.
+func (cs *CrudService) List(ctx context.Context, limit int) ([]model.Book, error) {
+	var books []model.Book
+	err := cs.retry.do(ctx, func() error {
+		return cs.call(ctx, func() error {
+			var err error
+			books, err = cs.next.List(ctx, limit)
+			return err
+		})
+	})
+	return books, err
+}
+
+// Find returns the books matching filter, retrying transient failures.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	var books []model.Book
+	err := cs.retry.do(ctx, func() error {
+		return cs.call(ctx, func() error {
+			var err error
+			books, err = model.Find(ctx, cs.next, filter)
+			return err
+		})
+	})
+	return books, err
+}
+
+// Get returns the book with the given ID, retrying transient failures.
+func (cs *CrudService) Get(ctx context.Context, id string) (model.Book, error) {
+	var book model.Book
+	err := cs.retry.do(ctx, func() error {
+		return cs.call(ctx, func() error {
+			var err error
+			book, err = cs.next.Get(ctx, id)
+			return err
+		})
+	})
+	return book, err
+}
+
+// Add adds a book. Writes are never retried.
+func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, error) {
+	var added model.Book
+	err := cs.call(ctx, func() error {
+		var err error
+		added, err = cs.next.Add(ctx, book)
+		return err
+	})
+	return added, err
+}
+
+// Update replaces the book with the given ID. Writes are never retried.
+func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
+	var updated model.Book
+	err := cs.call(ctx, func() error {
+		var err error
+		updated, err = cs.next.Update(ctx, id, book)
+		return err
+	})
+	return updated, err
+}
+
+// Remove deletes the book with the given ID. Writes are never retried.
+func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
+	var removed model.Book
+	err := cs.call(ctx, func() error {
+		var err error
+		removed, err = cs.next.Remove(ctx, id)
+		return err
+	})
+	return removed, err
+}
+
+// Ping checks the underlying store, bypassing the circuit breaker.
+func (cs *CrudService) Ping(ctx context.Context) error {
+	return cs.next.Ping(ctx)
+}
+
+func (cs *CrudService) call(ctx context.Context, fn func() error) error {
+	ok, wait := cs.breaker.allow()
+	if !ok {
+		cs.reject.Inc()
+		return &model.UnavailableError{RetryAfter: wait}
+	}
+	err := fn()
+	cs.breaker.record(classify(ctx, err))
+	return err
+}
+
+// classify decides how the result of a call affects the breaker. Errors caused by the caller,
+// such as invalid IDs or canceled requests, do not count as store failures.
+func classify(ctx context.Context, err error) outcome {
+	switch {
+	case err == nil, errors.Is(err, model.ErrNotFound), errors.Is(err, model.ErrInvalidID), errors.Is(err, errors.ErrUnsupported):
+		return success
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		return ignored
+	default:
+		return failure
+	}
+}
+
+func (cs *CrudService) onStateChange(from, to State) {
+	cs.state.Set(float64(to))
+	cs.changes.WithLabelValues(to.String()).Inc()
+	slog.Warn("circuit breaker state changed", "from", from.String(), "to", to.String())
+}
@@ -0,0 +1,141 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+var errTransient = errors.New("connection reset")
+
+type storeStub struct {
+	model.CrudService
+	calls int
+	errs  []error
+}
+
+func (s *storeStub) next() error {
+	s.calls++
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func (s *storeStub) Get(_ context.Context, id string) (model.Book, error) {
+	return model.Book{ID: id}, s.next()
+}
+
+func (s *storeStub) Add(_ context.Context, book model.Book) (model.Book, error) {
+	return book, s.next()
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	store := &storeStub{}
+	cs := NewCrudService(store, WithBreaker(2, 10*time.Second), WithReadRetries(1, 0, 0))
+	cs.breaker.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		err       error
+		wantErr   error
+		wantState State
+		wantCalls int
+	}{
+		{"first_failure", 0, errTransient, errTransient, StateClosed, 1},
+		{"not_found_is_success", 0, model.ErrNotFound, model.ErrNotFound, StateClosed, 2},
+		{"second_failure", 0, errTransient, errTransient, StateClosed, 3},
+		{"threshold_reached", 0, errTransient, errTransient, StateOpen, 4},
+		{"fail_fast", 0, nil, model.ErrUnavailable, StateOpen, 4},
+		{"failed_probe", 10 * time.Second, errTransient, errTransient, StateOpen, 5},
+		{"fail_fast_again", 5 * time.Second, nil, model.ErrUnavailable, StateOpen, 5},
+		{"successful_probe", 5 * time.Second, nil, nil, StateClosed, 6},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+			if tc.err != nil {
+				store.errs = []error{tc.err}
+			}
+			_, err := cs.Add(context.Background(), model.Book{})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Unexpected error, got %v, want %v", err, tc.wantErr)
+			}
+			if got := cs.State(); got != tc.wantState {
+				t.Errorf("Unexpected state, got %s, want %s", got, tc.wantState)
+			}
+			if store.calls != tc.wantCalls {
+				t.Errorf("Unexpected number of store calls, got %d, want %d", store.calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUnavailableRetryAfter(t *testing.T) {
+	cs := NewCrudService(&storeStub{errs: []error{errTransient}}, WithBreaker(1, time.Minute))
+	cs.Add(context.Background(), model.Book{})
+
+	_, err := cs.Add(context.Background(), model.Book{})
+	var ue *model.UnavailableError
+	if !errors.As(err, &ue) {
+		t.Fatalf("Expected *model.UnavailableError, got %v", err)
+	}
+	if ue.RetryAfter <= 0 || ue.RetryAfter > time.Minute {
+		t.Errorf("Unexpected RetryAfter %v", ue.RetryAfter)
+	}
+}
+
+func TestReadRetries(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"no_error", nil, nil, 1},
+		{"transient_then_success", []error{errTransient}, nil, 2},
+		{"attempts_exhausted", []error{errTransient, errTransient, errTransient}, errTransient, 3},
+		{"permanent_error", []error{errPermanent}, errPermanent, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &storeStub{errs: tc.errs}
+			cs := NewCrudService(store,
+				WithBreaker(10, time.Minute),
+				WithReadRetries(3, time.Millisecond, time.Millisecond),
+				WithRetryable(func(err error) bool { return errors.Is(err, errTransient) }))
+
+			_, err := cs.Get(context.Background(), "000000000000000000000001")
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Unexpected error, got %v, want %v", err, tc.wantErr)
+			}
+			if store.calls != tc.wantCalls {
+				t.Errorf("Unexpected number of store calls, got %d, want %d", store.calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestReadRetriesNonPositiveDelays(t *testing.T) {
+	store := &storeStub{errs: []error{errTransient}}
+	cs := NewCrudService(store,
+		WithBreaker(10, time.Minute),
+		WithReadRetries(2, -time.Second, -time.Second),
+		WithRetryable(func(err error) bool { return errors.Is(err, errTransient) }))
+
+	// Negative delays fall back to the defaults instead of breaking the backoff.
+	if _, err := cs.Get(context.Background(), "000000000000000000000001"); err != nil {
+		t.Errorf("Unexpected error, got %v, want nil", err)
+	}
+	if store.calls != 2 {
+		t.Errorf("Unexpected number of store calls, got %d, want %d", store.calls, 2)
+	}
+}
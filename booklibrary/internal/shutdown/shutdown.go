@@ -0,0 +1,104 @@
+// Package shutdown coordinates the graceful shutdown of the service in ordered phases.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+type phase struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// Coordinator runs shutdown phases in the order they were added.
+type Coordinator struct {
+	phases []phase
+}
+
+// New creates a Coordinator without any phases.
+func New() *Coordinator {
+	return &Coordinator{}
+}
+
+// Add appends a phase named name. fn is called with a context that is canceled after timeout, or
+// only when the context passed to Run is canceled if timeout is zero.
+func (c *Coordinator) Add(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	c.phases = append(c.phases, phase{name: name, timeout: timeout, fn: fn})
+}
+
+// Run runs all phases in order and logs the start, duration and outcome of each phase. A failing
+// phase does not stop later phases from running. Run returns the errors of all failed phases.
+func (c *Coordinator) Run(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	start := time.Now()
+	var errs []error
+	for _, p := range c.phases {
+		logger.InfoContext(ctx, "shutdown phase started", log.PhaseKey, p.name)
+		began := time.Now()
+		if err := p.run(ctx); err != nil {
+			logger.ErrorContext(ctx, "shutdown phase failed", log.PhaseKey, p.name, "duration", time.Since(began), log.ErrorKey, err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+		logger.InfoContext(ctx, "shutdown phase completed", log.PhaseKey, p.name, "duration", time.Since(began))
+	}
+	logger.InfoContext(ctx, "shutdown completed", "duration", time.Since(start), "failed", len(errs))
+	return errors.Join(errs...)
+}
+
+func (p phase) run(ctx context.Context) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return p.fn(ctx)
+}
+
+// Delay returns a phase function that waits for d, or until its context is done. It gives load
+// balancers time to observe that the service is no longer ready before connections are drained.
+func Delay(d time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if d <= 0 {
+			return nil
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Drain returns a phase function that calls stop and, until stop returns, logs the number of
+// requests still in flight as reported by inFlight every interval.
+func Drain(inFlight func() int, interval time.Duration, stop func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		logger := log.FromContext(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- stop(ctx)
+		}()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case err := <-done:
+				return err
+			case <-t.C:
+				logger.InfoContext(ctx, "draining requests", slog.Int("inFlight", inFlight()))
+			}
+		}
+	}
+}
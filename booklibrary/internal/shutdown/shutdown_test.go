@@ -0,0 +1,102 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+func TestRun(t *testing.T) {
+	errFailed := errors.New("failed")
+	var order []string
+	record := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	c := New()
+	c.Add("first", 0, record("first", nil))
+	c.Add("second", 0, record("second", errFailed))
+	c.Add("third", 0, record("third", nil))
+
+	var logs bytes.Buffer
+	ctx := log.NewContext(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+	err := c.Run(ctx)
+	if !errors.Is(err, errFailed) {
+		t.Errorf("Run() error = %v, want %v", err, errFailed)
+	}
+	if got := strings.Join(order, ","); got != "first,second,third" {
+		t.Errorf("Unexpected phase order, got %q, want %q", got, "first,second,third")
+	}
+	for _, want := range []string{"phase=first", "shutdown phase failed", "phase=third", "shutdown completed"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Log %q does not contain %q", logs.String(), want)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	c := New()
+	c.Add("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ran := false
+	c.Add("next", time.Second, func(ctx context.Context) error {
+		ran = ctx.Err() == nil
+		return nil
+	})
+
+	if err := c.Run(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !ran {
+		t.Errorf("Expected next phase to run with a fresh deadline")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	start := time.Now()
+	if err := Delay(20 * time.Millisecond)(context.Background()); err != nil {
+		t.Fatalf("Delay() error = %v", err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Delay returned after %v, want at least %v", d, 20*time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Delay(time.Hour)(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delay() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	var inFlight atomic.Int64
+	inFlight.Store(3)
+	stop := func(context.Context) error {
+		for inFlight.Load() > 0 {
+			time.Sleep(5 * time.Millisecond)
+			inFlight.Add(-1)
+		}
+		return nil
+	}
+
+	var logs bytes.Buffer
+	ctx := log.NewContext(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+	drain := Drain(func() int { return int(inFlight.Load()) }, time.Millisecond, stop)
+	if err := drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "draining requests") {
+		t.Errorf("Log %q does not report in-flight requests", logs.String())
+	}
+}
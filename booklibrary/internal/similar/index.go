@@ -0,0 +1,273 @@
+// Package similar ranks books by how much they resemble each other, based on shared keywords and
+// authors.
+package similar
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+const (
+	// keywordWeight and authorWeight weigh keyword overlap and a shared author in a score.
+	keywordWeight = 0.8
+	authorWeight  = 0.2
+	// pageSize is the number of books Rebuild reads at a time.
+	pageSize = 500
+)
+
+// Match is a book similar to another one. Score ranges from 0 (nothing in common) to 1 (same
+// author and keywords).
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// Index is an in-process inverted index of the keywords and authors of all books. Keywords and
+// authors are compared ignoring case and surrounding space. It is safe for concurrent use.
+//
+// Keyword overlap is scored as weighted Jaccard similarity, where each keyword is weighted by its
+// inverse document frequency, so that rare keywords count for more than those most books share.
+type Index struct {
+	mu sync.RWMutex
+	st state
+	// pending records the changes made while a rebuild reads books, to replay them onto its result.
+	rebuilding bool
+	pending    []model.Book
+	removed    map[string]bool
+	rebuildMu  sync.Mutex
+}
+
+// NewIndex creates an empty index.
+func NewIndex() *Index {
+	return &Index{st: newState()}
+}
+
+// Put adds the book to the index, or updates it if it is indexed.
+func (ix *Index) Put(b model.Book) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.st.put(b)
+	if ix.rebuilding {
+		ix.pending = append(ix.pending, b)
+		delete(ix.removed, b.ID)
+	}
+}
+
+// Remove removes the book with the given ID from the index.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.st.remove(id)
+	if ix.rebuilding {
+		ix.removed[id] = true
+	}
+}
+
+// Len returns the number of indexed books.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.st.books)
+}
+
+// Similar returns up to limit indexed books that share keywords or the author with b, most similar
+// first and by ID among equal scores. b itself is never included, and need not be indexed.
+func (ix *Index) Similar(b model.Book, limit int) []Match {
+	q := newEntry(b)
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	candidates := make(map[string]struct{})
+	for _, kw := range q.keywords {
+		for id := range ix.st.byKeyword[kw] {
+			candidates[id] = struct{}{}
+		}
+	}
+	if q.author != "" {
+		for id := range ix.st.byAuthor[q.author] {
+			candidates[id] = struct{}{}
+		}
+	}
+	delete(candidates, b.ID)
+
+	matches := make([]Match, 0, len(candidates))
+	for id := range candidates {
+		matches = append(matches, Match{ID: id, Score: ix.st.score(q, ix.st.books[id])})
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Rebuild replaces the index with all books in crud, read in pages in ID order. Changes made with
+// Put and Remove while it runs are kept. If reading fails, the index is left unchanged.
+func (ix *Index) Rebuild(ctx context.Context, crud model.CrudService) error {
+	ix.rebuildMu.Lock()
+	defer ix.rebuildMu.Unlock()
+	start := time.Now()
+	ix.mu.Lock()
+	ix.rebuilding = true
+	ix.removed = make(map[string]bool)
+	ix.mu.Unlock()
+	defer func() {
+		ix.mu.Lock()
+		ix.rebuilding = false
+		ix.pending = nil
+		ix.removed = nil
+		ix.mu.Unlock()
+	}()
+
+	st := newState()
+	after := ""
+	for {
+		books, err := model.Find(ctx, crud, model.Filter{After: after, Limit: pageSize})
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			st.put(b)
+		}
+		if len(books) < pageSize {
+			break
+		}
+		after = books[len(books)-1].ID
+	}
+
+	ix.mu.Lock()
+	for _, b := range ix.pending {
+		st.put(b)
+	}
+	for id := range ix.removed {
+		st.remove(id)
+	}
+	ix.st = st
+	n := len(st.books)
+	ix.mu.Unlock()
+	log.FromContext(ctx).InfoContext(ctx, "rebuilt similarity index", slog.Int("books", n), slog.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// entry holds the normalized author and distinct keywords of a book.
+type entry struct {
+	author   string
+	keywords []string
+}
+
+func newEntry(b model.Book) entry {
+	e := entry{author: normalize(b.Author)}
+	for _, kw := range b.Keywords {
+		if v := normalize(kw.Value); v != "" && !slices.Contains(e.keywords, v) {
+			e.keywords = append(e.keywords, v)
+		}
+	}
+	return e
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// state maps books to their entries and keywords and authors to the IDs of their books.
+type state struct {
+	books     map[string]entry
+	byKeyword map[string]map[string]struct{}
+	byAuthor  map[string]map[string]struct{}
+}
+
+func newState() state {
+	return state{
+		books:     make(map[string]entry),
+		byKeyword: make(map[string]map[string]struct{}),
+		byAuthor:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (st state) put(b model.Book) {
+	st.remove(b.ID)
+	e := newEntry(b)
+	st.books[b.ID] = e
+	for _, kw := range e.keywords {
+		link(st.byKeyword, kw, b.ID)
+	}
+	if e.author != "" {
+		link(st.byAuthor, e.author, b.ID)
+	}
+}
+
+func (st state) remove(id string) {
+	e, ok := st.books[id]
+	if !ok {
+		return
+	}
+	delete(st.books, id)
+	for _, kw := range e.keywords {
+		unlink(st.byKeyword, kw, id)
+	}
+	if e.author != "" {
+		unlink(st.byAuthor, e.author, id)
+	}
+}
+
+// score rates how similar the book with entry c is to the book with entry q.
+func (st state) score(q, c entry) float64 {
+	var shared, all float64
+	for _, kw := range q.keywords {
+		w := st.weight(kw)
+		all += w
+		if slices.Contains(c.keywords, kw) {
+			shared += w
+		}
+	}
+	for _, kw := range c.keywords {
+		if !slices.Contains(q.keywords, kw) {
+			all += st.weight(kw)
+		}
+	}
+	var s float64
+	if all > 0 {
+		s = keywordWeight * shared / all
+	}
+	if q.author != "" && q.author == c.author {
+		s += authorWeight
+	}
+	return s
+}
+
+// weight returns the inverse document frequency of a keyword. Keywords unknown to the index are
+// treated as if only one book had them.
+func (st state) weight(kw string) float64 {
+	df := max(len(st.byKeyword[kw]), 1)
+	return math.Log(1 + float64(max(len(st.books), 1))/float64(df))
+}
+
+func link(m map[string]map[string]struct{}, key, id string) {
+	ids, ok := m[key]
+	if !ok {
+		ids = make(map[string]struct{})
+		m[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func unlink(m map[string]map[string]struct{}, key, id string) {
+	delete(m[key], id)
+	if len(m[key]) == 0 {
+		delete(m, key)
+	}
+}
@@ -0,0 +1,149 @@
+package similar
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+func book(id, author string, keywords ...string) model.Book {
+	b := model.Book{ID: id, Author: author, Title: "Title " + id}
+	for _, kw := range keywords {
+		b.Keywords = append(b.Keywords, model.Keyword{Value: kw})
+	}
+	return b
+}
+
+func ids(matches []Match) []string {
+	ids := []string{}
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestSimilar(t *testing.T) {
+	ix := NewIndex()
+	for _, b := range []model.Book{
+		book("a", "Jane Doe", "Go", "Concurrency"),
+		book("b", "John Roe", "go", " concurrency "),
+		book("c", "jane doe", "Python"),
+		book("d", "Max Moe", "Go", "Web"),
+		book("e", "Erika Poe", "Cooking"),
+		book("f", "Max Moe", "Go"),
+	} {
+		ix.Put(b)
+	}
+
+	tests := []struct {
+		name  string
+		b     model.Book
+		limit int
+		want  []string
+	}{
+		// b shares all keywords. f and d only share Go, which most books have, so the shared author
+		// of c outweighs d's overlap, which is diluted by the rare keyword Web.
+		{name: "indexed", b: book("a", "Jane Doe", "Go", "Concurrency"), want: []string{"b", "f", "c", "d"}},
+		{name: "limit", b: book("a", "Jane Doe", "Go", "Concurrency"), limit: 2, want: []string{"b", "f"}},
+		{name: "not_indexed", b: book("x", "Erika Poe", "cooking"), want: []string{"e"}},
+		{name: "unknown_keywords", b: book("x", "Nobody", "Knitting"), want: []string{}},
+		{name: "no_keywords_or_author", b: book("x", ""), want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, ids(ix.Similar(tt.b, tt.limit))); diff != "" {
+				t.Errorf("Similar() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	matches := ix.Similar(book("a", "Jane Doe", "Go", "Concurrency"), 1)
+	if want := keywordWeight; matches[0].Score != want {
+		t.Errorf("Unexpected score for same keywords, got %v, want %v", matches[0].Score, want)
+	}
+}
+
+func TestPutRemove(t *testing.T) {
+	ix := NewIndex()
+	ix.Put(book("a", "Jane Doe", "Go"))
+	ix.Put(book("b", "John Roe", "Go"))
+	ix.Put(book("c", "John Roe", "Go"))
+
+	// Updating a book replaces its keywords and author.
+	ix.Put(book("b", "John Roe", "Python"))
+	ix.Remove("c")
+	ix.Remove("unknown")
+	if got := ids(ix.Similar(book("a", "Jane Doe", "Go"), 0)); len(got) != 0 {
+		t.Errorf("Unexpected matches after update and removal, got %v", got)
+	}
+	if got := ix.Len(); got != 2 {
+		t.Errorf("Unexpected index size, got %d, want 2", got)
+	}
+}
+
+// hookedService calls hook before each Find.
+type hookedService struct {
+	model.CrudService
+	hook func()
+	err  error
+}
+
+func (hs *hookedService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	if hs.hook != nil {
+		hs.hook()
+	}
+	if hs.err != nil {
+		return nil, hs.err
+	}
+	return model.Find(ctx, hs.CrudService, filter)
+}
+
+func TestRebuild(t *testing.T) {
+	ctx := context.Background()
+	crud := memory.NewCrudService()
+	var added []model.Book
+	for i := range pageSize + 1 {
+		b, err := crud.Add(ctx, book("", "Jane Doe", "Go", string(rune('a'+i%26))))
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		added = append(added, b)
+	}
+
+	ix := NewIndex()
+	ix.Put(book("stale", "Jane Doe", "Go"))
+	// Changes made while the rebuild reads books survive it.
+	hooked := &hookedService{CrudService: crud, hook: func() {
+		ix.Put(book("new", "Jane Doe", "Go"))
+		ix.Remove(added[0].ID)
+	}}
+	if err := ix.Rebuild(ctx, hooked); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got, want := ix.Len(), pageSize+1; got != want {
+		t.Errorf("Unexpected index size, got %d, want %d", got, want)
+	}
+	got := ids(ix.Similar(book("x", "Jane Doe"), 0))
+	for _, id := range []string{"stale", added[0].ID} {
+		if slices.Contains(got, id) {
+			t.Errorf("Rebuilt index contains %s", id)
+		}
+	}
+	if !slices.Contains(got, "new") {
+		t.Error("Rebuilt index lacks book added during rebuild")
+	}
+
+	// A failed rebuild keeps the index.
+	hooked = &hookedService{CrudService: crud, err: errors.New("boom")}
+	if err := ix.Rebuild(ctx, hooked); err == nil {
+		t.Fatal("Rebuild() error = nil, want error")
+	}
+	if got, want := ix.Len(), pageSize+1; got != want {
+		t.Errorf("Unexpected index size after failed rebuild, got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package similar
+
+import (
+	"context"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// Compile-time check to verify we implement Storage
+var _ model.CrudService = (*CrudService)(nil)
+
+// CrudService decorates a model.CrudService so that every Add, Update and Remove made through it
+// is applied to an Index. Changes made elsewhere (e.g. by other replicas) are only picked up by
+// Index.Rebuild.
+type CrudService struct {
+	model.CrudService
+	index *Index
+}
+
+// NewCrudService wraps next so that its changes are applied to index.
+func NewCrudService(next model.CrudService, index *Index) *CrudService {
+	return &CrudService{CrudService: next, index: index}
+}
+
+// Find returns the books matching filter, if next is a model.Finder.
+func (cs *CrudService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	return model.Find(ctx, cs.CrudService, filter)
+}
+
+// Add adds the book to next and to the index.
+func (cs *CrudService) Add(ctx context.Context, book model.Book) (model.Book, error) {
+	added, err := cs.CrudService.Add(ctx, book)
+	if err != nil {
+		return added, err
+	}
+	cs.index.Put(added)
+	return added, nil
+}
+
+// Update updates the book in next and in the index.
+func (cs *CrudService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
+	updated, err := cs.CrudService.Update(ctx, id, book)
+	if err != nil {
+		return updated, err
+	}
+	cs.index.Put(updated)
+	return updated, nil
+}
+
+// Remove removes the book from next and from the index.
+func (cs *CrudService) Remove(ctx context.Context, id string) (model.Book, error) {
+	removed, err := cs.CrudService.Remove(ctx, id)
+	if err != nil {
+		return removed, err
+	}
+	cs.index.Remove(id)
+	return removed, nil
+}
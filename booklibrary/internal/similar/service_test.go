@@ -0,0 +1,61 @@
+package similar
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) model.CrudService {
+		return NewCrudService(memory.NewCrudService(), NewIndex())
+	})
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ctx := context.Background()
+	ix := NewIndex()
+	crud := NewCrudService(memory.NewCrudService(), ix)
+	similar := func(t *testing.T, b model.Book, want ...string) {
+		t.Helper()
+		if diff := cmp.Diff(append([]string{}, want...), ids(ix.Similar(b, 0))); diff != "" {
+			t.Errorf("Similar() mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	a, err := crud.Add(ctx, book("", "Jane Doe", "Go"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	b, err := crud.Add(ctx, book("", "John Roe", "Go"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	similar(t, a, b.ID)
+
+	if _, err := crud.Update(ctx, b.ID, book("", "John Roe", "Python")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	similar(t, a)
+	similar(t, book("", "", "Python"), b.ID)
+
+	// Failed changes leave the index alone.
+	if _, err := crud.Update(ctx, "000000000000000000000000", book("", "Jane Doe", "Go")); err == nil {
+		t.Fatal("Update() of unknown book error = nil, want error")
+	}
+	if _, err := crud.Remove(ctx, "000000000000000000000000"); err == nil {
+		t.Fatal("Remove() of unknown book error = nil, want error")
+	}
+	if got := ix.Len(); got != 2 {
+		t.Errorf("Unexpected index size, got %d, want 2", got)
+	}
+
+	if _, err := crud.Remove(ctx, b.ID); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	similar(t, book("", "", "Python"))
+}
@@ -0,0 +1,224 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// RunIdempotency runs the conformance suite for model.IdempotencyService implementations as
+// subtests of t. newStore is called once per subtest and must return an empty store.
+func RunIdempotency(t *testing.T, newStore func(t *testing.T) model.IdempotencyService) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store model.IdempotencyService)
+	}{
+		{"ClaimComplete", testClaimComplete},
+		{"Expired", testClaimExpired},
+		{"Release", testRelease},
+		{"StaleToken", testStaleToken},
+		{"ConcurrentClaim", testConcurrentClaim},
+		{"CanceledContext", testIdempotencyCanceledContext},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// recorded is the response completed requests record in the suite.
+var recorded = model.RecordedResponse{
+	Status: 201,
+	Header: map[string][]string{"Content-Type": {"application/json"}, "Location": {"/api/books/1"}},
+	Body:   []byte(`{"title":"Idempotence"}`),
+}
+
+// claim claims key with a token derived from fingerprint.
+func claim(t *testing.T, store model.IdempotencyService, key, fingerprint string, expires time.Time) (model.IdempotentRequest, bool) {
+	t.Helper()
+	req, ok, err := store.Claim(context.Background(), key, token(fingerprint), fingerprint, expires)
+	if err != nil {
+		t.Fatalf("Error claiming key: %v", err)
+	}
+	return req, ok
+}
+
+// complete completes the request claimed for key with fingerprint.
+func complete(t *testing.T, store model.IdempotencyService, key, fingerprint string, expires time.Time) {
+	t.Helper()
+	if err := store.Complete(context.Background(), key, token(fingerprint), recorded, expires); err != nil {
+		t.Fatalf("Error completing request: %v", err)
+	}
+}
+
+func testClaimComplete(t *testing.T, store model.IdempotencyService) {
+	expires := time.Now().Add(time.Hour)
+	if _, ok := claim(t, store, "a", "fp", expires); !ok {
+		t.Fatal("Unexpected claim of new key, got false, want true")
+	}
+	req, ok := claim(t, store, "a", "other", expires)
+	if ok {
+		t.Fatal("Unexpected claim of key in progress, got true, want false")
+	}
+	want := model.IdempotentRequest{Key: "a", Fingerprint: "fp", Token: token("fp"), Expires: expires}
+	if diff := cmp.Diff(want, req, bookOpts); diff != "" {
+		t.Errorf("Request in progress mismatch (-want +got):\n%s", diff)
+	}
+
+	// Other keys are independent.
+	if _, ok := claim(t, store, "b", "fp", expires); !ok {
+		t.Fatal("Unexpected claim of other key, got false, want true")
+	}
+
+	later := expires.Add(time.Hour)
+	complete(t, store, "a", "fp", later)
+	req, ok = claim(t, store, "a", "fp", expires)
+	if ok {
+		t.Fatal("Unexpected claim of completed key, got true, want false")
+	}
+	resp := recorded
+	want = model.IdempotentRequest{Key: "a", Fingerprint: "fp", Token: token("fp"), Response: &resp, Expires: later}
+	if diff := cmp.Diff(want, req, bookOpts); diff != "" {
+		t.Errorf("Completed request mismatch (-want +got):\n%s", diff)
+	}
+
+	// A completed request is only completed once, and unknown keys are ignored.
+	if err := store.Complete(context.Background(), "a", token("fp"), model.RecordedResponse{Status: 500}, later); err != nil {
+		t.Fatalf("Error completing request: %v", err)
+	}
+	if req, _ := claim(t, store, "a", "fp", expires); req.Response == nil || req.Response.Status != recorded.Status {
+		t.Errorf("Unexpected response after second Complete(), got %+v, want status %d", req.Response, recorded.Status)
+	}
+	complete(t, store, "unknown", "fp", later)
+	if _, ok := claim(t, store, "unknown", "fp", expires); !ok {
+		t.Error("Unexpected claim of unknown key after Complete(), got false, want true")
+	}
+}
+
+func testClaimExpired(t *testing.T, store model.IdempotencyService) {
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+
+	// Abandoned requests give up their key when their claim expires.
+	claim(t, store, "a", "fp", past)
+	if _, ok := claim(t, store, "a", "other", future); !ok {
+		t.Fatal("Unexpected claim of expired key in progress, got false, want true")
+	}
+	if req, _ := claim(t, store, "a", "fp", future); req.Fingerprint != "other" || req.Response != nil {
+		t.Errorf("Unexpected request after reclaim, got %+v", req)
+	}
+
+	// Recorded responses are forgotten when they expire.
+	complete(t, store, "a", "other", past)
+	if _, ok := claim(t, store, "a", "fp", future); !ok {
+		t.Fatal("Unexpected claim of expired completed key, got false, want true")
+	}
+	if req, _ := claim(t, store, "a", "fp", future); req.Response != nil {
+		t.Errorf("Unexpected response of reclaimed key, got %+v, want nil", req.Response)
+	}
+}
+
+func testRelease(t *testing.T, store model.IdempotencyService) {
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour)
+	claim(t, store, "a", "fp", future)
+	if err := store.Release(ctx, "a", token("fp")); err != nil {
+		t.Fatalf("Error releasing key: %v", err)
+	}
+	if _, ok := claim(t, store, "a", "fp", future); !ok {
+		t.Fatal("Unexpected claim of released key, got false, want true")
+	}
+
+	complete(t, store, "a", "fp", future)
+	if err := store.Release(ctx, "a", token("fp")); err != nil {
+		t.Fatalf("Error releasing key: %v", err)
+	}
+	if req, ok := claim(t, store, "a", "fp", future); ok || req.Response == nil {
+		t.Error("Unexpected removal of completed request by Release()")
+	}
+	if err := store.Release(ctx, "unknown", token("fp")); err != nil {
+		t.Errorf("Unexpected error from Release() of unknown key, got %v", err)
+	}
+}
+
+// testStaleToken checks that a request whose claim expired cannot complete or release the claim
+// of a retry.
+func testStaleToken(t *testing.T, store model.IdempotencyService) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+
+	claim(t, store, "a", "stale", past)
+	if _, ok := claim(t, store, "a", "retry", future); !ok {
+		t.Fatal("Unexpected claim of expired key in progress, got false, want true")
+	}
+	if err := store.Complete(ctx, "a", token("stale"), recorded, future); err != nil {
+		t.Fatalf("Error completing request: %v", err)
+	}
+	if req, _ := claim(t, store, "a", "fp", future); req.Response != nil {
+		t.Errorf("Unexpected response after Complete() with stale token, got %+v, want nil", req.Response)
+	}
+	if err := store.Release(ctx, "a", token("stale")); err != nil {
+		t.Fatalf("Error releasing key: %v", err)
+	}
+	if req, ok := claim(t, store, "a", "fp", future); ok || req.Fingerprint != "retry" {
+		t.Errorf("Unexpected request after Release() with stale token, got %+v", req)
+	}
+}
+
+func testConcurrentClaim(t *testing.T, store model.IdempotencyService) {
+	const clients = 20
+	expires := time.Now().Add(time.Hour)
+	var mu sync.Mutex
+	claimed := 0
+	var wg sync.WaitGroup
+	for range clients {
+		wg.Go(func() {
+			_, ok, err := store.Claim(context.Background(), "a", token("fp"), "fp", expires)
+			if err != nil {
+				t.Errorf("Error claiming key: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Errorf("Unexpected number of concurrent claims, got %d, want 1", claimed)
+	}
+}
+
+func testIdempotencyCanceledContext(t *testing.T, store model.IdempotencyService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	expires := time.Now().Add(time.Hour)
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Claim", func() error { _, _, err := store.Claim(ctx, "a", token("fp"), "fp", expires); return err }},
+		{"Complete", func() error { return store.Complete(ctx, "a", token("fp"), recorded, expires) }},
+		{"Release", func() error { return store.Release(ctx, "a", token("fp")) }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error from %s() with canceled context, got %v, want %v", c.name, err, context.Canceled)
+		}
+	}
+}
+
+// token returns the claim token the suite uses for requests with fingerprint.
+func token(fingerprint string) string {
+	return "token-" + fingerprint
+}
@@ -0,0 +1,300 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// Book IDs referenced by lists. List stores do not check that the books exist.
+const (
+	bookA = "000000000000000000000a01"
+	bookB = "000000000000000000000a02"
+	bookC = "000000000000000000000a03"
+)
+
+// RunLists runs the conformance suite for model.ListService implementations as subtests of t.
+// newStore is called once per subtest and must return an empty store.
+func RunLists(t *testing.T, newStore func(t *testing.T) model.ListService) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, lists model.ListService)
+	}{
+		{"AddGet", testAddGetList},
+		{"InvalidID", testListInvalidID},
+		{"Owner", testListOwner},
+		{"Update", testUpdateList},
+		{"Remove", testRemoveList},
+		{"PutBook", testPutBook},
+		{"RemoveBook", testRemoveBook},
+		{"RemoveBookFromLists", testRemoveBookFromLists},
+		{"CanceledContext", testListCanceledContext},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+func addList(t *testing.T, lists model.ListService, owner, name string, bookIDs ...string) model.ReadingList {
+	t.Helper()
+	ctx := context.Background()
+	l, err := lists.AddList(ctx, model.ReadingList{Owner: owner, Name: name, Visibility: model.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("Error adding list: %v", err)
+	}
+	for _, id := range bookIDs {
+		if l, err = lists.PutBook(ctx, owner, l.ID, id, -1); err != nil {
+			t.Fatalf("Error putting book on list: %v", err)
+		}
+	}
+	return l
+}
+
+func diffLists(t *testing.T, op string, want, got any) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("%s mismatch (-want +got):\n%s", op, diff)
+	}
+}
+
+func testAddGetList(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	// Books and IDs passed to AddList are ignored.
+	added, err := lists.AddList(ctx, model.ReadingList{ID: unknownID, Owner: "alice", Name: "Favorites", Visibility: model.VisibilityPublic, BookIDs: []string{bookA}})
+	if err != nil {
+		t.Fatalf("Error adding list: %v", err)
+	}
+	if added.ID == "" || added.ID == unknownID {
+		t.Fatalf("Unexpected list ID, got %q, want %q", added.ID, "")
+	}
+	want := model.ReadingList{ID: added.ID, Owner: "alice", Name: "Favorites", Visibility: model.VisibilityPublic, BookIDs: []string{}}
+	diffLists(t, "AddList()", want, added)
+
+	got, err := lists.GetList(ctx, "alice", added.ID)
+	if err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	diffLists(t, "GetList()", want, got)
+}
+
+func testListInvalidID(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	l := addList(t, lists, "alice", "Favorites")
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"GetList", func() error { _, err := lists.GetList(ctx, "alice", invalidID); return err }},
+		{"UpdateList", func() error { _, err := lists.UpdateList(ctx, "alice", invalidID, l); return err }},
+		{"RemoveList", func() error { _, err := lists.RemoveList(ctx, "alice", invalidID); return err }},
+		{"PutBook", func() error { _, err := lists.PutBook(ctx, "alice", l.ID, invalidID, 0); return err }},
+		{"RemoveBook", func() error { _, err := lists.RemoveBook(ctx, "alice", l.ID, invalidID); return err }},
+		{"RemoveBookFromLists", func() error { return lists.RemoveBookFromLists(ctx, invalidID) }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, model.ErrInvalidID) {
+			t.Errorf("Unexpected error from %s() with invalid ID, got %v, want %v", c.name, err, model.ErrInvalidID)
+		}
+	}
+}
+
+func testListOwner(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	a1 := addList(t, lists, "alice", "Favorites")
+	a2 := addList(t, lists, "alice", "To read")
+	b1 := addList(t, lists, "bob", "Favorites")
+
+	got, err := lists.Lists(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Error listing lists: %v", err)
+	}
+	want := []model.ReadingList{a1, a2}
+	if a2.ID < a1.ID {
+		want = []model.ReadingList{a2, a1}
+	}
+	diffLists(t, "Lists()", want, got)
+
+	got, err = lists.Lists(ctx, "carol")
+	if err != nil {
+		t.Fatalf("Error listing lists: %v", err)
+	}
+	diffLists(t, "Lists() of user without lists", []model.ReadingList{}, got)
+
+	// Lists of other owners are not found, and stay unchanged.
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"GetList", func() error { _, err := lists.GetList(ctx, "alice", b1.ID); return err }},
+		{"UpdateList", func() error { _, err := lists.UpdateList(ctx, "alice", b1.ID, a1); return err }},
+		{"RemoveList", func() error { _, err := lists.RemoveList(ctx, "alice", b1.ID); return err }},
+		{"PutBook", func() error { _, err := lists.PutBook(ctx, "alice", b1.ID, bookA, 0); return err }},
+		{"RemoveBook", func() error { _, err := lists.RemoveBook(ctx, "alice", b1.ID, bookA); return err }},
+		{"GetList unknown", func() error { _, err := lists.GetList(ctx, "alice", unknownID); return err }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, model.ErrListNotFound) {
+			t.Errorf("Unexpected error from %s(), got %v, want %v", c.name, err, model.ErrListNotFound)
+		}
+	}
+	got1, err := lists.GetList(ctx, "bob", b1.ID)
+	if err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	diffLists(t, "GetList() of other owner", b1, got1)
+}
+
+func testUpdateList(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	l := addList(t, lists, "alice", "Favorites", bookA)
+
+	// Only name and visibility change.
+	updated, err := lists.UpdateList(ctx, "alice", l.ID, model.ReadingList{ID: unknownID, Owner: "bob", Name: "Best", Visibility: model.VisibilityPublic})
+	if err != nil {
+		t.Fatalf("Error updating list: %v", err)
+	}
+	want := model.ReadingList{ID: l.ID, Owner: "alice", Name: "Best", Visibility: model.VisibilityPublic, BookIDs: []string{bookA}}
+	diffLists(t, "UpdateList()", want, updated)
+
+	got, err := lists.GetList(ctx, "alice", l.ID)
+	if err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	diffLists(t, "GetList() after UpdateList()", want, got)
+}
+
+func testRemoveList(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	l := addList(t, lists, "alice", "Favorites", bookA)
+	removed, err := lists.RemoveList(ctx, "alice", l.ID)
+	if err != nil {
+		t.Fatalf("Error removing list: %v", err)
+	}
+	diffLists(t, "RemoveList()", l, removed)
+
+	if _, err := lists.GetList(ctx, "alice", l.ID); !errors.Is(err, model.ErrListNotFound) {
+		t.Errorf("Unexpected error from GetList() after RemoveList(), got %v, want %v", err, model.ErrListNotFound)
+	}
+	if _, err := lists.RemoveList(ctx, "alice", l.ID); !errors.Is(err, model.ErrListNotFound) {
+		t.Errorf("Unexpected error from Second RemoveList(), got %v, want %v", err, model.ErrListNotFound)
+	}
+}
+
+func testPutBook(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	l := addList(t, lists, "alice", "Favorites")
+	steps := []struct {
+		name     string
+		bookID   string
+		position int
+		want     []string
+	}{
+		{"append_to_empty", bookA, -1, []string{bookA}},
+		{"append", bookB, -1, []string{bookA, bookB}},
+		{"insert_first", bookC, 0, []string{bookC, bookA, bookB}},
+		{"move_last", bookC, -1, []string{bookA, bookB, bookC}},
+		{"move_middle", bookA, 1, []string{bookB, bookA, bookC}},
+		{"beyond_end", bookB, 10, []string{bookA, bookC, bookB}},
+		{"same_position", bookA, 0, []string{bookA, bookC, bookB}},
+	}
+	for _, s := range steps {
+		got, err := lists.PutBook(ctx, "alice", l.ID, s.bookID, s.position)
+		if err != nil {
+			t.Fatalf("Error putting book on list in step %s: %v", s.name, err)
+		}
+		diffLists(t, s.name+": PutBook()", s.want, got.BookIDs)
+	}
+
+	got, err := lists.GetList(ctx, "alice", l.ID)
+	if err != nil {
+		t.Fatalf("Error getting list: %v", err)
+	}
+	diffLists(t, "GetList() after PutBook()", []string{bookA, bookC, bookB}, got.BookIDs)
+}
+
+func testRemoveBook(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	l := addList(t, lists, "alice", "Favorites", bookA, bookB, bookC)
+	got, err := lists.RemoveBook(ctx, "alice", l.ID, bookB)
+	if err != nil {
+		t.Fatalf("Error removing book from list: %v", err)
+	}
+	diffLists(t, "RemoveBook()", []string{bookA, bookC}, got.BookIDs)
+
+	if _, err := lists.RemoveBook(ctx, "alice", l.ID, bookB); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from RemoveBook() of book not on list, got %v, want %v", err, model.ErrNotFound)
+	}
+	if _, err := lists.RemoveBook(ctx, "alice", unknownID, bookA); !errors.Is(err, model.ErrListNotFound) {
+		t.Errorf("Unexpected error from RemoveBook() from unknown list, got %v, want %v", err, model.ErrListNotFound)
+	}
+}
+
+func testRemoveBookFromLists(t *testing.T, lists model.ListService) {
+	ctx := context.Background()
+	a := addList(t, lists, "alice", "Favorites", bookA, bookB)
+	b := addList(t, lists, "bob", "Favorites", bookB, bookC)
+	c := addList(t, lists, "bob", "To read", bookC)
+
+	if err := lists.RemoveBookFromLists(ctx, bookB); err != nil {
+		t.Fatalf("Error removing book from lists: %v", err)
+	}
+	for _, tc := range []struct {
+		list model.ReadingList
+		want []string
+	}{
+		{a, []string{bookA}},
+		{b, []string{bookC}},
+		{c, []string{bookC}},
+	} {
+		got, err := lists.GetList(ctx, tc.list.Owner, tc.list.ID)
+		if err != nil {
+			t.Fatalf("Error getting list: %v", err)
+		}
+		diffLists(t, "GetList() after RemoveBookFromLists()", tc.want, got.BookIDs)
+	}
+
+	// Removing a book that is on no list succeeds.
+	if err := lists.RemoveBookFromLists(ctx, unknownID); err != nil {
+		t.Errorf("Unexpected error from RemoveBookFromLists() of unreferenced book, got %v", err)
+	}
+}
+
+func testListCanceledContext(t *testing.T, lists model.ListService) {
+	l := addList(t, lists, "alice", "Favorites", bookA)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Lists", func() error { _, err := lists.Lists(ctx, "alice"); return err }},
+		{"GetList", func() error { _, err := lists.GetList(ctx, "alice", l.ID); return err }},
+		{"AddList", func() error { _, err := lists.AddList(ctx, l); return err }},
+		{"UpdateList", func() error {
+			_, err := lists.UpdateList(ctx, "alice", l.ID, model.ReadingList{Name: "Changed", Visibility: model.VisibilityPublic})
+			return err
+		}},
+		{"RemoveList", func() error { _, err := lists.RemoveList(ctx, "alice", l.ID); return err }},
+		{"PutBook", func() error { _, err := lists.PutBook(ctx, "alice", l.ID, bookB, 0); return err }},
+		{"RemoveBook", func() error { _, err := lists.RemoveBook(ctx, "alice", l.ID, bookA); return err }},
+		{"RemoveBookFromLists", func() error { return lists.RemoveBookFromLists(ctx, bookA) }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error from %s() with canceled context, got %v, want %v", c.name, err, context.Canceled)
+		}
+	}
+
+	// None of the canceled writes took effect.
+	got, err := lists.Lists(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("Error listing lists: %v", err)
+	}
+	diffLists(t, "Lists() after canceled writes", []model.ReadingList{l}, got)
+}
@@ -0,0 +1,284 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// loanTime is the checkout time of loans created by the suite.
+var loanTime = time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+// RunLoans runs the conformance suite for model.LoanService implementations as subtests of t.
+// newStore is called once per subtest and must return an empty store.
+func RunLoans(t *testing.T, newStore func(t *testing.T) model.LoanService) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, loans model.LoanService)
+	}{
+		{"Copies", testCopies},
+		{"CheckoutGet", testCheckoutGet},
+		{"InvalidID", testLoanInvalidID},
+		{"NotFound", testLoanNotFound},
+		{"Return", testReturn},
+		{"Renew", testRenew},
+		{"Overdue", testOverdue},
+		{"ConcurrentCheckout", testConcurrentCheckout},
+		{"CanceledContext", testLoanCanceledContext},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+func setCopies(t *testing.T, loans model.LoanService, bookID string, copies int) {
+	t.Helper()
+	if _, err := loans.SetCopies(context.Background(), bookID, copies); err != nil {
+		t.Fatalf("Error setting copies: %v", err)
+	}
+}
+
+func checkout(t *testing.T, loans model.LoanService, bookID, borrower string, due time.Time) model.Loan {
+	t.Helper()
+	l, err := loans.Checkout(context.Background(), model.Loan{BookID: bookID, Borrower: borrower, CheckedOut: loanTime, Due: due})
+	if err != nil {
+		t.Fatalf("Error checking out book: %v", err)
+	}
+	return l
+}
+
+func wantAvailability(t *testing.T, loans model.LoanService, bookID string, want model.Availability) {
+	t.Helper()
+	got, err := loans.Availability(context.Background(), bookID)
+	if err != nil {
+		t.Fatalf("Error getting availability: %v", err)
+	}
+	diffBooks(t, "Availability()", want, got)
+}
+
+func testCopies(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	// Books without recorded copies have none.
+	wantAvailability(t, loans, bookA, model.Availability{})
+
+	got, err := loans.SetCopies(ctx, bookA, 2)
+	if err != nil {
+		t.Fatalf("Error setting copies: %v", err)
+	}
+	diffBooks(t, "SetCopies()", model.NewAvailability(2, 0), got)
+	checkout(t, loans, bookA, "alice", loanTime.Add(time.Hour))
+	wantAvailability(t, loans, bookA, model.NewAvailability(2, 1))
+	wantAvailability(t, loans, bookB, model.Availability{})
+
+	if _, err := loans.SetCopies(ctx, bookA, 0); !errors.Is(err, model.ErrCopiesOnLoan) {
+		t.Fatalf("Unexpected error from SetCopies() below loans, got %v, want %v", err, model.ErrCopiesOnLoan)
+	}
+	got, err = loans.SetCopies(ctx, bookA, 1)
+	if err != nil {
+		t.Fatalf("Error setting copies: %v", err)
+	}
+	diffBooks(t, "SetCopies()", model.NewAvailability(1, 1), got)
+}
+
+func testCheckoutGet(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	setCopies(t, loans, bookA, 1)
+	due := loanTime.Add(21 * 24 * time.Hour)
+	// IDs, return times and renewals passed to Checkout are ignored.
+	returned := loanTime
+	added, err := loans.Checkout(ctx, model.Loan{ID: unknownID, BookID: bookA, Borrower: "alice", CheckedOut: loanTime, Due: due, Returned: &returned, Renewals: 3})
+	if err != nil {
+		t.Fatalf("Error checking out book: %v", err)
+	}
+	if added.ID == "" || added.ID == unknownID {
+		t.Fatalf("Unexpected loan ID, got %q, want %q", added.ID, "")
+	}
+	want := model.Loan{ID: added.ID, BookID: bookA, Borrower: "alice", CheckedOut: loanTime, Due: due}
+	diffBooks(t, "Checkout()", want, added)
+
+	got, err := loans.GetLoan(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Error getting loan: %v", err)
+	}
+	diffBooks(t, "GetLoan()", want, got)
+
+	_, err = loans.Checkout(ctx, model.Loan{BookID: bookA, Borrower: "bob", CheckedOut: loanTime, Due: due})
+	if !errors.Is(err, model.ErrNoCopyAvailable) {
+		t.Fatalf("Unexpected error from Checkout() of unavailable book, got %v, want %v", err, model.ErrNoCopyAvailable)
+	}
+	_, err = loans.Checkout(ctx, model.Loan{BookID: bookB, Borrower: "bob", CheckedOut: loanTime, Due: due})
+	if !errors.Is(err, model.ErrNoCopyAvailable) {
+		t.Fatalf("Unexpected error from Checkout() of book without copies, got %v, want %v", err, model.ErrNoCopyAvailable)
+	}
+}
+
+func testLoanInvalidID(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Availability", func() error { _, err := loans.Availability(ctx, invalidID); return err }},
+		{"SetCopies", func() error { _, err := loans.SetCopies(ctx, invalidID, 1); return err }},
+		{"Checkout", func() error {
+			_, err := loans.Checkout(ctx, model.Loan{BookID: invalidID, Borrower: "alice", CheckedOut: loanTime, Due: loanTime})
+			return err
+		}},
+		{"GetLoan", func() error { _, err := loans.GetLoan(ctx, invalidID); return err }},
+		{"Return", func() error { _, err := loans.Return(ctx, invalidID, loanTime); return err }},
+		{"Renew", func() error { _, err := loans.Renew(ctx, invalidID, loanTime, 1); return err }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, model.ErrInvalidID) {
+			t.Errorf("Unexpected error from %s() with invalid ID, got %v, want %v", c.name, err, model.ErrInvalidID)
+		}
+	}
+}
+
+func testLoanNotFound(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"GetLoan", func() error { _, err := loans.GetLoan(ctx, unknownID); return err }},
+		{"Return", func() error { _, err := loans.Return(ctx, unknownID, loanTime); return err }},
+		{"Renew", func() error { _, err := loans.Renew(ctx, unknownID, loanTime, 1); return err }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, model.ErrLoanNotFound) {
+			t.Errorf("Unexpected error from %s() of unknown loan, got %v, want %v", c.name, err, model.ErrLoanNotFound)
+		}
+	}
+}
+
+func testReturn(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	setCopies(t, loans, bookA, 1)
+	l := checkout(t, loans, bookA, "alice", loanTime.Add(time.Hour))
+
+	at := loanTime.Add(30 * time.Minute)
+	got, err := loans.Return(ctx, l.ID, at)
+	if err != nil {
+		t.Fatalf("Error returning loan: %v", err)
+	}
+	want := l
+	want.Returned = &at
+	diffBooks(t, "Return()", want, got)
+	wantAvailability(t, loans, bookA, model.NewAvailability(1, 0))
+
+	if _, err := loans.Return(ctx, l.ID, at); !errors.Is(err, model.ErrLoanReturned) {
+		t.Errorf("Unexpected error from Return() of returned loan, got %v, want %v", err, model.ErrLoanReturned)
+	}
+	if _, err := loans.Renew(ctx, l.ID, at.Add(time.Hour), 1); !errors.Is(err, model.ErrLoanReturned) {
+		t.Errorf("Unexpected error from Renew() of returned loan, got %v, want %v", err, model.ErrLoanReturned)
+	}
+	// The returned copy can be checked out again.
+	checkout(t, loans, bookA, "bob", loanTime.Add(2*time.Hour))
+}
+
+func testRenew(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	setCopies(t, loans, bookA, 1)
+	l := checkout(t, loans, bookA, "alice", loanTime.Add(time.Hour))
+
+	for i := 1; i <= 2; i++ {
+		due := loanTime.Add(time.Duration(i+1) * time.Hour)
+		got, err := loans.Renew(ctx, l.ID, due, 2)
+		if err != nil {
+			t.Fatalf("Error renewing loan for renewal %d: %v", i, err)
+		}
+		want := l
+		want.Due = due
+		want.Renewals = i
+		diffBooks(t, "Renew()", want, got)
+	}
+	if _, err := loans.Renew(ctx, l.ID, loanTime.Add(4*time.Hour), 2); !errors.Is(err, model.ErrRenewalLimit) {
+		t.Fatalf("Unexpected error from Renew() beyond limit, got %v, want %v", err, model.ErrRenewalLimit)
+	}
+	got, err := loans.GetLoan(ctx, l.ID)
+	if err != nil {
+		t.Fatalf("Error getting loan: %v", err)
+	}
+	if !got.Due.Equal(loanTime.Add(3*time.Hour)) || got.Renewals != 2 {
+		t.Errorf("Unexpected loan after rejected Renew(), got due %v after %d renewals, want due %v after 2", got.Due, got.Renewals, loanTime.Add(3*time.Hour))
+	}
+}
+
+func testOverdue(t *testing.T, loans model.LoanService) {
+	ctx := context.Background()
+	setCopies(t, loans, bookA, 3)
+	setCopies(t, loans, bookB, 1)
+	now := loanTime.Add(24 * time.Hour)
+	late := checkout(t, loans, bookA, "alice", now.Add(-time.Hour))
+	later := checkout(t, loans, bookB, "bob", now.Add(-2*time.Hour))
+	checkout(t, loans, bookA, "carol", now.Add(time.Hour))
+	returned := checkout(t, loans, bookA, "dave", now.Add(-3*time.Hour))
+	if _, err := loans.Return(ctx, returned.ID, now); err != nil {
+		t.Fatalf("Error returning loan: %v", err)
+	}
+
+	got, err := loans.Overdue(ctx, now)
+	if err != nil {
+		t.Fatalf("Error listing overdue loans: %v", err)
+	}
+	diffBooks(t, "Overdue()", []model.Loan{later, late}, got)
+}
+
+func testConcurrentCheckout(t *testing.T, loans model.LoanService) {
+	const copies, borrowers = 3, 20
+	setCopies(t, loans, bookA, copies)
+
+	var mu sync.Mutex
+	succeeded := 0
+	var wg sync.WaitGroup
+	for range borrowers {
+		wg.Go(func() {
+			_, err := loans.Checkout(context.Background(), model.Loan{BookID: bookA, Borrower: "alice", CheckedOut: loanTime, Due: loanTime.Add(time.Hour)})
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case !errors.Is(err, model.ErrNoCopyAvailable):
+				t.Errorf("Error checking out book: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if succeeded != copies {
+		t.Errorf("Unexpected number of concurrent checkouts, got %d, want %d", succeeded, copies)
+	}
+	wantAvailability(t, loans, bookA, model.NewAvailability(copies, copies))
+}
+
+func testLoanCanceledContext(t *testing.T, loans model.LoanService) {
+	setCopies(t, loans, bookA, 2)
+	l := checkout(t, loans, bookA, "alice", loanTime.Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Availability", func() error { _, err := loans.Availability(ctx, bookA); return err }},
+		{"SetCopies", func() error { _, err := loans.SetCopies(ctx, bookA, 3); return err }},
+		{"Checkout", func() error { _, err := loans.Checkout(ctx, l); return err }},
+		{"GetLoan", func() error { _, err := loans.GetLoan(ctx, l.ID); return err }},
+		{"Return", func() error { _, err := loans.Return(ctx, l.ID, loanTime); return err }},
+		{"Renew", func() error { _, err := loans.Renew(ctx, l.ID, loanTime, 1); return err }},
+		{"Overdue", func() error { _, err := loans.Overdue(ctx, loanTime); return err }},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error from %s() with canceled context, got %v, want %v", c.name, err, context.Canceled)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+// Package storetest provides conformance test suites for model.CrudService, model.ListService,
+// model.LoanService and model.IdempotencyService implementations.
+//
+// A store proves that it is compatible with the API by passing Run in its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) model.CrudService {
+//			return NewCrudService()
+//		})
+//	}
+//
+// Stores that also implement model.Finder are checked for filtering; the Find tests are skipped for
+// others.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// unknownID is a well-formed ID that no store is expected to have assigned.
+const unknownID = "000000000000000000000000"
+
+// invalidID is an ID that no store accepts.
+const invalidID = "not-an-id"
+
+// Run runs the conformance suite as subtests of t. newStore is called once per subtest and must
+// return an empty store, which it may clean up with t.Cleanup.
+//
+// Release dates are compared at millisecond precision regardless of time zone, and nil and empty
+// keyword lists are considered equal.
+func Run(t *testing.T, newStore func(t *testing.T) model.CrudService) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, crud model.CrudService)
+	}{
+		{"AddGet", testAddGet},
+		{"AddIgnoresID", testAddIgnoresID},
+		{"InvalidID", testInvalidID},
+		{"NotFound", testNotFound},
+		{"ListLimit", testListLimit},
+		{"Find", testFind},
+		{"FindAfter", testFindAfter},
+		{"Update", testUpdate},
+		{"Remove", testRemove},
+		{"Isolation", testIsolation},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"CanceledContext", testCanceledContext},
+		{"Ping", testPing},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+var bookOpts = cmp.Options{
+	cmp.Comparer(func(a, b time.Time) bool { return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond)) }),
+	cmpopts.EquateEmpty(),
+}
+
+// sample returns a distinct book for n.
+func sample(n int) model.Book {
+	return model.Book{
+		Author:      fmt.Sprintf("Author %d", n),
+		Title:       fmt.Sprintf("Title %d", n),
+		ReleaseDate: time.Date(2000+n%25, time.Month(1+n%12), 1+n%28, 12, 0, 0, 0, time.UTC),
+		Keywords:    []model.Keyword{{Value: "Keyword"}, {Value: fmt.Sprintf("Keyword %d", n)}},
+	}
+}
+
+// finder returns crud as a model.Finder, skipping the test if it cannot filter books.
+func finder(t *testing.T, crud model.CrudService) model.Finder {
+	t.Helper()
+	f, ok := crud.(model.Finder)
+	if !ok {
+		t.Skip("store does not implement model.Finder")
+	}
+	return f
+}
+
+// add adds books to crud and returns them with their IDs in the order of their IDs.
+func add(t *testing.T, crud model.CrudService, books ...model.Book) []model.Book {
+	t.Helper()
+	added := make([]model.Book, len(books))
+	for i, b := range books {
+		a, err := crud.Add(context.Background(), b)
+		if err != nil {
+			t.Fatalf("Error adding book: %v", err)
+		}
+		added[i] = a
+	}
+	slices.SortFunc(added, func(a, b model.Book) int { return strings.Compare(a.ID, b.ID) })
+	return added
+}
+
+func diffBooks(t *testing.T, op string, want, got any) {
+	t.Helper()
+	if diff := cmp.Diff(want, got, bookOpts); diff != "" {
+		t.Fatalf("%s mismatch (-want +got):\n%s", op, diff)
+	}
+}
+
+func testAddGet(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	want := sample(1)
+	added, err := crud.Add(ctx, want)
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	if added.ID == "" {
+		t.Fatal("Added book has no ID")
+	}
+	want.ID = added.ID
+	diffBooks(t, "Add()", want, added)
+
+	got, err := crud.Get(ctx, added.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	diffBooks(t, "Get()", want, got)
+}
+
+func testAddIgnoresID(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	first := add(t, crud, sample(1))[0]
+
+	// Adding a book with the ID of an existing book creates a new book.
+	b := sample(2)
+	b.ID = first.ID
+	added, err := crud.Add(ctx, b)
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	if added.ID == first.ID {
+		t.Fatalf("Unexpected ID of second book, got %s, want a new ID", first.ID)
+	}
+	got, err := crud.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	diffBooks(t, "Get()", first, got)
+}
+
+func testInvalidID(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	if _, err := crud.Get(ctx, invalidID); !errors.Is(err, model.ErrInvalidID) {
+		t.Errorf("Unexpected error from Get(), got %v, want %v", err, model.ErrInvalidID)
+	}
+	if _, err := crud.Update(ctx, invalidID, sample(1)); !errors.Is(err, model.ErrInvalidID) {
+		t.Errorf("Unexpected error from Update(), got %v, want %v", err, model.ErrInvalidID)
+	}
+	if _, err := crud.Remove(ctx, invalidID); !errors.Is(err, model.ErrInvalidID) {
+		t.Errorf("Unexpected error from Remove(), got %v, want %v", err, model.ErrInvalidID)
+	}
+	if f, ok := crud.(model.Finder); ok {
+		if _, err := f.Find(ctx, model.Filter{After: invalidID}); !errors.Is(err, model.ErrInvalidID) {
+			t.Errorf("Unexpected error from Find(), got %v, want %v", err, model.ErrInvalidID)
+		}
+	}
+}
+
+func testNotFound(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	add(t, crud, sample(1))
+	if _, err := crud.Get(ctx, unknownID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from Get(), got %v, want %v", err, model.ErrNotFound)
+	}
+	if _, err := crud.Update(ctx, unknownID, sample(2)); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from Update(), got %v, want %v", err, model.ErrNotFound)
+	}
+	if _, err := crud.Remove(ctx, unknownID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from Remove(), got %v, want %v", err, model.ErrNotFound)
+	}
+	books, err := crud.List(ctx, 10)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	if len(books) != 1 {
+		t.Errorf("Unexpected number of books after failed writes, got %d, want 1", len(books))
+	}
+}
+
+func testListLimit(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	empty, err := crud.List(ctx, 10)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	if empty == nil || len(empty) != 0 {
+		t.Fatalf("Unexpected books in empty store, got %#v, want empty non-nil slice", empty)
+	}
+
+	var books []model.Book
+	for i := range 5 {
+		books = append(books, sample(i))
+	}
+	books = add(t, crud, books...)
+
+	tests := []struct {
+		limit int
+		want  []model.Book
+	}{
+		{limit: 0, want: books},
+		{limit: 1, want: books[:1]},
+		{limit: 3, want: books[:3]},
+		{limit: 5, want: books},
+		{limit: 10, want: books},
+	}
+	for _, tc := range tests {
+		got, err := crud.List(ctx, tc.limit)
+		if err != nil {
+			t.Fatalf("Error listing %d books: %v", tc.limit, err)
+		}
+		diffBooks(t, fmt.Sprintf("List(%d)", tc.limit), tc.want, got)
+	}
+}
+
+func testFind(t *testing.T, crud model.CrudService) {
+	f := finder(t, crud)
+	ctx := context.Background()
+	books := add(t, crud,
+		model.Book{Author: "Jane Doe", Title: "Go in Practice", ReleaseDate: time.Date(2016, time.October, 1, 0, 0, 0, 0, time.UTC), Keywords: []model.Keyword{{Value: "Go"}}},
+		model.Book{Author: "John Doe", Title: "Practical (Go)", ReleaseDate: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), Keywords: []model.Keyword{{Value: "Go"}, {Value: "Testing"}}},
+		model.Book{Author: "jane doe", Title: "MongoDB Basics", ReleaseDate: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), Keywords: []model.Keyword{{Value: "MongoDB"}}},
+	)
+	byTitle := make(map[string]model.Book)
+	for _, b := range books {
+		byTitle[b.Title] = b
+	}
+	pick := func(titles ...string) []model.Book {
+		var want []model.Book
+		for _, b := range books {
+			for _, title := range titles {
+				if b.Title == title {
+					want = append(want, byTitle[title])
+				}
+			}
+		}
+		return want
+	}
+
+	tests := []struct {
+		name   string
+		filter model.Filter
+		want   []model.Book
+	}{
+		{name: "all", filter: model.Filter{}, want: books},
+		{name: "author_ignores_case", filter: model.Filter{Author: "JANE DOE"}, want: pick("Go in Practice", "MongoDB Basics")},
+		{name: "title_contains", filter: model.Filter{Title: "practic"}, want: pick("Go in Practice", "Practical (Go)")},
+		{name: "title_is_literal", filter: model.Filter{Title: "(go)"}, want: pick("Practical (Go)")},
+		{name: "keyword", filter: model.Filter{Keyword: "Testing"}, want: pick("Practical (Go)")},
+		{name: "released_after_inclusive", filter: model.Filter{ReleasedAfter: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}, want: pick("Practical (Go)", "MongoDB Basics")},
+		{name: "released_before_exclusive", filter: model.Filter{ReleasedBefore: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}, want: pick("Go in Practice")},
+		{name: "combined", filter: model.Filter{Keyword: "Go", ReleasedAfter: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)}, want: pick("Practical (Go)")},
+		{name: "limit", filter: model.Filter{Keyword: "Go", Limit: 1}, want: pick("Go in Practice", "Practical (Go)")[:1]},
+		{name: "no_match", filter: model.Filter{Author: "Nobody"}, want: []model.Book{}},
+	}
+	for _, tc := range tests {
+		got, err := f.Find(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("Error finding books %s: %v", tc.name, err)
+		}
+		if got == nil {
+			t.Fatalf("Unexpected books found by %s, got nil, want non-nil slice", tc.name)
+		}
+		diffBooks(t, fmt.Sprintf("Find(%s)", tc.name), tc.want, got)
+	}
+}
+
+func testFindAfter(t *testing.T, crud model.CrudService) {
+	f := finder(t, crud)
+	ctx := context.Background()
+	var books []model.Book
+	for i := range 7 {
+		books = append(books, sample(i))
+	}
+	books = add(t, crud, books...)
+
+	// Paging with the last ID of each page visits every book exactly once.
+	var got []model.Book
+	after := ""
+	for range len(books) {
+		page, err := f.Find(ctx, model.Filter{After: after, Limit: 3})
+		if err != nil {
+			t.Fatalf("Error finding books: %v", err)
+		}
+		got = append(got, page...)
+		if len(page) < 3 {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+	diffBooks(t, "paging with Find()", books, got)
+
+	// A cursor does not have to be the ID of an existing book.
+	page, err := f.Find(ctx, model.Filter{After: unknownID, Limit: 2})
+	if err != nil {
+		t.Fatalf("Error finding books: %v", err)
+	}
+	diffBooks(t, "Find() after unknown ID", books[:2], page)
+}
+
+func testUpdate(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	b := add(t, crud, sample(1))[0]
+
+	// Update replaces all fields, including fields left empty, and keeps the ID given as argument.
+	want := model.Book{ID: b.ID, Title: "Updated"}
+	arg := want
+	arg.ID = unknownID
+	updated, err := crud.Update(ctx, b.ID, arg)
+	if err != nil {
+		t.Fatalf("Error updating book: %v", err)
+	}
+	diffBooks(t, "Update()", want, updated)
+
+	got, err := crud.Get(ctx, b.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	diffBooks(t, "Get() after Update()", want, got)
+
+	books, err := crud.List(ctx, 0)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	diffBooks(t, "List() after Update()", []model.Book{want}, books)
+}
+
+func testRemove(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	books := add(t, crud, sample(1), sample(2))
+
+	removed, err := crud.Remove(ctx, books[0].ID)
+	if err != nil {
+		t.Fatalf("Error removing book: %v", err)
+	}
+	diffBooks(t, "Remove()", books[0], removed)
+
+	if _, err := crud.Get(ctx, books[0].ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from Get() after Remove(), got %v, want %v", err, model.ErrNotFound)
+	}
+	if _, err := crud.Remove(ctx, books[0].ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Unexpected error from second Remove(), got %v, want %v", err, model.ErrNotFound)
+	}
+	got, err := crud.List(ctx, 0)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	diffBooks(t, "List() after Remove()", books[1:], got)
+}
+
+func testIsolation(t *testing.T, crud model.CrudService) {
+	ctx := context.Background()
+	in := sample(1)
+	added, err := crud.Add(ctx, in)
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	want := sample(1)
+	want.ID = added.ID
+
+	// Books passed to and returned from a store do not share state with the store.
+	in.Keywords[0].Value = "changed argument"
+	added.Keywords[0].Value = "changed result"
+	got, err := crud.Get(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	diffBooks(t, "Get() after changing books", want, got)
+
+	got.Keywords[0].Value = "changed result"
+	books, err := crud.List(ctx, 0)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	books[0].Keywords[0].Value = "changed result"
+	got, err = crud.Get(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("Error getting book: %v", err)
+	}
+	diffBooks(t, "Get() after changing books", want, got)
+}
+
+func testConcurrentWriters(t *testing.T, crud model.CrudService) {
+	const writers, perWriter = 8, 10
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*2)
+	for w := range writers {
+		wg.Go(func() {
+			for i := range perWriter {
+				n := w*perWriter + i
+				b, err := crud.Add(ctx, sample(n))
+				if err != nil {
+					errs <- fmt.Errorf("Error adding book: %w", err)
+					continue
+				}
+				b.Title = fmt.Sprintf("Updated %d", n)
+				if _, err := crud.Update(ctx, b.ID, b); err != nil {
+					errs <- fmt.Errorf("Error updating book: %w", err)
+				}
+				if _, err := crud.List(ctx, 5); err != nil {
+					errs <- fmt.Errorf("Error listing books: %w", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	books, err := crud.List(ctx, 0)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	if len(books) != writers*perWriter {
+		t.Fatalf("Unexpected number of books, got %d, want %d", len(books), writers*perWriter)
+	}
+	seen := make(map[string]bool)
+	for _, b := range books {
+		if seen[b.ID] {
+			t.Fatalf("Unexpected duplicate book, got ID %s twice", b.ID)
+		}
+		seen[b.ID] = true
+		var n int
+		if _, err := fmt.Sscanf(b.Title, "Updated %d", &n); err != nil {
+			t.Fatalf("Unexpected title of book %s, got %q, want an updated title", b.ID, b.Title)
+		}
+	}
+}
+
+func testCanceledContext(t *testing.T, crud model.CrudService) {
+	id := add(t, crud, sample(1))[0].ID
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	type call struct {
+		name string
+		call func() error
+	}
+	calls := []call{
+		{"List", func() error { _, err := crud.List(ctx, 10); return err }},
+		{"Get", func() error { _, err := crud.Get(ctx, id); return err }},
+		{"Add", func() error { _, err := crud.Add(ctx, sample(2)); return err }},
+		{"Update", func() error { _, err := crud.Update(ctx, id, sample(3)); return err }},
+		{"Remove", func() error { _, err := crud.Remove(ctx, id); return err }},
+	}
+	if f, ok := crud.(model.Finder); ok {
+		calls = append(calls, call{"Find", func() error { _, err := f.Find(ctx, model.Filter{}); return err }})
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error from %s() with canceled context, got %v, want %v", c.name, err, context.Canceled)
+		}
+	}
+
+	// None of the canceled writes took effect.
+	books, err := crud.List(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Error listing books: %v", err)
+	}
+	want := sample(1)
+	want.ID = id
+	diffBooks(t, "List() after canceled writes", []model.Book{want}, books)
+}
+
+func testPing(t *testing.T, crud model.CrudService) {
+	if err := crud.Ping(context.Background()); err != nil {
+		t.Fatalf("Error pinging store: %v", err)
+	}
+}
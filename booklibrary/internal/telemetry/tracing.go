@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// ServiceName is the service name reported to trace backends.
+const ServiceName = "booklibrary-api"
+
+// ShutdownFunc flushes and stops the tracer provider.
+type ShutdownFunc func(context.Context) error
+
+// Setup installs a global tracer provider and W3C trace context propagation. Spans are exported
+// over OTLP/HTTP if endpoint is set, otherwise they are written to stdout. The returned function
+// must be called on shutdown to flush pending spans.
+func Setup(ctx context.Context, endpoint, version string) (ShutdownFunc, error) {
+	exp, err := newExporter(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	if endpoint == "" {
+		slog.Warn("no OTLP endpoint configured, exporting spans to stdout")
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	slog.Info("exporting spans over OTLP", "endpoint", endpoint)
+	return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+}
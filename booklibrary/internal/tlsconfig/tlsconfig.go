@@ -0,0 +1,280 @@
+// Package tlsconfig builds server TLS configurations from certificate files, which are reloaded
+// when they change on disk, e.g. when cert-manager or another agent rotates them.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+type settings struct {
+	clientCAFile   string
+	clientAuth     tls.ClientAuthType
+	minVersion     uint16
+	cipherSuites   []uint16
+	reloadInterval time.Duration
+}
+
+// Option configures a Reloader.
+type Option func(*settings)
+
+// WithClientCA verifies client certificates against the CA certificates in the PEM file caFile.
+// auth selects whether clients must present a certificate, see ParseClientAuth.
+func WithClientCA(caFile string, auth tls.ClientAuthType) Option {
+	return func(s *settings) {
+		s.clientCAFile = caFile
+		s.clientAuth = auth
+	}
+}
+
+// WithMinVersion sets the minimum TLS version. The default is TLS 1.2.
+func WithMinVersion(v uint16) Option {
+	return func(s *settings) {
+		if v != 0 {
+			s.minVersion = v
+		}
+	}
+}
+
+// WithCipherSuites restricts the cipher suites of TLS 1.2 connections. TLS 1.3 cipher suites are
+// not configurable. Without it, Go's default cipher suites are used.
+func WithCipherSuites(ids []uint16) Option {
+	return func(s *settings) {
+		s.cipherSuites = ids
+	}
+}
+
+// WithReloadInterval sets how often the files are checked for changes. The default is 30s.
+func WithReloadInterval(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.reloadInterval = d
+		}
+	}
+}
+
+// Reloader serves a certificate and client CAs loaded from files and reloads them when the files
+// change. If a changed file cannot be loaded, e.g. because the certificate and key are being
+// replaced, the previous certificate is kept and loading is retried with the next check.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	s        settings
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	version   string
+}
+
+// New loads the certificate and key from the PEM files certFile and keyFile.
+func New(certFile, keyFile string, opts ...Option) (*Reloader, error) {
+	s := settings{minVersion: tls.VersionTLS12, reloadInterval: defaultReloadInterval}
+	for _, o := range opts {
+		o(&s)
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, s: s}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a server configuration that always uses the most recently loaded
+// certificate and client CAs. It offers HTTP/2 and HTTP/1.1 through ALPN.
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := r.base()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		c := r.base()
+		c.Certificates = []tls.Certificate{*r.cert}
+		c.ClientCAs = r.clientCAs
+		return c, nil
+	}
+	return cfg
+}
+
+func (r *Reloader) base() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:   r.s.minVersion,
+		CipherSuites: r.s.cipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.s.clientCAFile != "" {
+		cfg.ClientAuth = r.s.clientAuth
+	}
+	return cfg
+}
+
+// Run checks the files for changes until ctx is done.
+func (r *Reloader) Run(ctx context.Context) {
+	t := time.NewTicker(r.s.reloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := r.reload(); err != nil {
+				slog.Warn("reloading TLS certificate, keeping previous certificate", log.ErrorKey, err)
+			}
+		}
+	}
+}
+
+// reload loads the files if they changed since they were last loaded and reports whether they
+// were.
+func (r *Reloader) reload() (bool, error) {
+	version, err := r.fileVersion()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := version == r.version
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("loading certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.s.clientCAFile != "" {
+		pem, err := os.ReadFile(r.s.clientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("reading client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in client CA file %s", r.s.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.version = &cert, pool, version
+	r.mu.Unlock()
+	slog.Info("loaded TLS certificate", slog.String("subject", cert.Leaf.Subject.String()), slog.Time("notAfter", cert.Leaf.NotAfter))
+	return true, nil
+}
+
+// fileVersion identifies the current contents of the files by their sizes and modification times.
+func (r *Reloader) fileVersion() (string, error) {
+	var b strings.Builder
+	for _, name := range []string{r.certFile, r.keyFile, r.s.clientCAFile} {
+		if name == "" {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", name, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// ParseVersion parses a minimum TLS version, "1.2" or "1.3".
+func ParseVersion(s string) (uint16, error) {
+	switch s {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, want 1.2 or 1.3", s)
+	}
+}
+
+// ParseCipherSuites parses a comma-separated list of cipher suite names such as
+// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Only suites without known security issues are accepted.
+// An empty s returns nil, which selects Go's defaults.
+func ParseCipherSuites(s string) ([]uint16, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ParseClientAuth parses how client certificates are verified: "require" rejects clients without
+// a valid certificate, "optional" verifies certificates only if clients send one.
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch s {
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unsupported client auth %q, want require or optional", s)
+	}
+}
+
+// errNoIdentity reports that a certificate lacks the field that identifies the client.
+var errNoIdentity = errors.New("certificate has no identity")
+
+// IdentityMapper returns a function that maps a client certificate to an identity taken from
+// field: "cn" for the subject's common name, "subject" for the full distinguished name, "email"
+// for the first email address, "uri" for the first URI SAN (e.g. a SPIFFE ID) and "dns" for the
+// first DNS SAN.
+func IdentityMapper(field string) (func(*x509.Certificate) (string, error), error) {
+	var fn func(*x509.Certificate) string
+	switch field {
+	case "cn":
+		fn = func(c *x509.Certificate) string { return c.Subject.CommonName }
+	case "subject":
+		fn = func(c *x509.Certificate) string { return c.Subject.String() }
+	case "email":
+		fn = func(c *x509.Certificate) string { return first(c.EmailAddresses) }
+	case "uri":
+		fn = func(c *x509.Certificate) string {
+			if len(c.URIs) == 0 {
+				return ""
+			}
+			return c.URIs[0].String()
+		}
+	case "dns":
+		fn = func(c *x509.Certificate) string { return first(c.DNSNames) }
+	default:
+		return nil, fmt.Errorf("unsupported identity field %q, want cn, subject, email, uri or dns", field)
+	}
+	return func(c *x509.Certificate) (string, error) {
+		if id := fn(c); id != "" {
+			return id, nil
+		}
+		return "", fmt.Errorf("%w in field %s", errNoIdentity, field)
+	}, nil
+}
+
+func first(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
@@ -0,0 +1,285 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newCert issues a certificate for subject, signed by parent or self-signed if parent is nil.
+func newCert(t *testing.T, serial int64, subject pkix.Name, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write writes c and its key as PEM to the files certFile and keyFile.
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("Error writing certificate: %v", err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Error writing key: %v", err)
+	}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// handshake connects a client with cfg to a server with srvCfg and returns the server's
+// certificate as seen by the client, or the handshake error of the server or client.
+func handshake(t *testing.T, srvCfg, cfg *tls.Config) (*x509.Certificate, error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer l.Close()
+	errC := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errC <- err
+			return
+		}
+		defer conn.Close()
+		errC <- tls.Server(conn, srvCfg).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), cfg)
+	if err != nil {
+		<-errC
+		return nil, err
+	}
+	defer conn.Close()
+	// With TLS 1.3, the server verifies the client certificate after the client has finished.
+	if err := <-errC; err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	newCert(t, 1, pkix.Name{CommonName: "localhost"}, nil, false).write(t, certFile, keyFile)
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	cfg := r.TLSConfig()
+	client := &tls.Config{InsecureSkipVerify: true}
+	got, err := handshake(t, cfg, client)
+	if err != nil {
+		t.Fatalf("Handshake error = %v", err)
+	}
+	if got.SerialNumber.Int64() != 1 {
+		t.Fatalf("Unexpected serial number, got %d, want 1", got.SerialNumber)
+	}
+
+	if changed, err := r.reload(); changed || err != nil {
+		t.Fatalf("reload() of unchanged files = %t, %v, want false, nil", changed, err)
+	}
+
+	// A certificate whose key has not been written yet is not loaded.
+	next := newCert(t, 2, pkix.Name{CommonName: "localhost"}, nil, false)
+	next.write(t, certFile, "")
+	if _, err := r.reload(); err == nil {
+		t.Fatal("reload() of mismatched certificate and key succeeded")
+	}
+	if got, _ := handshake(t, cfg, client); got.SerialNumber.Int64() != 1 {
+		t.Fatalf("Unexpected serial number after failed reload, got %d, want 1", got.SerialNumber)
+	}
+
+	next.write(t, certFile, keyFile)
+	later := time.Now().Add(time.Second)
+	for _, name := range []string{certFile, keyFile} {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatalf("Error changing file time: %v", err)
+		}
+	}
+	if changed, err := r.reload(); !changed || err != nil {
+		t.Fatalf("reload() of changed files = %t, %v, want true, nil", changed, err)
+	}
+	if got, _ := handshake(t, cfg, client); got.SerialNumber.Int64() != 2 {
+		t.Fatalf("Unexpected serial number after reload, got %d, want 2", got.SerialNumber)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	ca := newCert(t, 1, pkix.Name{CommonName: "Test CA"}, nil, true)
+	ca.write(t, caFile, "")
+	newCert(t, 2, pkix.Name{CommonName: "localhost"}, ca, false).write(t, certFile, keyFile)
+	clientCert := newCert(t, 3, pkix.Name{CommonName: "alice"}, ca, false).tlsCert()
+	otherCA := newCert(t, 4, pkix.Name{CommonName: "Other CA"}, nil, true)
+	otherCert := newCert(t, 5, pkix.Name{CommonName: "mallory"}, otherCA, false).tlsCert()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	tests := []struct {
+		name    string
+		auth    tls.ClientAuthType
+		certs   []tls.Certificate
+		wantErr bool
+	}{
+		{name: "require_valid", auth: tls.RequireAndVerifyClientCert, certs: []tls.Certificate{clientCert}},
+		{name: "require_missing", auth: tls.RequireAndVerifyClientCert, wantErr: true},
+		{name: "require_untrusted", auth: tls.RequireAndVerifyClientCert, certs: []tls.Certificate{otherCert}, wantErr: true},
+		{name: "optional_missing", auth: tls.VerifyClientCertIfGiven},
+		{name: "optional_untrusted", auth: tls.VerifyClientCertIfGiven, certs: []tls.Certificate{otherCert}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := New(certFile, keyFile, WithClientCA(caFile, tc.auth))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			client := &tls.Config{
+				RootCAs:    roots,
+				ServerName: "localhost",
+				// Send the certificate even if it was not issued by a CA the server accepts.
+				GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					if len(tc.certs) == 0 {
+						return &tls.Certificate{}, nil
+					}
+					return &tc.certs[0], nil
+				},
+			}
+			_, err = handshake(t, r.TLSConfig(), client)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Handshake error = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	newCert(t, 1, pkix.Name{CommonName: "localhost"}, nil, false).write(t, certFile, keyFile)
+	r, err := New(certFile, keyFile, WithMinVersion(tls.VersionTLS13))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	client := &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}
+	if _, err := handshake(t, r.TLSConfig(), client); err == nil {
+		t.Fatal("TLS 1.2 handshake succeeded with minimum version TLS 1.3")
+	}
+}
+
+func TestParse(t *testing.T) {
+	if v, err := ParseVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("ParseVersion(1.3) = %d, %v", v, err)
+	}
+	if _, err := ParseVersion("1.0"); err == nil {
+		t.Error("ParseVersion(1.0) succeeded")
+	}
+	ids, err := ParseCipherSuites("TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384")
+	if err != nil || len(ids) != 2 || ids[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("ParseCipherSuites() = %v, %v", ids, err)
+	}
+	if _, err := ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA"); err == nil {
+		t.Error("ParseCipherSuites() accepted insecure suite")
+	}
+	if ids, err := ParseCipherSuites(""); ids != nil || err != nil {
+		t.Errorf("ParseCipherSuites(\"\") = %v, %v, want nil, nil", ids, err)
+	}
+	if _, err := ParseClientAuth("sometimes"); err == nil {
+		t.Error("ParseClientAuth(sometimes) succeeded")
+	}
+}
+
+func TestIdentityMapper(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/library/sa/librarian")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice", Organization: []string{"Library"}},
+		EmailAddresses: []string{"alice@example.org"},
+		URIs:           []*url.URL{spiffe},
+	}
+	tests := []struct {
+		field   string
+		want    string
+		wantErr error
+	}{
+		{field: "cn", want: "alice"},
+		{field: "subject", want: "CN=alice,O=Library"},
+		{field: "email", want: "alice@example.org"},
+		{field: "uri", want: "spiffe://example.org/ns/library/sa/librarian"},
+		{field: "dns", wantErr: errNoIdentity},
+	}
+	for _, tc := range tests {
+		t.Run(tc.field, func(t *testing.T) {
+			mapper, err := IdentityMapper(tc.field)
+			if err != nil {
+				t.Fatalf("IdentityMapper() error = %v", err)
+			}
+			got, err := mapper(cert)
+			if !errors.Is(err, tc.wantErr) || got != tc.want {
+				t.Fatalf("mapper() = %q, %v, want %q, %v", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+	if _, err := IdentityMapper("ou"); err == nil {
+		t.Fatal("IdentityMapper(ou) succeeded")
+	}
+}
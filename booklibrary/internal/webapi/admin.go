@@ -0,0 +1,167 @@
+package webapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+// Reindexer rebuilds the indexes of a data store.
+type Reindexer interface {
+	Reindex(ctx context.Context) error
+}
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"builtBy"`
+	GoVersion string `json:"goVersion"`
+}
+
+// logLevel is the payload of the log level endpoint.
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// newAdmin creates the router for runtime operations. All routes require the bearer token in s.
+func newAdmin(s settings) chi.Router {
+	a := admin{level: s.logLevel, build: s.buildInfo, reindexer: s.reindexer}
+	r := chi.NewRouter()
+	r.Use(bearerToken(s.adminToken))
+	r.Get("/buildinfo", a.buildInfo)
+	if a.level != nil {
+		r.Get("/loglevel", a.getLogLevel)
+		r.Put("/loglevel", a.setLogLevel)
+	}
+	// Reindexing and profiling can take longer than the server's write timeout.
+	r.Group(func(r chi.Router) {
+		r.Use(noWriteDeadline)
+		if a.reindexer != nil {
+			r.Post("/reindex", a.reindex)
+		}
+		r.Route("/debug/pprof", profiler)
+	})
+	return r
+}
+
+// noWriteDeadline lifts the server's write timeout for a request, so that a long-running operation
+// can still send its response. Requests served by writers that do not support deadlines, such as
+// httptest.ResponseRecorder, are passed on unchanged.
+func noWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			ctx := r.Context()
+			log.FromContext(ctx).DebugContext(ctx, "keeping write deadline", log.ErrorKey, err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// profiler registers the pprof handlers. middleware.Profiler cannot be used, since its redirect to
+// the index with a trailing slash loops with StripSlashes.
+func profiler(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		// The index links to profiles relative to its own URL.
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		pprof.Index(w, r)
+	})
+	r.Get("/cmdline", pprof.Cmdline)
+	r.Get("/profile", pprof.Profile)
+	r.HandleFunc("/symbol", pprof.Symbol)
+	r.Get("/trace", pprof.Trace)
+	r.Get("/{profile}", func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(chi.URLParam(r, "profile")).ServeHTTP(w, r)
+	})
+}
+
+type admin struct {
+	level     *slog.LevelVar
+	build     BuildInfo
+	reindexer Reindexer
+}
+
+// buildInfo returns the version information of the running binary.
+func (a admin) buildInfo(w http.ResponseWriter, r *http.Request) {
+	respond(w, r, a.build, http.StatusOK)
+}
+
+// getLogLevel returns the current minimum level of the logger.
+func (a admin) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	respond(w, r, logLevel{Level: a.level.Level().String()}, http.StatusOK)
+}
+
+// setLogLevel changes the minimum level of the logger. Levels are given as DEBUG, INFO, WARN or
+// ERROR, ignoring case, optionally with an offset such as WARN+2.
+func (a admin) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	var req logLevel
+	if err := bind(r, &req); err != nil {
+		logger.InfoContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		logger.InfoContext(ctx, "invalid log level", "level", req.Level)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	previous := a.level.Level()
+	a.level.Set(level)
+	// Logged at warning level, so that the change is recorded regardless of the new level.
+	logger.WarnContext(ctx, "changed log level", "from", previous.String(), "to", level.String())
+	respond(w, r, logLevel{Level: level.String()}, http.StatusOK)
+}
+
+// reindex rebuilds the indexes of the data store and responds with 204 once it is done.
+func (a admin) reindex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	start := time.Now()
+	if err := a.reindexer.Reindex(ctx); err != nil {
+		storeError(w, r, "reindexing store", err)
+		return
+	}
+	logger.InfoContext(ctx, "reindexed store", "duration", time.Since(start))
+	respond(w, r, nil, http.StatusNoContent)
+}
+
+// bearerToken rejects requests without an Authorization header carrying token as bearer token with
+// 401.
+func bearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !hasBearerToken(r, token) {
+				ctx := r.Context()
+				log.FromContext(ctx).WarnContext(ctx, "unauthorized admin request", "path", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// hasBearerToken reports whether the Authorization header of r carries token as bearer token.
+func hasBearerToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	// Comparing hashes keeps the comparison constant-time regardless of the token's length.
+	sum, want := sha256.Sum256([]byte(got)), sha256.Sum256([]byte(token))
+	return ok && subtle.ConstantTimeCompare(sum[:], want[:]) == 1
+}
@@ -0,0 +1,135 @@
+package webapi_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+const adminToken = "s3cr3t"
+
+type reindexStub func(ctx context.Context) error
+
+func (fn reindexStub) Reindex(ctx context.Context) error { return fn(ctx) }
+
+func TestAdmin(t *testing.T) {
+	var level slog.LevelVar
+	reindexed := 0
+	var reindexErr error
+	reindex := reindexStub(func(context.Context) error {
+		reindexed++
+		return reindexErr
+	})
+	crud := crudStub{}
+	router := webapi.NewMux(&crud,
+		webapi.WithAdmin(adminToken),
+		webapi.WithLogLevel(&level),
+		webapi.WithBuildInfo(webapi.BuildInfo{Version: "1.2.3", Commit: "abc123"}),
+		webapi.WithReindexer(reindex))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		err      error
+		want     int
+		wantBody string
+	}{
+		{name: "missing_token", method: http.MethodGet, path: "/admin/buildinfo", want: http.StatusUnauthorized},
+		{name: "wrong_token", method: http.MethodGet, path: "/admin/buildinfo", token: "guess", want: http.StatusUnauthorized},
+		{name: "build_info", method: http.MethodGet, path: "/admin/buildinfo", token: adminToken, want: http.StatusOK, wantBody: `"version":"1.2.3","commit":"abc123"`},
+		{name: "get_log_level", method: http.MethodGet, path: "/admin/loglevel", token: adminToken, want: http.StatusOK, wantBody: `{"level":"INFO"}`},
+		{name: "set_log_level", method: http.MethodPut, path: "/admin/loglevel", token: adminToken, body: `{"level":"debug"}`, want: http.StatusOK, wantBody: `{"level":"DEBUG"}`},
+		{name: "invalid_log_level", method: http.MethodPut, path: "/admin/loglevel", token: adminToken, body: `{"level":"verbose"}`, want: http.StatusBadRequest},
+		{name: "reindex", method: http.MethodPost, path: "/admin/reindex", token: adminToken, want: http.StatusNoContent},
+		{name: "reindex_error", method: http.MethodPost, path: "/admin/reindex", token: adminToken, err: errors.New("boom"), want: http.StatusInternalServerError},
+		{name: "pprof_index", method: http.MethodGet, path: "/admin/debug/pprof/", token: adminToken, want: http.StatusOK, wantBody: `href='goroutine?debug=1'`},
+		{name: "pprof_index_redirect", method: http.MethodGet, path: "/admin/debug/pprof", token: adminToken, want: http.StatusMovedPermanently},
+		{name: "pprof_profile", method: http.MethodGet, path: "/admin/debug/pprof/goroutine?debug=1", token: adminToken, want: http.StatusOK, wantBody: "goroutine profile"},
+		{name: "pprof_unauthorized", method: http.MethodGet, path: "/admin/debug/pprof/heap", want: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reindexErr = tc.err
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				r.Header.Set("Content-Type", applicationJSON)
+			}
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != tc.want {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", res.StatusCode, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && res.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("Expected WWW-Authenticate header")
+			}
+			body, _ := io.ReadAll(res.Body)
+			if !strings.Contains(string(body), tc.wantBody) {
+				t.Errorf("Unexpected body %q, want it to contain %q", body, tc.wantBody)
+			}
+		})
+	}
+
+	if got := level.Level(); got != slog.LevelDebug {
+		t.Errorf("Unexpected log level, got %v, want %v", got, slog.LevelDebug)
+	}
+	if reindexed != 2 {
+		t.Errorf("Unexpected number of reindexes, got %d, want 2", reindexed)
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	crud := crudStub{}
+	router := webapi.NewMux(&crud)
+	r := httptest.NewRequest(http.MethodGet, "/admin/buildinfo", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminReindexOutlastsWriteTimeout(t *testing.T) {
+	reindex := reindexStub(func(context.Context) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	crud := crudStub{}
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = webapi.NewServer(&crud, 0,
+		webapi.WithAdmin(adminToken),
+		webapi.WithReindexer(reindex),
+		webapi.WithTimeouts(0, 50*time.Millisecond, 0))
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/admin/reindex", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	defer res.Body.Close()
+	if got := res.StatusCode; got != http.StatusNoContent {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,171 @@
+package webapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/catalog"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	mediaJSON    = "application/json"
+	mediaXML     = "application/xml"
+	mediaTextXML = "text/xml"
+	mediaCSV     = "text/csv"
+	mediaMsgpack = "application/msgpack"
+	mediaMARCXML = "application/marcxml+xml"
+)
+
+var errUnsupportedValue = errors.New("value cannot be represented in this format")
+
+// codec encodes response payloads and decodes request payloads in one media type.
+type codec interface {
+	// mediaType returns the Content-Type of encoded payloads.
+	mediaType() string
+	// encode writes v to w.
+	encode(w io.Writer, v any) error
+	// decode reads v from r. Codecs that cannot decode return errUnsupportedValue.
+	decode(r io.Reader, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) mediaType() string { return mediaJSON }
+
+func (jsonCodec) encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// xmlCodec encodes and decodes XML, announcing it as typ.
+type xmlCodec struct {
+	typ string
+}
+
+// bookList is the XML representation of a list of books.
+type bookList struct {
+	XMLName xml.Name         `xml:"books"`
+	Books   []model.BookView `xml:"book"`
+}
+
+func (c xmlCodec) mediaType() string { return c.typ }
+
+func (xmlCodec) encode(w io.Writer, v any) error {
+	if books, ok := v.([]model.BookView); ok {
+		v = bookList{Books: books}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// msgpackCodec encodes and decodes MessagePack, announcing it as typ.
+type msgpackCodec struct {
+	typ string
+}
+
+func (c msgpackCodec) mediaType() string { return c.typ }
+
+func (msgpackCodec) encode(w io.Writer, v any) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+func (msgpackCodec) decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.DisallowUnknownFields(true)
+	return dec.Decode(v)
+}
+
+// csvCodec renders lists of books as CSV with a header row. Keywords are joined with semicolons
+// and release dates are rendered in each book's date format.
+// It cannot represent single books or decode payloads.
+type csvCodec struct{}
+
+var csvHeader = []string{"id", "author", "title", "releaseDate", "keywords"}
+
+func (csvCodec) mediaType() string { return mediaCSV }
+
+func (csvCodec) encode(w io.Writer, v any) error {
+	books, ok := v.([]model.BookView)
+	if !ok {
+		return errUnsupportedValue
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, b := range books {
+		kws := make([]string, len(b.Keywords))
+		for i, kw := range b.Keywords {
+			kws[i] = kw.Value
+		}
+		rec := []string{b.ID, b.Author, b.Title, b.Format.Format(b.ReleaseDate), strings.Join(kws, ";")}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (csvCodec) decode(io.Reader, any) error {
+	return errUnsupportedValue
+}
+
+// marcxmlCodec renders lists of books as a MARCXML collection for library systems. It cannot
+// represent single books or decode payloads; catalogues are imported by Resource.Import.
+type marcxmlCodec struct{}
+
+func (marcxmlCodec) mediaType() string { return mediaMARCXML }
+
+func (marcxmlCodec) encode(w io.Writer, v any) error {
+	books, ok := v.([]model.BookView)
+	if !ok {
+		return errUnsupportedValue
+	}
+	mw := catalog.NewMARCXMLWriter(w)
+	for _, b := range books {
+		if err := mw.Write(b.Book); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (marcxmlCodec) decode(io.Reader, any) error {
+	return errUnsupportedValue
+}
+
+var (
+	codecs = map[string]codec{
+		mediaJSON:                 jsonCodec{},
+		mediaXML:                  xmlCodec{typ: mediaXML},
+		mediaTextXML:              xmlCodec{typ: mediaTextXML},
+		mediaMsgpack:              msgpackCodec{typ: mediaMsgpack},
+		"application/x-msgpack":   msgpackCodec{typ: "application/x-msgpack"},
+		"application/vnd.msgpack": msgpackCodec{typ: "application/vnd.msgpack"},
+		mediaCSV:                  csvCodec{},
+		mediaMARCXML:              marcxmlCodec{},
+	}
+	// bookMediaTypes are the media types single books can be represented in, in order of preference.
+	bookMediaTypes = []string{mediaJSON, mediaXML, mediaTextXML, mediaMsgpack, "application/x-msgpack", "application/vnd.msgpack"}
+	// listMediaTypes are the media types lists of books can be represented in, in order of preference.
+	listMediaTypes = slices.Concat(bookMediaTypes, []string{mediaCSV, mediaMARCXML})
+)
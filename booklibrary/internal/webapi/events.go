@@ -0,0 +1,158 @@
+package webapi
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	changeCreated = "CREATED"
+	changeUpdated = "UPDATED"
+	changeDeleted = "DELETED"
+)
+
+// subscriberBuffer is the number of changes buffered per subscriber before further changes are dropped.
+const subscriberBuffer = 16
+
+// bookChange describes a book that was created, updated or deleted.
+type bookChange struct {
+	Type string
+	Book model.Book
+}
+
+// changeFeed fans out book changes to subscribers. Changes are only observed for writes made
+// through this process.
+type changeFeed struct {
+	mu     sync.Mutex
+	subs   map[chan bookChange]struct{}
+	closed bool
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subs: make(map[chan bookChange]struct{})}
+}
+
+// subscribe returns a channel that receives all changes published until ctx is done or the feed is
+// closed, after which the channel is closed.
+func (f *changeFeed) subscribe(ctx context.Context) <-chan bookChange {
+	ch := make(chan bookChange, subscriberBuffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(ch)
+		return ch
+	}
+	f.subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// close ends all subscriptions, so that long-lived streams do not hold up a server shutdown.
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// publish sends c to all subscribers without blocking. Subscribers that fall behind miss changes.
+func (f *changeFeed) publish(c bookChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- c:
+		default:
+			slog.Warn("dropping book change for slow subscriber", slog.String("type", c.Type), slog.String("id", c.Book.ID))
+		}
+	}
+}
+
+// Changes counts the books created, updated and deleted through the APIs of a process and
+// publishes them to GraphQL subscriptions. To observe writes made through other APIs, such as
+// gRPC, pass the same Changes to WithChanges and serve those APIs the store returned by Store.
+type Changes struct {
+	feed    *changeFeed
+	created prometheus.Counter
+	updated prometheus.Counter
+	deleted prometheus.Counter
+}
+
+// NewChanges creates a Changes with its metrics registered with reg.
+func NewChanges(reg prometheus.Registerer) *Changes {
+	f := promauto.With(reg)
+	return &Changes{
+		feed: newChangeFeed(),
+		created: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_books_created_total",
+			Help: "The total number of books added to the library.",
+		}),
+		updated: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_books_updated_total",
+			Help: "The total number of books updated in the library.",
+		}),
+		deleted: f.NewCounter(prometheus.CounterOpts{
+			Name: "booklibrary_books_deleted_total",
+			Help: "The total number of books removed from the library.",
+		}),
+	}
+}
+
+// Store wraps crud so that its successful writes are counted and published.
+func (c *Changes) Store(crud model.CrudService) model.CrudService {
+	return publishingService{CrudService: crud, changes: c}
+}
+
+// publishingService counts successful writes and publishes them to a changeFeed.
+type publishingService struct {
+	model.CrudService
+	changes *Changes
+}
+
+func (ps publishingService) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	return model.Find(ctx, ps.CrudService, filter)
+}
+
+func (ps publishingService) Add(ctx context.Context, book model.Book) (model.Book, error) {
+	added, err := ps.CrudService.Add(ctx, book)
+	if err == nil {
+		ps.changes.created.Inc()
+		ps.changes.feed.publish(bookChange{Type: changeCreated, Book: added})
+	}
+	return added, err
+}
+
+func (ps publishingService) Update(ctx context.Context, id string, book model.Book) (model.Book, error) {
+	updated, err := ps.CrudService.Update(ctx, id, book)
+	if err == nil {
+		ps.changes.updated.Inc()
+		ps.changes.feed.publish(bookChange{Type: changeUpdated, Book: updated})
+	}
+	return updated, err
+}
+
+func (ps publishingService) Remove(ctx context.Context, id string) (model.Book, error) {
+	removed, err := ps.CrudService.Remove(ctx, id)
+	if err == nil {
+		ps.changes.deleted.Inc()
+		ps.changes.feed.publish(bookChange{Type: changeDeleted, Book: removed})
+	}
+	return removed, err
+}
@@ -0,0 +1,454 @@
+package webapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+const (
+	mediaEventStream    = "text/event-stream"
+	defaultGraphQLLimit = 100
+	// sseKeepAlive is the interval of comments sent on idle subscription streams to keep proxies
+	// from closing the connection.
+	sseKeepAlive = 30 * time.Second
+)
+
+// graphQLRequest is a GraphQL over HTTP request.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
+
+var dateTimeType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "DateTime",
+	Description: "An RFC 3339 timestamp. Input also accepts a date in the form YYYY-MM-DD.",
+	Serialize: func(v any) any {
+		if t, ok := v.(time.Time); ok {
+			return model.DateRFC3339.Format(t)
+		}
+		return nil
+	},
+	ParseValue: func(v any) any {
+		if s, ok := v.(string); ok {
+			if t, err := model.ParseDate(s); err == nil {
+				return t
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(v ast.Value) any {
+		if s, ok := v.(*ast.StringValue); ok {
+			if t, err := model.ParseDate(s.Value); err == nil {
+				return t
+			}
+		}
+		return nil
+	},
+})
+
+var bookType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Book",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: bookField(func(b model.Book) any { return b.ID })},
+		"author":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: bookField(func(b model.Book) any { return b.Author })},
+		"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: bookField(func(b model.Book) any { return b.Title })},
+		"releaseDate": &graphql.Field{Type: graphql.NewNonNull(dateTimeType), Resolve: bookField(func(b model.Book) any { return b.ReleaseDate })},
+		"keywords": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: bookField(func(b model.Book) any {
+				kws := make([]string, len(b.Keywords))
+				for i, kw := range b.Keywords {
+					kws[i] = kw.Value
+				}
+				return kws
+			}),
+		},
+	},
+})
+
+var bookInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "BookInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"author":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"releaseDate": &graphql.InputObjectFieldConfig{Type: dateTimeType},
+		"keywords":    &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	},
+})
+
+var changeTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "BookChangeType",
+	Values: graphql.EnumValueConfigMap{
+		changeCreated: &graphql.EnumValueConfig{Value: changeCreated},
+		changeUpdated: &graphql.EnumValueConfig{Value: changeUpdated},
+		changeDeleted: &graphql.EnumValueConfig{Value: changeDeleted},
+	},
+})
+
+var bookChangeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "BookChange",
+	Fields: graphql.Fields{
+		"type": &graphql.Field{Type: graphql.NewNonNull(changeTypeEnum)},
+		"book": &graphql.Field{Type: graphql.NewNonNull(bookType)},
+	},
+})
+
+// bookField adapts an accessor of model.Book to a field resolver.
+func bookField(fn func(model.Book) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		b, ok := p.Source.(model.Book)
+		if !ok {
+			return nil, fmt.Errorf("unexpected source %T", p.Source)
+		}
+		return fn(b), nil
+	}
+}
+
+// rootKey is the key of the graphQL resolving an operation in the operation's root value.
+const rootKey = "graphql"
+
+// resolver adapts a method of graphQL to a field resolver. The schema is shared by all route
+// multiplexers, so each operation passes its graphQL in the root value.
+func resolver(fn func(*graphQL, graphql.ResolveParams) (any, error)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		root, _ := p.Info.RootValue.(map[string]any)
+		g, ok := root[rootKey].(*graphQL)
+		if !ok {
+			return nil, errors.New("no resolver in root value")
+		}
+		return fn(g, p)
+	}
+}
+
+// graphQLSchema is the GraphQL schema of the API. It is static, so failing to build it is a programming
+// error that panics at startup.
+var graphQLSchema = mustSchema()
+
+func mustSchema() graphql.Schema {
+	id := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}
+	input := &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookInputType)}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"books": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType))),
+					Description: "Books matching all given filters, limited to limit books or 100 if limit is not positive.",
+					Args: graphql.FieldConfigArgument{
+						"author":         &graphql.ArgumentConfig{Type: graphql.String, Description: "Author, ignoring case."},
+						"title":          &graphql.ArgumentConfig{Type: graphql.String, Description: "Part of the title, ignoring case."},
+						"keyword":        &graphql.ArgumentConfig{Type: graphql.String},
+						"releasedAfter":  &graphql.ArgumentConfig{Type: dateTimeType, Description: "Inclusive lower bound of the release date."},
+						"releasedBefore": &graphql.ArgumentConfig{Type: dateTimeType, Description: "Exclusive upper bound of the release date."},
+						"limit":          &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultGraphQLLimit},
+					},
+					Resolve: resolver((*graphQL).books),
+				},
+				"book": &graphql.Field{
+					Type:    bookType,
+					Args:    graphql.FieldConfigArgument{"id": id},
+					Resolve: resolver((*graphQL).book),
+				},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"createBook": &graphql.Field{
+					Type:    graphql.NewNonNull(bookType),
+					Args:    graphql.FieldConfigArgument{"input": input},
+					Resolve: resolver((*graphQL).createBook),
+				},
+				"updateBook": &graphql.Field{
+					Type:        bookType,
+					Description: "Replaces a book. Returns null if no book with this ID exists.",
+					Args:        graphql.FieldConfigArgument{"id": id, "input": input},
+					Resolve:     resolver((*graphQL).updateBook),
+				},
+				"deleteBook": &graphql.Field{
+					Type:        bookType,
+					Description: "Deletes a book and returns it. Returns null if no book with this ID exists.",
+					Args:        graphql.FieldConfigArgument{"id": id},
+					Resolve:     resolver((*graphQL).deleteBook),
+				},
+			},
+		}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Subscription",
+			Fields: graphql.Fields{
+				"bookChanged": &graphql.Field{
+					Type:        graphql.NewNonNull(bookChangeType),
+					Description: "Books created, updated or deleted through this instance.",
+					Subscribe:   resolver((*graphQL).subscribe),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						c, ok := p.Source.(bookChange)
+						if !ok {
+							return nil, fmt.Errorf("unexpected source %T", p.Source)
+						}
+						return map[string]any{"type": c.Type, "book": c.Book}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		panic(fmt.Sprintf("building GraphQL schema: %v", err))
+	}
+	return schema
+}
+
+// graphQL resolves GraphQL operations against a model.CrudService.
+type graphQL struct {
+	crud model.CrudService
+	feed *changeFeed
+}
+
+func newGraphQL(crud model.CrudService, feed *changeFeed) *graphQL {
+	return &graphQL{crud: crud, feed: feed}
+}
+
+func (g *graphQL) books(p graphql.ResolveParams) (any, error) {
+	f := model.Filter{Limit: defaultGraphQLLimit}
+	if v, ok := p.Args["limit"].(int); ok && v > 0 {
+		f.Limit = v
+	}
+	f.Author, _ = p.Args["author"].(string)
+	f.Title, _ = p.Args["title"].(string)
+	f.Keyword, _ = p.Args["keyword"].(string)
+	f.ReleasedAfter, _ = p.Args["releasedAfter"].(time.Time)
+	f.ReleasedBefore, _ = p.Args["releasedBefore"].(time.Time)
+	return model.Find(p.Context, g.crud, f)
+}
+
+func (g *graphQL) book(p graphql.ResolveParams) (any, error) {
+	book, err := g.crud.Get(p.Context, p.Args["id"].(string))
+	return nullIfNotFound(book, err)
+}
+
+func (g *graphQL) createBook(p graphql.ResolveParams) (any, error) {
+	added, err := g.crud.Add(p.Context, bookInput(p.Args["input"]))
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+func (g *graphQL) updateBook(p graphql.ResolveParams) (any, error) {
+	updated, err := g.crud.Update(p.Context, p.Args["id"].(string), bookInput(p.Args["input"]))
+	return nullIfNotFound(updated, err)
+}
+
+func (g *graphQL) deleteBook(p graphql.ResolveParams) (any, error) {
+	removed, err := g.crud.Remove(p.Context, p.Args["id"].(string))
+	return nullIfNotFound(removed, err)
+}
+
+type changesKey struct{}
+
+// subscribe resolves bookChanged with the changes subscribed to by stream, or subscribes now if
+// there are none.
+func (g *graphQL) subscribe(p graphql.ResolveParams) (any, error) {
+	changes, ok := p.Context.Value(changesKey{}).(<-chan bookChange)
+	if !ok {
+		changes = g.feed.subscribe(p.Context)
+	}
+	ch := make(chan any)
+	go func() {
+		defer close(ch)
+		for c := range changes {
+			select {
+			case ch <- c:
+			case <-p.Context.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// nullIfNotFound maps unknown or invalid IDs to a null result.
+func nullIfNotFound(book model.Book, err error) (any, error) {
+	if errors.Is(err, model.ErrNotFound) || errors.Is(err, model.ErrInvalidID) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// bookInput converts a BookInput argument to a model.Book.
+func bookInput(v any) model.Book {
+	in, _ := v.(map[string]any)
+	var b model.Book
+	b.Author, _ = in["author"].(string)
+	b.Title, _ = in["title"].(string)
+	b.ReleaseDate, _ = in["releaseDate"].(time.Time)
+	kws, _ := in["keywords"].([]any)
+	b.Keywords = make([]model.Keyword, 0, len(kws))
+	for _, kw := range kws {
+		if s, ok := kw.(string); ok {
+			b.Keywords = append(b.Keywords, model.Keyword{Value: s})
+		}
+	}
+	return b
+}
+
+// ServeHTTP executes a GraphQL request sent as JSON with POST. Subscriptions require the client to
+// accept text/event-stream and are streamed as server-sent events.
+func (g *graphQL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+
+	if c, _, ok := requestCodec(r); !ok || c.mediaType() != mediaJSON {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	var req graphQLRequest
+	if err := bind(r, &req); err != nil {
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	params := graphql.Params{
+		Schema:         graphQLSchema,
+		RootObject:     map[string]any{rootKey: g},
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}
+	if operationType(req.Query, req.OperationName) != ast.OperationTypeSubscription {
+		respond(w, r, graphql.Do(params), http.StatusOK)
+		return
+	}
+
+	accept := r.Header.Values("Accept")
+	if _, _, ok := selectMediaType(accept, []string{mediaEventStream}); !ok || len(accept) == 0 {
+		http.Error(w, "subscriptions require Accept: "+mediaEventStream, http.StatusNotAcceptable)
+		return
+	}
+	g.stream(w, r, params)
+}
+
+// stream sends the results of a subscription as server-sent events following the distinct
+// connections mode of the GraphQL over SSE protocol.
+func (g *graphQL) stream(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// Subscribe before the stream is announced to the client, so that it observes every change made
+	// after receiving the response headers.
+	ctx = context.WithValue(ctx, changesKey{}, g.feed.subscribe(ctx))
+	params.Context = ctx
+	logger := log.FromContext(ctx)
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.WarnContext(ctx, "clearing write deadline", log.ErrorKey, err)
+	}
+	w.Header().Set("Content-Type", mediaEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.ErrorContext(ctx, "flushing event stream", log.ErrorKey, err)
+		return
+	}
+
+	results := graphql.Subscribe(params)
+	// Drain results until the subscription goroutine exits, even if the client is gone.
+	defer func() {
+		cancel()
+		for range results {
+		}
+	}()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				rc.Flush()
+				return
+			}
+			b, err := json.Marshal(res)
+			if err != nil {
+				logger.ErrorContext(ctx, "encoding subscription result", log.ErrorKey, err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: next\ndata: %s\n\n", b); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ":\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// graphQLClass classifies GraphQL requests by their operation, so that queries and subscriptions are
+// counted as reads and only mutations as writes. Requests that cannot be read are counted as writes.
+func graphQLClass(r *http.Request) string {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return classWrite
+	}
+	var req graphQLRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return classWrite
+	}
+	switch operationType(req.Query, req.OperationName) {
+	case ast.OperationTypeQuery, ast.OperationTypeSubscription:
+		return classRead
+	default:
+		return classWrite
+	}
+}
+
+// operationType returns the type of the operation in query selected by name, or of its only
+// operation if name is empty. It returns an empty string if the query cannot be parsed; the
+// executor reports the actual error.
+func operationType(query, name string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			return op.Operation
+		}
+	}
+	return ""
+}
@@ -0,0 +1,322 @@
+package webapi_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func graphQL(t *testing.T, h http.Handler, query string, vars map[string]any) map[string]any {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		t.Fatalf("Error marshaling GraphQL request: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBuffer(body))
+	r.Header.Set("Content-Type", applicationJSON)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Result().StatusCode; got != http.StatusOK {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusOK)
+	}
+	var res map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error unmarshaling JSON response: %v", err)
+	}
+	if errs, ok := res["errors"]; ok {
+		t.Fatalf("Unexpected GraphQL errors: %v", errs)
+	}
+	return res["data"].(map[string]any)
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	var filter model.Filter
+	crud := crudStub{}
+	crud.FindFn = func(_ context.Context, f model.Filter) ([]model.Book, error) {
+		filter = f
+		return []model.Book{{
+			ID:          "000000000000000000000001",
+			Author:      "John Doe",
+			Title:       "GraphQL in Go",
+			ReleaseDate: time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC),
+			Keywords:    []model.Keyword{{Value: "Go"}, {Value: "GraphQL"}},
+		}}, nil
+	}
+	crud.GetFn = func(_ context.Context, _ string) (model.Book, error) {
+		return model.Book{}, model.ErrNotFound
+	}
+	mux := webapi.NewMux(&crud)
+
+	got := graphQL(t, mux, `query($after: DateTime) {
+		books(author: "john doe", keyword: "Go", releasedAfter: $after, limit: 5) { title releaseDate keywords }
+		book(id: "000000000000000000000002") { id }
+	}`, map[string]any{"after": "2020-01-01"})
+
+	want := map[string]any{
+		"books": []any{map[string]any{
+			"title":       "GraphQL in Go",
+			"releaseDate": "2020-02-01T11:00:00Z",
+			"keywords":    []any{"Go", "GraphQL"},
+		}},
+		"book": nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Unexpected GraphQL data (-want +got):\n%s", diff)
+	}
+
+	wantFilter := model.Filter{
+		Author:        "john doe",
+		Keyword:       "Go",
+		ReleasedAfter: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Limit:         5,
+	}
+	if diff := cmp.Diff(wantFilter, filter); diff != "" {
+		t.Fatalf("Unexpected filter (-want +got):\n%s", diff)
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, b model.Book) (model.Book, error) {
+		b.ID = "000000000000000000000001"
+		return b, nil
+	}
+	crud.UpdateFn = func(_ context.Context, id string, b model.Book) (model.Book, error) {
+		b.ID = id
+		return b, nil
+	}
+	crud.RemoveFn = func(_ context.Context, id string) (model.Book, error) {
+		return model.Book{}, model.ErrNotFound
+	}
+	mux := webapi.NewMux(&crud)
+
+	got := graphQL(t, mux, `mutation($in: BookInput!) {
+		createBook(input: $in) { id author keywords }
+		updateBook(id: "000000000000000000000002", input: $in) { id title }
+		deleteBook(id: "000000000000000000000003") { id }
+	}`, map[string]any{"in": map[string]any{"author": "Jane Doe", "title": "Mutations", "keywords": []string{"Go"}}})
+
+	want := map[string]any{
+		"createBook": map[string]any{"id": "000000000000000000000001", "author": "Jane Doe", "keywords": []any{"Go"}},
+		"updateBook": map[string]any{"id": "000000000000000000000002", "title": "Mutations"},
+		"deleteBook": nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Unexpected GraphQL data (-want +got):\n%s", diff)
+	}
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, b model.Book) (model.Book, error) {
+		b.ID = "000000000000000000000001"
+		return b, nil
+	}
+	srv := httptest.NewServer(webapi.NewMux(&crud))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body := `{"query":"subscription { bookChanged { type book { id title } } }"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", applicationJSON)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Received unexpected HTTP content, got %q, want %q", got, "text/event-stream")
+	}
+
+	// The subscription is registered once the stream has started, so a book added now is observed.
+	add, err := srv.Client().Post(srv.URL+"/api/books", applicationJSON, strings.NewReader(`{"title":"Subscriptions"}`))
+	if err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	add.Body.Close()
+
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		want := `{"data":{"bookChanged":{"book":{"id":"000000000000000000000001","title":"Subscriptions"},"type":"CREATED"}}}`
+		if data != want {
+			t.Fatalf("Unexpected event data, got %q, want %q", data, want)
+		}
+		return
+	}
+	t.Fatalf("Event stream ended without event: %v", sc.Err())
+}
+
+func TestSharedChanges(t *testing.T) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, b model.Book) (model.Book, error) {
+		b.ID = "000000000000000000000001"
+		return b, nil
+	}
+	reg := prometheus.NewRegistry()
+	changes := webapi.NewChanges(reg)
+	srv := httptest.NewServer(webapi.NewMux(&crud, webapi.WithRegistry(reg), webapi.WithChanges(changes)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body := `{"query":"subscription { bookChanged { type book { id } } }"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", applicationJSON)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	defer res.Body.Close()
+
+	// Books added through another API, such as gRPC, are published and counted.
+	if _, err := changes.Store(&crud).Add(ctx, model.Book{Title: "Shared"}); err != nil {
+		t.Fatalf("Error adding book: %v", err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "booklibrary_books_created_total" {
+			continue
+		}
+		if got := mf.GetMetric()[0].GetCounter().GetValue(); got != 1 {
+			t.Errorf("Unexpected value for %s, got %v, want %v", mf.GetName(), got, 1)
+		}
+	}
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			want := `{"data":{"bookChanged":{"book":{"id":"000000000000000000000001"},"type":"CREATED"}}}`
+			if data != want {
+				t.Fatalf("Unexpected event data, got %q, want %q", data, want)
+			}
+			return
+		}
+	}
+	t.Fatalf("Event stream ended without event: %v", sc.Err())
+}
+
+func TestServerShutdownEndsSubscriptions(t *testing.T) {
+	crud := crudStub{}
+	srv := webapi.NewServer(&crud, 0)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	body := `{"query":"subscription { bookChanged { type } }"}`
+	req, err := http.NewRequest(http.MethodPost, "http://"+lis.Addr().String()+"/graphql", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", applicationJSON)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	defer res.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		if sc.Text() == "event: complete" {
+			return
+		}
+	}
+	t.Fatalf("Event stream ended without completion: %v", sc.Err())
+}
+
+func TestGraphQLSubscriptionRequiresEventStream(t *testing.T) {
+	crud := crudStub{}
+	mux := webapi.NewMux(&crud)
+	r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"subscription { bookChanged { type } }"}`))
+	r.Header.Set("Content-Type", applicationJSON)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if got := w.Result().StatusCode; got != http.StatusNotAcceptable {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusNotAcceptable)
+	}
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	crud := crudStub{}
+	crud.FindFn = func(_ context.Context, _ model.Filter) ([]model.Book, error) {
+		return []model.Book{}, nil
+	}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return []model.Book{}, nil
+	}
+	crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+		book.ID = "000000000000000000000001"
+		return book, nil
+	}
+	// Queries have ample read quota, mutations share a single write.
+	mux := webapi.NewMux(&crud, webapi.WithRateLimit(ratelimit.NewMemory(10, time.Minute), ratelimit.NewMemory(1, time.Minute), false))
+
+	post := func(query string) int {
+		body, err := json.Marshal(map[string]any{"query": query})
+		if err != nil {
+			t.Fatalf("Error marshaling GraphQL request: %v", err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", applicationJSON)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+	const (
+		query    = `{ books { id } }`
+		mutation = `mutation { createBook(input: {author: "Jooss", title: "Limits", releaseDate: "2024-01-01"}) { id } }`
+	)
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"mutation", mutation, http.StatusOK},
+		{"mutation_limited", mutation, http.StatusTooManyRequests},
+		{"query", query, http.StatusOK},
+		{"query_again", query, http.StatusOK},
+	}
+	for _, tc := range tests {
+		if got := post(tc.query); got != tc.want {
+			t.Errorf("Received unexpected HTTP status code for %s, got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package webapi
+
+import (
+	"net/http"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+// readyHandler responds with a JSON health report. The service is ready unless a critical check is
+// down; a degraded service still accepts traffic.
+func readyHandler(h *health.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		rep := h.Report(ctx)
+		status := http.StatusOK
+		if rep.Status == health.StatusDown {
+			log.FromContext(ctx).WarnContext(ctx, "service not ready", "report", rep)
+			status = http.StatusServiceUnavailable
+		}
+		respond(w, r, rep, status)
+	}
+}
+
+// startupHandler responds with 200 once all critical checks have passed at least once, and 503 before.
+func startupHandler(h *health.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.Started(r.Context()) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,173 @@
+package webapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// IdempotencyKeyHeader is the header that lets clients safely retry a request. Retries with the same
+// key and request receive the response of the first request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// defaultIdempotencyTTL is how long responses are kept for retries.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLease is how long a request in progress holds its key. It bounds how long a
+	// request that never completes, for example because the server crashed, blocks retries.
+	idempotencyLease = time.Minute
+	// maxIdempotencyKey is the maximum length of an idempotency key.
+	maxIdempotencyKey = 255
+	// maxIdempotentBody is the maximum size of an idempotent request in bytes.
+	maxIdempotentBody = 1 << 20
+)
+
+// idempotent lets clients retry requests with an Idempotency-Key header without repeating their
+// effect. The first request with a key runs and its response is kept for ttl; retries with the
+// same request and negotiated media type receive that response with an Idempotent-Replayed header.
+// Requests that reuse a key for a different request, or arrive while the first is still in
+// progress, are rejected with 409. Server errors are not kept, so the request can be retried. Keys
+// are scoped to the client identity, if any. A nil store or a request without the header disables deduplication.
+func idempotent(store model.IdempotencyService, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if store == nil || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			logger := log.FromContext(ctx)
+			if len(key) > maxIdempotencyKey {
+				logger.InfoContext(ctx, "invalid idempotency key", slog.Int("length", len(key)))
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			r.Body.Close()
+			if err != nil {
+				var mbe *http.MaxBytesError
+				if errors.As(err, &mbe) {
+					logger.InfoContext(ctx, "idempotent request too large", log.ErrorKey, err)
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				logger.InfoContext(ctx, "reading request body", log.ErrorKey, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			id, _ := Identity(ctx)
+			scoped := digest(id, key)
+			// The negotiated representation is part of the request, so that a retry with a different
+			// Accept header is not answered in the media type of the first request.
+			fingerprint := digest(r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"),
+				responseCodec(ctx).mediaType(), string(responseDateFormat(ctx)), string(body))
+			// The token keeps this request from completing or releasing the claim of a retry once
+			// its lease has expired.
+			token := rand.Text()
+			req, claimed, err := store.Claim(ctx, scoped, token, fingerprint, time.Now().Add(idempotencyLease))
+			if err != nil {
+				storeError(w, r, "claiming idempotency key", err)
+				return
+			}
+			if !claimed {
+				replay(w, r, req, fingerprint)
+				return
+			}
+
+			rec := newRecorder()
+			next.ServeHTTP(rec, r)
+			// Handlers that write nothing respond with 200, like with any other ResponseWriter.
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			// Record the outcome even if the client has gone away, since it will likely retry.
+			ctx = context.WithoutCancel(ctx)
+			if rec.status >= http.StatusInternalServerError {
+				if err := store.Release(ctx, scoped, token); err != nil {
+					logger.WarnContext(ctx, "releasing idempotency key", log.ErrorKey, err)
+				}
+			} else {
+				resp := model.RecordedResponse{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+				if err := store.Complete(ctx, scoped, token, resp, time.Now().Add(ttl)); err != nil {
+					logger.WarnContext(ctx, "recording idempotent response", log.ErrorKey, err)
+				}
+			}
+			maps.Copy(w.Header(), rec.header)
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+		})
+	}
+}
+
+// replay responds to a retry of req with its recorded response, or with 409 if the retry is a
+// different request or req is still in progress.
+func replay(w http.ResponseWriter, r *http.Request, req model.IdempotentRequest, fingerprint string) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	switch {
+	case req.Fingerprint != fingerprint:
+		logger.InfoContext(ctx, "idempotency key reused for different request")
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	case req.Response == nil:
+		logger.InfoContext(ctx, "idempotent request in progress")
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	default:
+		logger.DebugContext(ctx, "replaying idempotent response", slog.Int("status", req.Response.Status))
+		maps.Copy(w.Header(), req.Response.Header)
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(req.Response.Status)
+		w.Write(req.Response.Body)
+	}
+}
+
+// digest returns the hex-encoded SHA-256 hash of parts, each terminated by a NUL byte so that
+// different splits of the same bytes hash differently.
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		io.WriteString(h, p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder buffers a response so that it can be recorded before it is sent.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
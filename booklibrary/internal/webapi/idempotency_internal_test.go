@@ -0,0 +1,29 @@
+package webapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+)
+
+func TestIdempotentEmptyResponse(t *testing.T) {
+	// The handler writes neither a status nor a body.
+	h := idempotent(memory.NewIdempotencyService(), time.Hour)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, replayed := range []string{"", "true"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		r.Header.Set(IdempotencyKeyHeader, "k1")
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("Idempotent-Replayed"); got != replayed {
+			t.Errorf("Unexpected Idempotent-Replayed header, got %q, want %q", got, replayed)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package webapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const idempotentBook = `{"author":"Jörg Jooss","title":"Retries in Action","releaseDate":"2024-01-01T00:00:00Z"}`
+
+// countingCrud returns a store that adds books with new IDs and counts them in added.
+func countingCrud(added *atomic.Int32) *crudStub {
+	return &crudStub{AddFn: func(_ context.Context, book model.Book) (model.Book, error) {
+		added.Add(1)
+		book.ID = bson.NewObjectID().Hex()
+		return book, nil
+	}}
+}
+
+func postBook(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", applicationJSON)
+	if key != "" {
+		r.Header.Set(webapi.IdempotencyKeyHeader, key)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	var added atomic.Int32
+	router := webapi.NewResource(countingCrud(&added), webapi.WithIdempotency(memory.NewIdempotencyService(), time.Hour))
+
+	first := postBook(router, "k1", idempotentBook)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", first.Code, http.StatusCreated)
+	}
+	retry := postBook(router, "k1", idempotentBook)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", retry.Code, http.StatusCreated)
+	}
+	if got := added.Load(); got != 1 {
+		t.Errorf("Retry added book again, got %d books, want 1", got)
+	}
+	if got, want := retry.Header().Get("Location"), first.Header().Get("Location"); got != want {
+		t.Errorf("Unexpected Location on retry, got %q, want %q", got, want)
+	}
+	if got, want := retry.Body.String(), first.Body.String(); got != want {
+		t.Errorf("Unexpected body on retry, got %s, want %s", got, want)
+	}
+	if got := retry.Header().Get("Idempotent-Replayed"); got != "true" {
+		t.Errorf("Unexpected Idempotent-Replayed header, got %q, want %q", got, "true")
+	}
+	if got := first.Header().Get("Idempotent-Replayed"); got != "" {
+		t.Errorf("First response has Idempotent-Replayed header %q", got)
+	}
+
+	// The key cannot be reused for another book.
+	other := strings.Replace(idempotentBook, "Retries", "Timeouts", 1)
+	if w := postBook(router, "k1", other); w.Code != http.StatusConflict {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusConflict)
+	}
+	// Other keys and requests without a key are not deduplicated.
+	postBook(router, "k2", idempotentBook)
+	postBook(router, "", idempotentBook)
+	postBook(router, "", idempotentBook)
+	if got := added.Load(); got != 4 {
+		t.Errorf("Unexpected number of books added, got %d, want 4", got)
+	}
+
+	if w := postBook(router, strings.Repeat("k", 256), idempotentBook); w.Code != http.StatusBadRequest {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestIdempotentCreateInProgress(t *testing.T) {
+	started, unblock := make(chan struct{}), make(chan struct{})
+	crud := crudStub{AddFn: func(_ context.Context, book model.Book) (model.Book, error) {
+		close(started)
+		<-unblock
+		book.ID = bson.NewObjectID().Hex()
+		return book, nil
+	}}
+	router := webapi.NewResource(&crud, webapi.WithIdempotency(memory.NewIdempotencyService(), time.Hour))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postBook(router, "k1", idempotentBook) }()
+	<-started
+	w := postBook(router, "k1", idempotentBook)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusConflict)
+	}
+	if got := w.Header().Get("Retry-After"); got == "" {
+		t.Error("No Retry-After header present in response")
+	}
+	close(unblock)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusCreated)
+	}
+}
+
+func TestIdempotentCreateServerError(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var added atomic.Int32
+	crud := countingCrud(&added)
+	add := crud.AddFn
+	crud.AddFn = func(ctx context.Context, book model.Book) (model.Book, error) {
+		if fail.Load() {
+			return model.Book{}, &model.UnavailableError{RetryAfter: time.Second}
+		}
+		return add(ctx, book)
+	}
+	router := webapi.NewResource(crud, webapi.WithIdempotency(memory.NewIdempotencyService(), time.Hour))
+
+	if w := postBook(router, "k1", idempotentBook); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	// Server errors are not replayed, so the retry adds the book.
+	fail.Store(false)
+	if w := postBook(router, "k1", idempotentBook); w.Code != http.StatusCreated {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got := added.Load(); got != 1 {
+		t.Errorf("Unexpected number of books added, got %d, want 1", got)
+	}
+}
+
+func TestIdempotentCreateAccept(t *testing.T) {
+	var added atomic.Int32
+	router := webapi.NewResource(countingCrud(&added), webapi.WithIdempotency(memory.NewIdempotencyService(), time.Hour))
+
+	if w := postBook(router, "k1", idempotentBook); w.Code != http.StatusCreated {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusCreated)
+	}
+	// The JSON response is not replayed to a retry that accepts only XML.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(idempotentBook))
+	r.Header.Set("Content-Type", applicationJSON)
+	r.Header.Set("Accept", "application/xml")
+	r.Header.Set(webapi.IdempotencyKeyHeader, "k1")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusConflict)
+	}
+	if got := added.Load(); got != 1 {
+		t.Errorf("Unexpected number of books added, got %d, want 1", got)
+	}
+}
@@ -0,0 +1,45 @@
+package webapi
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+)
+
+type identityKey struct{}
+
+// Identity returns the identity of the client that sent the request with ctx, as mapped from its
+// verified TLS client certificate. It reports false for clients without a certificate.
+func Identity(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(string)
+	return id, ok
+}
+
+// clientIdentity maps the verified client certificate of a request to an identity with mapper,
+// stores it in the request context and adds it to the request logger. Requests with a certificate
+// that mapper cannot map are rejected with 403.
+func clientIdentity(mapper func(*x509.Certificate) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			logger := log.FromContext(ctx)
+			id, err := mapper(r.TLS.VerifiedChains[0][0])
+			if err != nil {
+				logger.WarnContext(ctx, "mapping client certificate to identity", log.ErrorKey, err,
+					"subject", r.TLS.VerifiedChains[0][0].Subject.String())
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			ctx = context.WithValue(ctx, identityKey{}, id)
+			ctx = log.NewContext(ctx, logger.With(log.IdentityKey, id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,129 @@
+package webapi
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/catalog"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// maxImportSize is the maximum size of a catalogue import in bytes.
+const maxImportSize = 32 << 20
+
+// importFormats maps the media types of catalogue imports to their catalog format.
+var importFormats = map[string]string{
+	"application/marc":     catalog.MARC,
+	mediaMARCXML:           catalog.MARCXML,
+	"application/x-bibtex": catalog.BibTeX,
+	"text/x-bibtex":        catalog.BibTeX,
+}
+
+// importReport is the response to a catalogue import. In a dry run, Imported counts the books that
+// would have been added. Aborted is set if adding a book failed, in which case Records ends with
+// the record of that book.
+type importReport struct {
+	DryRun   bool           `json:"dryRun"`
+	Imported int            `json:"imported"`
+	Skipped  int            `json:"skipped"`
+	Aborted  bool           `json:"aborted,omitempty"`
+	Records  []importRecord `json:"records"`
+}
+
+// importRecord reports how a catalogue record was mapped to a book. The book has an ID if it was
+// added, and Error is set if it was skipped.
+type importRecord struct {
+	Index    int             `json:"index"`
+	Ref      string          `json:"ref,omitempty"`
+	Book     *model.BookView `json:"book"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Import adds the books mapped from a catalogue in MARC 21, MARCXML or BibTeX, as given by the
+// Content-Type, and reports for each record the book and mapping warnings. Records without a title
+// are skipped. With the query parameter dryRun=true, no books are added. Catalogues that cannot be
+// parsed are rejected with 400 and those larger than 32 MiB with 413. If adding a book fails, the
+// import stops and the books added before it remain in the library. The response then reports the
+// records up to the failing one with the status code of storeError, so that clients can resume
+// the import after the books that were added.
+func (rs Resource) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := importFormats[mt]
+	if !ok {
+		w.Header().Set("Accept-Post", strings.Join(slices.Sorted(maps.Keys(importFormats)), ", "))
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			logger.InfoContext(ctx, "invalid dry run", slog.String("dryRun", v))
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	defer body.Close()
+	recs, err := catalog.Read(format, body)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			logger.InfoContext(ctx, "catalogue too large", log.ErrorKey, err)
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		logger.InfoContext(ctx, "reading catalogue", slog.String("format", format), log.ErrorKey, err)
+		// Tell librarians where their catalogue is broken.
+		http.Error(w, fmt.Sprintf("%s: %v", http.StatusText(http.StatusBadRequest), err), http.StatusBadRequest)
+		return
+	}
+
+	f := responseDateFormat(ctx)
+	report := importReport{DryRun: dryRun, Records: make([]importRecord, 0, len(recs))}
+	for i, rec := range recs {
+		ir := importRecord{Index: i + 1, Ref: rec.Ref, Warnings: rec.Warnings}
+		book := rec.Book
+		switch {
+		case rec.Err != nil:
+			ir.Error = rec.Err.Error()
+			report.Skipped++
+		case dryRun:
+			report.Imported++
+		default:
+			added, err := rs.crud.Add(ctx, book)
+			if err != nil {
+				logger.WarnContext(ctx, "aborting import", slog.Int("record", i+1), slog.Int("imported", report.Imported))
+				status := storeStatus(w, r, "adding book to database", err)
+				v := book.View(f)
+				ir.Book = &v
+				ir.Error = "adding book: " + http.StatusText(status)
+				report.Aborted = true
+				report.Records = append(report.Records, ir)
+				respond(w, r, report, status)
+				return
+			}
+			book = added
+			report.Imported++
+		}
+		v := book.View(f)
+		ir.Book = &v
+		report.Records = append(report.Records, ir)
+	}
+
+	logger.InfoContext(ctx, "imported catalogue", slog.String("format", format), slog.Bool("dryRun", dryRun),
+		slog.Int("records", len(recs)), slog.Int("imported", report.Imported), slog.Int("skipped", report.Skipped))
+	respond(w, r, report, http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package webapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+const bibtex = `@book{donovan2015,
+  author = {Donovan, Alan A. A. and Kernighan, Brian W.},
+  title = {The {Go} Programming Language},
+  year = 2015, month = oct,
+  keywords = {Go, Programming},
+}
+@book{untitled, author = {Jane Doe}}
+@misc{gophers, title = {Gophers}, year = {n.d.}}
+`
+
+type importReport struct {
+	DryRun   bool `json:"dryRun"`
+	Imported int  `json:"imported"`
+	Skipped  int  `json:"skipped"`
+	Aborted  bool `json:"aborted"`
+	Records  []struct {
+		Index    int            `json:"index"`
+		Ref      string         `json:"ref"`
+		Book     model.BookView `json:"book"`
+		Warnings []string       `json:"warnings"`
+		Error    string         `json:"error"`
+	} `json:"records"`
+}
+
+func importCatalogue(t *testing.T, router http.Handler, path, contentType, body string, status int) importReport {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	router.ServeHTTP(w, r)
+	if w.Code != status {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	var report importReport
+	if strings.HasPrefix(w.Header().Get("Content-Type"), applicationJSON) {
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+	}
+	return report
+}
+
+func TestImportDryRun(t *testing.T) {
+	crud := memory.NewCrudService()
+	router := webapi.NewMux(crud)
+
+	report := importCatalogue(t, router, "/api/books/import?dryRun=true&dateFormat=date", "application/x-bibtex; charset=utf-8", bibtex, http.StatusOK)
+	if !report.DryRun || report.Imported != 2 || report.Skipped != 1 || len(report.Records) != 3 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	first := report.Records[0]
+	want := model.Book{
+		Author:      "Alan A. A. Donovan and Brian W. Kernighan",
+		Title:       "The Go Programming Language",
+		ReleaseDate: time.Date(2015, time.October, 1, 0, 0, 0, 0, time.UTC),
+		Keywords:    []model.Keyword{{Value: "Go"}, {Value: "Programming"}},
+	}
+	if diff := cmp.Diff(want, first.Book.Book); diff != "" {
+		t.Errorf("Unexpected book (-want +got):\n%s", diff)
+	}
+	if first.Index != 1 || first.Ref != "donovan2015" || len(first.Warnings) != 0 || first.Error != "" {
+		t.Errorf("Unexpected record %+v", first)
+	}
+	if skipped := report.Records[1]; skipped.Ref != "untitled" || skipped.Error == "" {
+		t.Errorf("Unexpected record %+v", skipped)
+	}
+	if warned := report.Records[2]; len(warned.Warnings) != 4 || warned.Error != "" {
+		t.Errorf("Unexpected record %+v", warned)
+	}
+
+	books, err := crud.List(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(books) != 0 {
+		t.Errorf("Dry run added %d books", len(books))
+	}
+}
+
+func TestImport(t *testing.T) {
+	crud := memory.NewCrudService()
+	router := webapi.NewMux(crud)
+
+	report := importCatalogue(t, router, "/api/books/import", "application/x-bibtex", bibtex, http.StatusOK)
+	if report.DryRun || report.Imported != 2 || report.Skipped != 1 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	for _, i := range []int{0, 2} {
+		if id := report.Records[i].Book.ID; id == "" {
+			t.Errorf("Imported record %d has no ID", i+1)
+		}
+	}
+	if id := report.Records[1].Book.ID; id != "" {
+		t.Errorf("Skipped record has ID %s", id)
+	}
+
+	// The library exported as MARCXML can be imported into another library.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/books", nil)
+	r.Header.Set("Accept", "application/marcxml+xml")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/marcxml+xml" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+	other := webapi.NewMux(memory.NewCrudService())
+	report = importCatalogue(t, other, "/api/books/import", "application/marcxml+xml", w.Body.String(), http.StatusOK)
+	if report.Imported != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if diff := cmp.Diff("The Go Programming Language", report.Records[0].Book.Title); diff != "" {
+		t.Errorf("Unexpected title (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportAborted(t *testing.T) {
+	added := 0
+	crud := crudStub{AddFn: func(_ context.Context, book model.Book) (model.Book, error) {
+		if added == 1 {
+			return model.Book{}, &model.UnavailableError{RetryAfter: time.Second}
+		}
+		added++
+		book.ID = "000000000000000000000001"
+		return book, nil
+	}}
+	router := webapi.NewMux(&crud)
+
+	report := importCatalogue(t, router, "/api/books/import", "application/x-bibtex", bibtex, http.StatusServiceUnavailable)
+	if !report.Aborted || report.Imported != 1 || report.Skipped != 1 || len(report.Records) != 3 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if first := report.Records[0]; first.Book.ID == "" || first.Error != "" {
+		t.Errorf("Unexpected imported record %+v", first)
+	}
+	if failed := report.Records[2]; failed.Ref != "gophers" || failed.Book.ID != "" || failed.Error == "" {
+		t.Errorf("Unexpected failed record %+v", failed)
+	}
+}
+
+func TestImportRejected(t *testing.T) {
+	router := webapi.NewMux(memory.NewCrudService())
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "media_type", path: "/api/books/import", contentType: applicationJSON, body: "[]", status: http.StatusUnsupportedMediaType},
+		{name: "malformed", path: "/api/books/import", contentType: "application/x-bibtex", body: "@book{key, title = {", status: http.StatusBadRequest},
+		{name: "dry_run", path: "/api/books/import?dryRun=maybe", contentType: "application/x-bibtex", body: bibtex, status: http.StatusBadRequest},
+		{name: "too_large", path: "/api/books/import", contentType: "application/x-bibtex", body: strings.Repeat(" ", 32<<20+1), status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importCatalogue(t, router, tt.path, tt.contentType, tt.body, tt.status)
+		})
+	}
+
+	// Store failures abort the import.
+	crud := crudStub{AddFn: func(context.Context, model.Book) (model.Book, error) {
+		return model.Book{}, &model.UnavailableError{RetryAfter: time.Second}
+	}}
+	importCatalogue(t, webapi.NewMux(&crud), "/api/books/import", "application/x-bibtex", bibtex, http.StatusServiceUnavailable)
+}
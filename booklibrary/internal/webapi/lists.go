@@ -0,0 +1,249 @@
+package webapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// UserHeader is the header that identifies the user behind a request if WithTrustedUserHeader is set.
+const UserHeader = "X-User-ID"
+
+// maxListName is the maximum length of a reading list name in bytes.
+const maxListName = 200
+
+// listInput is the payload for creating and updating reading lists.
+type listInput struct {
+	Name       string           `json:"name"`
+	Visibility model.Visibility `json:"visibility"`
+}
+
+// listBookInput is the optional payload for putting a book on a reading list.
+type listBookInput struct {
+	Position *int `json:"position"`
+}
+
+// newListResource creates the router for the reading lists of the user given by the URL parameter
+// user. Everyone can read public lists, but only the user can read private lists and change lists.
+func newListResource(lists model.ListService, s settings, m *metrics) chi.Router {
+	lr := listResource{lists: lists, trustUserHeader: s.trustUserHeader}
+	r := chi.NewRouter()
+	r.Use(m.rateLimit(s.readLimiter, s.writeLimiter, s.limitByKey))
+	r.Use(consumes)
+	r.With(m.instrument("list_lists")).Get("/", lr.List)
+	r.With(m.instrument("create_list"), lr.owner).Post("/", lr.Create)
+	r.Route("/{list}", func(r chi.Router) {
+		r.With(m.instrument("get_list")).Get("/", lr.Get)
+		r.With(m.instrument("update_list"), lr.owner).Put("/", lr.Update)
+		r.With(m.instrument("delete_list"), lr.owner).Delete("/", lr.Delete)
+		r.With(m.instrument("put_list_book"), lr.owner).Put("/books/{book}", lr.PutBook)
+		r.With(m.instrument("remove_list_book"), lr.owner).Delete("/books/{book}", lr.RemoveBook)
+	})
+	return r
+}
+
+type listResource struct {
+	lists           model.ListService
+	trustUserHeader bool
+}
+
+// caller returns the user behind the request: the identity of its TLS client certificate, or the
+// UserHeader if trustUserHeader is set. It returns an empty string for anonymous requests.
+func caller(r *http.Request, trustUserHeader bool) string {
+	if id, ok := Identity(r.Context()); ok {
+		return id
+	}
+	if trustUserHeader {
+		return r.Header.Get(UserHeader)
+	}
+	return ""
+}
+
+// owner rejects requests of anonymous callers with 401 and of other users with 403.
+func (lr listResource) owner(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := caller(r, lr.trustUserHeader)
+		if user == "" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if user != chi.URLParam(r, "user") {
+			ctx := r.Context()
+			log.FromContext(ctx).InfoContext(ctx, "rejecting change to lists of other user", "caller", user)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// List returns the user's lists ordered by ID. Other callers only receive public lists.
+func (lr listResource) List(w http.ResponseWriter, r *http.Request) {
+	user := chi.URLParam(r, "user")
+	all, err := lr.lists.Lists(r.Context(), user)
+	if err != nil {
+		storeError(w, r, "database access", err)
+		return
+	}
+	if caller(r, lr.trustUserHeader) != user {
+		visible := []model.ReadingList{}
+		for _, l := range all {
+			if l.Visibility == model.VisibilityPublic {
+				visible = append(visible, l)
+			}
+		}
+		all = visible
+	}
+	respond(w, r, all, http.StatusOK)
+}
+
+// Get returns a single list. Private lists of other users are reported as not found.
+func (lr listResource) Get(w http.ResponseWriter, r *http.Request) {
+	user := chi.URLParam(r, "user")
+	l, err := lr.lists.GetList(r.Context(), user, chi.URLParam(r, "list"))
+	if err != nil {
+		listError(w, r, err)
+		return
+	}
+	if l.Visibility != model.VisibilityPublic && caller(r, lr.trustUserHeader) != user {
+		http.NotFound(w, r)
+		return
+	}
+	respond(w, r, l, http.StatusOK)
+}
+
+// Create adds a new, empty list for the user.
+func (lr listResource) Create(w http.ResponseWriter, r *http.Request) {
+	in, ok := bindList(w, r)
+	if !ok {
+		return
+	}
+	added, err := lr.lists.AddList(r.Context(), model.ReadingList{
+		Owner:      chi.URLParam(r, "user"),
+		Name:       in.Name,
+		Visibility: in.Visibility,
+	})
+	if err != nil {
+		storeError(w, r, "adding list to database", err)
+		return
+	}
+	path := strings.TrimSuffix(r.URL.String(), "/")
+	respond(w, r, added, http.StatusCreated, header{name: "Location", val: fmt.Sprintf("%s/%s", path, added.ID)})
+}
+
+// Update replaces the name and visibility of a list.
+func (lr listResource) Update(w http.ResponseWriter, r *http.Request) {
+	in, ok := bindList(w, r)
+	if !ok {
+		return
+	}
+	updated, err := lr.lists.UpdateList(r.Context(), chi.URLParam(r, "user"), chi.URLParam(r, "list"),
+		model.ReadingList{Name: in.Name, Visibility: in.Visibility})
+	if err != nil {
+		listError(w, r, err)
+		return
+	}
+	respond(w, r, updated, http.StatusOK)
+}
+
+// Delete removes a list.
+func (lr listResource) Delete(w http.ResponseWriter, r *http.Request) {
+	if _, err := lr.lists.RemoveList(r.Context(), chi.URLParam(r, "user"), chi.URLParam(r, "list")); err != nil {
+		listError(w, r, err)
+		return
+	}
+	respond(w, r, nil, http.StatusNoContent)
+}
+
+// PutBook adds a book to a list, or moves it if it is already on the list. The optional payload's
+// position sets the book's zero-based index; without it, new books are appended and books already
+// on the list keep their place.
+func (lr listResource) PutBook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	var in listBookInput
+	if err := bind(r, &in); err != nil && !errors.Is(err, io.EOF) {
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	user, id, bookID := chi.URLParam(r, "user"), chi.URLParam(r, "list"), chi.URLParam(r, "book")
+	position := -1
+	if in.Position != nil {
+		position = *in.Position
+	} else {
+		l, err := lr.lists.GetList(ctx, user, id)
+		if err != nil {
+			listError(w, r, err)
+			return
+		}
+		for i, b := range l.BookIDs {
+			if b == bookID {
+				position = i
+			}
+		}
+	}
+	if position < -1 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	l, err := lr.lists.PutBook(ctx, user, id, bookID, position)
+	if err != nil {
+		listError(w, r, err)
+		return
+	}
+	respond(w, r, l, http.StatusOK)
+}
+
+// RemoveBook removes a book from a list.
+func (lr listResource) RemoveBook(w http.ResponseWriter, r *http.Request) {
+	_, err := lr.lists.RemoveBook(r.Context(), chi.URLParam(r, "user"), chi.URLParam(r, "list"), chi.URLParam(r, "book"))
+	if err != nil {
+		listError(w, r, err)
+		return
+	}
+	respond(w, r, nil, http.StatusNoContent)
+}
+
+// bindList decodes and validates a list payload. Lists are private unless stated otherwise. It
+// responds with 400 and reports false if the payload is invalid.
+func bindList(w http.ResponseWriter, r *http.Request) (listInput, bool) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	var in listInput
+	if err := bind(r, &in); err != nil {
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return listInput{}, false
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Visibility == "" {
+		in.Visibility = model.VisibilityPrivate
+	}
+	if in.Name == "" || len(in.Name) > maxListName || !in.Visibility.Valid() {
+		logger.InfoContext(ctx, "invalid list", "name", in.Name, "visibility", in.Visibility)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return listInput{}, false
+	}
+	return in, true
+}
+
+// listError responds with 404 for unknown or malformed lists and books, and like storeError otherwise.
+func listError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, model.ErrInvalidID) || errors.Is(err, model.ErrListNotFound) || errors.Is(err, model.ErrNotFound) {
+		ctx := r.Context()
+		log.FromContext(ctx).InfoContext(ctx, "list or book not found", log.ErrorKey, err)
+		http.NotFound(w, r)
+		return
+	}
+	storeError(w, r, "database access", err)
+}
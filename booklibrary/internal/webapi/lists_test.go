@@ -0,0 +1,121 @@
+package webapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/integrity"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+func TestReadingLists(t *testing.T) {
+	ctx := context.Background()
+	crud := memory.NewCrudService()
+	var books []string
+	for _, title := range []string{"First", "Second"} {
+		b, err := crud.Add(ctx, model.Book{Title: title})
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		books = append(books, b.ID)
+	}
+	router := webapi.NewMux(crud, webapi.WithLists(integrity.NewListService(memory.NewListService(), crud)), webapi.WithTrustedUserHeader())
+
+	do := func(t *testing.T, method, path, user, body string, want int) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", applicationJSON)
+		}
+		if user != "" {
+			r.Header.Set(webapi.UserHeader, user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		res := w.Result()
+		if res.StatusCode != want {
+			t.Fatalf("%s %s as %q: Received unexpected HTTP status code, got %d, want %d", method, path, user, res.StatusCode, want)
+		}
+		return res
+	}
+	decode := func(t *testing.T, res *http.Response, v any) {
+		t.Helper()
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+	}
+
+	const lists = "/api/users/alice/lists"
+	do(t, http.MethodPost, lists, "", `{"name":"Favorites"}`, http.StatusUnauthorized)
+	do(t, http.MethodPost, lists, "bob", `{"name":"Favorites"}`, http.StatusForbidden)
+	do(t, http.MethodPost, lists, "alice", `{"name":" "}`, http.StatusBadRequest)
+	do(t, http.MethodPost, lists, "alice", `{"name":"Favorites","visibility":"friends"}`, http.StatusBadRequest)
+
+	res := do(t, http.MethodPost, lists, "alice", `{"name":"Favorites"}`, http.StatusCreated)
+	var l model.ReadingList
+	decode(t, res, &l)
+	list := lists + "/" + l.ID
+	if got := res.Header.Get("Location"); got != list {
+		t.Errorf("Unexpected Location, got %q, want %q", got, list)
+	}
+	if l.Visibility != model.VisibilityPrivate {
+		t.Errorf("Unexpected visibility, got %q, want %q", l.Visibility, model.VisibilityPrivate)
+	}
+
+	do(t, http.MethodPut, list+"/books/"+books[0], "alice", "", http.StatusOK)
+	do(t, http.MethodPut, list+"/books/000000000000000000000000", "alice", "", http.StatusNotFound)
+	do(t, http.MethodPut, list+"/books/"+books[1], "alice", `{"position":-2}`, http.StatusBadRequest)
+	res = do(t, http.MethodPut, list+"/books/"+books[1], "alice", `{"position":0}`, http.StatusOK)
+	decode(t, res, &l)
+	if diff := cmp.Diff([]string{books[1], books[0]}, l.BookIDs); diff != "" {
+		t.Errorf("Unexpected books after insert (-want +got):\n%s", diff)
+	}
+	// Putting a book again without a position keeps its place.
+	res = do(t, http.MethodPut, list+"/books/"+books[1], "alice", "", http.StatusOK)
+	decode(t, res, &l)
+	if diff := cmp.Diff([]string{books[1], books[0]}, l.BookIDs); diff != "" {
+		t.Errorf("Unexpected books after repeated put (-want +got):\n%s", diff)
+	}
+
+	// Private lists are hidden from other users.
+	do(t, http.MethodGet, list, "alice", "", http.StatusOK)
+	do(t, http.MethodGet, list, "bob", "", http.StatusNotFound)
+	var all []model.ReadingList
+	decode(t, do(t, http.MethodGet, lists, "bob", "", http.StatusOK), &all)
+	if len(all) != 0 {
+		t.Errorf("Unexpected lists visible to other user, got %v", all)
+	}
+
+	do(t, http.MethodPut, list, "alice", `{"name":"Best books","visibility":"public"}`, http.StatusOK)
+	decode(t, do(t, http.MethodGet, list, "", "", http.StatusOK), &l)
+	if l.Name != "Best books" || len(l.BookIDs) != 2 {
+		t.Errorf("Unexpected public list, got %+v", l)
+	}
+	decode(t, do(t, http.MethodGet, lists, "bob", "", http.StatusOK), &all)
+	if len(all) != 1 {
+		t.Errorf("Unexpected number of public lists, got %d, want 1", len(all))
+	}
+
+	do(t, http.MethodDelete, list+"/books/"+books[0], "bob", "", http.StatusForbidden)
+	do(t, http.MethodDelete, list+"/books/"+books[0], "alice", "", http.StatusNoContent)
+	do(t, http.MethodDelete, list+"/books/"+books[0], "alice", "", http.StatusNotFound)
+	do(t, http.MethodDelete, list, "alice", "", http.StatusNoContent)
+	do(t, http.MethodGet, list, "alice", "", http.StatusNotFound)
+}
+
+func TestReadingListsDisabled(t *testing.T) {
+	crud := crudStub{}
+	router := webapi.NewMux(&crud)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/alice/lists", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,233 @@
+package webapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+const (
+	defaultLoanPeriod  = 21 * 24 * time.Hour
+	defaultMaxRenewals = 2
+	// loansPath is where NewMux serves loans.
+	loansPath = "/api/loans"
+)
+
+// copiesInput is the payload for setting the copies of a book.
+type copiesInput struct {
+	Copies *int `json:"copies"`
+}
+
+// checkoutInput is the optional payload for checking out a book. Due defaults to the end of the
+// loan period and cannot be later.
+type checkoutInput struct {
+	Due *time.Time `json:"due"`
+}
+
+// newLoanResource creates the router for loans. Loans are created by checking out a book with
+// loanHandlers.Checkout.
+func newLoanResource(lh loanHandlers, s settings, m *metrics) chi.Router {
+	r := chi.NewRouter()
+	r.Use(m.rateLimit(s.readLimiter, s.writeLimiter, s.limitByKey))
+	r.Use(consumes)
+	r.With(m.instrument("overdue_loans")).Get("/overdue", lh.Overdue)
+	r.Route("/{loan}", func(r chi.Router) {
+		r.With(m.instrument("get_loan")).Get("/", lh.Get)
+		r.With(m.instrument("return_loan")).Post("/return", lh.Return)
+		r.With(m.instrument("renew_loan")).Post("/renew", lh.Renew)
+	})
+	return r
+}
+
+// loanHandlers serve the copies and loans of books. Books are lent to the caller, who is identified
+// like the owner of reading lists. Callers with the admin token see the loans of all borrowers.
+type loanHandlers struct {
+	loans           model.LoanService
+	period          time.Duration
+	maxRenewals     int
+	trustUserHeader bool
+	adminToken      string
+}
+
+func newLoanHandlers(s settings) loanHandlers {
+	return loanHandlers{
+		loans:           s.loans,
+		period:          s.loanPeriod,
+		maxRenewals:     s.maxRenewals,
+		trustUserHeader: s.trustUserHeader,
+		adminToken:      s.adminToken,
+	}
+}
+
+// SetCopies sets the number of copies of the book given by the URL parameter id and returns its
+// availability.
+func (lh loanHandlers) SetCopies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	var in copiesInput
+	if err := bind(r, &in); err != nil {
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if in.Copies == nil || *in.Copies < 0 {
+		logger.InfoContext(ctx, "invalid number of copies")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	a, err := lh.loans.SetCopies(ctx, chi.URLParam(r, "id"), *in.Copies)
+	if err != nil {
+		loanError(w, r, err)
+		return
+	}
+	respond(w, r, a, http.StatusOK)
+}
+
+// Checkout lends a copy of the book given by the URL parameter id to the caller. It responds with
+// 401 for anonymous callers, with 400 if the due date is not within the loan period, and with 409
+// if all copies are on loan.
+func (lh loanHandlers) Checkout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	borrower := caller(r, lh.trustUserHeader)
+	if borrower == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var in checkoutInput
+	if err := bind(r, &in); err != nil && !errors.Is(err, io.EOF) {
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	latest := now.Add(lh.period)
+	due := latest
+	if in.Due != nil {
+		due = in.Due.UTC()
+	}
+	// Later due dates would bypass the loan period, renewals and the overdue report.
+	if !due.After(now) || due.After(latest) {
+		logger.InfoContext(ctx, "invalid checkout", "due", due)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	loan, err := lh.loans.Checkout(ctx, model.Loan{BookID: chi.URLParam(r, "id"), Borrower: borrower, CheckedOut: now, Due: due})
+	if err != nil {
+		loanError(w, r, err)
+		return
+	}
+	respond(w, r, loan, http.StatusCreated, header{name: "Location", val: loansPath + "/" + loan.ID})
+}
+
+// Get returns a single loan of the caller.
+func (lh loanHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	loan, ok := lh.borrowed(w, r)
+	if !ok {
+		return
+	}
+	respond(w, r, loan, http.StatusOK)
+}
+
+// borrowed returns the loan given by the URL parameter loan if the caller is its borrower. Otherwise,
+// it responds with 401 for anonymous callers, with 403 for other callers, or like loanError, and
+// reports false.
+func (lh loanHandlers) borrowed(w http.ResponseWriter, r *http.Request) (model.Loan, bool) {
+	ctx := r.Context()
+	borrower := caller(r, lh.trustUserHeader)
+	if borrower == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return model.Loan{}, false
+	}
+	loan, err := lh.loans.GetLoan(ctx, chi.URLParam(r, "loan"))
+	if err != nil {
+		loanError(w, r, err)
+		return model.Loan{}, false
+	}
+	if loan.Borrower != borrower {
+		log.FromContext(ctx).InfoContext(ctx, "rejecting access to loan of other borrower", "caller", borrower)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return model.Loan{}, false
+	}
+	return loan, true
+}
+
+// Return closes a loan of the caller. It responds with 409 if the loan has already been returned.
+func (lh loanHandlers) Return(w http.ResponseWriter, r *http.Request) {
+	loan, ok := lh.borrowed(w, r)
+	if !ok {
+		return
+	}
+	loan, err := lh.loans.Return(r.Context(), loan.ID, time.Now().UTC())
+	if err != nil {
+		loanError(w, r, err)
+		return
+	}
+	respond(w, r, loan, http.StatusOK)
+}
+
+// Renew extends a loan of the caller by the loan period, counted from its due date or from now if
+// it is overdue. It responds with 409 if the loan has been returned or renewed too often.
+func (lh loanHandlers) Renew(w http.ResponseWriter, r *http.Request) {
+	loan, ok := lh.borrowed(w, r)
+	if !ok {
+		return
+	}
+	from := time.Now().UTC()
+	if loan.Due.After(from) {
+		from = loan.Due
+	}
+	loan, err := lh.loans.Renew(r.Context(), loan.ID, from.Add(lh.period), lh.maxRenewals)
+	if err != nil {
+		loanError(w, r, err)
+		return
+	}
+	respond(w, r, loan, http.StatusOK)
+}
+
+// Overdue returns the open loans that are past their due date, ordered by due date. Callers with
+// the admin token receive the loans of all borrowers, other callers only their own. It responds
+// with 401 for anonymous callers.
+func (lh loanHandlers) Overdue(w http.ResponseWriter, r *http.Request) {
+	admin := lh.adminToken != "" && hasBearerToken(r, lh.adminToken)
+	borrower := caller(r, lh.trustUserHeader)
+	if !admin && borrower == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	loans, err := lh.loans.Overdue(r.Context(), time.Now().UTC())
+	if err != nil {
+		storeError(w, r, "database access", err)
+		return
+	}
+	if !admin {
+		loans = slices.DeleteFunc(loans, func(l model.Loan) bool { return l.Borrower != borrower })
+	}
+	respond(w, r, loans, http.StatusOK)
+}
+
+// loanError responds with 404 for unknown or malformed books and loans, with 409 for requests that
+// conflict with the state of a loan or the copies of a book, and like storeError otherwise.
+func loanError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	switch {
+	case errors.Is(err, model.ErrInvalidID), errors.Is(err, model.ErrNotFound), errors.Is(err, model.ErrLoanNotFound):
+		logger.InfoContext(ctx, "loan or book not found", log.ErrorKey, err)
+		http.NotFound(w, r)
+	case errors.Is(err, model.ErrNoCopyAvailable), errors.Is(err, model.ErrCopiesOnLoan),
+		errors.Is(err, model.ErrLoanReturned), errors.Is(err, model.ErrRenewalLimit):
+		logger.InfoContext(ctx, "conflicting loan request", log.ErrorKey, err)
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		storeError(w, r, "database access", err)
+	}
+}
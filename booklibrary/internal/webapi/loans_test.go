@@ -0,0 +1,200 @@
+package webapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/integrity"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+func TestLoans(t *testing.T) {
+	ctx := context.Background()
+	crud := memory.NewCrudService()
+	var books []string
+	for _, title := range []string{"First", "Second"} {
+		b, err := crud.Add(ctx, model.Book{Title: title})
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		books = append(books, b.ID)
+	}
+	loans := memory.NewLoanService()
+	router := webapi.NewMux(integrity.NewCrudService(crud, memory.NewListService(), loans),
+		webapi.WithLoans(integrity.NewLoanService(loans, crud)),
+		webapi.WithLoanPolicy(time.Hour, 1),
+		webapi.WithTrustedUserHeader(),
+		webapi.WithAdmin(adminToken))
+
+	user := "alice"
+	do := func(t *testing.T, method, path, body string, want int) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", applicationJSON)
+		}
+		if user != "" {
+			r.Header.Set(webapi.UserHeader, user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		res := w.Result()
+		if res.StatusCode != want {
+			t.Fatalf("%s %s: Received unexpected HTTP status code, got %d, want %d", method, path, res.StatusCode, want)
+		}
+		return res
+	}
+	decode := func(t *testing.T, res *http.Response, v any) {
+		t.Helper()
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+	}
+	availability := func(t *testing.T, want model.Availability) {
+		t.Helper()
+		var b model.Book
+		decode(t, do(t, http.MethodGet, "/api/books/"+books[0], "", http.StatusOK), &b)
+		if b.Availability == nil {
+			t.Fatal("Book has no availability")
+		}
+		if diff := cmp.Diff(want, *b.Availability); diff != "" {
+			t.Errorf("Unexpected availability (-want +got):\n%s", diff)
+		}
+	}
+
+	book := "/api/books/" + books[0]
+	availability(t, model.Availability{})
+	do(t, http.MethodPut, book+"/copies", `{"copies":-1}`, http.StatusBadRequest)
+	do(t, http.MethodPut, book+"/copies", `{}`, http.StatusBadRequest)
+	do(t, http.MethodPut, "/api/books/000000000000000000000000/copies", `{"copies":1}`, http.StatusNotFound)
+	do(t, http.MethodPut, book+"/copies", `{"copies":1}`, http.StatusOK)
+	availability(t, model.NewAvailability(1, 0))
+
+	user = ""
+	do(t, http.MethodPost, book+"/loans", "", http.StatusUnauthorized)
+	user = "alice"
+	do(t, http.MethodPost, book+"/loans", `{"due":"2001-01-01T00:00:00Z"}`, http.StatusBadRequest)
+	// Due dates cannot lie beyond the loan period.
+	do(t, http.MethodPost, book+"/loans", `{"due":"2999-01-01T00:00:00Z"}`, http.StatusBadRequest)
+	do(t, http.MethodPost, "/api/books/000000000000000000000000/loans", "", http.StatusNotFound)
+	start := time.Now()
+	res := do(t, http.MethodPost, book+"/loans", "", http.StatusCreated)
+	var l model.Loan
+	decode(t, res, &l)
+	loan := "/api/loans/" + l.ID
+	if got := res.Header.Get("Location"); got != loan {
+		t.Errorf("Unexpected Location, got %q, want %q", got, loan)
+	}
+	if l.BookID != books[0] || l.Borrower != "alice" || l.Due.Before(start.Add(time.Hour)) {
+		t.Errorf("Unexpected loan, got %+v", l)
+	}
+	user = "bob"
+	do(t, http.MethodPost, book+"/loans", "", http.StatusConflict)
+	availability(t, model.NewAvailability(1, 1))
+	do(t, http.MethodPut, book+"/copies", `{"copies":0}`, http.StatusConflict)
+	do(t, http.MethodDelete, book, "", http.StatusConflict)
+
+	// Only the borrower can see, renew or return a loan.
+	do(t, http.MethodGet, loan, "", http.StatusForbidden)
+	do(t, http.MethodPost, loan+"/renew", "", http.StatusForbidden)
+	do(t, http.MethodPost, loan+"/return", "", http.StatusForbidden)
+	user = ""
+	do(t, http.MethodGet, loan, "", http.StatusUnauthorized)
+	do(t, http.MethodPost, loan+"/return", "", http.StatusUnauthorized)
+	user = "alice"
+
+	var renewed model.Loan
+	decode(t, do(t, http.MethodPost, loan+"/renew", "", http.StatusOK), &renewed)
+	if want := l.Due.Add(time.Hour); !renewed.Due.Equal(want) || renewed.Renewals != 1 {
+		t.Errorf("Unexpected renewed loan, got due %v after %d renewals, want %v after 1", renewed.Due, renewed.Renewals, want)
+	}
+	do(t, http.MethodPost, loan+"/renew", "", http.StatusConflict)
+
+	decode(t, do(t, http.MethodPost, loan+"/return", "", http.StatusOK), &l)
+	if l.Returned == nil {
+		t.Error("Returned loan has no return time")
+	}
+	do(t, http.MethodPost, loan+"/return", "", http.StatusConflict)
+	decode(t, do(t, http.MethodGet, loan, "", http.StatusOK), &l)
+	if l.Open() {
+		t.Error("Loan is still open after return")
+	}
+	availability(t, model.NewAvailability(1, 0))
+	do(t, http.MethodGet, "/api/loans/000000000000000000000000", "", http.StatusNotFound)
+	do(t, http.MethodPost, "/api/loans/000000000000000000000000/renew", "", http.StatusNotFound)
+	do(t, http.MethodPost, "/api/loans/not-an-id/return", "", http.StatusNotFound)
+
+	// The API rejects due dates in the past, so the overdue loan is created in the store.
+	if _, err := loans.SetCopies(ctx, books[1], 1); err != nil {
+		t.Fatalf("SetCopies() error = %v", err)
+	}
+	late, err := loans.Checkout(ctx, model.Loan{BookID: books[1], Borrower: "bob", CheckedOut: start.Add(-2 * time.Hour), Due: start.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	// Borrowers only see their own overdue loans, admins those of all borrowers.
+	overdue := func(t *testing.T, res *http.Response, want ...string) {
+		t.Helper()
+		var got []model.Loan
+		decode(t, res, &got)
+		var ids []string
+		for _, l := range got {
+			ids = append(ids, l.ID)
+		}
+		if diff := cmp.Diff(want, ids); diff != "" {
+			t.Errorf("Unexpected overdue loans (-want +got):\n%s", diff)
+		}
+	}
+	overdue(t, do(t, http.MethodGet, "/api/loans/overdue", "", http.StatusOK))
+	user = "bob"
+	overdue(t, do(t, http.MethodGet, "/api/loans/overdue", "", http.StatusOK), late.ID)
+	user = ""
+	do(t, http.MethodGet, "/api/loans/overdue", "", http.StatusUnauthorized)
+	r := httptest.NewRequest(http.MethodGet, "/api/loans/overdue", nil)
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusOK)
+	}
+	overdue(t, w.Result(), late.ID)
+	user = "alice"
+
+	// Books without open loans can be removed, which withdraws their copies.
+	do(t, http.MethodDelete, book, "", http.StatusNoContent)
+	if a, err := loans.Availability(ctx, books[0]); err != nil || a.Copies != 0 {
+		t.Errorf("Unexpected availability of removed book, got %+v, %v, want no copies", a, err)
+	}
+}
+
+func TestLoansDisabled(t *testing.T) {
+	crud := memory.NewCrudService()
+	b, err := crud.Add(context.Background(), model.Book{Title: "First"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	router := webapi.NewMux(crud)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/books/"+b.ID, nil))
+	if strings.Contains(w.Body.String(), "availability") {
+		t.Errorf("Unexpected availability without loans, got %s", w.Body.String())
+	}
+	for _, path := range []string{"/api/loans/overdue", "/api/books/" + b.ID + "/loans"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"borrower":"alice"}`))
+		r.Header.Set("Content-Type", applicationJSON)
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("POST %s: Received unexpected HTTP status code, got %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
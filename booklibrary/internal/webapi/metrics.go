@@ -4,51 +4,101 @@ import (
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	inFlightGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "booklibrary_in_flight_requests",
-		Help: "A gauge of requests currently being served by the booklibrary API.",
-	})
-
-	counter = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "booklibrary_api_requests_total",
-			Help: "A counter for requests to the the booklibrary API.",
-		},
-		[]string{"code", "method"},
+// NewRegistry creates a Prometheus registry with the Go runtime and process collectors registered.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
+	return reg
+}
 
-	duration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "booklibrary_request_duration_seconds",
-			Help:    "A histogram of latencies for booklibrary API requests.",
-			Buckets: []float64{.25, .5, 1, 2.5, 5, 10},
-		},
-		[]string{"handler", "method"},
-	)
+const inFlightMetric = "booklibrary_in_flight_requests"
 
-	responseSize = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "booklibrary_response_size_bytes",
-			Help:    "A histogram of response sizes for booklibrary API requests.",
-			Buckets: []float64{200, 500, 900, 1500},
-		},
-		[]string{},
-	)
-)
+type metrics struct {
+	inFlightGauge prometheus.Gauge
+	counter       *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	responseSize  *prometheus.HistogramVec
+	rateLimited   *prometheus.CounterVec
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	f := promauto.With(reg)
+	return &metrics{
+		inFlightGauge: f.NewGauge(prometheus.GaugeOpts{
+			Name: inFlightMetric,
+			Help: "A gauge of requests currently being served by the booklibrary API.",
+		}),
+
+		counter: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "booklibrary_api_requests_total",
+				Help: "A counter for requests to the the booklibrary API.",
+			},
+			[]string{"handler", "code", "method"},
+		),
+
+		duration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "booklibrary_request_duration_seconds",
+				Help:    "A histogram of latencies for booklibrary API requests.",
+				Buckets: []float64{.25, .5, 1, 2.5, 5, 10},
+			},
+			[]string{"handler", "method"},
+		),
 
-func metricsFor(name string) func(http.Handler) http.Handler {
+		responseSize: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "booklibrary_response_size_bytes",
+				Help:    "A histogram of response sizes for booklibrary API requests.",
+				Buckets: []float64{200, 500, 900, 1500},
+			},
+			[]string{"handler"},
+		),
+
+		rateLimited: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "booklibrary_api_rate_limited_total",
+			Help: "The total number of requests rejected by the rate limiter.",
+		}, []string{"class"}),
+	}
+}
+
+func (m *metrics) instrument(name string) func(http.Handler) http.Handler {
+	handler := prometheus.Labels{"handler": name}
 	return func(next http.Handler) http.Handler {
-		return promhttp.InstrumentHandlerInFlight(inFlightGauge,
-			promhttp.InstrumentHandlerDuration(duration.MustCurryWith(prometheus.Labels{"handler": name}),
-				promhttp.InstrumentHandlerCounter(counter,
-					promhttp.InstrumentHandlerResponseSize(responseSize, next),
+		return promhttp.InstrumentHandlerInFlight(m.inFlightGauge,
+			promhttp.InstrumentHandlerDuration(m.duration.MustCurryWith(handler),
+				promhttp.InstrumentHandlerCounter(m.counter.MustCurryWith(handler),
+					promhttp.InstrumentHandlerResponseSize(m.responseSize.MustCurryWith(handler), next),
 				),
 			),
 		)
 	}
 }
+
+// InFlightRequests returns the number of API requests currently being served, as gathered from the
+// registry passed to WithRegistry.
+func InFlightRequests(g prometheus.Gatherer) (int, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return 0, err
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != inFlightMetric {
+			continue
+		}
+		var n float64
+		for _, m := range mf.GetMetric() {
+			n += m.GetGauge().GetValue()
+		}
+		return int(n), nil
+	}
+	return 0, nil
+}
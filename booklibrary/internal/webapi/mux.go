@@ -1,41 +1,55 @@
 package webapi
 
 import (
-	"context"
-	"fmt"
 	"net/http"
-	"time"
-
-	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // NewMux creates a new route multiplexer for all endpoints offered the BookLibrary API and all required middleware enabled.
-func NewMux(crud model.CrudService) *chi.Mux {
+func NewMux(crud model.CrudService, opts ...Option) *chi.Mux {
+	return newMux(crud, newSettings(opts))
+}
+
+func newMux(crud model.CrudService, s settings) *chi.Mux {
 	r := chi.NewRouter()
+	if s.trustProxy {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.StripSlashes)
 	r.Use(middleware.Heartbeat("/healthz/live"))
-	r.Get("/healthz/ready", readyHandler(crud))
-	r.Mount("/api/books", NewResource(crud))
-	r.Method(http.MethodGet, "/metrics", promhttp.Handler())
-	return r
-}
+	r.Use(tracing())
+	r.Use(routeSpan)
+	if s.identity != nil {
+		r.Use(clientIdentity(s.identity))
+	}
+	r.Use(requestLogger)
+	h := s.health
+	if h == nil {
+		h = health.NewRegistry(0)
+		h.Register("database", crud.Ping)
+	}
+	r.Get("/healthz/ready", readyHandler(h))
+	r.Get("/healthz/startup", startupHandler(h))
 
-func readyHandler(crud model.CrudService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		defer cancel()
-		if err := crud.Ping(ctx); err != nil {
-			slog.Error("ping database", log.ErrorKey, err)
-			w.WriteHeader(http.StatusServiceUnavailable)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintln(w, "OK")
+	m := newMetrics(s.registry)
+	crud = s.changes.Store(crud)
+	gql := newGraphQL(crud, s.changes.feed)
+	r.Mount("/api/books", newResource(crud, s, m))
+	if s.lists != nil {
+		r.Mount("/api/users/{user}/lists", newListResource(s.lists, s, m))
+	}
+	if s.loans != nil {
+		r.Mount(loansPath, newLoanResource(newLoanHandlers(s), s, m))
 	}
+	r.With(m.rateLimitBy(graphQLClass, s.readLimiter, s.writeLimiter, s.limitByKey), m.instrument("graphql")).Post("/graphql", gql.ServeHTTP)
+	r.Method(http.MethodGet, "/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	if s.adminToken != "" {
+		r.Mount("/admin", newAdmin(s))
+	}
+	return r
 }
@@ -1,19 +1,36 @@
 package webapi_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/tlsconfig"
 	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestSystemEndpoints(t *testing.T) {
 	tests := []struct {
 		name string
 		path string
+		ping error
 		want int
 	}{
 		{
@@ -31,6 +48,23 @@ func TestSystemEndpoints(t *testing.T) {
 			path: "/healthz/ready",
 			want: http.StatusOK,
 		},
+		{
+			name: "get_readiness_database_down",
+			path: "/healthz/ready",
+			ping: errors.New("connection refused"),
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name: "get_startup",
+			path: "/healthz/startup",
+			want: http.StatusOK,
+		},
+		{
+			name: "get_startup_database_down",
+			path: "/healthz/startup",
+			ping: errors.New("connection refused"),
+			want: http.StatusServiceUnavailable,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -38,6 +72,9 @@ func TestSystemEndpoints(t *testing.T) {
 			crud.GetFn = func(_ context.Context, id string) (model.Book, error) {
 				return model.Book{}, nil
 			}
+			crud.PingFn = func(_ context.Context) error {
+				return tc.ping
+			}
 			router := webapi.NewMux(&crud)
 			ts := httptest.NewServer(router)
 			defer ts.Close()
@@ -51,7 +88,215 @@ func TestSystemEndpoints(t *testing.T) {
 				t.Fatalf("Error sending request: %v", err)
 			}
 			if res.StatusCode != tc.want {
-				t.Errorf("Received unexpected HTTP status code, got %d, want %d", res.StatusCode, http.StatusOK)
+				t.Errorf("Received unexpected HTTP status code, got %d, want %d", res.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestBookMetrics(t *testing.T) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+		book.ID = "000000000000000000000001"
+		return book, nil
+	}
+	crud.RemoveFn = func(_ context.Context, id string) (model.Book, error) {
+		return model.Book{ID: id}, nil
+	}
+	reg := prometheus.NewRegistry()
+	router := webapi.NewMux(&crud, webapi.WithRegistry(reg))
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/books", strings.NewReader(`{"title":"Go Testing in Action"}`)),
+		httptest.NewRequest(http.MethodDelete, "/api/books/000000000000000000000001", nil),
+	}
+	for _, r := range requests {
+		r.Header.Set("Content-Type", applicationJSON)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+	want := map[string]float64{
+		"booklibrary_books_created_total": 1,
+		"booklibrary_books_updated_total": 0,
+		"booklibrary_books_deleted_total": 1,
+	}
+	for _, mf := range mfs {
+		if v, ok := want[mf.GetName()]; ok {
+			if got := mf.GetMetric()[0].GetCounter().GetValue(); got != v {
+				t.Errorf("Unexpected value for %s, got %v, want %v", mf.GetName(), got, v)
+			}
+			delete(want, mf.GetName())
+		}
+	}
+	for name := range want {
+		t.Errorf("Metric %s not registered", name)
+	}
+}
+
+func TestInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		close(started)
+		<-release
+		return []model.Book{}, nil
+	}
+	reg := prometheus.NewRegistry()
+	router := webapi.NewMux(&crud, webapi.WithRegistry(reg))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/books", nil))
+	}()
+	<-started
+	if n, err := webapi.InFlightRequests(reg); err != nil || n != 1 {
+		t.Errorf("InFlightRequests() = %d, %v, want 1, nil", n, err)
+	}
+	close(release)
+	<-done
+	if n, err := webapi.InFlightRequests(reg); err != nil || n != 0 {
+		t.Errorf("InFlightRequests() = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"propagate_request_id", "4bf92f3577b34da6", "4bf92f3577b34da6"},
+		{"assign_request_id", "", ""},
+		{"replace_invalid_request_id", "not valid", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			crud := crudStub{}
+			crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+				return []model.Book{}, nil
+			}
+			router := webapi.NewMux(&crud)
+			r := httptest.NewRequest(http.MethodGet, "/api/books", nil)
+			if tc.in != "" {
+				r.Header.Set(webapi.RequestIDHeader, tc.in)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			got := w.Result().Header.Get(webapi.RequestIDHeader)
+			if got == "" {
+				t.Fatalf("No %s header present in response", webapi.RequestIDHeader)
+			}
+			if tc.want != "" && got != tc.want {
+				t.Errorf("Unexpected request ID, got %q, want %q", got, tc.want)
+			}
+			if tc.in != "" && tc.want == "" && got == tc.in {
+				t.Errorf("Invalid request ID %q was propagated", tc.in)
+			}
+		})
+	}
+}
+
+// issue creates a certificate for cn signed by parent, or a self-signed CA if parent is nil.
+func issue(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientIdentity(t *testing.T) {
+	ca := issue(t, "Test CA", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	mapper, err := tlsconfig.IdentityMapper("cn")
+	if err != nil {
+		t.Fatalf("IdentityMapper() error = %v", err)
+	}
+
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return []model.Book{}, nil
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	// Serve the handler built by NewServer, so that the test fails if it drops the option.
+	srv := httptest.NewUnstartedServer(webapi.NewServer(&crud, 0, webapi.WithClientIdentity(mapper)).Handler)
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: pool}
+	srv.Config.BaseContext = func(net.Listener) context.Context {
+		return log.NewContext(context.Background(), logger)
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		cn           string
+		want         int
+		wantIdentity string
+	}{
+		{name: "identity_from_cn", cn: "alice", want: http.StatusOK, wantIdentity: "identity=alice"},
+		{name: "no_certificate", want: http.StatusOK},
+		{name: "certificate_without_cn", cn: "-", want: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.Reset()
+			client := srv.Client()
+			transport := client.Transport.(*http.Transport).Clone()
+			if tc.cn != "" {
+				cn := tc.cn
+				if cn == "-" {
+					cn = ""
+				}
+				transport.TLSClientConfig.Certificates = []tls.Certificate{issue(t, cn, &ca)}
+			}
+			client = &http.Client{Transport: transport}
+			res, err := client.Get(srv.URL + "/api/books")
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tc.want {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", res.StatusCode, tc.want)
+			}
+			if res.ProtoMajor != 2 {
+				t.Errorf("Unexpected protocol, got %s, want HTTP/2", res.Proto)
+			}
+			if tc.wantIdentity != "" && !strings.Contains(logs.String(), tc.wantIdentity) {
+				t.Errorf("Access log %q does not contain %q", logs.String(), tc.wantIdentity)
 			}
 		})
 	}
@@ -0,0 +1,158 @@
+package webapi
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+// DateFormatParam is the name of the query and media type parameter that selects the
+// representation of release dates, e.g. ?dateFormat=rfc3339 or application/json; dateFormat=unixms.
+const DateFormatParam = "dateFormat"
+
+type (
+	codecKey      struct{}
+	dateFormatKey struct{}
+)
+
+// negotiate selects the response codec from the request's Accept header among the given media
+// types and stores it in the request context along with the requested date format. Requests that
+// accept none of them are rejected with 406, and requests for an unknown date format with 400,
+// before they reach the handler. A missing Accept header selects the first media type.
+func negotiate(mediaTypes []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			mt, params, ok := selectMediaType(r.Header.Values("Accept"), mediaTypes)
+			if !ok {
+				w.Header().Set("Accept", strings.Join(mediaTypes, ", "))
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return
+			}
+			f, err := dateFormat(r, params)
+			if err != nil {
+				log.FromContext(r.Context()).InfoContext(r.Context(), "invalid date format", log.ErrorKey, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			w.Header().Add("Vary", "Accept")
+			ctx := context.WithValue(r.Context(), codecKey{}, codecs[mt])
+			ctx = context.WithValue(ctx, dateFormatKey{}, f)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// dateFormat returns the date format requested by the query parameter, or else by the media type
+// parameter in params. Media type parameter names are case-insensitive and have been lowercased
+// by mime.ParseMediaType.
+func dateFormat(r *http.Request, params map[string]string) (model.DateFormat, error) {
+	if v := r.URL.Query().Get(DateFormatParam); v != "" {
+		return model.ParseDateFormat(v)
+	}
+	return model.ParseDateFormat(params[strings.ToLower(DateFormatParam)])
+}
+
+// consumes rejects requests with a body whose Content-Type cannot be decoded with 415. Bodies
+// without a Content-Type are decoded as JSON by bind, as they were before content negotiation.
+func consumes(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 || r.Header.Get("Content-Type") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, _, ok := requestCodec(r); !ok {
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// responseCodec returns the codec selected by negotiate, or JSON if the route does not negotiate.
+func responseCodec(ctx context.Context) codec {
+	if c, ok := ctx.Value(codecKey{}).(codec); ok {
+		return c
+	}
+	return jsonCodec{}
+}
+
+// responseDateFormat returns the date format selected by negotiate, or Unix seconds if the route
+// does not negotiate.
+func responseDateFormat(ctx context.Context) model.DateFormat {
+	if f, ok := ctx.Value(dateFormatKey{}).(model.DateFormat); ok {
+		return f
+	}
+	return model.DateUnix
+}
+
+// requestCodec returns the codec and media type parameters for the request's Content-Type, if it
+// can decode payloads.
+func requestCodec(r *http.Request) (codec, map[string]string, bool) {
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, false
+	}
+	c, ok := codecs[mt]
+	if !ok || c.mediaType() == mediaCSV || c.mediaType() == mediaMARCXML {
+		return nil, nil, false
+	}
+	return c, params, true
+}
+
+// selectMediaType picks the offered media type with the highest quality in the Accept header
+// values and returns it with the parameters of the matching media range. Ties are broken by the
+// order of offers. A more specific range always takes precedence over a less specific one.
+func selectMediaType(accept []string, offers []string) (string, map[string]string, bool) {
+	if len(accept) == 0 {
+		return offers[0], nil, true
+	}
+
+	best, bestQ, bestParams := "", 0.0, map[string]string(nil)
+	for _, offer := range offers {
+		q, spec, match := 0.0, -1, map[string]string(nil)
+		for _, v := range accept {
+			for _, rng := range strings.Split(v, ",") {
+				mt, params, err := mime.ParseMediaType(strings.TrimSpace(rng))
+				if err != nil {
+					continue
+				}
+				s := specificity(mt, offer)
+				if s <= spec {
+					continue
+				}
+				spec, q, match = s, 1.0, params
+				if qv, ok := params["q"]; ok {
+					if f, err := strconv.ParseFloat(qv, 64); err == nil {
+						q = f
+					}
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ, bestParams = offer, q, match
+		}
+	}
+	return best, bestParams, bestQ > 0
+}
+
+// specificity returns how specifically the media range rng matches the media type mt: 2 for an
+// exact match, 1 for type/*, 0 for */* and -1 if it does not match.
+func specificity(rng, mt string) int {
+	switch {
+	case rng == mt:
+		return 2
+	case rng == "*/*":
+		return 0
+	case strings.HasSuffix(rng, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(rng, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
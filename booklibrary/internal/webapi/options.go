@@ -0,0 +1,238 @@
+package webapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	defaultIdleTimeout  = 120 * time.Second
+)
+
+type settings struct {
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	registry        *prometheus.Registry
+	readLimiter     ratelimit.Limiter
+	writeLimiter    ratelimit.Limiter
+	limitByKey      bool
+	trustProxy      bool
+	health          *health.Registry
+	tlsConfig       *tls.Config
+	identity        func(*x509.Certificate) (string, error)
+	adminToken      string
+	logLevel        *slog.LevelVar
+	buildInfo       BuildInfo
+	reindexer       Reindexer
+	lists           model.ListService
+	trustUserHeader bool
+	loans           model.LoanService
+	loanPeriod      time.Duration
+	maxRenewals     int
+	recommender     Recommender
+	idempotency     model.IdempotencyService
+	idempotencyTTL  time.Duration
+	changes         *Changes
+}
+
+func defaultSettings() settings {
+	return settings{
+		readTimeout:    defaultReadTimeout,
+		writeTimeout:   defaultWriteTimeout,
+		idleTimeout:    defaultIdleTimeout,
+		loanPeriod:     defaultLoanPeriod,
+		maxRenewals:    defaultMaxRenewals,
+		idempotencyTTL: defaultIdempotencyTTL,
+	}
+}
+
+func newSettings(opts []Option) settings {
+	s := defaultSettings()
+	for _, o := range opts {
+		o(&s)
+	}
+	if s.registry == nil {
+		s.registry = NewRegistry()
+	}
+	if s.changes == nil {
+		s.changes = NewChanges(s.registry)
+	}
+	return s
+}
+
+// Option configures the server and route multiplexer created by NewServer and NewMux.
+type Option func(*settings)
+
+// WithTimeouts sets the server's read, write and idle timeouts. Zero values keep the defaults.
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(s *settings) {
+		if read > 0 {
+			s.readTimeout = read
+		}
+		if write > 0 {
+			s.writeTimeout = write
+		}
+		if idle > 0 {
+			s.idleTimeout = idle
+		}
+	}
+}
+
+// WithRegistry registers the API's metrics with reg and serves reg on /metrics. Without it, each
+// route multiplexer uses its own registry created by NewRegistry.
+func WithRegistry(reg *prometheus.Registry) Option {
+	return func(s *settings) {
+		s.registry = reg
+	}
+}
+
+// WithRateLimit limits requests to the books API. Reads (GET, HEAD) are checked against read, all
+// other methods against write; a nil limiter leaves that class unlimited. GraphQL queries and
+// subscriptions count as reads, mutations as writes. Clients are identified by
+// IP address, or by the X-API-Key header if byAPIKey is set. Only enable byAPIKey if keys are
+// validated upstream, since clients could otherwise bypass the limit by sending random keys.
+func WithRateLimit(read, write ratelimit.Limiter, byAPIKey bool) Option {
+	return func(s *settings) {
+		s.readLimiter = read
+		s.writeLimiter = write
+		s.limitByKey = byAPIKey
+	}
+}
+
+// WithTrustedProxy takes the client IP address from the X-Forwarded-For, X-Real-IP or True-Client-IP
+// headers. Only enable it if the API is exclusively reachable through a proxy that sets them.
+func WithTrustedProxy() Option {
+	return func(s *settings) {
+		s.trustProxy = true
+	}
+}
+
+// WithHealth serves the checks registered with h on /healthz/ready and /healthz/startup. Without it,
+// both probes only check the data store with CrudService.Ping.
+func WithHealth(h *health.Registry) Option {
+	return func(s *settings) {
+		s.health = h
+	}
+}
+
+// WithTLS serves HTTPS with cfg instead of plain HTTP. Callers must start the server with
+// ListenAndServeTLS("", ""), since cfg provides the certificate.
+func WithTLS(cfg *tls.Config) Option {
+	return func(s *settings) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithClientIdentity maps verified TLS client certificates to client identities with mapper. The
+// identity is available through Identity and is logged with each request. Requests whose
+// certificate cannot be mapped are rejected with 403.
+func WithClientIdentity(mapper func(*x509.Certificate) (string, error)) Option {
+	return func(s *settings) {
+		s.identity = mapper
+	}
+}
+
+// WithAdmin serves runtime operations under /admin to requests that carry token as bearer token in
+// the Authorization header: build information, the log level if WithLogLevel is given, a store
+// reindex if WithReindexer is given, and pprof profiles. An empty token disables /admin.
+func WithAdmin(token string) Option {
+	return func(s *settings) {
+		s.adminToken = token
+	}
+}
+
+// WithLogLevel lets /admin/loglevel read and change the minimum level of the logger created with
+// level.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(s *settings) {
+		s.logLevel = level
+	}
+}
+
+// WithBuildInfo serves info on /admin/buildinfo.
+func WithBuildInfo(info BuildInfo) Option {
+	return func(s *settings) {
+		s.buildInfo = info
+	}
+}
+
+// WithReindexer lets /admin/reindex rebuild the data store's indexes with r.
+func WithReindexer(r Reindexer) Option {
+	return func(s *settings) {
+		s.reindexer = r
+	}
+}
+
+// WithLists serves the reading lists stored in lists under /api/users/{user}/lists. lists should
+// only accept books in the catalogue, e.g. by wrapping it with integrity.NewListService.
+func WithLists(lists model.ListService) Option {
+	return func(s *settings) {
+		s.lists = lists
+	}
+}
+
+// WithTrustedUserHeader identifies the user behind requests without a TLS client certificate by
+// the X-User-ID header. Only enable it if an upstream gateway authenticates users and sets the
+// header, since clients could otherwise act on behalf of any user.
+func WithTrustedUserHeader() Option {
+	return func(s *settings) {
+		s.trustUserHeader = true
+	}
+}
+
+// WithLoans tracks the copies and loans of books in loans. It serves loans under /api/loans, lets
+// clients set the copies of a book and check it out under /api/books/{id}, and adds each book's
+// availability to GET /api/books/{id}.
+func WithLoans(loans model.LoanService) Option {
+	return func(s *settings) {
+		s.loans = loans
+	}
+}
+
+// WithLoanPolicy sets how long books are lent by default and on each renewal, and how often a loan
+// can be renewed. Without it, loans last 21 days and can be renewed twice. A zero period keeps the
+// default; a negative maxRenewals is treated as zero.
+func WithLoanPolicy(period time.Duration, maxRenewals int) Option {
+	return func(s *settings) {
+		if period > 0 {
+			s.loanPeriod = period
+		}
+		s.maxRenewals = max(maxRenewals, 0)
+	}
+}
+
+// WithRecommender serves the books r considers most similar to a book on GET /api/books/{id}/similar.
+func WithRecommender(r Recommender) Option {
+	return func(s *settings) {
+		s.recommender = r
+	}
+}
+
+// WithIdempotency lets clients retry POST /api/books with an Idempotency-Key header without adding
+// the book twice. Responses are kept in store for ttl, or 24 hours if ttl is zero.
+func WithIdempotency(store model.IdempotencyService, ttl time.Duration) Option {
+	return func(s *settings) {
+		s.idempotency = store
+		if ttl > 0 {
+			s.idempotencyTTL = ttl
+		}
+	}
+}
+
+// WithChanges counts and publishes the books changed through the API with c. Without it, each route
+// multiplexer creates its own Changes, registered with the registry given by WithRegistry.
+func WithChanges(c *Changes) Option {
+	return func(s *settings) {
+		s.changes = c
+	}
+}
@@ -0,0 +1,92 @@
+package webapi
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+)
+
+// APIKeyHeader is the header that identifies a client when rate limiting by API key.
+const APIKeyHeader = "X-API-Key"
+
+const (
+	classRead  = "read"
+	classWrite = "write"
+)
+
+// rateLimit rejects requests with 429 once the client has exhausted its quota. GET and HEAD requests
+// are counted against the read limiter, all others against the write limiter. A nil limiter leaves
+// the respective class unlimited.
+func (m *metrics) rateLimit(read, write ratelimit.Limiter, byAPIKey bool) func(http.Handler) http.Handler {
+	return m.rateLimitBy(methodClass, read, write, byAPIKey)
+}
+
+// rateLimitBy is like rateLimit, but counts requests against the limiter of the class returned by
+// classify.
+func (m *metrics) rateLimitBy(classify func(*http.Request) string, read, write ratelimit.Limiter, byAPIKey bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class, limiter := classWrite, write
+			if classify(r) == classRead {
+				class, limiter = classRead, read
+			}
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			res, err := limiter.Allow(ctx, class+":"+clientKey(r, byAPIKey))
+			if err != nil {
+				// Fail open, a broken limiter backend must not take down the API.
+				log.FromContext(ctx).ErrorContext(ctx, "checking rate limit", log.ErrorKey, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				m.rateLimited.WithLabelValues(class).Inc()
+				h.Set("Retry-After", seconds(res.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// methodClass classifies GET and HEAD requests as reads and all others as writes.
+func methodClass(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return classRead
+	}
+	return classWrite
+}
+
+// clientKey identifies the client by its API key if enabled and present, otherwise by its IP address.
+func clientKey(r *http.Request, byAPIKey bool) string {
+	if byAPIKey {
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
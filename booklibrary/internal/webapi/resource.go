@@ -4,235 +4,290 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joergjo/go-samples/booklibrary/internal/log"
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/similar"
+)
+
+const (
+	defaultSimilar = 10
+	maxSimilar     = 50
 )
 
 // NewResource creates a new router with all endpoints offered the BookLibrary API.
-func NewResource(crud model.CrudService) chi.Router {
-	rs := Resource{crud: crud}
+func NewResource(crud model.CrudService, opts ...Option) chi.Router {
+	s := newSettings(opts)
+	return newResource(s.changes.Store(crud), s, newMetrics(s.registry))
+}
+
+func newResource(crud model.CrudService, s settings, m *metrics) chi.Router {
+	rs := Resource{crud: crud, loans: s.loans, recommender: s.recommender}
 	r := chi.NewRouter()
-	r.Use(middleware.AllowContentType("application/json"))
-	r.With(metricsFor("list_books)")).Get("/", rs.List)
-	r.With(metricsFor("create_book)")).Post("/", rs.Create)
-	r.Route("/{id}", func(r chi.Router) {
-		r.With(metricsFor("get_book)")).Get("/", rs.Get)
-		r.With(metricsFor("update_book)")).Put("/", rs.Update)
-		r.With(metricsFor("delete_book)")).Delete("/", rs.Delete)
+	r.Use(m.rateLimit(s.readLimiter, s.writeLimiter, s.limitByKey))
+	// Catalogues are imported in their own media types.
+	r.With(m.instrument("import_books"), negotiate([]string{mediaJSON})).Post("/import", rs.Import)
+	r.Group(func(r chi.Router) {
+		r.Use(consumes)
+		r.With(m.instrument("list_books"), negotiate(listMediaTypes)).Get("/", rs.List)
+		r.With(m.instrument("create_book"), negotiate(bookMediaTypes), idempotent(s.idempotency, s.idempotencyTTL)).Post("/", rs.Create)
+		r.Route("/{id}", func(r chi.Router) {
+			r.With(m.instrument("get_book"), negotiate(bookMediaTypes)).Get("/", rs.Get)
+			r.With(m.instrument("update_book"), negotiate(bookMediaTypes)).Put("/", rs.Update)
+			r.With(m.instrument("delete_book")).Delete("/", rs.Delete)
+			if s.recommender != nil {
+				r.With(m.instrument("similar_books"), negotiate(listMediaTypes)).Get("/similar", rs.Similar)
+			}
+			if s.loans != nil {
+				lh := newLoanHandlers(s)
+				r.With(m.instrument("set_copies")).Put("/copies", lh.SetCopies)
+				r.With(m.instrument("checkout")).Post("/loans", lh.Checkout)
+			}
+		})
 	})
 	return r
 }
 
 // Resource is a RESTful representation of a book library.
 type Resource struct {
-	crud model.CrudService
+	crud        model.CrudService
+	loans       model.LoanService
+	recommender Recommender
+}
+
+// Recommender ranks books by their similarity to a book.
+type Recommender interface {
+	Similar(b model.Book, limit int) []similar.Match
 }
 
-// List returns all books in the library, limited by the query parameter limit or at most 100 if limit is not a valid integer.
+// List returns all books in the library ordered by ID, limited by the query parameter limit or at most 100 if limit
+// is not a valid integer. The query parameter after continues the listing after the book with this ID. If the page
+// is full, the response links to the next page in a Link header.
 func (rs Resource) List(w http.ResponseWriter, r *http.Request) {
-	l := r.URL.Query().Get("limit")
-	limit, err := strconv.Atoi(l)
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
 	if err != nil || limit < 1 {
 		limit = 100
 	}
-	slog.Debug("limiting results", slog.Int("limit", limit))
+	logger.DebugContext(ctx, "limiting results", slog.Int("limit", limit))
 
-	all, err := rs.crud.List(r.Context(), limit)
+	var all []model.Book
+	if after := q.Get("after"); after != "" {
+		all, err = model.Find(ctx, rs.crud, model.Filter{After: after, Limit: limit})
+	} else {
+		all, err = rs.crud.List(ctx, limit)
+	}
 	if err != nil {
-		slog.Error("database access", log.ErrorKey, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusInternalServerError),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "List")))
+		if errors.Is(err, model.ErrInvalidID) {
+			logger.InfoContext(ctx, "invalid cursor", slog.String("after", q.Get("after")))
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		storeError(w, r, "database access", err)
 		return
 	}
 
-	respond(w, all, http.StatusOK)
-	slog.Debug(
-		"handler complete",
-		slog.Int("status", http.StatusOK),
-		slog.Group("handler",
-			slog.String("resource", "Book"),
-			slog.String("method", "List")))
+	var headers []header
+	if len(all) == limit {
+		q.Set("after", all[len(all)-1].ID)
+		q.Set("limit", strconv.Itoa(limit))
+		next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		headers = append(headers, header{name: "Link", val: fmt.Sprintf(`<%s>; rel="next"`, next.String())})
+	}
+	respond(w, r, all, http.StatusOK, headers...)
 }
 
 // Get returns a single book by its ID. If the ID is not a valid UUID or no book for this ID can be found,
-// the handler returns 404.
+// the handler returns 404. If loans are tracked, the book includes its availability.
 func (rs Resource) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
 	id := chi.URLParam(r, "id")
-	book, err := rs.crud.Get(r.Context(), id)
+	book, err := rs.crud.Get(ctx, id)
 	if err != nil {
 		if errors.Is(err, model.ErrInvalidID) {
-			slog.Info("invalid ID", slog.String("id", id))
+			logger.InfoContext(ctx, "invalid ID", slog.String("id", id))
 			http.NotFound(w, r)
-			slog.Debug(
-				"handler complete",
-				slog.Int("status", http.StatusNotFound),
-				slog.Group("handler",
-					slog.String("resource", "Book"),
-					slog.String("method", "Get")))
 			return
 		}
 		if errors.Is(err, model.ErrNotFound) {
-			slog.Info("book not found", slog.String("id", id))
+			logger.InfoContext(ctx, "book not found", slog.String("id", id))
 			http.NotFound(w, r)
-			slog.Debug(
-				"handler complete",
-				slog.Int("status", http.StatusNotFound),
-				slog.Group("handler",
-					slog.String("resource", "Book"),
-					slog.String("method", "Get")))
 			return
 		}
-		slog.Error("database access", log.ErrorKey, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusInternalServerError),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "Get")))
+		storeError(w, r, "database access", err)
 		return
 	}
+	if rs.loans != nil {
+		// The catalogue entry is still useful without its availability.
+		a, err := rs.loans.Availability(ctx, id)
+		if err != nil {
+			logger.WarnContext(ctx, "omitting availability", log.ErrorKey, err)
+		} else {
+			book.Availability = &a
+		}
+	}
 
-	respond(w, book, http.StatusOK)
-	slog.Debug(
-		"handler complete",
-		slog.Int("status", http.StatusOK),
-		slog.Group("handler",
-			slog.String("resource", "Book"),
-			slog.String("method", "Get")))
+	respond(w, r, book, http.StatusOK)
+}
+
+// Similar returns the books that resemble the book with the given ID most, most similar first, limited by the query
+// parameter limit or at most 10 if limit is not a valid integer. If the ID is not valid or no book for this ID can
+// be found, the handler returns 404.
+func (rs Resource) Similar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	id := chi.URLParam(r, "id")
+	book, err := rs.crud.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidID) || errors.Is(err, model.ErrNotFound) {
+			logger.InfoContext(ctx, "book not found", slog.String("id", id))
+			http.NotFound(w, r)
+			return
+		}
+		storeError(w, r, "database access", err)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultSimilar
+	}
+	limit = min(limit, maxSimilar)
+
+	matches := rs.recommender.Similar(book, limit)
+	books := make([]model.Book, 0, len(matches))
+	for _, m := range matches {
+		b, err := rs.crud.Get(ctx, m.ID)
+		if errors.Is(err, model.ErrNotFound) {
+			// Removed by another instance since the index was last rebuilt
+			continue
+		}
+		if err != nil {
+			storeError(w, r, "database access", err)
+			return
+		}
+		books = append(books, b)
+	}
+	respond(w, r, books, http.StatusOK)
 }
 
 // Create adds a new book to the library.
 func (rs Resource) Create(w http.ResponseWriter, r *http.Request) {
-	// Unmarshal JSON to domain object
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+
+	// Decode payload to domain object
 	var book model.Book
 	err := bind(r, &book)
 	if err != nil {
-		slog.Error("binding request payload", log.ErrorKey, err)
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusBadRequest),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "Create")))
-
 		return
 	}
 
+	// Availability is not part of the catalogue
+	book.Availability = nil
+
 	// Add to storage
-	added, err := rs.crud.Add(r.Context(), book)
+	added, err := rs.crud.Add(ctx, book)
 	if err != nil {
-		slog.Error("adding book to database", log.ErrorKey, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusInternalServerError),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "Create")))
+		storeError(w, r, "adding book to database", err)
 		return
 	}
 
-	// Return Created with JSON payload
+	// Return Created with payload
 	path := strings.TrimSuffix(r.URL.String(), "/")
 	loc := header{
 		name: "Location",
 		val:  fmt.Sprintf("%s/%s", path, added.ID),
 	}
-	respond(w, added, http.StatusCreated, loc)
-	slog.Debug(
-		"handler complete",
-		slog.Int("status", http.StatusCreated),
-		slog.Group("handler",
-			slog.String("resource", "Book"),
-			slog.String("method", "Create")))
+	respond(w, r, added, http.StatusCreated, loc)
 }
 
 // Update replaces a book in the library with the given ID.
 func (rs Resource) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+
 	var book model.Book
 	err := bind(r, &book)
 	if err != nil {
-		slog.Error("binding request payload", log.ErrorKey, err)
+		logger.ErrorContext(ctx, "binding request payload", log.ErrorKey, err)
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusBadRequest),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "Update")))
 		return
 	}
 
 	// Updated book by ID in request URI
+	book.Availability = nil
 	id := chi.URLParam(r, "id")
-	updated, err := rs.crud.Update(r.Context(), id, book)
+	updated, err := rs.crud.Update(ctx, id, book)
 	if err != nil {
 		if errors.Is(err, model.ErrInvalidID) || errors.Is(err, model.ErrNotFound) {
-			slog.Info("book not found", slog.String("id", id))
+			logger.InfoContext(ctx, "book not found", slog.String("id", id))
 			http.NotFound(w, r)
-			slog.Debug(
-				"handler complete",
-				slog.Int("status", http.StatusNotFound),
-				slog.Group("handler",
-					slog.String("resource", "Book"),
-					slog.String("method", "Update")))
 			return
 		}
-		slog.Error("database access", log.ErrorKey, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		slog.Debug(
-			"handler complete",
-			slog.Int("status", http.StatusInternalServerError),
-			slog.Group("handler",
-				slog.String("resource", "Book"),
-				slog.String("method", "Update")))
+		storeError(w, r, "database access", err)
 		return
 	}
 
-	respond(w, updated, http.StatusOK)
-	slog.Debug(
-		"handler complete",
-		slog.Int("status", http.StatusOK),
-		slog.Group("handler",
-			slog.String("resource", "Book"),
-			slog.String("method", "Update")))
+	respond(w, r, updated, http.StatusOK)
 }
 
-// Delete removes a book from the library by its ID.
+// Delete removes a book from the library by its ID. It responds with 409 if copies of the book are
+// on loan.
 func (rs Resource) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
 	id := chi.URLParam(r, "id")
-	if _, err := rs.crud.Remove(r.Context(), id); err != nil {
+	if _, err := rs.crud.Remove(ctx, id); err != nil {
 		if errors.Is(err, model.ErrInvalidID) || errors.Is(err, model.ErrNotFound) {
-			slog.Info("book not found", slog.String("id", id))
+			logger.InfoContext(ctx, "book not found", slog.String("id", id))
 			http.NotFound(w, r)
-			slog.Debug(
-				"handler complete",
-				slog.Int("status", http.StatusNotFound),
-				slog.Group("handler", slog.String("resource", "Book"), slog.String("method", "Delete")))
 			return
 		}
-		slog.Error("database access", log.ErrorKey, err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		if errors.Is(err, model.ErrCopiesOnLoan) {
+			logger.InfoContext(ctx, "book on loan", slog.String("id", id))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		storeError(w, r, "database access", err)
 		return
 	}
 
-	respond(w, nil, http.StatusNoContent)
-	slog.Debug(
-		"handler complete",
-		slog.Int("status", http.StatusNoContent),
-		slog.Group("handler", slog.String("resource", "Book"), slog.String("method", "Delete")))
+	respond(w, r, nil, http.StatusNoContent)
+}
+
+// storeError responds with 503 and Retry-After if the data store is unavailable, with 501 if it does
+// not support the operation, and with 500 otherwise.
+func storeError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	status := storeStatus(w, r, msg, err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// storeStatus logs err and returns the status code storeError responds with, setting Retry-After
+// if the data store is unavailable.
+func storeStatus(w http.ResponseWriter, r *http.Request, msg string, err error) int {
+	ctx := r.Context()
+	logger := log.FromContext(ctx)
+	var ue *model.UnavailableError
+	if errors.As(err, &ue) {
+		logger.WarnContext(ctx, msg, log.ErrorKey, err)
+		w.Header().Set("Retry-After", seconds(ue.RetryAfter))
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		logger.WarnContext(ctx, msg, log.ErrorKey, err)
+		return http.StatusNotImplemented
+	}
+	logger.ErrorContext(ctx, msg, log.ErrorKey, err)
+	return http.StatusInternalServerError
 }
@@ -4,16 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/joergjo/go-samples/booklibrary/internal/memory"
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+	"github.com/joergjo/go-samples/booklibrary/internal/similar"
 	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"github.com/vmihailenco/msgpack/v5"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -21,6 +27,7 @@ const applicationJSON = "application/json"
 
 type crudStub struct {
 	ListFn   func(ctx context.Context, limit int) ([]model.Book, error)
+	FindFn   func(ctx context.Context, filter model.Filter) ([]model.Book, error)
 	GetFn    func(ctx context.Context, id string) (model.Book, error)
 	AddFn    func(ctx context.Context, book model.Book) (model.Book, error)
 	UpdateFn func(ctx context.Context, id string, model model.Book) (model.Book, error)
@@ -36,6 +43,11 @@ func (cs *crudStub) List(ctx context.Context, limit int) ([]model.Book, error) {
 	return cs.ListFn(ctx, limit)
 }
 
+// Find finds books matching a filter
+func (cs *crudStub) Find(ctx context.Context, filter model.Filter) ([]model.Book, error) {
+	return cs.FindFn(ctx, filter)
+}
+
 // Book finds a specific book
 func (cs *crudStub) Get(ctx context.Context, id string) (model.Book, error) {
 	return cs.GetFn(ctx, id)
@@ -57,7 +69,10 @@ func (s *crudStub) Remove(ctx context.Context, id string) (model.Book, error) {
 }
 
 func (s *crudStub) Ping(ctx context.Context) error {
-	return nil
+	if s.PingFn == nil {
+		return nil
+	}
+	return s.PingFn(ctx)
 }
 
 func testData(count int) map[string]model.Book {
@@ -348,3 +363,511 @@ func TestUpdateBook(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimit(t *testing.T) {
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return []model.Book{}, nil
+	}
+	crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+		book.ID = bson.NewObjectID().Hex()
+		return book, nil
+	}
+	router := webapi.NewResource(&crud, webapi.WithRateLimit(
+		ratelimit.NewMemory(2, time.Minute),
+		ratelimit.NewMemory(1, time.Minute),
+		false))
+
+	tests := []struct {
+		name   string
+		method string
+		want   int
+	}{
+		{"first_write", http.MethodPost, http.StatusCreated},
+		{"second_write", http.MethodPost, http.StatusTooManyRequests},
+		{"first_read", http.MethodGet, http.StatusOK},
+		{"second_read", http.MethodGet, http.StatusOK},
+		{"third_read", http.MethodGet, http.StatusTooManyRequests},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", bytes.NewBufferString(`{"title":"Rate Limiting in Go"}`))
+			r.Header.Set("Content-Type", applicationJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			res := w.Result()
+
+			if got := res.StatusCode; got != tc.want {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, tc.want)
+			}
+			if res.Header.Get("RateLimit-Limit") == "" {
+				t.Errorf("No RateLimit-Limit header present in response")
+			}
+			if got := res.Header.Get("Retry-After"); (got != "") != (tc.want == http.StatusTooManyRequests) {
+				t.Errorf("Unexpected Retry-After header %q for status %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStoreUnavailable(t *testing.T) {
+	crud := crudStub{}
+	crud.GetFn = func(_ context.Context, _ string) (model.Book, error) {
+		return model.Book{}, &model.UnavailableError{RetryAfter: 1500 * time.Millisecond}
+	}
+
+	router := webapi.NewResource(&crud)
+	r := httptest.NewRequest(http.MethodGet, "/000000000000000000000001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	res := w.Result()
+
+	if got := res.StatusCode; got != http.StatusServiceUnavailable {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	if got := res.Header.Get("Retry-After"); got != "2" {
+		t.Fatalf("Unexpected Retry-After header, got %q, want %q", got, "2")
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	books := testData(2)
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		all := make([]model.Book, 0, len(books))
+		for _, b := range books {
+			all = append(all, b)
+		}
+		return all, nil
+	}
+	crud.GetFn = func(_ context.Context, id string) (model.Book, error) {
+		return books[id], nil
+	}
+	var id string
+	for id = range books {
+		break
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		status int
+		want   string
+	}{
+		{"list default", "/", "", http.StatusOK, "application/json"},
+		{"list wildcard", "/", "*/*", http.StatusOK, "application/json"},
+		{"list xml", "/", "application/xml", http.StatusOK, "application/xml"},
+		{"list text xml", "/", "text/*", http.StatusOK, "text/xml"},
+		{"list csv", "/", "text/csv", http.StatusOK, "text/csv"},
+		{"list msgpack", "/", "application/x-msgpack", http.StatusOK, "application/x-msgpack"},
+		{"list q values", "/", "application/json;q=0.5, application/xml;q=0.9", http.StatusOK, "application/xml"},
+		{"get csv", "/" + id, "text/csv", http.StatusNotAcceptable, ""},
+		{"get excluded", "/" + id, "*/*;q=0.5, application/json;q=0", http.StatusOK, "application/xml"},
+		{"get unsupported", "/" + id, "image/png", http.StatusNotAcceptable, ""},
+	}
+
+	router := webapi.NewResource(&crud)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			res := w.Result()
+
+			if got := res.StatusCode; got != tc.status {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, tc.status)
+			}
+			if tc.want == "" {
+				return
+			}
+			if got := res.Header.Get("Content-Type"); got != tc.want {
+				t.Fatalf("Received unexpected HTTP content, got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBookRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}{
+		{"json", "application/json", json.Marshal, json.Unmarshal},
+		{"xml", "application/xml", xml.Marshal, xml.Unmarshal},
+		{"msgpack", "application/msgpack", msgpack.Marshal, msgpack.Unmarshal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			crud := crudStub{}
+			crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+				book.ID = bson.NewObjectID().Hex()
+				return book, nil
+			}
+
+			router := webapi.NewResource(&crud)
+			book := model.Book{
+				Author:      "Jörg Jooss",
+				Title:       "Go Encoding in Action",
+				ReleaseDate: time.Unix(1700000000, 0),
+				Keywords:    []model.Keyword{{Value: "Golang"}, {Value: "Encoding"}},
+			}
+			body, err := tc.marshal(book)
+			if err != nil {
+				t.Fatalf("Error marshaling Book: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+			r.Header.Set("Content-Type", tc.mediaType)
+			r.Header.Set("Accept", tc.mediaType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			res := w.Result()
+
+			if got := res.StatusCode; got != http.StatusCreated {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusCreated)
+			}
+			if got := res.Header.Get("Content-Type"); got != tc.mediaType {
+				t.Fatalf("Received unexpected HTTP content, got %q, want %q", got, tc.mediaType)
+			}
+
+			body, err = io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("Error reading response body: %v", err)
+			}
+			var got model.Book
+			if err := tc.unmarshal(body, &got); err != nil {
+				t.Fatalf("Error unmarshaling response: %v", err)
+			}
+			book.ID = got.ID
+			if diff := cmp.Diff(book, got); diff != "" {
+				t.Fatalf("Book mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnsupportedMediaType(t *testing.T) {
+	crud := crudStub{}
+	router := webapi.NewResource(&crud)
+
+	for _, ct := range []string{"text/csv", "text/plain"} {
+		t.Run(ct, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"title":"Go"}`))
+			r.Header.Set("Content-Type", ct)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if got := w.Result().StatusCode; got != http.StatusUnsupportedMediaType {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusUnsupportedMediaType)
+			}
+		})
+	}
+}
+
+func TestAddBookWithoutContentType(t *testing.T) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+		book.ID = "000000000000000000000001"
+		return book, nil
+	}
+	router := webapi.NewResource(&crud)
+
+	// Payloads without a Content-Type are read as JSON.
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"title":"Go"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if got := w.Result().StatusCode; got != http.StatusCreated {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, http.StatusCreated)
+	}
+	var book model.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if book.Title != "Go" {
+		t.Errorf("Unexpected title, got %q, want %q", book.Title, "Go")
+	}
+}
+
+func TestListBooksCSV(t *testing.T) {
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return []model.Book{{
+			ID:          "000000000000000000000001",
+			Author:      "Doe, John",
+			Title:       "CSV in Go",
+			ReleaseDate: time.Unix(1700000000, 0),
+			Keywords:    []model.Keyword{{Value: "Go"}, {Value: "CSV"}},
+		}}, nil
+	}
+
+	router := webapi.NewResource(&crud)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	want := "id,author,title,releaseDate,keywords\n" +
+		"000000000000000000000001,\"Doe, John\",CSV in Go,1700000000,Go;CSV\n"
+	if got := w.Body.String(); got != want {
+		t.Fatalf("Unexpected CSV body, got %q, want %q", got, want)
+	}
+}
+
+func TestListBooksAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		books    int
+		err      error
+		wantCode int
+		wantLink string
+	}{
+		{
+			name:     "full_page",
+			query:    "?after=000000000000000000000001&limit=2",
+			books:    2,
+			wantCode: http.StatusOK,
+			wantLink: `</?after=000000000000000000000003&limit=2>; rel="next"`,
+		},
+		{
+			name:     "last_page",
+			query:    "?after=000000000000000000000001&limit=2",
+			books:    1,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid_cursor",
+			query:    "?after=invalid",
+			err:      model.ErrInvalidID,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var filter model.Filter
+			crud := crudStub{}
+			crud.FindFn = func(_ context.Context, f model.Filter) ([]model.Book, error) {
+				filter = f
+				var books []model.Book
+				for i := range tc.books {
+					books = append(books, model.Book{ID: fmt.Sprintf("%024d", i+2)})
+				}
+				return books, tc.err
+			}
+
+			router := webapi.NewResource(&crud)
+			r := httptest.NewRequest(http.MethodGet, "/"+tc.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if got := w.Result().StatusCode; got != tc.wantCode {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, tc.wantCode)
+			}
+			if got := w.Header().Get("Link"); got != tc.wantLink {
+				t.Fatalf("Unexpected Link header, got %q, want %q", got, tc.wantLink)
+			}
+			if tc.err == nil && filter.After != "000000000000000000000001" {
+				t.Fatalf("Unexpected cursor, got %q, want %q", filter.After, "000000000000000000000001")
+			}
+		})
+	}
+}
+
+func TestListBooksAfterUnsupported(t *testing.T) {
+	// Embedding the interface hides the stub's Find, so the store cannot filter books.
+	crud := struct{ model.CrudService }{&crudStub{}}
+	router := webapi.NewResource(crud)
+	r := httptest.NewRequest(http.MethodGet, "/?after=000000000000000000000001", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	book := model.Book{
+		ID:          "000000000000000000000001",
+		Author:      "John Doe",
+		Title:       "Dates in Go",
+		ReleaseDate: time.Date(2020, time.February, 1, 11, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		status int
+		want   string
+	}{
+		{"default", "", "", http.StatusOK, `"releaseDate":1580554800`},
+		{"query", "?dateFormat=rfc3339", "", http.StatusOK, `"releaseDate":"2020-02-01T11:00:00Z"`},
+		{"media type parameter", "", "application/json; dateFormat=unixms", http.StatusOK, `"releaseDate":1580554800000`},
+		{"query wins", "?dateFormat=date", "application/json; dateFormat=unixms", http.StatusOK, `"releaseDate":"2020-02-01"`},
+		{"unknown", "?dateFormat=julian", "", http.StatusBadRequest, ""},
+	}
+
+	crud := crudStub{}
+	crud.GetFn = func(_ context.Context, _ string) (model.Book, error) {
+		return book, nil
+	}
+	router := webapi.NewResource(&crud)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/"+book.ID+tc.query, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if got := w.Result().StatusCode; got != tc.status {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", got, tc.status)
+			}
+			if got := w.Body.String(); !strings.Contains(got, tc.want) {
+				t.Fatalf("Unexpected response body, got %q, want it to contain %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("input", func(t *testing.T) {
+		crud := crudStub{}
+		crud.AddFn = func(_ context.Context, b model.Book) (model.Book, error) {
+			return b, nil
+		}
+		router := webapi.NewResource(&crud)
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"title":"Dates in Go","releaseDate":1580554800000}`))
+		r.Header.Set("Content-Type", "application/json; dateFormat=unixms")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		want := `"releaseDate":1580554800`
+		if got := w.Body.String(); !strings.Contains(got, want+",") {
+			t.Fatalf("Unexpected response body, got %q, want it to contain %q", got, want)
+		}
+	})
+}
+
+func TestSimilarBooks(t *testing.T) {
+	index := similar.NewIndex()
+	crud := similar.NewCrudService(memory.NewCrudService(), index)
+	router := webapi.NewMux(crud, webapi.WithRecommender(index))
+
+	// Books added through the API are indexed right away.
+	var ids []string
+	for _, payload := range []string{
+		`{"author":"Jane Doe","title":"Go","keywords":[{"keyword":"Go"},{"keyword":"Concurrency"}]}`,
+		`{"author":"John Roe","title":"Go Again","keywords":[{"keyword":"Go"}]}`,
+		`{"author":"Max Moe","title":"More Go","keywords":[{"keyword":"Go"},{"keyword":"Concurrency"}]}`,
+		`{"author":"Erika Poe","title":"Cooking","keywords":[{"keyword":"Cooking"}]}`,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/books", strings.NewReader(payload))
+		r.Header.Set("Content-Type", applicationJSON)
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusCreated)
+		}
+		var b model.Book
+		if err := json.NewDecoder(w.Body).Decode(&b); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+		ids = append(ids, b.ID)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   []string
+	}{
+		{name: "ranked", path: "/api/books/" + ids[0] + "/similar", status: http.StatusOK, want: []string{ids[2], ids[1]}},
+		{name: "limit", path: "/api/books/" + ids[0] + "/similar?limit=1", status: http.StatusOK, want: []string{ids[2]}},
+		{name: "none", path: "/api/books/" + ids[3] + "/similar", status: http.StatusOK, want: []string{}},
+		{name: "unknown_book", path: "/api/books/000000000000000000000000/similar", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var books []model.Book
+			if err := json.NewDecoder(w.Body).Decode(&books); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			got := []string{}
+			for _, b := range books {
+				got = append(got, b.ID)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Unexpected similar books (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Without a recommender, the route does not exist.
+	w := httptest.NewRecorder()
+	webapi.NewMux(crud).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/books/"+ids[0]+"/similar", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func BenchmarkListBooks(b *testing.B) {
+	books := make([]model.Book, 0, 100)
+	for _, book := range testData(100) {
+		books = append(books, book)
+	}
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return books, nil
+	}
+	router := webapi.NewResource(&crud)
+
+	for _, accept := range []string{applicationJSON, "application/msgpack", "text/csv"} {
+		b.Run(accept, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Header.Set("Accept", accept)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					b.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusOK)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAddBook(b *testing.B) {
+	crud := crudStub{}
+	crud.AddFn = func(_ context.Context, book model.Book) (model.Book, error) {
+		book.ID = "000000000000000000000001"
+		return book, nil
+	}
+	router := webapi.NewResource(&crud)
+	body := []byte(`{"author":"Jörg Jooss","title":"Benchmarking Go","releaseDate":1700000000,"keywords":[{"keyword":"Go"}]}`)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", applicationJSON)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			b.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusCreated)
+		}
+	}
+}
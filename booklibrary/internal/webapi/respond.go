@@ -0,0 +1,81 @@
+package webapi
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/log"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+)
+
+type header struct {
+	name string
+	val  string
+}
+
+// respond writes data with the codec and date format negotiated for the request, or as JSON with
+// Unix dates if there are none. A nil data writes no body.
+func respond(w http.ResponseWriter, r *http.Request, data any, status int, headers ...header) {
+	for _, h := range headers {
+		w.Header().Add(h.name, h.val)
+	}
+	if data == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	ctx := r.Context()
+	c := responseCodec(ctx)
+	var buf bytes.Buffer
+	if err := c.encode(&buf, view(data, responseDateFormat(ctx))); err != nil {
+		log.FromContext(ctx).ErrorContext(ctx, "encoding response", log.ErrorKey, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.mediaType())
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// bind decodes the request payload into v according to the request's Content-Type. Payloads
+// without a Content-Type are decoded as JSON. Numeric release dates are read in the date format
+// requested by the query or Content-Type parameter.
+func bind(r *http.Request, v any) error {
+	defer r.Body.Close()
+	c, params, ok := requestCodec(r)
+	if !ok {
+		c = jsonCodec{}
+	}
+	b, ok := v.(*model.Book)
+	if !ok {
+		return c.decode(r.Body, v)
+	}
+
+	f, err := dateFormat(r, params)
+	if err != nil {
+		return err
+	}
+	bv := model.BookView{Format: f}
+	if err := c.decode(r.Body, &bv); err != nil {
+		return err
+	}
+	*b = bv.Book
+	return nil
+}
+
+// view renders books in data with date format f. Other data is returned unchanged.
+func view(data any, f model.DateFormat) any {
+	switch v := data.(type) {
+	case model.Book:
+		return v.View(f)
+	case []model.Book:
+		views := make([]model.BookView, len(v))
+		for i, b := range v {
+			views[i] = b.View(f)
+		}
+		return views
+	default:
+		return data
+	}
+}
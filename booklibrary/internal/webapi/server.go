@@ -4,21 +4,25 @@ import (
 	"net"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/joergjo/go-samples/booklibrary/internal/model"
 )
 
-// NewServer creates a new HTTP server with the given handler and port.
-func NewServer(crud model.CrudService, port int) *http.Server {
-	mux := NewMux(crud)
+// NewServer creates a new HTTP server with the given handler and port. With WithTLS, the server
+// serves HTTP/2 and HTTP/1.1 over TLS. Shutting down the server ends all GraphQL subscriptions, so
+// that their streams do not keep the server from draining.
+func NewServer(crud model.CrudService, port int, opts ...Option) *http.Server {
+	s := newSettings(opts)
+	mux := newMux(crud, s)
 	addr := net.JoinHostPort("", strconv.Itoa(port))
-	s := http.Server{
+	srv := http.Server{
 		Addr:         addr,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  s.readTimeout,
+		WriteTimeout: s.writeTimeout,
+		IdleTimeout:  s.idleTimeout,
 		Handler:      mux,
+		TLSConfig:    s.tlsConfig,
 	}
-	return &s
+	srv.RegisterOnShutdown(s.changes.feed.close)
+	return &srv
 }
@@ -0,0 +1,49 @@
+package webapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/health"
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/ratelimit"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+)
+
+// The server must serve the route multiplexer built from all of its options, not just some.
+
+func TestServerRateLimit(t *testing.T) {
+	crud := crudStub{}
+	crud.ListFn = func(_ context.Context, _ int) ([]model.Book, error) {
+		return []model.Book{}, nil
+	}
+	srv := webapi.NewServer(&crud, 0, webapi.WithRateLimit(ratelimit.NewMemory(1, time.Minute), nil, false))
+
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/books", nil))
+		if w.Code != want {
+			t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, want)
+		}
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	crud := crudStub{}
+	crud.PingFn = func(_ context.Context) error {
+		return nil
+	}
+	h := health.NewRegistry(0)
+	h.Register("breaker", func(context.Context) error { return errors.New("circuit open") })
+	srv := webapi.NewServer(&crud, 0, webapi.WithHealth(h))
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Received unexpected HTTP status code, got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,34 @@
+package webapi
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing starts a server span for each request, continuing any W3C trace context sent by the client.
+// Probes and metric scrapes are not traced.
+func tracing() func(http.Handler) http.Handler {
+	return otelhttp.NewMiddleware("booklibrary-api",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !strings.HasPrefix(r.URL.Path, "/healthz") && r.URL.Path != "/metrics"
+		}))
+}
+
+// routeSpan renames the server span after the matched route template once chi has routed the request,
+// so that spans for /api/books/{id} are grouped regardless of the ID.
+func routeSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		pattern := routePattern(r)
+		if pattern == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + pattern)
+		span.SetAttributes(semconv.HTTPRoute(pattern))
+	})
+}
@@ -0,0 +1,59 @@
+package webapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joergjo/go-samples/booklibrary/internal/model"
+	"github.com/joergjo/go-samples/booklibrary/internal/webapi"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestServerSpan(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	crud := crudStub{GetFn: func(_ context.Context, id string) (model.Book, error) {
+		return model.Book{ID: id, Title: "Tracing in Action"}, nil
+	}}
+	mux := webapi.NewMux(&crud)
+	for _, path := range []string{"/api/books/5f0f6d8a2d3b4c1e9a7b6c5d", "/healthz/live"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Received unexpected HTTP status code for %s, got %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+
+	// Probes are not traced, so the book lookup is the only span.
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Unexpected number of spans, got %d, want 1", len(spans))
+	}
+	span := spans[0]
+	if got, want := span.Name(), "GET /api/books/{id}"; got != want {
+		t.Errorf("Unexpected span name, got %q, want %q", got, want)
+	}
+	if got := span.SpanKind(); got != trace.SpanKindServer {
+		t.Errorf("Unexpected span kind, got %v, want %v", got, trace.SpanKindServer)
+	}
+	route := semconv.HTTPRoute("/api/books/{id}")
+	found := false
+	for _, attr := range span.Attributes() {
+		if attr == route {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Span attributes %v do not contain %v", span.Attributes(), route)
+	}
+}